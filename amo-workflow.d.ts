@@ -12,10 +12,27 @@ declare namespace Amo {
     content?: string;
   }
 
+  interface ReadRangeResult extends FileResult {
+    offset?: number;
+    bytes_read?: number;
+    // true when the range ran past the end of the file
+    eof?: boolean;
+  }
+
+  interface JSONResult extends Result {
+    data?: any;
+  }
+
   interface CommandResult {
     stdout: string;
     stderr: string;
     error?: string;
+    // Process exit code (-1 when killed by a signal); absent when the command could not be started
+    exitCode?: number;
+    // Signal that killed the process, e.g. "killed" or "terminated"
+    signal?: string;
+    // stdout or stderr exceeded maxOutputBytes and was cut off
+    truncated?: boolean;
   }
 
   // File system types
@@ -28,6 +45,31 @@ declare namespace Amo {
     mode: string;
   }
 
+  interface WatchChanges {
+    created: FileInfo[];
+    modified: FileInfo[];
+    deleted: FileInfo[];
+  }
+
+  interface CopyOptions {
+    // Keep the source modification times (default: true)
+    preserveTime?: boolean;
+    // Keep the source permissions (default: true)
+    preserveMode?: boolean;
+  }
+
+  interface WatchOptions {
+    // Polling interval in milliseconds (default: 1000)
+    interval?: number;
+  }
+
+  interface StatManyResult {
+    success: boolean;
+    path: string;
+    info?: FileInfo;
+    error?: string;
+  }
+
   interface DirectoryResult extends Result {
     files?: FileInfo[];
   }
@@ -36,6 +78,26 @@ declare namespace Amo {
     size?: number;
   }
 
+  interface SizeHumanResult extends SizeResult {
+    // e.g. "1.5 MB"
+    human?: string;
+  }
+
+  interface DiskUsageEntry {
+    name: string;
+    path: string;
+    size: number;
+    human: string;
+    is_dir: boolean;
+  }
+
+  interface DiskUsageResult extends Result {
+    total?: number;
+    human?: string;
+    // Immediate children, largest first
+    entries?: DiskUsageEntry[];
+  }
+
   interface PathResult extends Result {
     path?: string;
   }
@@ -44,6 +106,17 @@ declare namespace Amo {
     files?: string[];
   }
 
+  // Archive result types
+  interface ArchiveResult extends Result {
+    path?: string;
+    count?: number; // files added
+  }
+
+  interface ExtractResult extends Result {
+    files?: string[]; // entry names, relative to the target directory
+    count?: number;
+  }
+
   // Hash result types
   interface HashResult extends Result {
     hash?: string;
@@ -66,6 +139,11 @@ declare namespace Amo {
     data?: any;
   }
 
+  interface GraphQLResponse extends HTTPJSONResponse {
+    /** GraphQL errors reported by the server, also present with partial data */
+    errors?: any[];
+  }
+
   // Options types
   interface CommandOptions {
     timeout?: number;
@@ -73,10 +151,61 @@ declare namespace Amo {
     env?: Record<string, string>;
     interactive?: boolean;
     stdin?: string;
+    // Bytes of stdout and of stderr to keep (default 4 MB, 0 for no limit)
+    maxOutputBytes?: number;
+    // Called with each line of output as it arrives (\n, \r\n or \r ends a line);
+    // the full output is still returned. Throwing kills the command.
+    onStdout?: (line: string) => void;
+    onStderr?: (line: string) => void;
+  }
+
+  interface RequestOptions {
+    // Sent as-is when a string, otherwise encoded as JSON
+    body?: string | object;
+    headers?: Record<string, string>;
+    // Return the body base64 encoded, for images and other binary data
+    binary?: boolean;
+    // Least milliseconds between requests to the same host (default: network_min_interval_ms)
+    minInterval?: number;
+  }
+
+  interface GetOptions {
+    // Revalidate a cached copy with If-None-Match and reuse it on 304
+    cache?: boolean;
+    // Return the body base64 encoded, for images and other binary data
+    binary?: boolean;
+    // Least milliseconds between requests to the same host (default: network_min_interval_ms)
+    minInterval?: number;
   }
 
   interface DownloadOptions {
     show_progress?: boolean;
+    // Sent with the download request, e.g. Authorization or User-Agent
+    headers?: Record<string, string>;
+    // Least milliseconds between requests to the same host (default: network_min_interval_ms)
+    minInterval?: number;
+  }
+
+  interface DownloadJob {
+    url: string;
+    output: string;
+  }
+
+  interface DownloadJobResult {
+    index: number;
+    url: string;
+    output: string;
+    status_code: number;
+    error: string;
+  }
+
+  interface DownloadAllOptions {
+    // Parallel downloads (default: 4)
+    concurrency?: number;
+    // Called as each job finishes; throwing stops jobs not yet started
+    onComplete?: (result: DownloadJobResult) => void;
+    // Least milliseconds between requests to the same host (default: network_min_interval_ms)
+    minInterval?: number;
   }
 
   // Progress information for downloads
@@ -101,25 +230,37 @@ declare const fs: {
   isDir(path: string): boolean;
   info(path: string): Amo.Result;
   stat(path: string): Amo.Result; // alias
+  statMany(paths: string[]): Amo.StatManyResult[];
 
   // Directory operations
   readdir(path: string): Amo.DirectoryResult;
   list(path: string): Amo.DirectoryResult; // alias
+  /** Polls path until the workflow ends or the callback returns false */
+  watch(path: string, callback: (changes: Amo.WatchChanges) => boolean | void, options?: Amo.WatchOptions): Amo.Result;
   mkdir(path: string): Amo.Result;
 
   // File operations
   read(path: string): Amo.FileResult;
   readFile(path: string): Amo.FileResult; // alias
-  write(path: string, content: string): Amo.Result;
-  writeFile(path: string, content: string): Amo.Result; // alias
+  /** Reads length bytes from offset (at most 64 MB) as base64, or as text with encoding "utf8" */
+  readRange(path: string, offset: number, length: number, encoding?: "base64" | "utf8" | "text"): Amo.ReadRangeResult;
+  // options.mode sets permissions from an octal string, e.g. { mode: "0755" }
+  write(path: string, content: string, options?: { mode?: string }): Amo.Result;
+  writeFile(path: string, content: string, options?: { mode?: string }): Amo.Result; // alias
   append(path: string, content: string): Amo.Result;
   appendFile(path: string, content: string): Amo.Result; // alias
-  copy(src: string, dst: string): Amo.Result;
+  readJSON(path: string): Amo.JSONResult;
+  // Replaces the file atomically; indent is a number of spaces or a string (default 2, 0 for compact)
+  writeJSON(path: string, value: any, indent?: number | string): Amo.Result;
+  /** Copies keep modification times and permissions unless turned off in options */
+  copy(src: string, dst: string, options?: Amo.CopyOptions): Amo.Result;
   move(src: string, dst: string): Amo.Result;
   rename(src: string, dst: string): Amo.Result; // alias
   remove(path: string): Amo.Result;
   delete(path: string): Amo.Result; // alias
   rm(path: string): Amo.Result; // alias
+  // Set permissions from an octal string such as "0755" (no-op on Windows)
+  chmod(path: string, mode: string): Amo.Result;
 
   // Path operations
   join(elements: string[]): string;
@@ -136,8 +277,13 @@ declare const fs: {
 
   // Utilities
   size(path: string): Amo.SizeResult;
+  sizeHuman(path: string): Amo.SizeHumanResult;
+  /** Size of each immediate child of dir (directories include everything below them) */
+  diskUsage(dir: string): Amo.DiskUsageResult;
   find(root: string, pattern: string): Amo.FindResult;
   search(root: string, pattern: string): Amo.FindResult; // alias
+  /** Match paths like "media/**\/*.mp4"; ** spans any number of directories */
+  glob(pattern: string): Amo.FindResult;
   
   // New path functions
   getCurrentWorkingPath(): Amo.PathResult;
@@ -149,16 +295,32 @@ declare const fs: {
   sha256(path: string): Amo.HashResult;
   
   // Archive operations
-  extractZip(zipPath: string, targetDir: string): Amo.Result;
+  zip(sourceDir: string, outputPath: string): Amo.ArchiveResult;
+  unzip(zipPath: string, targetDir: string): Amo.ExtractResult;
+  extractZip(zipPath: string, targetDir: string): Amo.ExtractResult; // alias
+  targz(sourceDir: string, outputPath: string): Amo.ArchiveResult;
+  untargz(archivePath: string, targetDir: string): Amo.ExtractResult;
 };
 
 // HTTP/Network API
 declare const http: {
-  get(url: string, headers?: Record<string, string>): Amo.HTTPResponse;
+  get(url: string, headers?: Record<string, string>, options?: Amo.GetOptions): Amo.HTTPResponse;
   post(url: string, body: string, headers?: Record<string, string>): Amo.HTTPResponse;
   getJSON(url: string, headers?: Record<string, string>): Amo.HTTPJSONResponse;
+  /** POSTs {query, variables}; api.github.com requests get GITHUB_TOKEN / github_token */
+  graphql(url: string, query: string, variables?: Record<string, any>, headers?: Record<string, string>): Amo.GraphQLResponse;
+  /** POSTs filePath as multipart/form-data under fieldName; data holds a JSON response parsed */
+  uploadFile(url: string, fieldName: string, filePath: string, extraFields?: Record<string, string>, headers?: Record<string, string>): Amo.HTTPJSONResponse;
+  /** GET, POST, PUT, PATCH, DELETE or HEAD */
+  request(method: string, url: string, options?: Amo.RequestOptions): Amo.HTTPResponse;
   downloadFile(url: string, outputPath: string, options?: Amo.DownloadOptions): Amo.HTTPResponse;
   downloadFileResume(url: string, outputPath: string, options?: Amo.DownloadOptions): Amo.HTTPResponse;
+  /** Continues from outputPath.part; an error thrown by the callback is returned in error */
+  downloadResume(url: string, outputPath: string, progressCallback?: (progress: Amo.DownloadProgress) => void): Amo.HTTPResponse;
+  /** Removes outputPath.part and outputPath.part.meta; data.removed tells whether any existed */
+  downloadClearPartial(outputPath: string): Amo.Result;
+  /** Downloads jobs in parallel; data.results holds one DownloadJobResult per job */
+  downloadAll(jobs: Amo.DownloadJob[], options?: Amo.DownloadAllOptions): Amo.Result;
 };
 
 // Encoding API
@@ -175,6 +337,17 @@ declare const encoding: {
   // md5(input: string): string;
 };
 
+// Time API
+declare const time: {
+  // Pause for ms milliseconds; returns early when the workflow is cancelled or times out
+  sleep(ms: number): void;
+  // Milliseconds since the Unix epoch, like Date.now()
+  now(): number;
+  // Format epoch milliseconds in local time with a Go layout ("2006-01-02 15:04")
+  // or rfc3339 (default), rfc1123, datetime, date, time or kitchen
+  format(epoch: number, layout?: string): string;
+};
+
 // Console API
 declare const console: {
   log(...args: any[]): void;
@@ -184,6 +357,10 @@ declare const console: {
 
 // Core API functions
 declare function getVar(key: string): string;
+/** Structured variable from `--var-json` (falls back to the `--var` string); undefined if unset */
+declare function getVarObject(key: string): any;
+/** Positional arguments given after `--` on the `amo run` command line */
+declare function getArgs(): string[];
 declare function getOS(): string;
 declare function getRegion(): string;
 declare function getArch(): string;
@@ -192,6 +369,16 @@ declare function cliCommand(
   args?: string[], 
   options?: Amo.CommandOptions
 ): Amo.CommandResult; 
+/** Load a module from the workflow's directory (path relative to the calling file, `.js` optional) and return its `module.exports` */
+declare function require(path: string): any;
+
+// Notification API
+declare const notify: {
+  // Show a desktop notification (osascript / notify-send / PowerShell toast)
+  desktop(title: string, message: string): Amo.Result;
+  // POST a JSON payload (object or pre-encoded string) to a whitelisted URL
+  webhook(url: string, payload: any): Amo.Result & { status_code?: number };
+};
 
 // Clipboard API
 declare const clipboard: {
@@ -200,3 +387,11 @@ declare const clipboard: {
   // Write plain text to system clipboard
   write(text: string): Amo.Result;
 };
+
+// Environment API (read-only, limited to workflow_env_allowlist)
+declare const env: {
+  // Value of an allowlisted environment variable, or null when unset or not allowed
+  get(name: string): string | null;
+  // All allowlisted environment variables whose names start with prefix
+  getAll(prefix?: string): Record<string, string>;
+};
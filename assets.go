@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"amo/pkg/env"
 )
@@ -19,6 +20,17 @@ var EmbeddedAssets embed.FS
 type AssetManager struct {
 	fs            embed.FS
 	crossPlatform *env.CrossPlatformUtils
+
+	// The embedded files are walked once, on first use. Embedded assets
+	// cannot change, so the index never needs invalidating.
+	indexOnce sync.Once
+	files     []string
+	fileSet   map[string]bool
+	dirSet    map[string]bool
+	indexErr  error
+
+	workflowNamesOnce sync.Once
+	workflowNames     []string
 }
 
 // NewAssetManager creates a new asset manager
@@ -77,14 +89,14 @@ func (am *AssetManager) Exists(path string) bool {
 		// Try workflow files
 		if strings.HasSuffix(path, ".js") {
 			// Try workflow directory
-			if _, err := am.fs.ReadFile("assets/workflow/" + path); err == nil {
+			if am.hasFile("assets/workflow/" + path) {
 				return true
 			}
 		}
 
 		// Try tools.json directly
 		if path == "tools.json" {
-			if _, err := am.fs.ReadFile("assets/tools.json"); err == nil {
+			if am.hasFile("assets/tools.json") {
 				return true
 			}
 		}
@@ -93,8 +105,37 @@ func (am *AssetManager) Exists(path string) bool {
 		path = "assets/" + path
 	}
 
-	_, err := am.fs.ReadFile(path)
-	return err == nil
+	return am.hasFile(path)
+}
+
+// loadIndex walks the embedded assets once and records every file and directory
+func (am *AssetManager) loadIndex() {
+	am.indexOnce.Do(func() {
+		am.fileSet = make(map[string]bool)
+		am.dirSet = make(map[string]bool)
+		am.indexErr = fs.WalkDir(am.fs, "assets", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				am.dirSet[path] = true
+			} else {
+				am.files = append(am.files, path)
+				am.fileSet[path] = true
+			}
+			return nil
+		})
+	})
+}
+
+// hasFile reports whether path, including its assets/ prefix, is an embedded file
+func (am *AssetManager) hasFile(path string) bool {
+	am.loadIndex()
+	if am.indexErr != nil {
+		_, err := am.fs.ReadFile(path)
+		return err == nil
+	}
+	return am.fileSet[path]
 }
 
 // ListFiles lists all files in a directory within the embedded assets
@@ -104,51 +145,49 @@ func (am *AssetManager) ListFiles(dir string) ([]string, error) {
 	if !strings.HasPrefix(dir, "assets/") {
 		dir = "assets/" + dir
 	}
+	dir = strings.TrimSuffix(dir, "/")
 
-	var files []string
-	err := fs.WalkDir(am.fs, dir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	am.loadIndex()
+	if am.indexErr != nil {
+		return nil, fmt.Errorf("failed to list files in directory %s: %w", dir, am.indexErr)
+	}
+	if !am.dirSet[dir] && !am.fileSet[dir] {
+		return nil, fmt.Errorf("failed to list files in directory %s: %w", dir, fs.ErrNotExist)
+	}
 
-		if !d.IsDir() {
+	var files []string
+	for _, path := range am.files {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
 			// Remove the assets/ prefix for cleaner paths
-			cleanPath := strings.TrimPrefix(path, "assets/")
-			files = append(files, cleanPath)
+			files = append(files, strings.TrimPrefix(path, "assets/"))
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to list files in directory %s: %w", dir, err)
 	}
 
 	return files, nil
 }
 
-// GetWorkflowFileNames returns a list of available workflow file names
+// GetWorkflowFileNames returns a list of available workflow file names. The
+// list is computed once; callers get their own copy.
 func (am *AssetManager) GetWorkflowFileNames() ([]string, error) {
-	var allFiles []string
-
-	// List from workflow directory
-	workflowFiles, err := am.ListFiles("workflow")
-	if err == nil {
-		allFiles = append(allFiles, workflowFiles...)
-	}
+	am.workflowNamesOnce.Do(func() {
+		// List from workflow directory
+		workflowFiles, err := am.ListFiles("workflow")
+		if err != nil {
+			return
+		}
 
-	// Note: No longer checking workflows directory since tool management moved to Go
+		// Note: No longer checking workflows directory since tool management moved to Go
 
-	var names []string
-	for _, file := range allFiles {
-		// Extract just the filename from the full path
-		name := filepath.Base(file)
-		if strings.HasSuffix(name, ".js") {
-			names = append(names, name)
+		for _, file := range workflowFiles {
+			// Extract just the filename from the full path
+			name := filepath.Base(file)
+			if strings.HasSuffix(name, ".js") {
+				am.workflowNames = append(am.workflowNames, name)
+			}
 		}
-	}
+	})
 
-	return names, nil
+	return append([]string(nil), am.workflowNames...), nil
 }
 
 // WriteToFile writes an embedded asset to a physical file
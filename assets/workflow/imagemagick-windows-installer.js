@@ -30,8 +30,8 @@ function getWindowsArch() {
     const candidates = [
         getVar('ARCH'),
         getVar('arch'),
-        getVar('PROCESSOR_ARCHITECTURE'),
-        getVar('PROCESSOR_ARCHITEW6432'),
+        env.get('PROCESSOR_ARCHITECTURE'),
+        env.get('PROCESSOR_ARCHITEW6432'),
     ];
     let arch = '';
     for (let i = 0; i < candidates.length; i++) {
@@ -625,7 +625,7 @@ async function main() {
         console.log(`✅ Running on Windows system`);
         
         const providedInstallDir = getVar('installDir');
-        const homeDir = getVar('HOME') || getVar('USERPROFILE') || providedInstallDir || '/tmp';
+        const homeDir = env.get('HOME') || env.get('USERPROFILE') || providedInstallDir || '/tmp';
         const installDir = getVar('INSTALL_DIR') || providedInstallDir || fs.join([homeDir, '.amo', 'tools']);
         const toolsDir = getVar('TOOLS_DIR') || installDir;
         const downloadsDir = getVar('DOWNLOADS_DIR') || fs.join([homeDir, '.amo', 'downloads']);
@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"amo/pkg/config"
+	"amo/pkg/env"
 
 	"github.com/spf13/cobra"
 )
@@ -18,16 +20,22 @@ func NewConfigCmd() *cobra.Command {
 Configuration is stored in ~/.amo/config.yaml.
 
 Usage:
-  amo config <key>           Get a config value
-  amo config <key> <value>   Set a config value
-  amo config ls              List all config values
-  amo config rm <key>        Remove a config key (restore default)
+  amo config get <key>          Get a config value (same as amo config <key>)
+  amo config set <key> <value>  Set a config value (same as amo config <key> <value>)
+  amo config unset <key>        Restore a key's default (alias: rm)
+  amo config ls                 List all config values
+  amo config use [<profile>]    Switch profile, or list profiles
+  amo config migrate            Drop unsupported keys and add missing ones
+
+Values are checked against the key's type: numeric keys such as the network
+timeouts take integers and boolean keys take true/false.
 
 Examples:
   amo config workflows                  # Get workflows directory
   amo config workflows ~/my-workflows   # Set workflows directory
   amo config ls                         # List all settings
-  amo config rm workflows               # Reset to default
+  amo config set network_dial_timeout_seconds 30
+  amo config unset workflows            # Reset to default
 
 Supported configuration keys:
   workflows                     Directory path for custom workflows
@@ -36,12 +44,44 @@ Supported configuration keys:
 		RunE: runConfigCommand,
 	}
 
+	configCmd.AddCommand(newConfigGetCmd())
+	configCmd.AddCommand(newConfigSetCmd())
 	configCmd.AddCommand(newConfigLsCmd())
 	configCmd.AddCommand(newConfigRmCmd())
+	configCmd.AddCommand(newConfigUseCmd())
+	configCmd.AddCommand(newConfigMigrateCmd())
 
 	return configCmd
 }
 
+func newConfigGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <key>",
+		Short: "Show a configuration value",
+		Long: `Show a configuration value.
+
+Example:
+  amo config get network_dial_timeout_seconds`,
+		Args: cobra.ExactArgs(1),
+		RunE: runConfigCommand,
+	}
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a configuration value",
+		Long: `Set a configuration value. Integer and boolean keys reject values of the
+wrong type instead of storing them as strings.
+
+Examples:
+  amo config set network_idle_timeout_seconds 120
+  amo config set security_cli_whitelist_enabled true`,
+		Args: cobra.ExactArgs(2),
+		RunE: runConfigCommand,
+	}
+}
+
 func newConfigLsCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "ls",
@@ -57,17 +97,131 @@ Example:
 
 func newConfigRmCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "rm <key>",
-		Short: "Remove a configuration value (restore default)",
+		Use:     "rm <key>",
+		Aliases: []string{"unset"},
+		Short:   "Remove a configuration value (restore default)",
 		Long: `Remove a configuration value, restoring it to the default value.
 
 Example:
-  amo config rm workflows`,
+  amo config unset workflows`,
 		Args: cobra.ExactArgs(1),
 		RunE: runConfigRmCmd,
 	}
 }
 
+func newConfigUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use [<profile>]",
+		Short: "Switch to a named configuration profile",
+		Long: `Switch the active configuration profile, or list profiles when no name is given.
+
+Each profile is stored as config.<profile>.yaml next to config.yaml; the
+"default" profile is config.yaml itself. The selection is remembered for
+later commands until another profile is chosen.
+
+Examples:
+  amo config use                # List profiles and show the active one
+  amo config use client-a       # Switch to (and create) config.client-a.yaml
+  amo config use default        # Back to config.yaml`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runConfigUseCmd,
+	}
+}
+
+func newConfigMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrite the config file with the current set of keys",
+		Long: `Rewrite the config file of the active profile so it holds exactly the keys
+this version of amo supports: keys that are no longer supported are dropped,
+new keys are added with their defaults, and values you set are kept.
+
+Example:
+  amo config migrate`,
+		Args:        cobra.NoArgs,
+		RunE:        runConfigMigrateCmd,
+		Annotations: map[string]string{annotationSkipConfigLoad: "true"},
+	}
+}
+
+func runConfigMigrateCmd(cmd *cobra.Command, args []string) error {
+	manager, err := config.NewManager()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to initialize config manager: %w", err))
+	}
+
+	report, err := manager.Migrate()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to migrate configuration: %w", err))
+	}
+
+	if !report.Changed() {
+		fmt.Printf("✅ %s is up to date\n", manager.GetConfigFile())
+		return nil
+	}
+	for _, key := range report.Removed {
+		fmt.Printf("➖ Removed unsupported key: %s\n", key)
+	}
+	for _, key := range report.Added {
+		value := config.DefaultConfig[key]
+		if value == "" {
+			value = "<not set>"
+		}
+		fmt.Printf("➕ Added %s = %v (default)\n", key, value)
+	}
+	fmt.Printf("✅ Migrated %s: %d removed, %d added\n", manager.GetConfigFile(), len(report.Removed), len(report.Added))
+	return nil
+}
+
+func runConfigUseCmd(cmd *cobra.Command, args []string) error {
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+	}
+	configDir := environment.GetUserConfigDir()
+
+	if len(args) == 0 {
+		active, err := config.ActiveProfile(configDir)
+		if err != nil {
+			return newInfraError(err)
+		}
+		profiles, err := config.ListProfiles(configDir)
+		if err != nil {
+			return newInfraError(err)
+		}
+		fmt.Println("📋 Configuration profiles:")
+		for _, profile := range profiles {
+			marker := "  "
+			if profile == active {
+				marker = "* "
+			}
+			fmt.Printf("%s%s (%s)\n", marker, profile, config.ProfileConfigFileName(profile))
+		}
+		return nil
+	}
+
+	profile := args[0]
+	if err := config.ValidateProfileName(profile); err != nil {
+		return newUserError("%v", err)
+	}
+	if err := config.SetActiveProfile(configDir, profile); err != nil {
+		return newInfraError(err)
+	}
+
+	manager, err := config.NewManager()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to initialize config manager: %w", err))
+	}
+	if manager.GetProfile() == "" {
+		fmt.Printf("⚠️  --config-file or %s is set; it overrides the active profile for this command\n", config.EnvConfigFile)
+	} else if err := manager.Initialize(); err != nil {
+		return newInfraError(fmt.Errorf("failed to create profile config: %w", err))
+	}
+
+	fmt.Printf("✅ Active profile: %s (%s)\n", profile, filepath.Join(configDir, config.ProfileConfigFileName(profile)))
+	return nil
+}
+
 func runConfigCommand(cmd *cobra.Command, args []string) error {
 	manager, err := config.NewManager()
 	if err != nil {
@@ -94,12 +248,21 @@ func runConfigCommand(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	value := args[1]
+	value, err := config.CoerceValue(key, args[1])
+	if err == nil {
+		err = config.ValidateValue(key, value)
+	}
+	if err != nil {
+		return newUserError("%v", err)
+	}
 	if err := manager.Set(key, value); err != nil {
 		return newInfraError(fmt.Errorf("failed to set configuration: %w", err))
 	}
 
-	fmt.Printf("✅ Configuration set: %s = %s\n", key, value)
+	if config.IsSecretKey(key) {
+		value = "<hidden>"
+	}
+	fmt.Printf("✅ Configuration set: %s = %v\n", key, value)
 	return nil
 }
 
@@ -109,7 +272,11 @@ func runConfigLsCmd(cmd *cobra.Command, args []string) error {
 		return newInfraError(fmt.Errorf("failed to initialize config manager: %w", err))
 	}
 
-	fmt.Printf("📋 Configuration values (stored in %s):\n\n", manager.GetConfigFile())
+	if profile := manager.GetProfile(); profile != "" {
+		fmt.Printf("📋 Configuration values for profile %s (stored in %s):\n\n", profile, manager.GetConfigFile())
+	} else {
+		fmt.Printf("📋 Configuration values (stored in %s):\n\n", manager.GetConfigFile())
+	}
 
 	settings := manager.GetAll()
 
@@ -124,6 +291,9 @@ func runConfigLsCmd(cmd *cobra.Command, args []string) error {
 		value, exists := settings[key]
 
 		if exists && value != nil && value != "" {
+			if config.IsSecretKey(key) {
+				value = "<hidden>"
+			}
 			fmt.Printf("%s = %v\n", key, value)
 		} else {
 			fmt.Printf("%s = <not set>\n", key)
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"amo/pkg/config"
+	"amo/pkg/env"
+
+	"github.com/spf13/cobra"
+)
+
+// NewEnvCmd creates the env subcommand for inspecting the runtime environment
+func NewEnvCmd() *cobra.Command {
+	envCmd := &cobra.Command{
+		Use:   "env",
+		Short: "Inspect the environment amo runs in",
+		Long:  "Inspect the environment amo runs in, e.g. for bug reports.",
+	}
+
+	envCmd.AddCommand(NewEnvInfoCmd())
+	envCmd.AddCommand(NewEnvCleanCmd())
+
+	return envCmd
+}
+
+// NewEnvCleanCmd creates the env clean subcommand
+func NewEnvCleanCmd() *cobra.Command {
+	var olderThan time.Duration
+
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove temp directories left behind by crashed workflows",
+		Long: `Remove the directories under ~/.amo/temp in which nothing has changed for the
+given age. Workflows normally clean up their temp directories; these are the ones
+left behind when a workflow crashed or was killed. amo run also removes them,
+with the default age, before running a workflow.
+
+Examples:
+  amo env clean
+  amo env clean --older-than 1h`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan < 0 {
+				return newUserError("--older-than must not be negative, got %s", olderThan)
+			}
+			environment, err := env.NewEnvironment()
+			if err != nil {
+				return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+			}
+			removed, err := environment.CleanupStaleTemp(olderThan)
+			for _, path := range removed {
+				fmt.Printf("🗑️  Removed %s\n", path)
+			}
+			if err != nil {
+				return newInfraError(err)
+			}
+			fmt.Printf("✅ Removed %d stale temp directories\n", len(removed))
+			return nil
+		},
+	}
+
+	cleanCmd.Flags().DurationVar(&olderThan, "older-than", env.StaleTempAge, "Only remove directories unchanged for at least this long")
+
+	return cleanCmd
+}
+
+// NewEnvInfoCmd creates the env info subcommand
+func NewEnvInfoCmd() *cobra.Command {
+	var jsonOutput bool
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show system, region and tool directory diagnostics",
+		Long: `Show the diagnostics that help most when reporting a bug: version, operating
+system and directories, the detected region with the score of every region, and
+the tools install directory and whether it is on PATH.
+
+Examples:
+  amo env info
+  amo env info --json > amo-env.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showEnvInfo(jsonOutput)
+		},
+	}
+
+	infoCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the diagnostics as JSON")
+
+	return infoCmd
+}
+
+// envInfo is the report printed by env info
+type envInfo struct {
+	Version    string                 `json:"version"`
+	GitCommit  string                 `json:"git_commit"`
+	System     map[string]interface{} `json:"system"`
+	ConfigFile string                 `json:"config_file"`
+	Region     envRegionInfo          `json:"region"`
+	Tools      envToolsInfo           `json:"tools"`
+}
+
+type envRegionInfo struct {
+	Detected string                 `json:"detected"`
+	Name     string                 `json:"name"`
+	Score    float64                `json:"score"`
+	Debug    map[string]interface{} `json:"debug"`
+}
+
+type envToolsInfo struct {
+	InstallDir string `json:"install_dir"`
+	Exists     bool   `json:"exists"`
+	InPath     bool   `json:"in_path"`
+}
+
+func showEnvInfo(jsonOutput bool) error {
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+	}
+
+	system, err := environment.GetSystemInfo()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to collect system information: %w", err))
+	}
+	// GetSystemInfo creates a fresh temp directory; only its location is of interest
+	if tempPath, ok := system["temp_path"].(string); ok {
+		_ = environment.CleanupTempPath(tempPath)
+	}
+
+	info := envInfo{Version: version, GitCommit: gitCommit, System: system}
+
+	configuredRegion := ""
+	if manager, err := config.NewManager(); err == nil {
+		info.ConfigFile = manager.GetConfigFile()
+		configuredRegion = manager.GetString(config.KeyRegion)
+	}
+
+	detector := env.NewRegionDetectorWithOverride(configuredRegion)
+	_, info.Region.Score = detector.DetectRegionWithScore()
+	// DetectRegion also honours AMO_REGION and the region config value
+	info.Region.Detected = environment.DetectRegion()
+	if regionConfig, ok := detector.GetRegionInfo(info.Region.Detected); ok {
+		info.Region.Name = regionConfig.Name
+	}
+	info.Region.Debug = detector.DebugInfo()
+
+	if manager, err := createToolManager(); err == nil {
+		info.Tools.InstallDir = manager.GetInstallDir()
+		if stat, err := os.Stat(info.Tools.InstallDir); err == nil && stat.IsDir() {
+			info.Tools.Exists = true
+		}
+		info.Tools.InPath = isDirInPath(environment, info.Tools.InstallDir)
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return newRuntimeError(fmt.Errorf("failed to encode environment info: %w", err))
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Println("🩺 Amo Environment")
+	fmt.Println("==================")
+	fmt.Printf("Version:      %s (%s)\n", info.Version, info.GitCommit)
+	fmt.Printf("Config file:  %s\n", info.ConfigFile)
+	fmt.Println()
+
+	fmt.Println("💻 System:")
+	keys := make([]string, 0, len(info.System))
+	for key := range info.System {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %-22s %v\n", key+":", info.System[key])
+	}
+	fmt.Println()
+
+	fmt.Println("🌍 Region:")
+	if info.Region.Name != "" {
+		fmt.Printf("  Detected:  %s (%s, score %.2f)\n", info.Region.Detected, info.Region.Name, info.Region.Score)
+	} else {
+		fmt.Printf("  Detected:  %s (score %.2f)\n", info.Region.Detected, info.Region.Score)
+	}
+	if override, _ := info.Region.Debug["region_override"].(string); override != "" {
+		fmt.Printf("  Override:  AMO_REGION=%s\n", override)
+	} else if configured, _ := info.Region.Debug["region_config"].(string); configured != "" {
+		fmt.Printf("  Override:  %s=%s (config)\n", config.KeyRegion, configured)
+	}
+	fmt.Printf("  Language:  %v\n", info.Region.Debug["system_language"])
+	fmt.Printf("  Timezone:  %v (UTC offset %vs)\n", info.Region.Debug["timezone"], info.Region.Debug["utc_offset"])
+	if monetary, _ := info.Region.Debug["monetary_locale"].(string); monetary != "" {
+		fmt.Printf("  Currency:  LC_MONETARY=%s\n", monetary)
+	}
+	if locale, _ := info.Region.Debug["system_locale"].(string); locale != "" {
+		fmt.Printf("  Locale:    %s (system setting)\n", locale)
+	}
+	if scores, ok := info.Region.Debug["scores"].([]map[string]interface{}); ok {
+		fmt.Println("  Scores:")
+		for _, score := range scores {
+			fmt.Printf("    %-8v %-24v %v\n", score["code"], score["name"], score["score"])
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("🔧 Tools:")
+	fmt.Printf("  Install dir:  %s\n", info.Tools.InstallDir)
+	switch {
+	case !info.Tools.Exists:
+		fmt.Println("  Status:       ⚪ does not exist yet")
+	case info.Tools.InPath:
+		fmt.Println("  Status:       ✅ in PATH")
+	default:
+		fmt.Println("  Status:       ❌ not in PATH (run 'amo tool path setup')")
+	}
+
+	return nil
+}
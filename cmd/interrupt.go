@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"amo/pkg/filesystem"
+)
+
+// interruptGracePeriod is how long the running command gets to stop after
+// Ctrl-C before the process exits; a second Ctrl-C exits immediately
+const interruptGracePeriod = 2 * time.Second
+
+// watchInterrupts returns a context that is cancelled on SIGINT/SIGTERM. On the
+// first signal it cancels the context, removes temp files tracked by in-flight
+// downloads and installs (resumable .part files are kept), and exits with
+// ExitCodeInterrupted once the grace period is over.
+func watchInterrupts(parent context.Context) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "\n⚠️  Interrupted, cleaning up...")
+		cancel()
+
+		for _, path := range filesystem.RemoveTrackedTempPaths() {
+			fmt.Fprintf(os.Stderr, "🧹 Removed %s\n", path)
+		}
+
+		select {
+		case <-signals:
+		case <-time.After(interruptGracePeriod):
+		}
+		os.Exit(ExitCodeInterrupted)
+	}()
+
+	return ctx
+}
+
+// interrupted reports whether ctx was cancelled by watchInterrupts
+func interrupted(ctx context.Context) bool {
+	return ctx != nil && ctx.Err() != nil
+}
+
+func newInterruptedError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: ExitCodeInterrupted, err: err}
+}
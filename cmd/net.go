@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"amo/pkg/config"
+	"amo/pkg/network"
+
+	"github.com/spf13/cobra"
+)
+
+// NewNetCmd creates the net subcommand for inspecting network access rules
+func NewNetCmd() *cobra.Command {
+	netCmd := &cobra.Command{
+		Use:   "net",
+		Short: "Inspect network access settings",
+		Long:  "Inspect the network whitelist used by workflows and downloads.",
+	}
+
+	netCmd.AddCommand(NewNetTestCmd())
+
+	return netCmd
+}
+
+// NewNetTestCmd creates the net test subcommand
+func NewNetTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "test <url>",
+		Short: "Check whether a URL is allowed by the network whitelist",
+		Long: `Check a URL against the network whitelist without making a request.
+
+The whitelist is read from ~/.amo/allowed_hosts.txt (allowed_hosts.<profile>.txt
+for a profile other than default) together with the workflow sources in
+~/.amo/allowed_workflow_hosts.txt and the host lists in
+~/.amo/allowed_hosts.d/*.txt. The command reports the entry that matched, or
+why no entry did, and exits with an error when the URL is blocked.
+
+Examples:
+  amo net test https://github.com/user/repo/releases/download/v1/tool.zip
+  amo net test https://example.com/file.txt`,
+		Args: cobra.ExactArgs(1),
+		RunE: runNetTestCmd,
+	}
+}
+
+func runNetTestCmd(cmd *cobra.Command, args []string) error {
+	client, err := network.NewNetworkClient()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to initialize network client: %w", err))
+	}
+
+	urlStr := args[0]
+	check := client.CheckURL(urlStr)
+	if check.Allowed {
+		fmt.Printf("✅ Allowed: %s\n", urlStr)
+		fmt.Printf("   %s\n", check.Reason)
+		return nil
+	}
+
+	fmt.Printf("❌ Blocked: %s\n", urlStr)
+	fmt.Printf("   %s\n", check.Reason)
+	hostsFile := "~/.amo/allowed_hosts.txt"
+	if manager, err := config.NewManager(); err == nil {
+		hostsFile = manager.GetAllowedHostsFile()
+	}
+	fmt.Printf("   %d whitelist entries loaded; add the host to %s or run `amo workflow source add <domain>[/<path>]`\n", len(client.AllowedHosts()), hostsFile)
+	return newUserError("URL is not allowed by the network whitelist: %s", urlStr)
+}
@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
+	"amo/pkg/config"
+	"amo/pkg/env"
+	"amo/pkg/network"
+	"amo/pkg/tool"
 	"amo/pkg/workflow"
 
 	"github.com/spf13/cobra"
@@ -39,12 +44,67 @@ func (e *exitError) ExitCode() int {
 	return e.code
 }
 
+// Process exit codes; scripts and CI jobs can branch on them
 const (
-	ExitCodeInfraError   = 1
-	ExitCodeRuntimeError = 2
-	ExitCodeUserError    = 3
+	// ExitCodeInfraError is for failures of amo itself or its environment
+	ExitCodeInfraError = 1
+	// ExitCodeUserError is for invalid arguments, flags and input, like the
+	// usage errors of shell builtins
+	ExitCodeUserError = 2
+	// ExitCodeRuntimeError is for workflows that failed while running
+	ExitCodeRuntimeError = 3
+	// ExitCodeNetworkDenied is for network access refused by the allowed
+	// hosts whitelist or by offline mode
+	ExitCodeNetworkDenied = 4
+	// ExitCodeTimeout is for workflows stopped by --timeout, like timeout(1)
+	ExitCodeTimeout = 124
+	// ExitCodeInterrupted follows the shell convention of 128 + SIGINT
+	ExitCodeInterrupted = 130
 )
 
+// userErrorCauses are errors that are the user's to fix, whatever command
+// wrapped them: an unknown tool name or a script that is not an amo workflow
+// or is too large
+var userErrorCauses = []error{
+	tool.ErrToolNotFound,
+	workflow.ErrInvalidWorkflow,
+	workflow.ErrScriptTooLarge,
+}
+
+// networkDeniedCauses are errors for network access amo refused to make
+var networkDeniedCauses = []error{
+	network.ErrURLNotAllowed,
+	network.ErrOffline,
+}
+
+// ExitCode returns the process exit code for an error returned by the root
+// command. Known causes found with errors.Is take precedence over the generic
+// category a command assigned, except for interruption and timeouts.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr ExitCodeError
+	hasCode := errors.As(err, &exitErr)
+	if hasCode && (exitErr.ExitCode() == ExitCodeInterrupted || exitErr.ExitCode() == ExitCodeTimeout) {
+		return exitErr.ExitCode()
+	}
+	for _, cause := range networkDeniedCauses {
+		if errors.Is(err, cause) {
+			return ExitCodeNetworkDenied
+		}
+	}
+	for _, cause := range userErrorCauses {
+		if errors.Is(err, cause) {
+			return ExitCodeUserError
+		}
+	}
+	if hasCode {
+		return exitErr.ExitCode()
+	}
+	return ExitCodeInfraError
+}
+
 func newInfraError(err error) error {
 	if err == nil {
 		return nil
@@ -72,11 +132,24 @@ var (
 	GitCommit = "unknown"
 	BuildTime = "unknown"
 	Debug     bool
+
+	// configFile is the --config-file override for the config location
+	configFile string
+
+	// offlineMode is the --offline flag
+	offlineMode bool
+
+	// quiet is the --quiet flag
+	quiet bool
 )
 
 // Global asset manager
 var AssetManager workflow.AssetReader
 
+// annotationSkipConfigLoad marks commands that must see the config file as
+// the user left it; loading the config writes the defaults into it
+const annotationSkipConfigLoad = "amo/skip-config-load"
+
 // No global flags for root command anymore - workflow execution moved to run subcommand
 
 func NewRootCmd() *cobra.Command {
@@ -90,14 +163,54 @@ It supports variable management and system command execution through a JavaScrip
 Use 'amo run <workflow-file>' to execute workflows.
 Use 'amo tool' to manage tools.`,
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", Version, GitCommit, BuildTime),
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			config.SetConfigFile(configFile)
+			if cmd.Annotations[annotationSkipConfigLoad] == "" {
+				if cfg, err := config.NewManager(); err == nil {
+					env.SetConfiguredRegion(cfg.GetString(config.KeyRegion))
+				}
+			}
+			network.SetOffline(offlineMode)
+			network.SetQuietProgress(quiet)
+			cmd.SetContext(watchInterrupts(cmd.Context()))
+		},
 	}
 
+	// Bad flags are usage errors, not failures of amo
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &exitError{code: ExitCodeUserError, err: err}
+	})
+
+	rootCmd.PersistentFlags().BoolVar(&offlineMode, "offline", false, "Fail immediately instead of accessing the network (or set "+network.EnvOffline+"=1)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress download progress output")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config-file", "", "Config file to use instead of ~/.amo/config.yaml (or set "+config.EnvConfigFile+")")
+
 	// Add subcommands
 	rootCmd.AddCommand(NewRunCmd())
 	rootCmd.AddCommand(NewWorkflowCmd())
 	rootCmd.AddCommand(NewVersionCmd())
 	rootCmd.AddCommand(NewToolCmd())
 	rootCmd.AddCommand(NewConfigCmd())
+	rootCmd.AddCommand(NewNetCmd())
+	rootCmd.AddCommand(NewEnvCmd())
+
+	wrapArgErrors(rootCmd)
 
 	return rootCmd
 }
+
+// wrapArgErrors makes the positional argument checks of cmd and all its
+// subcommands report usage errors with ExitCodeUserError
+func wrapArgErrors(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return &exitError{code: ExitCodeUserError, err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		wrapArgErrors(sub)
+	}
+}
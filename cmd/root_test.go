@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"amo/pkg/network"
+	"amo/pkg/tool"
+	"amo/pkg/workflow"
+)
+
+func TestExitCodeValues(t *testing.T) {
+	// Scripts and CI jobs depend on these numbers; changing one is a breaking change
+	codes := map[string][2]int{
+		"infra":          {ExitCodeInfraError, 1},
+		"user":           {ExitCodeUserError, 2},
+		"runtime":        {ExitCodeRuntimeError, 3},
+		"network denied": {ExitCodeNetworkDenied, 4},
+		"timeout":        {ExitCodeTimeout, 124},
+		"interrupted":    {ExitCodeInterrupted, 130},
+	}
+	for name, code := range codes {
+		if code[0] != code[1] {
+			t.Errorf("%s exit code = %d; expected %d", name, code[0], code[1])
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"success", nil, 0},
+		{"plain error", errors.New("boom"), ExitCodeInfraError},
+		{"infra", newInfraError(errors.New("config unreadable")), ExitCodeInfraError},
+		{"runtime", newRuntimeError(errors.New("script threw")), ExitCodeRuntimeError},
+		{"user", newUserError("unknown flag %s", "--nope"), ExitCodeUserError},
+		{"requirements", &exitError{code: ExitCodeUserError, err: &requirementsError{scriptPath: "a.js"}}, ExitCodeUserError},
+		{"unknown tool wrapped as infra", newInfraError(fmt.Errorf("install: %w", tool.ErrToolNotFound)), ExitCodeUserError},
+		{"invalid workflow wrapped as runtime", newRuntimeError(fmt.Errorf("load: %w", workflow.ErrInvalidWorkflow)), ExitCodeUserError},
+		{"network denied in a workflow", newRuntimeError(fmt.Errorf("fetch: %w", network.ErrURLNotAllowed)), ExitCodeNetworkDenied},
+		{"offline", newInfraError(fmt.Errorf("download: %w", network.ErrOffline)), ExitCodeNetworkDenied},
+		{"timeout", &exitError{code: ExitCodeTimeout, err: context.DeadlineExceeded}, ExitCodeTimeout},
+		{"timeout wins over network denied", &exitError{code: ExitCodeTimeout, err: fmt.Errorf("%w: %w", context.DeadlineExceeded, network.ErrURLNotAllowed)}, ExitCodeTimeout},
+		{"interrupted", newInterruptedError(context.Canceled), ExitCodeInterrupted},
+		{"interrupted wins over user error", newInterruptedError(fmt.Errorf("stopped: %w", workflow.ErrInvalidWorkflow)), ExitCodeInterrupted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ExitCode(tc.err); got != tc.expected {
+				t.Errorf("ExitCode(%v) = %d; expected %d", tc.err, got, tc.expected)
+			}
+		})
+	}
+}
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
 
 	"amo/pkg/cli"
 	"amo/pkg/config"
+	"amo/pkg/env"
+	"amo/pkg/filesystem"
 	"amo/pkg/tool"
 	"amo/pkg/workflow"
 
@@ -17,12 +22,22 @@ import (
 
 // Command line flags for run command
 var (
-	runVarSpecs    []string
-	runInputPath   string
-	runOutputPath  string
-	runHelp        bool
-	runDebug       bool
-	runTimeoutSecs int
+	runVarSpecs     []string
+	runEnvFile      string
+	runVarJSON      string
+	runInputPath    string
+	runOutputPath   string
+	runHelp         bool
+	runDebug        bool
+	runTimeoutSecs  int
+	runNotifyOnDone bool
+	runMaxProcs     int
+	runRegion       string
+	runCapture      string
+	runAllowHosts   []string
+	runWatch        bool
+	runSkipReqs     bool
+	runStep         bool
 )
 
 var whitelistWarningShown bool
@@ -30,30 +45,53 @@ var whitelistWarningShown bool
 // NewRunCmd creates the run subcommand for executing workflows
 func NewRunCmd() *cobra.Command {
 	runCmd := &cobra.Command{
-		Use:   "run <workflow-file>",
+		Use:   "run <workflow-file|-> [-- <args>...]",
 		Short: "Run a JavaScript workflow file",
 		Long: `Execute a JavaScript workflow file with optional variables and parameters.
 
 The workflow file can be:
 - An embedded workflow (e.g., file-organizer.js)
 - An external file path (e.g., /path/to/my-workflow.js)
+- "-" to read the workflow source from stdin
 
 Examples:
   amo run file-organizer.js --var source_dir=/Downloads --var target_dir=/Organized
   amo run /path/to/custom-workflow.js --input /data --output /results
   amo run video-to-audio.js --var input=/videos --var format=mp3 --debug
-  amo run workflow.js --timeout 3600  # With 1 hour timeout limit`,
-		Args: cobra.ExactArgs(1),
+  amo run deploy.js --env-file deploy.env --var target=staging  # --var overrides the file
+  amo run batch.js --var-json vars.json  # Structured variables via getVarObject()
+  amo run convert.js -- a.wav b.wav  # Positional arguments via getArgs()
+  generate-workflow | amo run -  # Workflow source piped on stdin
+  amo run workflow.js --timeout 3600  # With 1 hour timeout limit
+  amo run long-task.js --notify-on-done  # Desktop notification when finished
+  amo run batch.js --max-procs 2  # At most 2 cliCommand subprocesses at once
+  amo run setup.js --region cn    # getRegion() returns cn for this run
+  amo run nightly.js --capture nightly.log  # Also write console output to a file
+  amo run fetch.js --allow-host api.example.com  # Extra network host for this run only
+  amo run ./my-workflow.js --watch  # Re-run whenever the script is saved
+  amo run convert.js --skip-requirements  # Ignore @requires/@var declared in the header
+  amo run deploy.js --step  # Confirm each cliCommand and http call before it runs`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: runWorkflowCommand,
 	}
 
 	// Add flags
 	runCmd.Flags().StringSliceVar(&runVarSpecs, "var", []string{}, "Runtime variables (key=value)")
+	runCmd.Flags().StringVar(&runEnvFile, "env-file", "", "Load runtime variables from a dotenv file (KEY=VALUE lines; --var takes precedence)")
+	runCmd.Flags().StringVar(&runVarJSON, "var-json", "", "Load structured runtime variables from a JSON object file (read with getVarObject)")
 	runCmd.Flags().StringVar(&runInputPath, "input", "", "Input path (same as --var input=...)")
 	runCmd.Flags().StringVar(&runOutputPath, "output", "", "Output path (same as --var output=...)")
 	runCmd.Flags().BoolVar(&runHelp, "workflow-help", false, "Show workflow help message")
 	runCmd.Flags().BoolVar(&runDebug, "debug", false, "Enable debug mode")
-	runCmd.Flags().IntVar(&runTimeoutSecs, "timeout", 0, "Timeout in seconds (0 = no timeout)")
+	runCmd.Flags().IntVar(&runTimeoutSecs, "timeout", 0, "Timeout in seconds (0 = no timeout; defaults to "+config.KeyWorkflowDefaultTimeoutSeconds+", capped by "+config.KeyWorkflowMaxTimeoutSeconds+")")
+	runCmd.Flags().BoolVar(&runNotifyOnDone, "notify-on-done", false, "Show a desktop notification when the workflow finishes")
+	runCmd.Flags().IntVar(&runMaxProcs, "max-procs", workflow.DefaultMaxProcs(), "Maximum concurrent subprocesses started by cliCommand")
+	runCmd.Flags().StringVar(&runRegion, "region", "", "Region getRegion() returns for this run (e.g. cn, us, global) instead of detecting it")
+	runCmd.Flags().StringVar(&runCapture, "capture", "", "Also write the workflow's console output (stdout and stderr) to this file")
+	runCmd.Flags().StringArrayVar(&runAllowHosts, "allow-host", nil, "Also allow network access to this host (or host/path) for this run only; repeatable")
+	runCmd.Flags().BoolVar(&runWatch, "watch", false, "Re-run the workflow whenever the script file changes")
+	runCmd.Flags().BoolVar(&runStep, "step", false, "Pause before each cliCommand and http call; press Enter to run it or q to abort")
+	runCmd.Flags().BoolVar(&runSkipReqs, "skip-requirements", false, "Run even if tools (@requires) or variables (@var) declared in the workflow header are missing")
 
 	return runCmd
 }
@@ -63,26 +101,126 @@ func runWorkflowCommand(cmd *cobra.Command, args []string) error {
 		return newUserError("workflow filename is required")
 	}
 
-	// Get script path
+	// Everything after -- is passed to the workflow as getArgs()
+	var workflowArgs []string
+	switch dash := cmd.ArgsLenAtDash(); {
+	case dash == 0:
+		return newUserError("workflow filename is required before --")
+	case dash > 1 || (dash < 0 && len(args) > 1):
+		return newUserError("unexpected arguments %q; pass workflow arguments after --", args[1:])
+	case dash == 1:
+		workflowArgs = args[1:]
+	}
+
+	// Get script path; "-" reads the workflow from stdin
 	scriptPath := args[0]
+	script := workflowScript{name: scriptPath}
+	if scriptPath == stdinScriptArg {
+		if watch, _ := cmd.Flags().GetBool("watch"); watch {
+			return newUserError("--watch cannot be used with a workflow read from stdin")
+		}
+		if step, _ := cmd.Flags().GetBool("step"); step {
+			return newUserError("--step reads answers from stdin, so it cannot be used with a workflow read from stdin")
+		}
+		source, err := readStdinWorkflow(cmd.InOrStdin(), workflow.MaxScriptSize())
+		if err != nil {
+			return newUserError("%v", err)
+		}
+		script = workflowScript{name: "stdin", source: source, fromStdin: true}
+	}
 
 	// Help mode - just run the workflow with --help flag
 	if workflowHelp, _ := cmd.Flags().GetBool("workflow-help"); workflowHelp {
 		vars := map[string]string{
 			"help": "true",
 		}
-		if err := executeWorkflow(scriptPath, vars, 0, false); err != nil {
+		if err := executeWorkflow(cmd.Context(), script, vars, nil, nil, 0, engineSettings{}, false, false); err != nil {
 			return newRuntimeError(err)
 		}
 		return nil
 	}
 
-	// Parse variables
+	// Parse variables; precedence is --env-file < --var-json < --var
+	vars := map[string]string{}
+	if envFile, _ := cmd.Flags().GetString("env-file"); envFile != "" {
+		fileVars, err := cli.LoadEnvFile(envFile)
+		if err != nil {
+			return newUserError("%v", err)
+		}
+		vars = fileVars
+	}
+	var varObjects map[string]interface{}
+	if varJSON, _ := cmd.Flags().GetString("var-json"); varJSON != "" {
+		objects, err := cli.LoadVarJSON(varJSON)
+		if err != nil {
+			return newUserError("%v", err)
+		}
+		for key, value := range objects {
+			vars[key] = cli.VarString(value)
+		}
+		varObjects = objects
+	}
 	varsFlag, _ := cmd.Flags().GetStringSlice("var")
-	vars := cli.ParseVars(varsFlag)
+	for key, value := range cli.ParseVars(varsFlag) {
+		vars[key] = value
+		delete(varObjects, key)
+	}
 
-	// Get timeout parameter
+	// Get timeout parameter; the config sets a default and a cap
 	timeout, _ := cmd.Flags().GetInt("timeout")
+	if timeout < 0 {
+		return newUserError("--timeout must not be negative, got %d", timeout)
+	}
+	if manager, err := config.NewManager(); err == nil {
+		maxTimeout := manager.GetInt(config.KeyWorkflowMaxTimeoutSeconds)
+		var capped bool
+		timeout, capped = config.ResolveWorkflowTimeout(timeout, cmd.Flags().Changed("timeout"),
+			manager.GetInt(config.KeyWorkflowDefaultTimeoutSeconds), maxTimeout)
+		if capped && cmd.Flags().Changed("timeout") {
+			fmt.Fprintf(os.Stderr, "⚠️  Timeout capped to %d seconds by %s\n", maxTimeout, config.KeyWorkflowMaxTimeoutSeconds)
+		}
+	}
+
+	// Get subprocess limit
+	maxProcs, _ := cmd.Flags().GetInt("max-procs")
+	if maxProcs < 1 {
+		return newUserError("--max-procs must be at least 1, got %d", maxProcs)
+	}
+
+	// Get region override for getRegion()
+	region, _ := cmd.Flags().GetString("region")
+	if err := config.ValidateValue(config.KeyRegion, region); err != nil {
+		return newUserError("invalid --region: %v", err)
+	}
+	settings := engineSettings{maxProcs: maxProcs, region: region}
+
+	// Get hosts allowed for this run only
+	allowHosts, _ := cmd.Flags().GetStringArray("allow-host")
+	for _, host := range allowHosts {
+		if strings.TrimSpace(host) == "" || strings.Contains(host, "://") || strings.ContainsAny(host, " \t") {
+			return newUserError("invalid --allow-host %q: use a host or host/path such as api.example.com", host)
+		}
+	}
+	if len(allowHosts) > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  --allow-host: this run may also access %s, beyond the network whitelist\n", strings.Join(allowHosts, ", "))
+		settings.allowHosts = allowHosts
+	}
+
+	// Tee console output to the --capture file
+	if capturePath, _ := cmd.Flags().GetString("capture"); capturePath != "" {
+		captureFile, err := os.Create(capturePath)
+		if err != nil {
+			return newUserError("cannot write --capture file: %v", err)
+		}
+		defer captureFile.Close()
+		settings.stdout = io.MultiWriter(os.Stdout, captureFile)
+		settings.stderr = io.MultiWriter(os.Stderr, captureFile)
+	}
+
+	// Confirm external calls one by one
+	if step, _ := cmd.Flags().GetBool("step"); step {
+		settings.stepper = stepPrompt(cmd.InOrStdin(), os.Stderr)
+	}
 
 	// Get debug parameter
 	debug, _ := cmd.Flags().GetBool("debug")
@@ -91,38 +229,142 @@ func runWorkflowCommand(cmd *cobra.Command, args []string) error {
 	input, _ := cmd.Flags().GetString("input")
 	if input != "" {
 		vars["input"] = input
+		delete(varObjects, "input")
 	}
 
 	output, _ := cmd.Flags().GetString("output")
 	if output != "" {
 		vars["output"] = output
+		delete(varObjects, "output")
 	}
 
-	// Add environment variables to vars map
-	if debug {
-		fmt.Fprintf(os.Stderr, "📋 Adding environment variables to vars map...\n")
-	}
-	for _, envVar := range os.Environ() {
-		parts := strings.SplitN(envVar, "=", 2)
-		if len(parts) == 2 && !strings.HasPrefix(parts[0], "_") {
-			// Only if not explicitly set by user
-			if _, exists := vars[parts[0]]; !exists {
-				vars[parts[0]] = parts[1]
-				if debug {
-					fmt.Fprintf(os.Stderr, "  Adding env var: %s = %s\n", parts[0], parts[1])
-				}
+	notifyOnDone, _ := cmd.Flags().GetBool("notify-on-done")
+	skipRequirements, _ := cmd.Flags().GetBool("skip-requirements")
+	checkRequirements := !skipRequirements
+
+	if watch, _ := cmd.Flags().GetBool("watch"); watch {
+		return watchWorkflow(scriptPath, func() error {
+			err := executeWorkflow(cmd.Context(), script, vars, varObjects, workflowArgs, timeout, settings, debug, checkRequirements)
+			if notifyOnDone {
+				notifyWorkflowDone(scriptPath, err)
 			}
-		}
+			return err
+		})
 	}
 
 	// Execute workflow with variables and timeout
-	if err := executeWorkflow(scriptPath, vars, timeout, debug); err != nil {
+	err := executeWorkflow(cmd.Context(), script, vars, varObjects, workflowArgs, timeout, settings, debug, checkRequirements)
+
+	if notifyOnDone {
+		notifyWorkflowDone(script.name, err)
+	}
+
+	if err != nil {
+		if interrupted(cmd.Context()) {
+			return newInterruptedError(err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return &exitError{code: ExitCodeTimeout, err: err}
+		}
+		if errors.Is(err, workflow.ErrStepAborted) {
+			return newInterruptedError(err)
+		}
+		var reqErr *requirementsError
+		if errors.As(err, &reqErr) {
+			return &exitError{code: ExitCodeUserError, err: reqErr}
+		}
 		return newRuntimeError(err)
 	}
 	return nil
 }
 
-func executeWorkflow(scriptPath string, vars map[string]string, timeout int, debug bool) error {
+// notifyWorkflowDone shows a desktop notification with the run result.
+// Missing notification backends only produce a warning.
+func notifyWorkflowDone(scriptPath string, runErr error) {
+	message := fmt.Sprintf("%s completed successfully", scriptPath)
+	if runErr != nil {
+		message = fmt.Sprintf("%s failed: %v", scriptPath, runErr)
+	}
+	if err := env.NewNotifier().Desktop("amo", message); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not show desktop notification: %v\n", err)
+	}
+}
+
+// engineSettings are the amo run flags that configure the workflow engine
+type engineSettings struct {
+	maxProcs int
+	region   string
+	// allowHosts extend the network whitelist for this run
+	allowHosts []string
+	// stdout and stderr receive console output; nil means the terminal
+	stdout io.Writer
+	stderr io.Writer
+	// stepper confirms each cliCommand and http call (--step)
+	stepper func(action string) bool
+}
+
+func (s engineSettings) apply(engine *workflow.Engine) {
+	engine.SetMaxProcs(s.maxProcs)
+	engine.SetRegion(s.region)
+	engine.AddAllowedHosts(s.allowHosts)
+	engine.SetOutput(s.stdout, s.stderr)
+	engine.SetStepper(s.stepper)
+}
+
+// stepPrompt shows each call on out and waits for a line on in: empty runs
+// the call, q aborts the workflow. End of input aborts too.
+func stepPrompt(in io.Reader, out io.Writer) func(action string) bool {
+	reader := bufio.NewReader(in)
+	return func(action string) bool {
+		fmt.Fprintf(out, "⏸️  %s\n   [Enter] run, [q] abort: ", action)
+		answer, err := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "q" || answer == "quit" || (err != nil && answer == "") {
+			fmt.Fprintln(out)
+			return false
+		}
+		return true
+	}
+}
+
+// stdinScriptArg is the workflow argument that makes amo run read the
+// workflow source from stdin
+const stdinScriptArg = "-"
+
+// workflowScript is the workflow amo run executes: a name or path looked up
+// by the engine, or source piped in with "amo run -"
+type workflowScript struct {
+	name      string
+	source    string
+	fromStdin bool
+}
+
+// readStdinWorkflow reads a workflow piped to amo run -. At most limit bytes
+// (when non-zero) are read, so an endless pipe fails instead of filling memory.
+func readStdinWorkflow(in io.Reader, limit int64) (string, error) {
+	if f, ok := in.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return "", fmt.Errorf("no workflow piped to stdin; use e.g. cat workflow.js | amo run -")
+		}
+	}
+	if limit > 0 {
+		in = io.LimitReader(in, limit+1)
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read workflow from stdin: %w", err)
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return "", fmt.Errorf("workflow on stdin is over the %s limit (%s): %w",
+			filesystem.FormatBytes(limit), config.KeyWorkflowMaxScriptSizeKB, workflow.ErrScriptTooLarge)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("no workflow source on stdin")
+	}
+	return string(data), nil
+}
+
+func executeWorkflow(parent context.Context, script workflowScript, vars map[string]string, varObjects map[string]interface{}, workflowArgs []string, timeout int, settings engineSettings, debug, checkRequirements bool) error {
 	if !whitelistWarningShown {
 		if manager, err := config.NewManager(); err == nil {
 			if !manager.GetBool(config.KeySecurityWhitelistEnabled) {
@@ -133,30 +375,37 @@ func executeWorkflow(scriptPath string, vars map[string]string, timeout int, deb
 		}
 	}
 
+	// Temp directories of workflows that crashed are never cleaned up by them
+	if environment, err := env.NewEnvironment(); err == nil {
+		_, _ = environment.CleanupStaleTemp(env.StaleTempAge)
+	}
+
 	if debug {
 		fmt.Fprintf(os.Stderr, "🚀 Amo Workflow Engine\n")
 		fmt.Fprintf(os.Stderr, "======================\n")
-		fmt.Fprintf(os.Stderr, "Executing workflow: %s\n", scriptPath)
+		fmt.Fprintf(os.Stderr, "Executing workflow: %s\n", script.name)
 		fmt.Fprintf(os.Stderr, "Debug mode: enabled\n")
 		if timeout > 0 {
-			fmt.Fprintf(os.Stderr, "Timeout: %d seconds\n", timeout)
+			fmt.Fprintf(os.Stderr, "Effective timeout: %d seconds\n", timeout)
 		} else {
-			fmt.Fprintf(os.Stderr, "Timeout: unlimited\n")
+			fmt.Fprintf(os.Stderr, "Effective timeout: unlimited\n")
 		}
 		fmt.Fprintf(os.Stderr, "\n")
 	}
 
-	// Create context with optional timeout
-	var ctx context.Context
+	// Create context with optional timeout; the parent is cancelled on Ctrl-C
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx := parent
 	if timeout > 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		ctx, cancel = context.WithTimeout(parent, time.Duration(timeout)*time.Second)
 		defer cancel()
-	} else {
-		ctx = context.Background()
 	}
 
 	engine := workflow.NewEngine(ctx)
+	settings.apply(engine)
 
 	// Set asset reader if available
 	if AssetManager != nil {
@@ -168,6 +417,20 @@ func executeWorkflow(scriptPath string, vars map[string]string, timeout int, deb
 	if err == nil {
 		toolPathProvider := (*tool.Manager)(toolManager).NewToolPathProviderAdapter()
 		engine.SetToolPathProvider(toolPathProvider)
+	} else {
+		toolManager = nil
+	}
+
+	// Defaults from @var name=value apply even with --skip-requirements; the
+	// requirements check fails early, with everything that is missing, instead
+	// of deep in the run
+	if scriptName, header := loadWorkflowHeader(engine, script); header != nil {
+		vars = applyVarDefaults(header, vars, varObjects)
+		if checkRequirements {
+			if err := checkWorkflowRequirements(toolManager, scriptName, header, vars, varObjects); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Set variables in engine
@@ -182,6 +445,16 @@ func executeWorkflow(scriptPath string, vars map[string]string, timeout int, deb
 			fmt.Fprintf(os.Stderr, "\n")
 		}
 	}
+	if len(varObjects) > 0 {
+		engine.SetVarObjects(varObjects)
+	}
+	if len(workflowArgs) > 0 {
+		engine.SetArgs(workflowArgs)
+
+		if debug {
+			fmt.Fprintf(os.Stderr, "📋 Workflow Arguments: %q\n\n", workflowArgs)
+		}
+	}
 
 	// Execute workflow
 	if debug {
@@ -189,11 +462,16 @@ func executeWorkflow(scriptPath string, vars map[string]string, timeout int, deb
 		fmt.Fprintf(os.Stderr, "\n")
 	}
 
-	if err := engine.RunWorkflow(scriptPath); err != nil {
+	if script.fromStdin {
+		err = engine.RunWorkflowSource(script.source, script.name)
+	} else {
+		err = engine.RunWorkflow(script.name)
+	}
+	if err != nil {
 		if debug {
 			fmt.Fprintf(os.Stderr, "\n❌ Workflow execution failed: %v\n", err)
 		}
-		return fmt.Errorf("failed to execute workflow %s: %w", scriptPath, err)
+		return fmt.Errorf("failed to execute workflow %s: %w", script.name, err)
 	}
 
 	if debug {
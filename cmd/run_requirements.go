@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"amo/pkg/tool"
+	"amo/pkg/workflow"
+)
+
+// requirementsError lists the @requires tools and @var variables a workflow
+// declares but that are missing, so they can all be fixed in one go
+type requirementsError struct {
+	scriptPath string
+	problems   []string
+}
+
+func (e *requirementsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s cannot run, its declared requirements are not met:", e.scriptPath)
+	for _, problem := range e.problems {
+		fmt.Fprintf(&b, "\n  - %s", problem)
+	}
+	b.WriteString("\nuse --skip-requirements to run it anyway")
+	return b.String()
+}
+
+// loadWorkflowHeader returns the resolved name and the parsed header of the
+// workflow to run. Scripts that cannot be found or parsed return a nil header
+// and are left for RunWorkflow to report.
+func loadWorkflowHeader(engine *workflow.Engine, script workflowScript) (string, *workflow.WorkflowHeader) {
+	name, source := script.name, script.source
+	if !script.fromStdin {
+		found, err := engine.FindWorkflow(script.name)
+		if err != nil {
+			return name, nil
+		}
+		name, source = found.Name, found.Source
+	}
+	header, err := workflow.ParseWorkflowHeader(source)
+	if err != nil {
+		return name, nil
+	}
+	return name, header
+}
+
+// applyVarDefaults returns vars with the default of every declared @var
+// name=value that was not passed, either as --var or as a structured variable
+func applyVarDefaults(header *workflow.WorkflowHeader, vars map[string]string, varObjects map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(vars))
+	for key, value := range vars {
+		result[key] = value
+	}
+	for _, v := range header.Vars {
+		_, isVar := result[v.Name]
+		_, isObject := varObjects[v.Name]
+		if v.HasDefault && !isVar && !isObject {
+			result[v.Name] = v.Default
+		}
+	}
+	return result
+}
+
+// checkWorkflowRequirements verifies the tools and required variables declared
+// in the workflow header before it runs. Tools known to amo are checked with
+// the tool manager; other names must be on PATH or in the tool path cache.
+func checkWorkflowRequirements(toolManager *tool.Manager, name string, header *workflow.WorkflowHeader, vars map[string]string, varObjects map[string]interface{}) error {
+	var problems []string
+	for _, name := range header.Requires {
+		available, managed := requiredToolAvailable(toolManager, name)
+		switch {
+		case available:
+		case managed:
+			problems = append(problems, fmt.Sprintf("tool '%s' is not installed (install it with: amo tool install %s)", name, name))
+		default:
+			problems = append(problems, fmt.Sprintf("tool '%s' was not found on PATH (pin its location with: amo tool cache set %s <path>)", name, name))
+		}
+	}
+	for _, v := range header.Vars {
+		_, isVar := vars[v.Name]
+		_, isObject := varObjects[v.Name]
+		if v.Required() && !isVar && !isObject {
+			problem := fmt.Sprintf("variable '%s' is not set (pass --var %s=...)", v.Name, v.Name)
+			if v.Description != "" {
+				problem += ": " + v.Description
+			}
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &requirementsError{scriptPath: name, problems: problems}
+	}
+	return nil
+}
+
+// requiredToolAvailable reports whether a tool named in @requires can be
+// used, and whether it is one amo can install
+func requiredToolAvailable(toolManager *tool.Manager, name string) (available, managed bool) {
+	if toolManager != nil {
+		if status, err := toolManager.CheckTool(name); err == nil {
+			return status.Installed, true
+		}
+		if path, cached := toolManager.GetCachedToolPath(name); cached {
+			if _, err := os.Stat(path); err == nil {
+				return true, false
+			}
+		}
+	}
+	_, err := exec.LookPath(name)
+	return err == nil, false
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"amo/pkg/workflow"
+)
+
+func TestCheckWorkflowRequirementsVars(t *testing.T) {
+	header, err := workflow.ParseWorkflowHeader("//!amo\n// @var input Folder with the videos\n// @var format=mp3\n// @var? title\n")
+	if err != nil {
+		t.Fatalf("ParseWorkflowHeader failed: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		vars       map[string]string
+		varObjects map[string]interface{}
+		missing    []string
+	}{
+		{"required passed", map[string]string{"input": "videos"}, nil, nil},
+		{"required as object", nil, map[string]interface{}{"input": []interface{}{"a"}}, nil},
+		{"required missing", map[string]string{"format": "ogg", "title": "x"}, nil, []string{"input"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vars := applyVarDefaults(header, tc.vars, tc.varObjects)
+			err := checkWorkflowRequirements(nil, "convert.js", header, vars, tc.varObjects)
+			if len(tc.missing) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			var reqErr *requirementsError
+			if !errors.As(err, &reqErr) {
+				t.Fatalf("Expected *requirementsError, got %T: %v", err, err)
+			}
+			if len(reqErr.problems) != len(tc.missing) {
+				t.Errorf("Problems = %q; expected one per missing variable %q", reqErr.problems, tc.missing)
+			}
+			for i, name := range tc.missing {
+				if i < len(reqErr.problems) && !strings.Contains(reqErr.problems[i], "'"+name+"'") {
+					t.Errorf("Problem %q does not name variable %s", reqErr.problems[i], name)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyVarDefaults(t *testing.T) {
+	header, err := workflow.ParseWorkflowHeader("//!amo\n// @var format=mp3\n// @var suffix=\n// @var? title\n// @var layout=grid\n")
+	if err != nil {
+		t.Fatalf("ParseWorkflowHeader failed: %v", err)
+	}
+	passed := map[string]string{"format": "ogg"}
+
+	vars := applyVarDefaults(header, passed, map[string]interface{}{"layout": map[string]interface{}{"cols": 2}})
+	if vars["format"] != "ogg" {
+		t.Errorf("format = %q; a passed value must win over the default", vars["format"])
+	}
+	if value, ok := vars["suffix"]; !ok || value != "" {
+		t.Errorf("suffix = %q, %v; expected the empty default", value, ok)
+	}
+	if _, ok := vars["title"]; ok {
+		t.Error("An optional variable without a default must stay unset")
+	}
+	if _, ok := vars["layout"]; ok {
+		t.Error("A default must not shadow a structured variable of the same name")
+	}
+	if len(passed) != 1 {
+		t.Errorf("applyVarDefaults modified the caller's map: %v", passed)
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"amo/pkg/workflow"
+)
+
+// countingReader records how many bytes were read from it
+type countingReader struct {
+	r    io.Reader
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestReadStdinWorkflow(t *testing.T) {
+	script := "//!amo\nconsole.log(1);\n"
+
+	source, err := readStdinWorkflow(strings.NewReader(script), int64(len(script)))
+	if err != nil || source != script {
+		t.Errorf("readStdinWorkflow = %q, %v; expected the script at exactly the limit", source, err)
+	}
+
+	if _, err := readStdinWorkflow(strings.NewReader(" \n"), 1024); err == nil {
+		t.Error("Expected an error for empty stdin")
+	}
+
+	// An oversized pipe is cut off after limit+1 bytes instead of read to the end
+	huge := &countingReader{r: strings.NewReader(script + strings.Repeat("//", 1<<20))}
+	const limit = 1024
+	if _, err := readStdinWorkflow(huge, limit); !errors.Is(err, workflow.ErrScriptTooLarge) {
+		t.Errorf("Expected ErrScriptTooLarge, got: %v", err)
+	}
+	if huge.read > limit+1 {
+		t.Errorf("Read %d bytes from stdin; expected at most %d", huge.read, limit+1)
+	}
+
+	// A zero limit turns the check off
+	big := script + strings.Repeat("//", 4096)
+	if source, err := readStdinWorkflow(strings.NewReader(big), 0); err != nil || source != big {
+		t.Errorf("readStdinWorkflow without a limit = %d bytes, %v; expected the whole script", len(source), err)
+	}
+}
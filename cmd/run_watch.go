@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"amo/pkg/filesystem"
+	"amo/pkg/workflow"
+)
+
+// Polling interval and debounce delay for run --watch
+const (
+	watchPollInterval = 500 * time.Millisecond
+	watchDebounce     = 300 * time.Millisecond
+)
+
+// watchWorkflow runs the workflow and re-runs it whenever the script changes.
+// Subpath workflows in a workflows directory (e.g. tools/convert.js) also watch
+// the other .js files next to them. It only returns on setup errors; stop it
+// with Ctrl-C.
+func watchWorkflow(scriptPath string, run func() error) error {
+	resolved, inWorkflowsDir, err := resolveWatchPath(scriptPath)
+	if err != nil {
+		return newUserError("%v", err)
+	}
+	watchDir := ""
+	if inWorkflowsDir && strings.ContainsAny(scriptPath, `/\`) {
+		watchDir = filepath.Dir(resolved)
+	}
+
+	fs := filesystem.NewFileSystem()
+	snapshot := watchSnapshot(fs, resolved, watchDir)
+
+	fmt.Fprintf(os.Stderr, "👀 Watching %s for changes (Ctrl-C to stop)\n", resolved)
+	for runNumber := 1; ; runNumber++ {
+		if runNumber > 1 {
+			fmt.Fprintf(os.Stderr, "\n──────── 🔄 Change detected, run #%d at %s ────────\n\n", runNumber, time.Now().Format("15:04:05"))
+		}
+
+		if err := run(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "✅ Workflow completed\n")
+		}
+		fmt.Fprintf(os.Stderr, "👀 Waiting for changes...\n")
+
+		snapshot = waitForChange(fs, resolved, watchDir, snapshot)
+	}
+}
+
+// waitForChange polls until the snapshot differs, then waits for it to settle
+// so that editors writing a file in several steps trigger a single run
+func waitForChange(fs *filesystem.FileSystem, scriptPath, watchDir string, previous map[string]string) map[string]string {
+	for {
+		time.Sleep(watchPollInterval)
+		current := watchSnapshot(fs, scriptPath, watchDir)
+		if sameSnapshot(previous, current) {
+			continue
+		}
+		for {
+			time.Sleep(watchDebounce)
+			settled := watchSnapshot(fs, scriptPath, watchDir)
+			if sameSnapshot(current, settled) {
+				return settled
+			}
+			current = settled
+		}
+	}
+}
+
+// watchSnapshot fingerprints the watched files by modification time and size
+func watchSnapshot(fs *filesystem.FileSystem, scriptPath, watchDir string) map[string]string {
+	paths := []string{scriptPath}
+	if watchDir != "" {
+		if entries, err := os.ReadDir(watchDir); err == nil {
+			for _, entry := range entries {
+				if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".js") {
+					paths = append(paths, filepath.Join(watchDir, entry.Name()))
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	snapshot := make(map[string]string, len(paths))
+	for _, path := range paths {
+		info, err := fs.GetFileInfo(path)
+		if err != nil {
+			snapshot[path] = "missing"
+			continue
+		}
+		snapshot[path] = fmt.Sprintf("%s/%d", info.ModTime, info.Size)
+	}
+	return snapshot
+}
+
+func sameSnapshot(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, fingerprint := range a {
+		if b[path] != fingerprint {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveWatchPath finds the workflow file on disk the way the engine does for
+// files and downloaded workflows, and reports whether it was found in a
+// workflows directory. Embedded workflows cannot be watched.
+func resolveWatchPath(scriptPath string) (string, bool, error) {
+	candidates := []string{scriptPath}
+	if downloader, err := workflow.NewWorkflowDownloader(); err == nil {
+		if dir := downloader.GetConfiguredWorkflowsDir(); dir != "" {
+			candidates = append(candidates, filepath.Join(dir, scriptPath))
+		}
+		candidates = append(candidates, filepath.Join(downloader.GetWorkflowsDir(), scriptPath))
+	}
+
+	for i, candidate := range candidates {
+		for _, path := range []string{candidate, candidate + ".js"} {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				if absPath, err := filepath.Abs(path); err == nil {
+					path = absPath
+				}
+				return path, i > 0, nil
+			}
+		}
+	}
+	return "", false, fmt.Errorf("--watch needs a workflow file on disk; %s was not found (embedded workflows cannot be watched)", scriptPath)
+}
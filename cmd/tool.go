@@ -6,7 +6,15 @@ import "github.com/spf13/cobra"
 var (
 	forceReinstall bool
 	showDetails    bool
+	listFilter     string
 	sourceURL      string
+	releaseTag     string
+	installDir     string
+	ignoreVersion  bool
+	assumeYes      bool
+	pythonPath     string
+	noMirror       bool
+	permissionJSON bool
 )
 
 // NewToolCmd creates and returns the tool management command
@@ -19,7 +27,9 @@ func NewToolCmd() *cobra.Command {
 Subcommands:
   list       - List all supported tools and their installation status  
   install    - Install one or more tools
-  permission - Manage CLI command permissions (list/add/remove)
+  export     - Print a manifest of the installed tools
+  import     - Install the tools listed in a manifest
+  permission - Manage CLI command permissions (list/add/remove/import/export)
   cache      - Manage tool path cache (info/clear)
   path       - Manage tools directory in system PATH`,
 	}
@@ -33,17 +43,56 @@ Subcommands:
 		RunE:    runToolListCommand,
 	}
 	listCmd.Flags().BoolVar(&showDetails, "details", false, "Show detailed information for each tool")
+	listCmd.Flags().StringVar(&listFilter, "filter", "", "Only show tools that are installed, missing or outdated (below check.min_version)")
 
 	// Install subcommand
 	installCmd := &cobra.Command{
 		Use:   "install <tool-name|all>",
 		Short: "Install a specific tool or all supported tools",
-		Long:  "Install a specific tool or all supported tools automatically (no confirmation required).",
-		Args:  cobra.ExactArgs(1),
-		RunE:  runToolInstallCommand,
+		Long: `Install a specific tool or all supported tools automatically.
+
+Installs through apt, yum or pacman run under sudo unless amo runs as root.
+The exact commands are shown and confirmed first; --yes skips the question,
+which is needed when there is no terminal to ask on.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runToolInstallCommand,
 	}
 	installCmd.Flags().BoolVar(&forceReinstall, "force", false, "Force reinstall even if tool is already installed")
 	installCmd.Flags().StringVar(&sourceURL, "url", "", "Override download URL for installer or binary (advanced)")
+	installCmd.Flags().StringVar(&installDir, "install-dir", "", "Install into this directory instead of the configured tools directory (e.g. ./.tools)")
+	installCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Run package manager installs under sudo without asking")
+	installCmd.Flags().StringVar(&pythonPath, "python", "", "Install pip tools with this interpreter's pip (python -m pip), e.g. a virtualenv's python")
+	installCmd.Flags().BoolVar(&noMirror, "no-mirror", false, "Download GitHub releases from GitHub only, never from the toolchains.mirror.toulan.fun mirror")
+	installCmd.Flags().StringVar(&releaseTag, "tag", "", "Install a specific GitHub release tag (e.g. v1.2.3) instead of the latest; combine with --force to replace an installed version")
+
+	// Export subcommand
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print a manifest of the installed tools",
+		Long: `Print the installed tools, their versions and paths as a JSON manifest that
+"amo tool import" accepts, to reproduce the same tool setup on another machine.`,
+		Args: cobra.NoArgs,
+		RunE: runToolExportCommand,
+	}
+
+	// Import subcommand
+	importCmd := &cobra.Command{
+		Use:   "import <manifest>",
+		Short: "Install the tools listed in a manifest",
+		Long: `Install every tool in a manifest written by "amo tool export" that is not
+installed yet. The import fails when a tool, already installed or just
+installed, has a different version than the manifest pins; pass
+--ignore-version to accept any version. Use - to read stdin.
+
+Examples:
+  amo tool export > tools-manifest.json
+  amo tool import tools-manifest.json --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: runToolImportCommand,
+	}
+	importCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Run package manager installs under sudo without asking")
+	importCmd.Flags().BoolVar(&noMirror, "no-mirror", false, "Download GitHub releases from GitHub only, never from the toolchains.mirror.toulan.fun mirror")
+	importCmd.Flags().BoolVar(&ignoreVersion, "ignore-version", false, "Accept installed tools whose version differs from the manifest")
 
 	// Permission subcommand
 	permissionCmd := &cobra.Command{
@@ -60,6 +109,7 @@ Subcommands:
 		Long:  "Display all commands in the whitelist.",
 		RunE:  runToolPermissionListCommand,
 	}
+	permissionListCmd.Flags().BoolVar(&permissionJSON, "json", false, "Print the allowed commands as a JSON array")
 
 	// Permission add subcommand
 	permissionAddCmd := &cobra.Command{
@@ -79,10 +129,36 @@ Subcommands:
 		RunE:  runToolPermissionRemoveCommand,
 	}
 
+	// Permission import subcommand
+	permissionImportCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Add commands from a file to the whitelist",
+		Long: `Merge commands into the workflow whitelist. The file is either a JSON array
+(as printed by "amo tool permission export") or one command per line with #
+comments. Commands already in the whitelist are skipped. Use - to read stdin.
+
+Examples:
+  amo tool permission import team-whitelist.json
+  amo tool permission export | ssh build-host amo tool permission import -`,
+		Args: cobra.ExactArgs(1),
+		RunE: runToolPermissionImportCommand,
+	}
+
+	// Permission export subcommand
+	permissionExportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the whitelist as JSON",
+		Long:  "Print the workflow whitelist as a JSON array that \"amo tool permission import\" accepts.",
+		Args:  cobra.NoArgs,
+		RunE:  runToolPermissionExportCommand,
+	}
+
 	// Add permission subcommands
 	permissionCmd.AddCommand(permissionListCmd)
 	permissionCmd.AddCommand(permissionAddCmd)
 	permissionCmd.AddCommand(permissionRemoveCmd)
+	permissionCmd.AddCommand(permissionImportCmd)
+	permissionCmd.AddCommand(permissionExportCmd)
 
 	// Cache subcommand
 	cacheCmd := &cobra.Command{
@@ -108,9 +184,32 @@ Subcommands:
 		RunE:  runToolCacheClearCommand,
 	}
 
+	// Cache set subcommand
+	cacheSetCmd := &cobra.Command{
+		Use:   "set <command> <path>",
+		Short: "Pin the location of a tool",
+		Long: `Cache <path> as the location of <command>, for tools installed outside the
+directories amo searches (e.g. a custom ffmpeg build). The path must be an
+existing executable file.`,
+		Example: "  amo tool cache set ffmpeg /opt/ffmpeg-custom/bin/ffmpeg",
+		Args:    cobra.ExactArgs(2),
+		RunE:    runToolCacheSetCommand,
+	}
+
+	// Cache unset subcommand
+	cacheUnsetCmd := &cobra.Command{
+		Use:   "unset <command>",
+		Short: "Remove the cached location of a tool",
+		Long:  "Remove the cached path of <command> so it is detected again on next use.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runToolCacheUnsetCommand,
+	}
+
 	// Add cache subcommands
 	cacheCmd.AddCommand(cacheInfoCmd)
 	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheSetCmd)
+	cacheCmd.AddCommand(cacheUnsetCmd)
 
 	// Path subcommand
 	pathCmd := &cobra.Command{
@@ -140,6 +239,8 @@ This command helps ensure that installed tools can be accessed directly from the
 	// Add subcommands
 	toolCmd.AddCommand(listCmd)
 	toolCmd.AddCommand(installCmd)
+	toolCmd.AddCommand(exportCmd)
+	toolCmd.AddCommand(importCmd)
 	toolCmd.AddCommand(permissionCmd)
 	toolCmd.AddCommand(cacheCmd)
 	pathCmd.AddCommand(pathInfoCmd)
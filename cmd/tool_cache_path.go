@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"amo/pkg/env"
+	"amo/pkg/filesystem"
+	"amo/pkg/tool"
 
 	"github.com/spf13/cobra"
 )
@@ -58,7 +60,7 @@ func runToolCacheInfoCommand(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println("💡 The cache file stores discovered tool paths for faster access.")
-	fmt.Println("   You can manually edit this file to specify custom tool locations.")
+	fmt.Println("   Use 'amo tool cache set <command> <path>' to pin a custom tool location.")
 	fmt.Println("   Use 'amo tool cache clear' to force re-detection of all tools.")
 
 	return nil
@@ -90,6 +92,48 @@ func runToolCacheClearCommand(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runToolCacheSetCommand(cmd *cobra.Command, args []string) error {
+	command, path := args[0], args[1]
+
+	absPath, err := tool.CheckExecutablePath(path)
+	if err != nil {
+		return newUserError("%v", err)
+	}
+
+	manager, err := createToolManager()
+	if err != nil {
+		return newInfraError(err)
+	}
+
+	if err := manager.PinToolPath(command, absPath); err != nil {
+		return newInfraError(err)
+	}
+
+	fmt.Printf("✅ %s → %s\n", command, absPath)
+	return nil
+}
+
+func runToolCacheUnsetCommand(cmd *cobra.Command, args []string) error {
+	command := args[0]
+
+	manager, err := createToolManager()
+	if err != nil {
+		return newInfraError(err)
+	}
+
+	removed, err := manager.UnpinToolPath(command)
+	if err != nil {
+		return newInfraError(err)
+	}
+	if !removed {
+		fmt.Printf("ℹ️  No cached path for '%s'\n", command)
+		return nil
+	}
+
+	fmt.Printf("✅ Removed cached path for '%s'\n", command)
+	return nil
+}
+
 func runToolPathInfoCommand(cmd *cobra.Command, args []string) error {
 	fmt.Println("🔍 PATH Configuration Information")
 	fmt.Println("=================================")
@@ -113,22 +157,7 @@ func runToolPathInfoCommand(cmd *cobra.Command, args []string) error {
 		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
 	}
 
-	pathEnv := envObj.GetCrossPlatformUtils().GetEnvironmentVariable("PATH")
-	pathSeparator := envObj.GetCrossPlatformUtils().GetPathListSeparator()
-
-	paths := strings.Split(pathEnv, pathSeparator)
-	absToolsDir, _ := filepath.Abs(toolsDir)
-
-	inPath := false
-	for _, path := range paths {
-		absPath, err := filepath.Abs(path)
-		if err == nil && absPath == absToolsDir {
-			inPath = true
-			break
-		}
-	}
-
-	if inPath {
+	if isDirInPath(envObj, toolsDir) {
 		fmt.Println("Status: ✅ Tools directory is in PATH")
 	} else {
 		fmt.Println("Status: ❌ Tools directory is NOT in PATH")
@@ -171,7 +200,7 @@ func runToolPathInfoCommand(cmd *cobra.Command, args []string) error {
 			executableCount++
 		}
 
-		fmt.Printf("  %s %s (%s)\n", icon, file.Name(), formatFileSize(info.Size()))
+		fmt.Printf("  %s %s (%s)\n", icon, file.Name(), filesystem.FormatBytes(info.Size()))
 	}
 
 	if executableCount == 0 {
@@ -247,21 +276,17 @@ func runToolPathSetupCommand(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func formatFileSize(size int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-	)
-
-	switch {
-	case size >= GB:
-		return fmt.Sprintf("%.1f GB", float64(size)/GB)
-	case size >= MB:
-		return fmt.Sprintf("%.1f MB", float64(size)/MB)
-	case size >= KB:
-		return fmt.Sprintf("%.1f KB", float64(size)/KB)
-	default:
-		return fmt.Sprintf("%d bytes", size)
+// isDirInPath reports whether dir is one of the entries of the PATH variable
+func isDirInPath(envObj *env.Environment, dir string) bool {
+	pathEnv := envObj.GetCrossPlatformUtils().GetEnvironmentVariable("PATH")
+	pathSeparator := envObj.GetCrossPlatformUtils().GetPathListSeparator()
+	absDir, _ := filepath.Abs(dir)
+
+	for _, path := range strings.Split(pathEnv, pathSeparator) {
+		absPath, err := filepath.Abs(path)
+		if err == nil && absPath == absDir {
+			return true
+		}
 	}
+	return false
 }
@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"amo/pkg/tool"
@@ -37,7 +39,37 @@ func createToolManager() (*tool.Manager, error) {
 	return manager, nil
 }
 
+// sudoPrompt asks on the terminal before a package manager install runs
+// under sudo. Without a terminal it answers no, so --yes is required.
+func sudoPrompt(cmd *cobra.Command) func(command string) bool {
+	return func(command string) bool {
+		in := cmd.InOrStdin()
+		if f, ok := in.(*os.File); ok {
+			if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+				return false
+			}
+		}
+		fmt.Print("Run it? [y/N]: ")
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
+
+// toolListFilters are the values tool list --filter accepts
+var toolListFilters = map[string]func(tool.ToolStatus) bool{
+	"installed": func(t tool.ToolStatus) bool { return t.Installed },
+	"missing":   func(t tool.ToolStatus) bool { return !t.Installed },
+	"outdated":  func(t tool.ToolStatus) bool { return t.Installed && t.Outdated },
+}
+
 func runToolListCommand(cmd *cobra.Command, args []string) error {
+	filter := strings.ToLower(strings.TrimSpace(listFilter))
+	matches, ok := toolListFilters[filter]
+	if filter != "" && !ok {
+		return newUserError("invalid --filter %q: use installed, missing or outdated", listFilter)
+	}
+
 	fmt.Println("🛠️  Tool Manager")
 	fmt.Println("================")
 
@@ -53,6 +85,7 @@ func runToolListCommand(cmd *cobra.Command, args []string) error {
 
 	installedCount := 0
 	totalTools := 0
+	shownCount := 0
 
 	err = manager.CheckToolsWithCallback(func(t tool.ToolStatus) {
 		if t.Installed {
@@ -60,6 +93,11 @@ func runToolListCommand(cmd *cobra.Command, args []string) error {
 		}
 		totalTools++
 
+		if matches != nil && !matches(t) {
+			return
+		}
+		shownCount++
+
 		status := tool.FormatToolStatus(t)
 		fmt.Println(status)
 
@@ -73,6 +111,9 @@ func runToolListCommand(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println()
+	if matches != nil {
+		fmt.Printf("🔎 %d tool(s) %s\n", shownCount, filter)
+	}
 	fmt.Printf("📊 Summary: %d/%d tools installed\n", installedCount, totalTools)
 
 	if installedCount < totalTools {
@@ -94,10 +135,23 @@ func runToolInstallCommand(cmd *cobra.Command, args []string) error {
 		return newInfraError(err)
 	}
 
+	manager.SetSudoConfirm(assumeYes, sudoPrompt(cmd))
+	manager.SetPythonInterpreter(pythonPath)
+	manager.SetNoMirror(noMirror)
+
+	// Apply the override before status checks so already-installed detection
+	// looks in the same directory the install would use
+	if err := manager.SetInstallDir(installDir); err != nil {
+		return newUserError("%v", err)
+	}
+
 	if toolName == "all" {
 		if sourceURL != "" {
 			return newUserError("--url cannot be used with 'all'. Provide a specific tool name.")
 		}
+		if releaseTag != "" {
+			return newUserError("--tag cannot be used with 'all'. Provide a specific tool name.")
+		}
 		if err := runToolInstallAllCommand(manager); err != nil {
 			return newInfraError(err)
 		}
@@ -125,8 +179,8 @@ func runToolInstallSingleCommand(manager *tool.Manager, toolName string) error {
 		return nil
 	}
 
-	if sourceURL != "" {
-		err = manager.InstallToolWithOptions(toolName, forceReinstall, &tool.InstallOptions{URL: sourceURL})
+	if sourceURL != "" || releaseTag != "" || installDir != "" {
+		err = manager.InstallToolWithOptions(toolName, forceReinstall, &tool.InstallOptions{URL: sourceURL, Tag: releaseTag, InstallDir: installDir})
 	} else {
 		err = manager.InstallTool(toolName, forceReinstall)
 	}
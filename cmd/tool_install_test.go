@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"amo/pkg/tool"
+)
+
+func TestToolListFilters(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+
+	manager, err := tool.NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	config := `{"version": "9.9", "tools": {
+		"old": {"name": "Old", "check": {"command": "sh", "args": ["-c", "echo 1.4.2"], "pattern": "([0-9.]+)", "min_version": "2.0"}},
+		"current": {"name": "Current", "check": {"command": "sh", "args": ["-c", "echo 2.1"], "pattern": "([0-9.]+)", "min_version": "2.0"}},
+		"missing": {"name": "Missing", "check": {"command": "amo-missing-tool-xyz", "min_version": "2.0"}}
+	}}`
+	if err := manager.LoadConfig([]byte(config)); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	var statuses []tool.ToolStatus
+	if err := manager.CheckToolsWithCallback(func(s tool.ToolStatus) { statuses = append(statuses, s) }); err != nil {
+		t.Fatalf("CheckToolsWithCallback failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"installed": {"Current", "Old"},
+		"missing":   {"Missing"},
+		"outdated":  {"Old"},
+	}
+	for filter, names := range expected {
+		var got []string
+		for _, s := range statuses {
+			if toolListFilters[filter](s) {
+				got = append(got, s.Name)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, names) {
+			t.Errorf("--filter %s shows %v; expected %v", filter, got, names)
+		}
+	}
+}
+
+func TestEmbeddedToolsDeclareMinVersions(t *testing.T) {
+	data, err := os.ReadFile("../assets/tools.json")
+	if err != nil {
+		t.Fatalf("Failed to read tools.json: %v", err)
+	}
+	var config tool.ToolConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to parse tools.json: %v", err)
+	}
+	for _, name := range []string{"ffmpeg", "imagemagick", "pandoc"} {
+		if config.Tools[name].Check.MinVersion == "" {
+			t.Errorf("%s has no check.min_version, so --filter outdated never lists it", name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"amo/pkg/tool"
+
+	"github.com/spf13/cobra"
+)
+
+func runToolExportCommand(cmd *cobra.Command, args []string) error {
+	manager, err := createToolManager()
+	if err != nil {
+		return newInfraError(err)
+	}
+
+	manifest, err := manager.ExportManifest()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to check tools: %w", err))
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to encode tool manifest: %w", err))
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runToolImportCommand(cmd *cobra.Command, args []string) error {
+	source := args[0]
+
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return newUserError("failed to read %s: %v", source, err)
+	}
+
+	manifest, err := tool.ParseManifest(data)
+	if err != nil {
+		return newUserError("%s: %v", source, err)
+	}
+
+	manager, err := createToolManager()
+	if err != nil {
+		return newInfraError(err)
+	}
+	manager.SetSudoConfirm(assumeYes, sudoPrompt(cmd))
+	manager.SetNoMirror(noMirror)
+
+	fmt.Printf("📦 Importing %d tool(s) from %s\n", len(manifest.Tools), source)
+	if manifest.ConfigVersion != "" && manifest.ConfigVersion != manager.GetConfigVersion() {
+		fmt.Printf("⚠️  Manifest was exported with tools.json %s; this amo has %s\n", manifest.ConfigVersion, manager.GetConfigVersion())
+	}
+	fmt.Println()
+
+	var installed, present, unknown, failed, mismatched []string
+	for _, entry := range manifest.Tools {
+		status, err := manager.CheckTool(entry.Name)
+		if errors.Is(err, tool.ErrToolNotFound) {
+			fmt.Printf("⚠️  %s is not a supported tool - skipping\n", entry.Name)
+			unknown = append(unknown, entry.Name)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to check %s status: %v\n", entry.Name, err)
+			failed = append(failed, entry.Name)
+			continue
+		}
+
+		if status.Installed {
+			fmt.Printf("✅ %s is already installed (%s)\n", status.Name, status.Version)
+			if !checkManifestVersion(entry, status.Version) {
+				mismatched = append(mismatched, entry.Name)
+			}
+			present = append(present, entry.Name)
+			continue
+		}
+
+		fmt.Printf("📦 Installing %s...\n", entry.Name)
+		if err := manager.InstallTool(entry.Name, false); err != nil {
+			fmt.Printf("❌ Installation of %s failed: %v\n", entry.Name, err)
+			failed = append(failed, entry.Name)
+			continue
+		}
+		newStatus, err := manager.CheckTool(entry.Name)
+		if err != nil || !newStatus.Installed {
+			fmt.Printf("❌ Installation of %s completed but verification failed\n", entry.Name)
+			failed = append(failed, entry.Name)
+			continue
+		}
+		fmt.Printf("✅ %s successfully installed (%s)\n", newStatus.Name, newStatus.Version)
+		if !checkManifestVersion(entry, newStatus.Version) {
+			mismatched = append(mismatched, entry.Name)
+		}
+		installed = append(installed, entry.Name)
+	}
+
+	fmt.Println()
+	fmt.Printf("📊 Installed %d, already present %d, unknown %d, failed %d, version mismatch %d\n",
+		len(installed), len(present), len(unknown), len(failed), len(mismatched))
+
+	if len(failed) > 0 {
+		fmt.Println()
+		fmt.Println("💡 You can try installing failed tools individually:")
+		for _, name := range failed {
+			fmt.Printf("   amo tool install %s\n", name)
+		}
+		return newInfraError(fmt.Errorf("failed to install %d tool(s)", len(failed)))
+	}
+	if len(mismatched) > 0 && !ignoreVersion {
+		fmt.Println()
+		fmt.Println("💡 Install the pinned versions, or rerun with --ignore-version to accept these")
+		return newInfraError(fmt.Errorf("%d tool(s) do not match the manifest version: %s", len(mismatched), strings.Join(mismatched, ", ")))
+	}
+	return nil
+}
+
+// checkManifestVersion reports whether version is the one the manifest pins
+// for entry, printing the difference when it is not
+func checkManifestVersion(entry tool.ManifestTool, version string) bool {
+	if entry.MatchesVersion(version) {
+		return true
+	}
+	if ignoreVersion {
+		fmt.Printf("   ⚠️  Manifest has version %s (ignored with --ignore-version)\n", entry.Version)
+	} else {
+		fmt.Printf("   ❌ Manifest has version %s\n", entry.Version)
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"amo/pkg/env"
@@ -41,9 +44,11 @@ func runToolPermissionCommand(cmd *cobra.Command, args []string) error {
 
 	fmt.Println()
 	fmt.Println("💡 Management commands:")
-	fmt.Println("   amo tool permission list         - List allowed commands")
-	fmt.Println("   amo tool permission add <cmd>    - Add command to whitelist")
-	fmt.Println("   amo tool permission remove <cmd> - Remove command from whitelist")
+	fmt.Println("   amo tool permission list          - List allowed commands")
+	fmt.Println("   amo tool permission add <cmd>     - Add command to whitelist")
+	fmt.Println("   amo tool permission remove <cmd>  - Remove command from whitelist")
+	fmt.Println("   amo tool permission import <file> - Merge commands from a file")
+	fmt.Println("   amo tool permission export        - Print the whitelist as JSON")
 	fmt.Println()
 	fmt.Println("🚫 Do NOT add package managers or system commands like:")
 	fmt.Println("   - brew, apt, yum, pip (these are for tool installation only)")
@@ -63,6 +68,10 @@ func runToolPermissionListCommand(cmd *cobra.Command, args []string) error {
 		return newInfraError(fmt.Errorf("failed to load allowed commands: %w", err))
 	}
 
+	if permissionJSON {
+		return printCommandsJSON(commands)
+	}
+
 	fmt.Println("📋 Allowed CLI Commands:")
 	fmt.Println("========================")
 
@@ -125,3 +134,69 @@ func runToolPermissionRemoveCommand(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runToolPermissionExportCommand(cmd *cobra.Command, args []string) error {
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+	}
+
+	commands, err := environment.LoadAllowedCLICommands()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to load allowed commands: %w", err))
+	}
+	return printCommandsJSON(commands)
+}
+
+func runToolPermissionImportCommand(cmd *cobra.Command, args []string) error {
+	source := args[0]
+
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return newUserError("failed to read %s: %v", source, err)
+	}
+
+	commands, err := env.ParseCLICommandList(data)
+	if err != nil {
+		return newUserError("%s: %v", source, err)
+	}
+
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+	}
+
+	added, err := environment.ImportAllowedCommands(commands)
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to import commands: %w", err))
+	}
+
+	if len(added) == 0 {
+		fmt.Printf("ℹ️  All %d command(s) in %s are already in the whitelist\n", len(commands), source)
+		return nil
+	}
+	for _, command := range added {
+		fmt.Printf("   • %s\n", command)
+	}
+	fmt.Printf("✅ Added %d command(s) to the whitelist\n", len(added))
+	return nil
+}
+
+// printCommandsJSON prints the whitelist as a JSON array, [] when it is empty
+func printCommandsJSON(commands []string) error {
+	if commands == nil {
+		commands = []string{}
+	}
+	data, err := json.MarshalIndent(commands, "", "  ")
+	if err != nil {
+		return newRuntimeError(fmt.Errorf("failed to encode commands: %w", err))
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"runtime"
 
+	"amo/pkg/env"
+
 	"github.com/spf13/cobra"
 )
 
@@ -30,7 +33,9 @@ func GetVersionInfo() (string, string, string, string) {
 
 // NewVersionCmd creates and returns the version command
 func NewVersionCmd() *cobra.Command {
-	return &cobra.Command{
+	var jsonOutput bool
+
+	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: "Show version information",
 		Long: `Display version information for Amo Workflow Engine including:
@@ -38,30 +43,82 @@ func NewVersionCmd() *cobra.Command {
 - Git commit hash
 - Build time
 - Build environment
-- Go version and platform information`,
-		Run: func(cmd *cobra.Command, args []string) {
-			showVersionInfo()
+- Go version and platform information
+- Version of the embedded tool configuration (tools.json)
+
+Examples:
+  amo version
+  amo version --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showVersionInfo(jsonOutput)
 		},
 	}
+
+	versionCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the version information as JSON")
+
+	return versionCmd
+}
+
+// versionInfo is the report printed by version
+type versionInfo struct {
+	Version           string `json:"version"`
+	GitCommit         string `json:"git_commit"`
+	BuildTime         string `json:"build_time"`
+	BuiltBy           string `json:"built_by"`
+	GoVersion         string `json:"go_version"`
+	OS                string `json:"os"`
+	Arch              string `json:"arch"`
+	Compiler          string `json:"compiler"`
+	ToolConfigVersion string `json:"tool_config_version"`
 }
 
 // showVersionInfo displays comprehensive version information
-func showVersionInfo() {
+func showVersionInfo(jsonOutput bool) error {
+	info := versionInfo{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildTime: buildTime,
+		BuiltBy:   buildBy,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Compiler:  runtime.Compiler,
+	}
+	if environment, err := env.NewEnvironment(); err == nil {
+		info.OS = environment.GetOperatingSystem()
+		info.Arch = environment.GetArchitecture()
+	}
+	if manager, err := createToolManager(); err == nil {
+		info.ToolConfigVersion = manager.GetConfigVersion()
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return newRuntimeError(fmt.Errorf("failed to encode version info: %w", err))
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	fmt.Printf("🚀 Amo Workflow Engine\n")
 	fmt.Printf("=======================\n\n")
 
 	// Application information
 	fmt.Printf("🔖 Version Information:\n")
-	fmt.Printf("  Version:     %s\n", version)
-	fmt.Printf("  Git Commit:  %s\n", gitCommit)
-	fmt.Printf("  Build Time:  %s\n", buildTime)
-	fmt.Printf("  Built By:    %s\n", buildBy)
+	fmt.Printf("  Version:     %s\n", info.Version)
+	fmt.Printf("  Git Commit:  %s\n", info.GitCommit)
+	fmt.Printf("  Build Time:  %s\n", info.BuildTime)
+	fmt.Printf("  Built By:    %s\n", info.BuiltBy)
+	fmt.Printf("  tools.json:  %s\n", info.ToolConfigVersion)
 	fmt.Printf("\n")
 
 	// Runtime information
 	fmt.Printf("⚙️ Runtime Information:\n")
-	fmt.Printf("  Go Version:  %s\n", runtime.Version())
-	fmt.Printf("  OS/Arch:     %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	fmt.Printf("  Compiler:    %s\n", runtime.Compiler)
+	fmt.Printf("  Go Version:  %s\n", info.GoVersion)
+	fmt.Printf("  OS/Arch:     %s/%s\n", info.OS, info.Arch)
+	fmt.Printf("  Compiler:    %s\n", info.Compiler)
 	fmt.Printf("\n")
+	return nil
 }
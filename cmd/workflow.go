@@ -1,12 +1,23 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"amo/pkg/config"
+	"amo/pkg/env"
+	"amo/pkg/filesystem"
+	"amo/pkg/network"
 	"amo/pkg/workflow"
 
 	"github.com/spf13/cobra"
@@ -22,25 +33,44 @@ func NewWorkflowCmd() *cobra.Command {
 
 	// Add subcommands
 	workflowCmd.AddCommand(NewWorkflowGetCmd())
+	workflowCmd.AddCommand(NewWorkflowBrowseCmd())
 	workflowCmd.AddCommand(NewWorkflowListCmd())
 	workflowCmd.AddCommand(NewWorkflowSourceCmd())
+	workflowCmd.AddCommand(NewWorkflowLintCmd())
+	workflowCmd.AddCommand(NewWorkflowValidateCmd())
+	workflowCmd.AddCommand(NewWorkflowInfoCmd())
 
 	return workflowCmd
 }
 
 // NewWorkflowListCmd creates the workflow list subcommand
 func NewWorkflowListCmd() *cobra.Command {
-	return &cobra.Command{
+	var showSource bool
+
+	listCmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all available workflow files",
-		Long:  "List all available workflow files from user directory and embedded assets.",
-		RunE:  listAllWorkflows,
+		Long: `List all available workflow files from user directory and embedded assets.
+
+With --show-source, each name is also resolved the way amo run resolves it
+(file path, configured directory, downloaded workflows, embedded) to show
+which copy runs and which copies it shadows.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listAllWorkflows(cmd, showSource)
+		},
 	}
+
+	listCmd.Flags().BoolVar(&showSource, "show-source", false, "Show which copy of each workflow amo run would use")
+
+	return listCmd
 }
 
 // NewWorkflowGetCmd creates the workflow get subcommand
 func NewWorkflowGetCmd() *cobra.Command {
 	var filename string
+	var targetDir string
+	var force bool
+	var options downloadOptions
 
 	getCmd := &cobra.Command{
 		Use:   "get <url>",
@@ -53,15 +83,39 @@ Supported domains (whitelist):
 - bitbucket.org
 - sourceforge.net
 
-The downloaded workflow will be saved to the user config directory (~/.amo/workflows/).
+The downloaded workflow will be saved to the user config directory (~/.amo/workflows/),
+or to the existing directory given with --to, e.g. a project folder under version control.
 
 Examples:
   amo workflow get https://github.com/user/repo/blob/main/workflow.js
   amo workflow get https://gitlab.com/user/repo/-/blob/main/workflow.js --filename my-workflow.js
-  amo workflow get https://raw.githubusercontent.com/user/repo/main/workflow.js`,
+  amo workflow get https://raw.githubusercontent.com/user/repo/main/workflow.js
+  amo workflow get https://github.com/user/repo/blob/main/workflow.js --to ./workflows
+  amo workflow get https://github.com/user/repo/blob/main/workflow.js --no-mirror
+  amo workflow get https://github.com/vendor/repo/blob/main/workflow.js --prefix vendor-
+
+A workflow saved to ~/.amo/workflows under the name of an embedded workflow
+would be run instead of the built-in one, so such downloads are refused unless
+--force is given.`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := downloadWorkflow(args[0], filename); err != nil {
+			if filename != "" && (options.prefix != "" || options.suffix != "") {
+				return newUserError("--prefix and --suffix apply to the name taken from the URL; include them in --filename instead")
+			}
+			if targetDir != "" {
+				absDir, err := filepath.Abs(targetDir)
+				if err != nil {
+					return newUserError("invalid --to directory %s: %v", targetDir, err)
+				}
+				if err := filesystem.NewFileSystem().CheckWritableDir(absDir); err != nil {
+					return newUserError("invalid --to directory: %v", err)
+				}
+				targetDir = absDir
+			}
+			if err := checkEmbeddedShadowing(args[0], filename, targetDir, force, options); err != nil {
+				return err
+			}
+			if err := downloadWorkflow(args[0], filename, targetDir, options); err != nil {
 				return newInfraError(err)
 			}
 			return nil
@@ -69,10 +123,48 @@ Examples:
 	}
 
 	getCmd.Flags().StringVar(&filename, "filename", "", "Custom filename for the downloaded workflow (optional)")
+	getCmd.Flags().StringVar(&targetDir, "to", "", "Save into this existing directory instead of ~/.amo/workflows")
+	getCmd.Flags().BoolVar(&force, "force", false, "Download even if the workflow would shadow an embedded one")
+	getCmd.Flags().StringVar(&options.prefix, "prefix", "", "Prefix for the name taken from the URL, e.g. vendor- saves workflow.js as vendor-workflow.js")
+	getCmd.Flags().StringVar(&options.suffix, "suffix", "", "Suffix for the name taken from the URL, added before .js")
+	getCmd.Flags().BoolVar(&options.noMirror, "no-mirror", false, "Download from the original URL only, never from the toolchains.mirror.toulan.fun mirror")
 
 	return getCmd
 }
 
+// NewWorkflowBrowseCmd creates the workflow browse subcommand
+func NewWorkflowBrowseCmd() *cobra.Command {
+	var pick string
+	var force bool
+
+	browseCmd := &cobra.Command{
+		Use:   "browse [<catalog-url>]",
+		Short: "Browse a workflow catalog and download a workflow from it",
+		Long: `List the workflows in a catalog and pick one to download.
+
+A catalog is a JSON index hosted on an allowed workflow source:
+
+  {"workflows": [{"name": "...", "description": "...", "url": "https://..."}]}
+
+The catalog URL defaults to the workflow_catalog_url config value.
+
+Examples:
+  amo config workflow_catalog_url https://github.com/user/repo/blob/main/catalog.json
+  amo workflow browse                   # List and pick interactively
+  amo workflow browse --pick 2          # Download the second entry
+  amo workflow browse <url> --pick name # Download the entry with this name`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return browseCatalog(cmd, args, pick, force)
+		},
+	}
+
+	browseCmd.Flags().StringVar(&pick, "pick", "", "Entry number or name to download without prompting")
+	browseCmd.Flags().BoolVar(&force, "force", false, "Download even if the workflow would shadow an embedded one")
+
+	return browseCmd
+}
+
 // NewWorkflowSourceCmd creates the workflow source subcommand group
 func NewWorkflowSourceCmd() *cobra.Command {
 	sourceCmd := &cobra.Command{
@@ -84,6 +176,7 @@ func NewWorkflowSourceCmd() *cobra.Command {
 	sourceCmd.AddCommand(NewWorkflowSourceListCmd())
 	sourceCmd.AddCommand(NewWorkflowSourceAddCmd())
 	sourceCmd.AddCommand(NewWorkflowSourceRmCmd())
+	sourceCmd.AddCommand(NewWorkflowSourceTestCmd())
 
 	return sourceCmd
 }
@@ -118,8 +211,195 @@ func NewWorkflowSourceRmCmd() *cobra.Command {
 	}
 }
 
-// listAllWorkflows lists both user and embedded workflows
-func listAllWorkflows(cmd *cobra.Command, args []string) error {
+// NewWorkflowSourceTestCmd previews how workflow get would treat a URL
+func NewWorkflowSourceTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "test <url>",
+		Short: "Check whether a URL is an allowed workflow source and where it would be downloaded from",
+		Long: `Check a URL the way 'amo workflow get' does, without downloading anything:
+whether it passes the allowed sources and which entry matched, the raw URL a
+GitHub or GitLab blob link is converted to, and the mirror site that GitHub
+downloads fall back to (or try first in the cn region).
+
+Examples:
+  amo workflow source test https://github.com/user/repo/blob/main/convert.js`,
+		Args: cobra.ExactArgs(1),
+		RunE: testWorkflowSource,
+	}
+}
+
+// NewWorkflowLintCmd creates the workflow lint subcommand
+func NewWorkflowLintCmd() *cobra.Command {
+	var jsonOutput bool
+
+	lintCmd := &cobra.Command{
+		Use:   "lint <file>",
+		Short: "Check a workflow script for common mistakes",
+		Long: `Check a workflow script for common mistakes without running it.
+
+Rules:
+- syntax               script does not parse (error)
+- missing-header       script does not start with //!amo (error)
+- unguarded-remove     fs.remove without a prior fs.exists check on the same path (warning)
+- command-not-allowed  cliCommand with a command missing from the CLI whitelist (warning)
+- unhandled-result     result of fs.write, fs.copy, ... is discarded (warning)
+- absolute-path        hard-coded absolute path literal (info)
+
+The command exits with an error when any error-severity finding is reported.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lintWorkflow(args[0], jsonOutput)
+		},
+	}
+
+	lintCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print findings as JSON")
+
+	return lintCmd
+}
+
+// lintWorkflow runs the workflow linter on a script and prints its findings
+func lintWorkflow(path string, jsonOutput bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return newUserError("failed to read workflow file %s: %v", path, err)
+	}
+
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+	}
+	allowedCommands, err := environment.LoadAllowedCLICommands()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to load allowed CLI commands: %w", err))
+	}
+	if allowedCommands == nil {
+		allowedCommands = []string{}
+	}
+
+	findings := workflow.LintScript(filepath.Base(path), string(src), allowedCommands)
+
+	errorCount := 0
+	for _, f := range findings {
+		if f.Severity == workflow.SeverityError {
+			errorCount++
+		}
+	}
+
+	if jsonOutput {
+		if findings == nil {
+			findings = []workflow.LintFinding{}
+		}
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return newRuntimeError(fmt.Errorf("failed to encode findings: %w", err))
+		}
+		fmt.Println(string(data))
+	} else if len(findings) == 0 {
+		fmt.Printf("✅ %s: no problems found\n", path)
+	} else {
+		for _, f := range findings {
+			fmt.Printf("%s:%d:%d: %s [%s] %s\n", path, f.Line, f.Column, f.Severity, f.Rule, f.Message)
+		}
+		fmt.Printf("\n%d problem(s), %d error(s)\n", len(findings), errorCount)
+	}
+
+	if errorCount > 0 {
+		return newUserError("workflow lint found %d error(s) in %s", errorCount, path)
+	}
+	return nil
+}
+
+// NewWorkflowValidateCmd creates the workflow validate subcommand
+func NewWorkflowValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate <name>",
+		Short: "Check which cliCommand calls the CLI whitelist would block",
+		Long: `Check a workflow's cliCommand calls against the allowed CLI commands list
+without running it. The workflow is looked up like amo run does: a file path,
+the workflows directories, then the embedded workflows.
+
+Commands passed as variables instead of string literals cannot be checked and
+are listed separately. The command exits with an error when the whitelist is
+enabled and any call would be blocked.
+
+Examples:
+  amo workflow validate ./deploy.js
+  amo workflow validate tools/convert`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateWorkflow(cmd.Context(), args[0])
+		},
+	}
+}
+
+// validateWorkflow reports the cliCommand calls in a workflow that the CLI
+// whitelist would block at runtime
+func validateWorkflow(ctx context.Context, name string) error {
+	engine := workflow.NewEngine(ctx)
+	if AssetManager != nil {
+		engine.SetAssetReader(AssetManager)
+	}
+	src, resolvedName, err := engine.LoadWorkflowSource(name)
+	if err != nil {
+		return newUserError("%v", err)
+	}
+
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to create environment: %w", err))
+	}
+	allowedCommands, err := environment.LoadAllowedCLICommands()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to load allowed CLI commands: %w", err))
+	}
+
+	calls, err := workflow.FindCLICommands(filepath.Base(resolvedName), src, allowedCommands)
+	if err != nil {
+		return newUserError("%v", err)
+	}
+
+	whitelistEnabled := true
+	if manager, err := config.NewManager(); err == nil {
+		whitelistEnabled = manager.GetBool(config.KeySecurityWhitelistEnabled)
+	}
+
+	var blocked, dynamic []workflow.CLICommandCall
+	for _, call := range calls {
+		switch {
+		case call.Command == "":
+			dynamic = append(dynamic, call)
+		case !call.Allowed:
+			blocked = append(blocked, call)
+		}
+	}
+
+	fmt.Printf("🔍 %s: %d cliCommand call(s)\n", resolvedName, len(calls))
+	if !whitelistEnabled {
+		fmt.Printf("⚠️  The CLI whitelist is disabled (%s), so nothing is blocked right now; the results below apply once it is enabled\n", config.KeySecurityWhitelistEnabled)
+	}
+	for _, call := range blocked {
+		fmt.Printf("❌ %s:%d:%d: '%s' is not in the allowed CLI commands list (allow it with: amo tool permission add %s)\n",
+			resolvedName, call.Line, call.Column, call.Command, call.Command)
+	}
+	for _, call := range dynamic {
+		fmt.Printf("❔ %s:%d:%d: command is not a string literal and can only be checked at runtime\n",
+			resolvedName, call.Line, call.Column)
+	}
+
+	switch {
+	case len(blocked) == 0:
+		fmt.Printf("✅ All statically known commands are allowed\n")
+	case whitelistEnabled:
+		return newUserError("%d cliCommand call(s) in %s would be blocked by the CLI whitelist", len(blocked), resolvedName)
+	default:
+		fmt.Printf("⚠️  %d cliCommand call(s) would be blocked once the whitelist is enabled\n", len(blocked))
+	}
+	return nil
+}
+
+// listAllWorkflows lists both user and embedded workflows, and with
+// showSource where each name resolves to
+func listAllWorkflows(cmd *cobra.Command, showSource bool) error {
 	// Get the workflow downloader
 	downloader, err := workflow.NewWorkflowDownloader()
 	if err != nil {
@@ -136,6 +416,9 @@ func listAllWorkflows(cmd *cobra.Command, args []string) error {
 	fmt.Println("📋 Available workflow files:")
 	fmt.Println("==========================")
 
+	// Every listed name, for --show-source
+	listed := make(map[string]bool)
+
 	// A function to list workflows from a specific directory
 	listWorkflowsFromDir := func(dir string, label string) error {
 		// Check if directory exists
@@ -143,27 +426,37 @@ func listAllWorkflows(cmd *cobra.Command, args []string) error {
 			return nil // Directory doesn't exist, nothing to list
 		}
 
+		// Paths matched by the directory's .amoignore are left out
+		ignore, err := workflow.LoadIgnoreFile(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read ignore file in %s: %w", dir, err)
+		}
+
 		// List workflows in the directory (including subdirectories)
 		var workflows []string
 
 		// Walk through all files recursively
-		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// Get relative path from the workflows directory
+			relPath, err := filepath.Rel(dir, path)
 			if err != nil {
 				return err
 			}
 
-			// Skip directories themselves
+			// Skip directories themselves, and everything in ignored ones
 			if info.IsDir() {
+				if relPath != "." && ignore.Ignored(relPath, true) {
+					return filepath.SkipDir
+				}
 				return nil
 			}
 
 			// Check if it's a JS file
-			if strings.HasSuffix(strings.ToLower(info.Name()), ".js") {
-				// Get relative path from the workflows directory
-				relPath, err := filepath.Rel(dir, path)
-				if err != nil {
-					return err
-				}
+			if strings.HasSuffix(strings.ToLower(info.Name()), ".js") && !ignore.Ignored(relPath, false) {
 				workflows = append(workflows, relPath)
 			}
 			return nil
@@ -178,6 +471,7 @@ func listAllWorkflows(cmd *cobra.Command, args []string) error {
 			// Sort the workflows for consistent output
 			sort.Strings(workflows)
 			for _, wf := range workflows {
+				listed[filepath.ToSlash(wf)] = true
 				// For files in subdirectories, use a different prefix
 				if strings.Contains(wf, string(filepath.Separator)) {
 					// Show subfolder structure with a different icon
@@ -209,6 +503,9 @@ func listAllWorkflows(cmd *cobra.Command, args []string) error {
 	// 3. List embedded workflows
 	if AssetManager == nil {
 		fmt.Println("No embedded workflows available")
+		if showSource {
+			printWorkflowSources(cmd.Context(), listed)
+		}
 		return nil
 	}
 
@@ -220,14 +517,22 @@ func listAllWorkflows(cmd *cobra.Command, args []string) error {
 	if len(workflows) > 0 {
 		fmt.Println("📦 Embedded workflows:")
 		for _, wf := range workflows {
+			listed[wf] = true
 			fmt.Printf("  - %s\n", wf)
 		}
 		fmt.Println()
 	} else {
 		fmt.Println("No embedded workflows found")
+		if showSource {
+			printWorkflowSources(cmd.Context(), listed)
+		}
 		return nil
 	}
 
+	if showSource {
+		printWorkflowSources(cmd.Context(), listed)
+	}
+
 	fmt.Println("📌 Usage: amo run <workflow-name>")
 	if len(workflows) > 0 {
 		fmt.Printf("Example: amo run %s\n", workflows[0])
@@ -241,12 +546,98 @@ func listAllWorkflows(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// downloadWorkflow downloads a workflow from the given URL
-func downloadWorkflow(url, filename string) error {
+// printWorkflowSources shows, for each name, the copy amo run would use and
+// the copies it shadows
+func printWorkflowSources(ctx context.Context, names map[string]bool) {
+	if len(names) == 0 {
+		return
+	}
+
+	engine := workflow.NewEngine(ctx)
+	if AssetManager != nil {
+		engine.SetAssetReader(AssetManager)
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	fmt.Println("🔎 Workflow sources (first match runs):")
+	for _, name := range sorted {
+		locations, err := engine.ResolveWorkflowSource(name)
+		if err != nil {
+			fmt.Printf("  - %s → ⚠️ %v\n", name, err)
+			continue
+		}
+		fmt.Printf("  - %s → %s: %s\n", name, locations[0].Kind, locations[0].Origin)
+		for _, shadowed := range locations[1:] {
+			fmt.Printf("      shadows %s: %s\n", shadowed.Kind, shadowed.Origin)
+		}
+	}
+	fmt.Println()
+}
+
+// checkEmbeddedShadowing warns when a download into the default workflows
+// directory has the name of an embedded workflow, since amo run would then
+// pick the download over the built-in copy. Without force the download is
+// refused.
+func checkEmbeddedShadowing(url, filename, targetDir string, force bool, options downloadOptions) error {
+	// Only the default directory is searched ahead of the embedded workflows
+	if targetDir != "" || AssetManager == nil {
+		return nil
+	}
+	downloader, err := workflow.NewWorkflowDownloader()
+	if err != nil {
+		return nil
+	}
+	options.apply(downloader)
+	name, err := downloader.TargetFilename(url, filename)
+	if err != nil {
+		// The download reports the problem itself
+		return nil
+	}
+	embedded, err := AssetManager.GetWorkflowFileNames()
+	if err != nil {
+		return nil
+	}
+
+	for _, builtin := range embedded {
+		if name != builtin {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  %s has the name of an embedded workflow; once downloaded, amo run %s runs the downloaded copy instead of the built-in one\n", name, name)
+		if !force {
+			return newUserError("refusing to shadow the embedded workflow %s; use --force to download it anyway, or --filename to save it under another name", name)
+		}
+		return nil
+	}
+	return nil
+}
+
+// downloadOptions are the workflow get flags that configure the downloader
+type downloadOptions struct {
+	// noMirror disables the mirror fallback for GitHub URLs
+	noMirror bool
+	// prefix and suffix wrap the name taken from the URL
+	prefix string
+	suffix string
+}
+
+func (o downloadOptions) apply(downloader *workflow.WorkflowDownloader) {
+	downloader.SetNoMirror(o.noMirror)
+	downloader.SetNameAffixes(o.prefix, o.suffix)
+}
+
+// downloadWorkflow downloads a workflow into targetDir, or into the default
+// workflows directory when targetDir is empty
+func downloadWorkflow(url, filename, targetDir string, options downloadOptions) error {
 	downloader, err := workflow.NewWorkflowDownloader()
 	if err != nil {
 		return fmt.Errorf("failed to initialize workflow downloader: %w", err)
 	}
+	options.apply(downloader)
 
 	fmt.Printf("Downloading workflow from: %s\n", url)
 
@@ -254,30 +645,116 @@ func downloadWorkflow(url, filename string) error {
 		fmt.Printf("Saving as: %s\n", filename)
 	}
 
-	err = downloader.DownloadWorkflow(url, filename)
+	err = downloader.DownloadWorkflowTo(url, filename, targetDir)
 	if err != nil {
 		return fmt.Errorf("failed to download workflow: %w", err)
 	}
 
 	// Determine the actual filename used
-	actualFilename := filename
-	if actualFilename == "" {
-		actualFilename, err = downloader.ExtractFilename(url)
-		if err != nil {
-			actualFilename = "workflow.js" // fallback
-		}
+	actualFilename, err := downloader.TargetFilename(url, filename)
+	if err != nil {
+		actualFilename = "workflow.js" // fallback
 	}
 
-	// Always use the default workflows directory for downloads
-	targetDir := downloader.GetWorkflowsDir()
+	// Without --to, downloads go to the default workflows directory, which
+	// amo run searches by name
+	if targetDir == "" {
+		workflowPath := filepath.Join(downloader.GetWorkflowsDir(), actualFilename)
+		fmt.Printf("✅ Workflow downloaded successfully to: %s\n", workflowPath)
+		fmt.Printf("Run with: amo run %s\n", actualFilename)
+		return nil
+	}
 
 	workflowPath := filepath.Join(targetDir, actualFilename)
 	fmt.Printf("✅ Workflow downloaded successfully to: %s\n", workflowPath)
-	fmt.Printf("Run with: amo run %s\n", actualFilename)
+	fmt.Printf("Run with: amo run %s\n", workflowPath)
+
+	return nil
+}
+
+// browseCatalog lists catalog entries and downloads the chosen one
+func browseCatalog(cmd *cobra.Command, args []string, pick string, force bool) error {
+	catalogURL := ""
+	if len(args) > 0 {
+		catalogURL = args[0]
+	} else {
+		manager, err := config.NewManager()
+		if err != nil {
+			return newInfraError(fmt.Errorf("failed to initialize config manager: %w", err))
+		}
+		catalogURL = manager.GetString(config.KeyWorkflowCatalogURL)
+	}
+	if strings.TrimSpace(catalogURL) == "" {
+		return newUserError("no catalog URL given; pass one or set it with: amo config %s <url>", config.KeyWorkflowCatalogURL)
+	}
+
+	downloader, err := workflow.NewWorkflowDownloader()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to initialize workflow downloader: %w", err))
+	}
+
+	entries, err := downloader.FetchCatalog(catalogURL)
+	if err != nil {
+		return newInfraError(err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("The catalog has no workflows")
+		return nil
+	}
+
+	fmt.Printf("📚 Workflows in %s:\n", catalogURL)
+	for i, entry := range entries {
+		fmt.Printf("%3d. %s", i+1, entry.Name)
+		if entry.Description != "" {
+			fmt.Printf(" - %s", entry.Description)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	if pick == "" {
+		fmt.Printf("Select a workflow to download (1-%d, empty to cancel): ", len(entries))
+		pick, err = bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return newInfraError(fmt.Errorf("failed to read selection: %w", err))
+		}
+		pick = strings.TrimSpace(pick)
+		if pick == "" {
+			fmt.Println("\nNo workflow selected")
+			return nil
+		}
+	}
+
+	entry, ok := selectCatalogEntry(entries, pick)
+	if !ok {
+		return newUserError("no catalog entry matches %q", pick)
+	}
 
+	if err := checkEmbeddedShadowing(entry.URL, entry.Filename, "", force, downloadOptions{}); err != nil {
+		return err
+	}
+	if err := downloadWorkflow(entry.URL, entry.Filename, "", downloadOptions{}); err != nil {
+		return newInfraError(err)
+	}
 	return nil
 }
 
+// selectCatalogEntry finds an entry by 1-based number or by name
+func selectCatalogEntry(entries []workflow.CatalogEntry, pick string) (workflow.CatalogEntry, bool) {
+	if n, err := strconv.Atoi(pick); err == nil {
+		if n >= 1 && n <= len(entries) {
+			return entries[n-1], true
+		}
+		return workflow.CatalogEntry{}, false
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name, pick) {
+			return entry, true
+		}
+	}
+	return workflow.CatalogEntry{}, false
+}
+
 // listWorkflowSources lists current workflow download sources
 func listWorkflowSources(cmd *cobra.Command, args []string) error {
 	downloader, err := workflow.NewWorkflowDownloader()
@@ -307,9 +784,9 @@ func listWorkflowSources(cmd *cobra.Command, args []string) error {
 
 // addWorkflowSource adds a source entry
 func addWorkflowSource(cmd *cobra.Command, args []string) error {
-	entry := strings.TrimSpace(args[0])
-	if entry == "" {
-		return newUserError("source cannot be empty")
+	entry, err := workflow.NormalizeSourceEntry(args[0])
+	if err != nil {
+		return newUserError("%v", err)
 	}
 
 	downloader, err := workflow.NewWorkflowDownloader()
@@ -352,3 +829,132 @@ func removeWorkflowSource(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// testWorkflowSource reports the source entry that allows a URL, its raw URL
+// and the download order workflow get would use
+func testWorkflowSource(cmd *cobra.Command, args []string) error {
+	urlStr := strings.TrimSpace(args[0])
+
+	downloader, err := workflow.NewWorkflowDownloader()
+	if err != nil {
+		return newInfraError(fmt.Errorf("failed to initialize workflow downloader: %w", err))
+	}
+
+	plan, err := downloader.PlanDownload(urlStr)
+	if err != nil {
+		return newUserError("%v", err)
+	}
+
+	fmt.Printf("🔍 %s\n", plan.URL)
+	if plan.SourceErr != nil {
+		fmt.Printf("❌ Not allowed: %v\n", plan.SourceErr)
+	} else {
+		fmt.Printf("✅ Allowed by source: %s\n", plan.MatchedSource)
+	}
+
+	if plan.RawURL != plan.URL {
+		fmt.Printf("   Raw URL:    %s\n", plan.RawURL)
+	} else {
+		fmt.Printf("   Raw URL:    unchanged\n")
+	}
+	switch {
+	case plan.MirrorURL == "":
+		fmt.Printf("   Mirror URL: none (only GitHub file URLs have a mirror)\n")
+	case !plan.MirrorAllowed:
+		fmt.Printf("   Mirror URL: %s (skipped, not an allowed source)\n", plan.MirrorURL)
+	default:
+		fmt.Printf("   Mirror URL: %s\n", plan.MirrorURL)
+	}
+
+	if plan.SourceErr != nil {
+		if parsed, parseErr := url.Parse(urlStr); errors.Is(plan.SourceErr, network.ErrURLNotAllowed) && parseErr == nil {
+			fmt.Println()
+			fmt.Printf("💡 Allow it with: amo workflow source add %s\n", strings.ToLower(parsed.Hostname()))
+		}
+		return newUserError("%s is not an allowed workflow source: %w", urlStr, plan.SourceErr)
+	}
+
+	fmt.Println("   Download order:")
+	for i, source := range plan.Sources {
+		fmt.Printf("     %d. %s\n", i+1, source)
+	}
+	return nil
+}
+
+// NewWorkflowInfoCmd creates the workflow info subcommand
+func NewWorkflowInfoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info <name>",
+		Short: "Show a workflow's header metadata and where it was found",
+		Long: `Show the metadata a workflow declares in the comment block after //!amo,
+and the file (or embedded workflow) that amo run would use:
+
+  //!amo
+  // @name Video to audio
+  // @description Extract the audio track of every video in a folder
+  // @requires ffmpeg
+  // @var input Folder with the videos
+  // @var format=mp3 Output format
+  // @var? title Title tag for the output files
+
+Without @description the first comment line is shown.
+
+Examples:
+  amo workflow info ./convert.js
+  amo workflow info hash-demo`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showWorkflowInfo(cmd.Context(), args[0])
+		},
+	}
+}
+
+// showWorkflowInfo prints the parsed header of a workflow
+func showWorkflowInfo(ctx context.Context, name string) error {
+	engine := workflow.NewEngine(ctx)
+	if AssetManager != nil {
+		engine.SetAssetReader(AssetManager)
+	}
+	found, err := engine.FindWorkflow(name)
+	if err != nil {
+		return newUserError("%v", err)
+	}
+
+	header, err := workflow.ParseWorkflowHeader(found.Source)
+	if err != nil {
+		return newUserError("%s: %v", found.Origin, err)
+	}
+
+	title := header.Name
+	if title == "" {
+		title = found.Name
+	}
+	fmt.Printf("📄 %s\n", title)
+	if header.Description != "" {
+		fmt.Printf("   %s\n", header.Description)
+	}
+	fmt.Println()
+	fmt.Printf("📂 Resolved from: %s\n", found.Origin)
+
+	if len(header.Requires) > 0 {
+		fmt.Printf("🔧 Requires: %s\n", strings.Join(header.Requires, ", "))
+	}
+	if len(header.Vars) > 0 {
+		fmt.Println("📋 Variables:")
+		for _, v := range header.Vars {
+			usage := fmt.Sprintf("--var %s=...", v.Name)
+			switch {
+			case v.HasDefault:
+				usage = fmt.Sprintf("[--var %s=...] (default %q)", v.Name, v.Default)
+			case v.Optional:
+				usage = fmt.Sprintf("[--var %s=...]", v.Name)
+			}
+			if v.Description != "" {
+				fmt.Printf("   %s  %s\n", usage, v.Description)
+			} else {
+				fmt.Printf("   %s\n", usage)
+			}
+		}
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package cli
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
 	"strings"
 )
 
@@ -15,3 +20,134 @@ func ParseVars(vars []string) map[string]string {
 	}
 	return result
 }
+
+// LoadEnvFile reads variables from a dotenv file
+func LoadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file: %w", err)
+	}
+	defer file.Close()
+
+	vars, err := ParseEnvFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// ParseEnvFile parses KEY=VALUE lines in dotenv format. Blank lines and lines
+// starting with # are ignored, an optional "export " prefix is accepted,
+// double-quoted values support \n, \t, \" and \\ escapes, single-quoted values
+// are taken literally, and unquoted values end at a " #" comment.
+func ParseEnvFile(r io.Reader) (map[string]string, error) {
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		key := strings.TrimSpace(parts[0])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: invalid key %q", lineNo, key)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		result[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parseEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	quote := raw[0]
+	if quote != '"' && quote != '\'' {
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+
+	var value strings.Builder
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		if c == quote {
+			rest := strings.TrimSpace(raw[i+1:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected text after closing quote: %q", rest)
+			}
+			return value.String(), nil
+		}
+		if c == '\\' && quote == '"' && i+1 < len(raw) {
+			i++
+			switch raw[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			default:
+				value.WriteByte(raw[i])
+			}
+			continue
+		}
+		value.WriteByte(c)
+	}
+	return "", fmt.Errorf("unterminated %c-quoted value", quote)
+}
+
+// LoadVarJSON reads structured variables from a JSON file whose top level
+// must be an object
+func LoadVarJSON(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read var file: %w", err)
+	}
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", path, err)
+	}
+	if vars == nil {
+		return nil, fmt.Errorf("%s: top-level value must be a JSON object", path)
+	}
+	return vars, nil
+}
+
+// VarString renders a structured variable for getVar: strings as-is,
+// numbers and booleans in their JSON form, arrays and objects as JSON text
+func VarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
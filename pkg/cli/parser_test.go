@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"",
+		"PLAIN=value",
+		"export EXPORTED=yes",
+		"SPACED = padded ",
+		"INLINE=value # trailing comment",
+		"HASH=a#b",
+		`DOUBLE="hello # world"`,
+		`ESCAPED="line1\nline2 \"q\""`,
+		`SINGLE='raw\n $HOME'`,
+		"EMPTY=",
+		"EQUALS=a=b=c",
+	}, "\n")
+
+	vars, err := ParseEnvFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseEnvFile failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "yes",
+		"SPACED":   "padded",
+		"INLINE":   "value",
+		"HASH":     "a#b",
+		"DOUBLE":   "hello # world",
+		"ESCAPED":  "line1\nline2 \"q\"",
+		"SINGLE":   `raw\n $HOME`,
+		"EMPTY":    "",
+		"EQUALS":   "a=b=c",
+	}
+	if len(vars) != len(expected) {
+		t.Errorf("Parsed %d vars; expected %d: %v", len(vars), len(expected), vars)
+	}
+	for key, want := range expected {
+		if got, ok := vars[key]; !ok || got != want {
+			t.Errorf("%s = %q (present: %v); expected %q", key, got, ok, want)
+		}
+	}
+}
+
+func TestParseEnvFileErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Missing equals", "JUSTAKEY"},
+		{"Empty key", "=value"},
+		{"Key with space", "MY KEY=value"},
+		{"Unterminated quote", `KEY="value`},
+		{"Text after quote", `KEY="value" extra`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseEnvFile(strings.NewReader(tc.input)); err == nil {
+				t.Errorf("Expected error for %q", tc.input)
+			}
+		})
+	}
+}
+
+func TestLoadVarJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vars.json")
+	if err := os.WriteFile(path, []byte(`{"files": ["a", "b"], "count": 2, "debug": true, "name": "x"}`), 0644); err != nil {
+		t.Fatalf("Failed to write var file: %v", err)
+	}
+
+	vars, err := LoadVarJSON(path)
+	if err != nil {
+		t.Fatalf("LoadVarJSON failed: %v", err)
+	}
+
+	expected := map[string]string{"files": `["a","b"]`, "count": "2", "debug": "true", "name": "x"}
+	for key, want := range expected {
+		if got := VarString(vars[key]); got != want {
+			t.Errorf("VarString(%s) = %q; expected %q", key, got, want)
+		}
+	}
+
+	for _, content := range []string{`["a"]`, `null`, `{bad`} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write var file: %v", err)
+		}
+		if _, err := LoadVarJSON(path); err == nil {
+			t.Errorf("Expected error for %s", content)
+		}
+	}
+}
@@ -1,26 +1,98 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"amo/pkg/env"
+	"amo/pkg/filesystem"
 
 	"github.com/spf13/viper"
 )
 
 const (
 	ConfigFileName = "config.yaml"
+
+	// EnvConfigFile names an alternative config file, like --config-file
+	EnvConfigFile = "AMO_CONFIG"
+
+	// EnvGitHubToken overrides the github_token config value
+	EnvGitHubToken = "GITHUB_TOKEN"
 )
 
+// configFileOverride is set from the --config-file flag and wins over AMO_CONFIG
+var configFileOverride string
+
+// SetConfigFile makes every Manager created afterwards use path as its config
+// file. An empty path restores the AMO_CONFIG / default lookup.
+func SetConfigFile(path string) {
+	configFileOverride = path
+}
+
+// resolveConfigFile returns the config file to use and the profile it belongs
+// to: --config-file, then AMO_CONFIG (both without a profile), then the active
+// profile's file in the user config directory
+func resolveConfigFile(environment *env.Environment) (string, string, error) {
+	path := configFileOverride
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	if path != "" {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid config file path %s: %w", path, err)
+		}
+		return absPath, "", nil
+	}
+
+	configDir := environment.GetUserConfigDir()
+	profile, err := ActiveProfile(configDir)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(configDir, ProfileConfigFileName(profile)), profile, nil
+}
+
 const (
 	KeyWorkflowDir                        = "workflows"
 	KeyNetworkDialTimeoutSeconds          = "network_dial_timeout_seconds"
 	KeyNetworkTLSHandshakeTimeoutSeconds  = "network_tls_handshake_timeout_seconds"
 	KeyNetworkResponseHeaderTimeoutSecond = "network_response_header_timeout_seconds"
 	KeyNetworkIdleTimeoutSeconds          = "network_idle_timeout_seconds"
+	KeyNetworkDownloadBufferKB            = "network_download_buffer_kb"
 	KeySecurityWhitelistEnabled           = "security_cli_whitelist_enabled"
+	KeyGitHubToken                        = "github_token"
+	KeyWorkflowEnvAllowlist               = "workflow_env_allowlist"
+	KeySecurityFSSandboxRoot              = "security_fs_sandbox_root"
+	KeySecurityFSRoots                    = "security_fs_roots"
+	KeyWorkflowCatalogURL                 = "workflow_catalog_url"
+	KeyNetworkAllowPrivate                = "network_allow_private"
+	KeyNetworkUserAgent                   = "network_user_agent"
+	KeyNetworkMinIntervalMs               = "network_min_interval_ms"
+	KeyWorkflowDefaultTimeoutSeconds      = "workflow_default_timeout_seconds"
+	KeyWorkflowMaxTimeoutSeconds          = "workflow_max_timeout_seconds"
+	KeyWorkflowMaxScriptSizeKB            = "workflow_max_script_size_kb"
+	KeyRegion                             = "region"
+)
+
+// DefaultWorkflowEnvAllowlist lists the environment variables workflows may read
+// through env.get/env.getAll unless workflow_env_allowlist overrides it.
+// Entries are comma separated; a trailing * matches a name prefix.
+const DefaultWorkflowEnvAllowlist = "HOME,USERPROFILE,USER,USERNAME,LANG,PATH,TEMP,TMP,TMPDIR,APPDATA,LOCALAPPDATA,XDG_*,PROCESSOR_ARCHITECTURE,PROCESSOR_ARCHITEW6432"
+
+// DefaultWorkflowMaxScriptSizeKB is the largest workflow script amo loads or
+// downloads unless KeyWorkflowMaxScriptSizeKB changes it (0 = no limit)
+const DefaultWorkflowMaxScriptSizeKB = 4096
+
+// Accepted range (in KB) for KeyNetworkDownloadBufferKB
+const (
+	MinNetworkDownloadBufferKB = 4
+	MaxNetworkDownloadBufferKB = 1024
 )
 
 var DefaultConfig = map[string]interface{}{
@@ -29,7 +101,20 @@ var DefaultConfig = map[string]interface{}{
 	KeyNetworkTLSHandshakeTimeoutSeconds:  15,
 	KeyNetworkResponseHeaderTimeoutSecond: 60,
 	KeyNetworkIdleTimeoutSeconds:          300,
+	KeyNetworkDownloadBufferKB:            32,
 	KeySecurityWhitelistEnabled:           false,
+	KeyGitHubToken:                        "",
+	KeyWorkflowEnvAllowlist:               DefaultWorkflowEnvAllowlist,
+	KeySecurityFSSandboxRoot:              "",
+	KeySecurityFSRoots:                    "",
+	KeyWorkflowCatalogURL:                 "",
+	KeyNetworkAllowPrivate:                false,
+	KeyNetworkUserAgent:                   "",
+	KeyNetworkMinIntervalMs:               0,
+	KeyWorkflowDefaultTimeoutSeconds:      0,
+	KeyWorkflowMaxTimeoutSeconds:          0,
+	KeyWorkflowMaxScriptSizeKB:            DefaultWorkflowMaxScriptSizeKB,
+	KeyRegion:                             "",
 }
 
 type Manager struct {
@@ -37,6 +122,7 @@ type Manager struct {
 	environment   *env.Environment
 	configDir     string
 	configFile    string
+	profile       string
 	isInitialized bool
 }
 
@@ -46,8 +132,11 @@ func NewManager() (*Manager, error) {
 		return nil, fmt.Errorf("failed to initialize environment: %w", err)
 	}
 
-	configDir := environment.GetUserConfigDir()
-	configFile := filepath.Join(configDir, ConfigFileName)
+	configFile, profile, err := resolveConfigFile(environment)
+	if err != nil {
+		return nil, err
+	}
+	configDir := filepath.Dir(configFile)
 
 	v := viper.New()
 	v.SetConfigFile(configFile)
@@ -58,6 +147,7 @@ func NewManager() (*Manager, error) {
 		environment: environment,
 		configDir:   configDir,
 		configFile:  configFile,
+		profile:     profile,
 	}
 
 	return manager, nil
@@ -96,7 +186,7 @@ func (m *Manager) Initialize() error {
 	}
 
 	// Save to ensure defaults are written
-	if err := m.viper.WriteConfig(); err != nil {
+	if err := m.writeConfig(); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -104,17 +194,116 @@ func (m *Manager) Initialize() error {
 	return nil
 }
 
+// writeConfig saves the config file atomically, so an interrupted write
+// leaves the previous config in place instead of a truncated one
+func (m *Manager) writeConfig() error {
+	var buf bytes.Buffer
+	if err := m.viper.WriteConfigTo(&buf); err != nil {
+		return err
+	}
+	return filesystem.NewFileSystem().WriteFileAtomic(m.configFile, buf.String())
+}
+
+// GetConfigFile returns the config file of the active profile, or the
+// --config-file / AMO_CONFIG override
 func (m *Manager) GetConfigFile() string {
 	return m.configFile
 }
 
+// GetAllowedHostsFile returns the network whitelist of the active profile in
+// the user config directory; an explicit config file uses allowed_hosts.txt
+func (m *Manager) GetAllowedHostsFile() string {
+	return filepath.Join(m.environment.GetUserConfigDir(), AllowedHostsFileName(m.profile))
+}
+
+// GetProfile returns the active profile, or "" when an explicit config file is in use
+func (m *Manager) GetProfile() string {
+	return m.profile
+}
+
+// Set validates and saves a value. String values are converted with CoerceValue.
 func (m *Manager) Set(key string, value interface{}) error {
 	if err := m.Initialize(); err != nil {
 		return err
 	}
 
+	if raw, ok := value.(string); ok {
+		coerced, err := CoerceValue(key, raw)
+		if err != nil {
+			return err
+		}
+		value = coerced
+	}
+	if err := ValidateValue(key, value); err != nil {
+		return err
+	}
+
 	m.viper.Set(key, value)
-	return m.viper.WriteConfig()
+	return m.writeConfig()
+}
+
+// CoerceValue converts a value given on the command line to the type of the
+// key's default, so numeric and boolean settings are not stored as strings
+func CoerceValue(key, raw string) (interface{}, error) {
+	defaultValue, exists := DefaultConfig[key]
+	if !exists {
+		return nil, fmt.Errorf("invalid configuration key: %s", key)
+	}
+
+	raw = strings.TrimSpace(raw)
+	switch defaultValue.(type) {
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an integer, got %q", key, raw)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %d", key, n)
+		}
+		return n, nil
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", key, raw)
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+// ValidateValue rejects values outside the range supported by a key
+func ValidateValue(key string, value interface{}) error {
+	switch key {
+	case KeyNetworkDownloadBufferKB:
+		kb, err := strconv.Atoi(fmt.Sprint(value))
+		if err != nil {
+			return fmt.Errorf("%s must be an integer: %v", key, value)
+		}
+		if kb < MinNetworkDownloadBufferKB || kb > MaxNetworkDownloadBufferKB {
+			return fmt.Errorf("%s must be between %d and %d, got %d", key, MinNetworkDownloadBufferKB, MaxNetworkDownloadBufferKB, kb)
+		}
+	case KeyRegion:
+		region := strings.ToLower(strings.TrimSpace(fmt.Sprint(value)))
+		if region != "" && region != "global" && env.GetRegionConfig(region) == nil {
+			return fmt.Errorf("%s must be global or a region code such as cn, us or eu, got %q", key, value)
+		}
+	}
+	return nil
+}
+
+// ResolveWorkflowTimeout returns the timeout in seconds a workflow runs with.
+// Without an explicit --timeout the configured default applies; a non-zero
+// maximum caps the result, including "unlimited" (0). capped reports whether
+// the maximum lowered the timeout.
+func ResolveWorkflowTimeout(requested int, explicit bool, defaultSeconds, maxSeconds int) (timeout int, capped bool) {
+	timeout = requested
+	if !explicit {
+		timeout = defaultSeconds
+	}
+	if maxSeconds > 0 && (timeout == 0 || timeout > maxSeconds) {
+		return maxSeconds, true
+	}
+	return timeout, false
 }
 
 func (m *Manager) Get(key string) interface{} {
@@ -162,7 +351,72 @@ func (m *Manager) Unset(key string) error {
 
 	// Set to default value
 	m.viper.Set(key, defaultValue)
-	return m.viper.WriteConfig()
+	return m.writeConfig()
+}
+
+// MigrationReport lists what Migrate changed in a config file
+type MigrationReport struct {
+	// Removed are keys in the file that are no longer supported
+	Removed []string
+	// Added are supported keys the file lacked, written with their defaults
+	Added []string
+}
+
+// Changed reports whether the migration rewrote the config file
+func (r MigrationReport) Changed() bool {
+	return len(r.Removed) > 0 || len(r.Added) > 0
+}
+
+// Migrate rewrites the config file to hold exactly the keys of DefaultConfig:
+// unknown keys are dropped, missing keys get their defaults and values the
+// user set are kept. The file is only written when something changes. Call it
+// on a fresh Manager, before anything initializes it (which adds defaults).
+func (m *Manager) Migrate() (MigrationReport, error) {
+	var report MigrationReport
+
+	current := viper.New()
+	current.SetConfigFile(m.configFile)
+	current.SetConfigType("yaml")
+	if _, err := os.Stat(m.configFile); err == nil {
+		if err := current.ReadInConfig(); err != nil {
+			return report, fmt.Errorf("failed to read config file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return report, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	for _, key := range current.AllKeys() {
+		if !m.IsValidKey(key) {
+			report.Removed = append(report.Removed, key)
+		}
+	}
+	sort.Strings(report.Removed)
+
+	migrated := viper.New()
+	migrated.SetConfigFile(m.configFile)
+	migrated.SetConfigType("yaml")
+	for _, key := range m.GetValidKeys() {
+		if current.IsSet(key) {
+			migrated.Set(key, current.Get(key))
+		} else {
+			report.Added = append(report.Added, key)
+			migrated.Set(key, DefaultConfig[key])
+		}
+	}
+
+	if !report.Changed() {
+		return report, nil
+	}
+
+	if err := os.MkdirAll(m.configDir, 0755); err != nil {
+		return report, fmt.Errorf("failed to create config directory: %w", err)
+	}
+	m.viper = migrated
+	m.isInitialized = false
+	if err := m.writeConfig(); err != nil {
+		return report, fmt.Errorf("failed to write config file: %w", err)
+	}
+	return report, nil
 }
 
 // GetAll returns all configuration values
@@ -180,12 +434,30 @@ func (m *Manager) IsValidKey(key string) bool {
 	return exists
 }
 
+// IsSecretKey reports whether a key holds a credential that should not be printed
+func IsSecretKey(key string) bool {
+	return key == KeyGitHubToken
+}
+
+// GitHubToken returns the GitHub API token from GITHUB_TOKEN, or else the
+// github_token value of m. A nil m only consults the environment.
+func GitHubToken(m *Manager) string {
+	if token := strings.TrimSpace(os.Getenv(EnvGitHubToken)); token != "" {
+		return token
+	}
+	if m != nil {
+		return strings.TrimSpace(m.GetString(KeyGitHubToken))
+	}
+	return ""
+}
+
 // GetValidKeys returns a list of all valid configuration keys
 func (m *Manager) GetValidKeys() []string {
 	keys := make([]string, 0, len(DefaultConfig))
 	for key := range DefaultConfig {
 		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewManagerConfigFileOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	envFile := filepath.Join(t.TempDir(), "env", "nested", "amo.yaml")
+	flagFile := filepath.Join(t.TempDir(), "flag", "amo.yaml")
+
+	testCases := []struct {
+		name     string
+		env      string
+		flag     string
+		expected string
+	}{
+		{"Default", "", "", filepath.Join(home, ".amo", ConfigFileName)},
+		{"AMO_CONFIG", envFile, "", envFile},
+		{"Flag wins over AMO_CONFIG", envFile, flagFile, flagFile},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvConfigFile, tc.env)
+			SetConfigFile(tc.flag)
+			defer SetConfigFile("")
+
+			manager, err := NewManager()
+			if err != nil {
+				t.Fatalf("NewManager failed: %v", err)
+			}
+			if got := manager.GetConfigFile(); got != tc.expected {
+				t.Errorf("GetConfigFile() = %q; expected %q", got, tc.expected)
+			}
+
+			// Initialize must create missing parent directories
+			if err := manager.Set(KeyWorkflowDir, "/tmp/wf"); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if _, err := os.Stat(tc.expected); err != nil {
+				t.Errorf("Config file was not created at %s: %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(EnvConfigFile, "")
+	configDir := filepath.Join(home, ".amo")
+
+	if profile, err := ActiveProfile(configDir); err != nil || profile != DefaultProfile {
+		t.Fatalf("ActiveProfile() = %q, %v; expected %q", profile, err, DefaultProfile)
+	}
+
+	if err := SetActiveProfile(configDir, "client-a"); err != nil {
+		t.Fatalf("SetActiveProfile failed: %v", err)
+	}
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if manager.GetProfile() != "client-a" {
+		t.Errorf("GetProfile() = %q; expected client-a", manager.GetProfile())
+	}
+	expectedFile := filepath.Join(configDir, "config.client-a.yaml")
+	if manager.GetConfigFile() != expectedFile {
+		t.Errorf("GetConfigFile() = %q; expected %q", manager.GetConfigFile(), expectedFile)
+	}
+	if err := manager.Set(KeyWorkflowDir, "/client-a/workflows"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	profiles, err := ListProfiles(configDir)
+	if err != nil || len(profiles) != 2 || profiles[0] != DefaultProfile || profiles[1] != "client-a" {
+		t.Errorf("ListProfiles() = %v, %v; expected [default client-a]", profiles, err)
+	}
+
+	// Switching back to default must not see the profile's values
+	if err := SetActiveProfile(configDir, DefaultProfile); err != nil {
+		t.Fatalf("SetActiveProfile(default) failed: %v", err)
+	}
+	manager, err = NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if manager.GetConfigFile() != filepath.Join(configDir, ConfigFileName) {
+		t.Errorf("GetConfigFile() = %q; expected config.yaml", manager.GetConfigFile())
+	}
+	if got := manager.GetString(KeyWorkflowDir); got != "" {
+		t.Errorf("Default profile sees %s = %q; expected it unset", KeyWorkflowDir, got)
+	}
+
+	for _, name := range []string{"", "../evil", "a/b", "-x"} {
+		if err := SetActiveProfile(configDir, name); err == nil {
+			t.Errorf("SetActiveProfile(%q) succeeded; expected an error", name)
+		}
+	}
+}
+
+func TestCoerceValue(t *testing.T) {
+	testCases := []struct {
+		key         string
+		raw         string
+		expected    interface{}
+		expectError bool
+	}{
+		{KeyNetworkDialTimeoutSeconds, "30", 30, false},
+		{KeyNetworkDialTimeoutSeconds, " 30 ", 30, false},
+		{KeyNetworkDialTimeoutSeconds, "30s", nil, true},
+		{KeyNetworkIdleTimeoutSeconds, "-1", nil, true},
+		{KeySecurityWhitelistEnabled, "true", true, false},
+		{KeyNetworkAllowPrivate, "0", false, false},
+		{KeyNetworkAllowPrivate, "maybe", nil, true},
+		{KeyWorkflowDir, "/tmp/wf", "/tmp/wf", false},
+		{"bogus", "1", nil, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := CoerceValue(tc.key, tc.raw)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("CoerceValue(%s, %q) = %v; expected an error", tc.key, tc.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.expected {
+			t.Errorf("CoerceValue(%s, %q) = %v (%T), %v; expected %v", tc.key, tc.raw, got, got, err, tc.expected)
+		}
+	}
+}
+
+func TestSetStoresTypedValues(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(EnvConfigFile, "")
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Set(KeyNetworkDialTimeoutSeconds, "42"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := manager.Set(KeyNetworkDialTimeoutSeconds, "soon"); err == nil {
+		t.Error("Expected an error for a non-integer timeout")
+	}
+
+	data, err := os.ReadFile(manager.GetConfigFile())
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if !strings.Contains(string(data), KeyNetworkDialTimeoutSeconds+": 42\n") {
+		t.Errorf("Timeout was not stored as an integer:\n%s", data)
+	}
+
+	if err := manager.Unset(KeyNetworkDialTimeoutSeconds); err != nil {
+		t.Fatalf("Unset failed: %v", err)
+	}
+	if got := manager.GetInt(KeyNetworkDialTimeoutSeconds); got != 15 {
+		t.Errorf("After Unset got %d; expected the default 15", got)
+	}
+}
+
+func TestGitHubToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(EnvConfigFile, "")
+	t.Setenv(EnvGitHubToken, "")
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if got := GitHubToken(manager); got != "" {
+		t.Errorf("GitHubToken = %q; expected none", got)
+	}
+	if err := manager.Set(KeyGitHubToken, " from-config "); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := GitHubToken(manager); got != "from-config" {
+		t.Errorf("GitHubToken = %q; expected the config value", got)
+	}
+	if got := GitHubToken(nil); got != "" {
+		t.Errorf("GitHubToken(nil) = %q; expected only the environment to be read", got)
+	}
+
+	t.Setenv(EnvGitHubToken, "from-env")
+	if got := GitHubToken(manager); got != "from-env" {
+		t.Errorf("GitHubToken = %q; expected %s to win over the config", got, EnvGitHubToken)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(EnvConfigFile, "")
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	legacy := "network_dial_timeout_seconds: 42\nold_mirror: https://example.com\nlegacy:\n  nested: true\n"
+	if err := os.MkdirAll(filepath.Dir(manager.GetConfigFile()), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(manager.GetConfigFile(), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	report, err := manager.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if strings.Join(report.Removed, ",") != "legacy.nested,old_mirror" {
+		t.Errorf("Removed = %v; expected [legacy.nested old_mirror]", report.Removed)
+	}
+	if len(report.Added) != len(DefaultConfig)-1 {
+		t.Errorf("Added %d keys; expected %d", len(report.Added), len(DefaultConfig)-1)
+	}
+	for _, key := range report.Added {
+		if key == KeyNetworkDialTimeoutSeconds {
+			t.Errorf("User-set key %s reported as added", key)
+		}
+	}
+
+	data, err := os.ReadFile(manager.GetConfigFile())
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), "old_mirror") || strings.Contains(string(data), "legacy") {
+		t.Errorf("Removed keys still in config:\n%s", data)
+	}
+	if got := manager.GetInt(KeyNetworkDialTimeoutSeconds); got != 42 {
+		t.Errorf("After Migrate got %d; expected the user value 42", got)
+	}
+
+	manager, err = NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	report, err = manager.Migrate()
+	if err != nil {
+		t.Fatalf("Second Migrate failed: %v", err)
+	}
+	if report.Changed() {
+		t.Errorf("Second Migrate changed the config: %+v", report)
+	}
+}
+
+func TestValidateRegion(t *testing.T) {
+	testCases := []struct {
+		value   string
+		wantErr bool
+	}{
+		{"cn", false},
+		{"US", false},
+		{"global", false},
+		{"", false},
+		{"atlantis", true},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateValue(KeyRegion, tc.value); (err != nil) != tc.wantErr {
+			t.Errorf("ValidateValue(%q) = %v; expected error %v", tc.value, err, tc.wantErr)
+		}
+	}
+}
+
+func TestResolveWorkflowTimeout(t *testing.T) {
+	tests := []struct {
+		name                 string
+		requested            int
+		explicit             bool
+		defaultSecs, maxSecs int
+		want                 int
+		wantCapped           bool
+	}{
+		{"nothing configured", 0, false, 0, 0, 0, false},
+		{"default applies without flag", 0, false, 600, 0, 600, false},
+		{"flag overrides default", 30, true, 600, 0, 30, false},
+		{"explicit unlimited without max", 0, true, 600, 0, 0, false},
+		{"flag above max is capped", 7200, true, 0, 3600, 3600, true},
+		{"explicit unlimited is capped", 0, true, 0, 3600, 3600, true},
+		{"default above max is capped", 0, false, 7200, 3600, 3600, true},
+		{"flag below max", 60, true, 0, 3600, 60, false},
+	}
+	for _, tt := range tests {
+		got, capped := ResolveWorkflowTimeout(tt.requested, tt.explicit, tt.defaultSecs, tt.maxSecs)
+		if got != tt.want || capped != tt.wantCapped {
+			t.Errorf("%s: ResolveWorkflowTimeout = %d, %v; want %d, %v", tt.name, got, capped, tt.want, tt.wantCapped)
+		}
+	}
+}
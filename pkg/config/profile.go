@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"amo/pkg/filesystem"
+)
+
+const (
+	// DefaultProfile is the profile stored in config.yaml
+	DefaultProfile = "default"
+
+	// ActiveProfileFileName records the profile selected with `amo config use`
+	ActiveProfileFileName = "active_profile"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ValidateProfileName rejects names that cannot be used in a profile file name
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// ProfileConfigFileName returns the config file name for a profile:
+// config.yaml for the default profile, config.<name>.yaml otherwise
+func ProfileConfigFileName(profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return ConfigFileName
+	}
+	return "config." + profile + ".yaml"
+}
+
+// AllowedHostsFileName returns the network whitelist file for a profile:
+// allowed_hosts.txt for the default profile, allowed_hosts.<name>.txt otherwise
+func AllowedHostsFileName(profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return "allowed_hosts.txt"
+	}
+	return "allowed_hosts." + profile + ".txt"
+}
+
+// ActiveProfile returns the profile recorded in configDir, or DefaultProfile
+// when none has been selected
+func ActiveProfile(configDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, ActiveProfileFileName))
+	if os.IsNotExist(err) {
+		return DefaultProfile, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read active profile: %w", err)
+	}
+
+	profile := strings.TrimSpace(string(data))
+	if profile == "" {
+		return DefaultProfile, nil
+	}
+	if err := ValidateProfileName(profile); err != nil {
+		return "", fmt.Errorf("corrupt %s file: %w", ActiveProfileFileName, err)
+	}
+	return profile, nil
+}
+
+// SetActiveProfile records profile as the active one in configDir.
+// Selecting the default profile removes the state file.
+func SetActiveProfile(configDir, profile string) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
+	}
+
+	statePath := filepath.Join(configDir, ActiveProfileFileName)
+	if profile == DefaultProfile {
+		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset active profile: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := filesystem.NewFileSystem().WriteFileAtomic(statePath, profile+"\n"); err != nil {
+		return fmt.Errorf("failed to save active profile: %w", err)
+	}
+	return nil
+}
+
+// ListProfiles returns the profiles that have a config file in configDir.
+// The default profile is always included.
+func ListProfiles(configDir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(configDir, "config.*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	profiles := []string{DefaultProfile}
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "config."), ".yaml")
+		if name != DefaultProfile && ValidateProfileName(name) == nil {
+			profiles = append(profiles, name)
+		}
+	}
+	sort.Strings(profiles[1:])
+	return profiles, nil
+}
@@ -3,124 +3,188 @@ package env
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 )
 
+// ErrClipboardUnavailable is returned when no clipboard can be reached, e.g. in
+// headless sessions or when no clipboard utility is installed.
+var ErrClipboardUnavailable = errors.New("clipboard unavailable")
+
 // Clipboard provides cross-platform clipboard text operations.
-type Clipboard struct{}
+type Clipboard struct {
+	goos     string
+	lookPath func(file string) (string, error)
+	getenv   func(key string) string
+	run      func(name string, stdin []byte, args ...string) ([]byte, error)
+}
+
+// clipboardCommand is one way of reading or writing the clipboard.
+type clipboardCommand struct {
+	tool string
+	args []string
+	// appendNewline works around clip.exe dropping a final line without one
+	appendNewline bool
+}
 
 // NewClipboard creates a new clipboard helper.
-func NewClipboard() *Clipboard { return &Clipboard{} }
+func NewClipboard() *Clipboard {
+	return &Clipboard{
+		goos:     runtime.GOOS,
+		lookPath: exec.LookPath,
+		getenv:   os.Getenv,
+		run:      runClipboardCommand,
+	}
+}
 
 // ReadText reads plain text from the system clipboard.
 func (c *Clipboard) ReadText() (string, error) {
-	switch runtime.GOOS {
+	commands, err := c.commands(false)
+	if err != nil {
+		return "", err
+	}
+	out, err := c.try(commands, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// WriteText writes plain text to the system clipboard.
+func (c *Clipboard) WriteText(text string) error {
+	commands, err := c.commands(true)
+	if err != nil {
+		return err
+	}
+	_, err = c.try(commands, []byte(text))
+	return err
+}
+
+// commands returns the clipboard commands to try, in order, for this platform.
+// On Linux/BSD it fails early when no display server is reachable.
+func (c *Clipboard) commands(write bool) ([]clipboardCommand, error) {
+	switch c.goos {
 	case "darwin":
-		// macOS: use pbpaste
-		cmd := exec.Command("pbpaste")
-		out, err := cmd.Output()
-		if err != nil {
-			return "", err
+		if write {
+			return []clipboardCommand{{tool: "pbcopy"}}, nil
 		}
-		return string(out), nil
+		return []clipboardCommand{{tool: "pbpaste"}}, nil
 
 	case "windows":
-		// Prefer PowerShell Get-Clipboard
-		if psPath, err := exec.LookPath("powershell"); err == nil {
-			// -Raw to avoid line splitting; -NoProfile/-NonInteractive for speed/stability
-			cmd := exec.Command(psPath, "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", "Get-Clipboard -Raw")
-			out, err := cmd.Output()
-			if err == nil {
-				return string(out), nil
-			}
-			// Fallback to Windows.Forms Clipboard (older environments). Requires STA.
-			fallback := "Add-Type -AssemblyName System.Windows.Forms; [System.Windows.Forms.Clipboard]::GetText()"
-			cmd = exec.Command(psPath, "-NoProfile", "-NonInteractive", "-STA", "-ExecutionPolicy", "Bypass", "-Command", fallback)
-			out, err = cmd.Output()
-			if err == nil {
-				return string(out), nil
-			}
+		psArgs := []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command"}
+		if write {
+			// Set-Clipboard reads from STDIN to avoid quoting issues
+			set := append(append([]string{}, psArgs...), "Set-Clipboard -Value (Get-Content -Raw -)")
+			return []clipboardCommand{
+				{tool: "powershell", args: set},
+				{tool: "pwsh", args: set},
+				{tool: "clip", appendNewline: true},
+			}, nil
 		}
-		return "", errors.New("no supported clipboard read method found (requires PowerShell)")
+		get := append(append([]string{}, psArgs...), "Get-Clipboard -Raw")
+		// Windows.Forms fallback for older environments; requires STA
+		forms := []string{"-NoProfile", "-NonInteractive", "-STA", "-ExecutionPolicy", "Bypass", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; [System.Windows.Forms.Clipboard]::GetText()"}
+		return []clipboardCommand{
+			{tool: "powershell", args: get},
+			{tool: "pwsh", args: get},
+			{tool: "powershell", args: forms},
+		}, nil
 
 	default:
-		// Linux/BSD: try Wayland first, then X11 tools
-		if path, err := exec.LookPath("wl-paste"); err == nil {
-			cmd := exec.Command(path)
-			out, err := cmd.Output()
-			if err == nil {
-				return string(out), nil
-			}
+		wayland := c.getenv("WAYLAND_DISPLAY") != ""
+		x11 := c.getenv("DISPLAY") != ""
+		if !wayland && !x11 {
+			return nil, fmt.Errorf("%w: no display server (DISPLAY and WAYLAND_DISPLAY are unset); clipboard access is not possible in headless or SSH sessions", ErrClipboardUnavailable)
 		}
-		if path, err := exec.LookPath("xclip"); err == nil {
-			cmd := exec.Command(path, "-selection", "clipboard", "-o")
-			out, err := cmd.Output()
-			if err == nil {
-				return string(out), nil
+
+		var commands []clipboardCommand
+		if wayland {
+			if write {
+				commands = append(commands, clipboardCommand{tool: "wl-copy"})
+			} else {
+				commands = append(commands, clipboardCommand{tool: "wl-paste", args: []string{"--no-newline"}})
 			}
 		}
-		if path, err := exec.LookPath("xsel"); err == nil {
-			cmd := exec.Command(path, "--clipboard", "--output")
-			out, err := cmd.Output()
-			if err == nil {
-				return string(out), nil
+		if x11 {
+			if write {
+				commands = append(commands,
+					clipboardCommand{tool: "xclip", args: []string{"-selection", "clipboard"}},
+					clipboardCommand{tool: "xsel", args: []string{"--clipboard", "--input"}})
+			} else {
+				commands = append(commands,
+					clipboardCommand{tool: "xclip", args: []string{"-selection", "clipboard", "-o"}},
+					clipboardCommand{tool: "xsel", args: []string{"--clipboard", "--output"}})
 			}
 		}
-		return "", errors.New("no clipboard utility found (install wl-clipboard, xclip, or xsel)")
+		return commands, nil
 	}
 }
 
-// WriteText writes plain text to the system clipboard.
-func (c *Clipboard) WriteText(text string) error {
-	data := []byte(text)
-	switch runtime.GOOS {
-	case "darwin":
-		cmd := exec.Command("pbcopy")
-		cmd.Stdin = bytes.NewReader(data)
-		return cmd.Run()
-
-	case "windows":
-		// Prefer PowerShell Set-Clipboard reading from STDIN to avoid quoting issues
-		if psPath, err := exec.LookPath("powershell"); err == nil {
-			cmd := exec.Command(psPath, "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", "Set-Clipboard -Value (Get-Content -Raw -)")
-			cmd.Stdin = bytes.NewReader(data)
-			if err := cmd.Run(); err == nil {
-				return nil
-			}
+// try runs each available command until one succeeds. It reports
+// ErrClipboardUnavailable when none of the tools is installed, and the
+// last failure otherwise.
+func (c *Clipboard) try(commands []clipboardCommand, stdin []byte) ([]byte, error) {
+	var tools []string
+	var lastErr error
+	for _, command := range commands {
+		tools = append(tools, command.tool)
+		path, err := c.lookPath(command.tool)
+		if err != nil {
+			continue
 		}
-		// Fallback to clip.exe via cmd; ensure trailing newline so clip captures correctly
-		cmd := exec.Command("cmd", "/c", "clip")
-		stdin := bytes.NewBuffer(data)
-		if !strings.HasSuffix(text, "\n") {
-			stdin.WriteByte('\n')
+
+		input := stdin
+		if command.appendNewline && !bytes.HasSuffix(input, []byte("\n")) {
+			input = append(append([]byte{}, input...), '\n')
 		}
-		cmd.Stdin = stdin
-		return cmd.Run()
 
-	default:
-		if path, err := exec.LookPath("wl-copy"); err == nil {
-			cmd := exec.Command(path)
-			cmd.Stdin = bytes.NewReader(data)
-			if err := cmd.Run(); err == nil {
-				return nil
-			}
+		out, err := c.run(path, input, command.args...)
+		if err == nil {
+			return out, nil
 		}
-		if path, err := exec.LookPath("xclip"); err == nil {
-			cmd := exec.Command(path, "-selection", "clipboard")
-			cmd.Stdin = bytes.NewReader(data)
-			if err := cmd.Run(); err == nil {
-				return nil
-			}
+		lastErr = fmt.Errorf("%s failed: %w", command.tool, err)
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("%w: no clipboard utility found (tried %s)", ErrClipboardUnavailable, strings.Join(uniqueStrings(tools), ", "))
+}
+
+// runClipboardCommand runs a clipboard tool. Writes (non-nil stdin) do not
+// capture output: wl-copy and xclip fork a child that keeps serving the
+// selection and would hold the pipes open. Reads include stderr in the error.
+func runClipboardCommand(name string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+		return nil, cmd.Run()
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
 		}
-		if path, err := exec.LookPath("xsel"); err == nil {
-			cmd := exec.Command(path, "--clipboard", "--input")
-			cmd.Stdin = bytes.NewReader(data)
-			if err := cmd.Run(); err == nil {
-				return nil
-			}
+		return nil, err
+	}
+	return out, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
 		}
-		return errors.New("no clipboard utility found (install wl-clipboard, xclip, or xsel)")
 	}
+	return result
 }
@@ -0,0 +1,102 @@
+package env
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func fakeClipboard(goos string, env map[string]string, tools map[string]bool, run func(name string, stdin []byte, args ...string) ([]byte, error)) *Clipboard {
+	return &Clipboard{
+		goos: goos,
+		lookPath: func(file string) (string, error) {
+			if !tools[file] {
+				return "", exec.ErrNotFound
+			}
+			return "/usr/bin/" + file, nil
+		},
+		getenv: func(key string) string { return env[key] },
+		run:    run,
+	}
+}
+
+func TestClipboard_Backends(t *testing.T) {
+	testCases := []struct {
+		name      string
+		goos      string
+		env       map[string]string
+		tools     map[string]bool
+		wantRead  string
+		wantWrite string
+	}{
+		{"macOS", "darwin", nil, map[string]bool{"pbcopy": true, "pbpaste": true}, "/usr/bin/pbpaste", "/usr/bin/pbcopy"},
+		{"Windows PowerShell", "windows", nil, map[string]bool{"powershell": true}, "/usr/bin/powershell", "/usr/bin/powershell"},
+		{"Windows pwsh", "windows", nil, map[string]bool{"pwsh": true}, "/usr/bin/pwsh", "/usr/bin/pwsh"},
+		{"Wayland", "linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, map[string]bool{"wl-copy": true, "wl-paste": true, "xclip": true}, "/usr/bin/wl-paste", "/usr/bin/wl-copy"},
+		{"X11", "linux", map[string]string{"DISPLAY": ":0"}, map[string]bool{"wl-copy": true, "wl-paste": true, "xsel": true}, "/usr/bin/xsel", "/usr/bin/xsel"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ran []string
+			cb := fakeClipboard(tc.goos, tc.env, tc.tools, func(name string, stdin []byte, args ...string) ([]byte, error) {
+				ran = append(ran, name)
+				return []byte("hello"), nil
+			})
+
+			text, err := cb.ReadText()
+			if err != nil {
+				t.Fatalf("ReadText returned error: %v", err)
+			}
+			if text != "hello" {
+				t.Errorf("ReadText = %q; expected %q", text, "hello")
+			}
+			if err := cb.WriteText("hello"); err != nil {
+				t.Fatalf("WriteText returned error: %v", err)
+			}
+			if len(ran) != 2 || ran[0] != tc.wantRead || ran[1] != tc.wantWrite {
+				t.Errorf("Ran %v; expected [%s %s]", ran, tc.wantRead, tc.wantWrite)
+			}
+		})
+	}
+}
+
+func TestClipboard_Headless(t *testing.T) {
+	ran := false
+	cb := fakeClipboard("linux", nil, map[string]bool{"xclip": true, "wl-copy": true}, func(name string, stdin []byte, args ...string) ([]byte, error) {
+		ran = true
+		return nil, nil
+	})
+
+	if _, err := cb.ReadText(); !errors.Is(err, ErrClipboardUnavailable) {
+		t.Errorf("ReadText: expected ErrClipboardUnavailable, got %v", err)
+	}
+	if err := cb.WriteText("x"); !errors.Is(err, ErrClipboardUnavailable) {
+		t.Errorf("WriteText: expected ErrClipboardUnavailable, got %v", err)
+	}
+	if ran {
+		t.Error("Expected no clipboard tool to run without a display")
+	}
+}
+
+func TestClipboard_NoTool(t *testing.T) {
+	cb := fakeClipboard("linux", map[string]string{"DISPLAY": ":0"}, nil, func(name string, stdin []byte, args ...string) ([]byte, error) {
+		return nil, nil
+	})
+	if err := cb.WriteText("x"); !errors.Is(err, ErrClipboardUnavailable) {
+		t.Errorf("Expected ErrClipboardUnavailable, got %v", err)
+	}
+}
+
+func TestClipboard_FallsBackAfterFailure(t *testing.T) {
+	cb := fakeClipboard("linux", map[string]string{"DISPLAY": ":0"}, map[string]bool{"xclip": true, "xsel": true}, func(name string, stdin []byte, args ...string) ([]byte, error) {
+		if name == "/usr/bin/xclip" {
+			return nil, errors.New("Error: Can't open display")
+		}
+		return []byte("from xsel"), nil
+	})
+	text, err := cb.ReadText()
+	if err != nil || text != "from xsel" {
+		t.Errorf("ReadText = %q, %v; expected fallback to xsel", text, err)
+	}
+}
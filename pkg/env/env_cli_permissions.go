@@ -1,6 +1,7 @@
 package env
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -150,6 +151,61 @@ func (e *Environment) RemoveAllowedCommand(command string) error {
 	return e.saveAllowedCLICommands(updatedCommands)
 }
 
+// ImportAllowedCommands merges commands into the whitelist, skipping empty
+// entries and commands that are already allowed, and returns the ones added
+func (e *Environment) ImportAllowedCommands(commands []string) ([]string, error) {
+	current, err := e.LoadAllowedCLICommands()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current commands: %w", err)
+	}
+
+	known := make(map[string]bool, len(current))
+	for _, cmd := range current {
+		known[cmd] = true
+	}
+
+	var added []string
+	for _, cmd := range commands {
+		cmd = strings.TrimSpace(cmd)
+		if cmd == "" || known[cmd] {
+			continue
+		}
+		known[cmd] = true
+		added = append(added, cmd)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := e.saveAllowedCLICommands(append(current, added...)); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// ParseCLICommandList reads a whitelist for import: either a JSON array of
+// command names (as printed by tool permission export) or one command per
+// line, where blank lines and # comments are ignored like in allowed_cli.txt
+func ParseCLICommandList(data []byte) ([]string, error) {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	if strings.HasPrefix(trimmed, "[") {
+		var commands []string
+		if err := json.Unmarshal([]byte(trimmed), &commands); err != nil {
+			return nil, fmt.Errorf("invalid JSON command list: %w", err)
+		}
+		return commands, nil
+	}
+
+	var commands []string
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			commands = append(commands, line)
+		}
+	}
+	return commands, nil
+}
+
 func (e *Environment) saveAllowedCLICommands(commands []string) error {
 	filePath := e.GetAllowedCLIPath()
 
@@ -0,0 +1,75 @@
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCLICommandList(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		expected    []string
+		expectError bool
+	}{
+		{"JSON array", `["git", "jq"]`, []string{"git", "jq"}, false},
+		{"Lines with comments", "# team list\ngit\n\n  jq  \r\n#rg\n", []string{"git", "jq"}, false},
+		{"BOM", "\ufeffgit\n", []string{"git"}, false},
+		{"Empty", "", nil, false},
+		{"Broken JSON", `["git",`, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseCLICommandList([]byte(tc.input))
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCLICommandList failed: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("ParseCLICommandList() = %v; expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestImportAllowedCommands(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	environment, err := NewEnvironment()
+	if err != nil {
+		t.Fatalf("NewEnvironment failed: %v", err)
+	}
+
+	added, err := environment.ImportAllowedCommands([]string{"git", "echo", "git", " ", "jq"})
+	if err != nil {
+		t.Fatalf("ImportAllowedCommands failed: %v", err)
+	}
+	if strings.Join(added, ",") != "git,jq" {
+		t.Errorf("Added %v; expected [git jq] (echo is allowed by default)", added)
+	}
+
+	commands, err := environment.LoadAllowedCLICommands()
+	if err != nil {
+		t.Fatalf("LoadAllowedCLICommands failed: %v", err)
+	}
+	count := map[string]int{}
+	for _, c := range commands {
+		count[c]++
+	}
+	if count["git"] != 1 || count["jq"] != 1 || count["echo"] != 1 {
+		t.Errorf("Whitelist after import = %v; expected git, jq and echo once each", commands)
+	}
+
+	added, err = environment.ImportAllowedCommands([]string{"git"})
+	if err != nil || len(added) != 0 {
+		t.Errorf("Re-import added %v, %v; expected nothing", added, err)
+	}
+}
@@ -4,15 +4,27 @@ import (
 	"crypto/rand"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
 const AppName = "amo"
 
+// StaleTempAge is how long a directory under the temp path may go unchanged
+// before it is treated as left behind by a crashed workflow
+const StaleTempAge = 24 * time.Hour
+
 type Environment struct {
 	userConfigDir string
 	crossPlatform *CrossPlatformUtils
+
+	// detectedRegion caches the system region; language and timezone do not
+	// change while amo runs
+	detectedRegionOnce sync.Once
+	detectedRegion     string
 }
 
 func NewEnvironment() (*Environment, error) {
@@ -33,6 +45,23 @@ func NewEnvironment() (*Environment, error) {
 	}, nil
 }
 
+var (
+	sharedOnce sync.Once
+	sharedEnv  *Environment
+	sharedErr  error
+)
+
+// Shared returns a process-wide Environment, created on first use, for hot
+// paths such as workflow os/region lookups that would otherwise resolve and
+// create the config directory on every call. It is safe for concurrent use;
+// the config directory is fixed by the HOME of the first caller.
+func Shared() (*Environment, error) {
+	sharedOnce.Do(func() {
+		sharedEnv, sharedErr = NewEnvironment()
+	})
+	return sharedEnv, sharedErr
+}
+
 func (e *Environment) GetCurrentWorkingDir() (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -83,13 +112,33 @@ func (e *Environment) GetArchitecture() string {
 	return runtime.GOARCH
 }
 
+// configuredRegion is the region config value, set with SetConfiguredRegion
+var configuredRegion string
+
+// SetConfiguredRegion sets the region DetectRegion reports when AMO_REGION is
+// not set. The config package cannot be imported here, so amo passes the
+// region config value in on startup.
+func SetConfiguredRegion(region string) {
+	configuredRegion = region
+}
+
+// DetectRegion returns AMO_REGION, else the configured region, else the
+// region detected from the system language and timezone. Detection runs once
+// per Environment, so once per process through Shared; the overrides are
+// checked on every call.
 func (e *Environment) DetectRegion() string {
 	if value := strings.TrimSpace(e.crossPlatform.GetEnvironmentVariable("AMO_REGION")); value != "" {
 		return strings.ToLower(value)
 	}
 
-	detector := NewRegionDetector()
-	return detector.DetectRegion()
+	detector := NewRegionDetectorWithOverride(configuredRegion)
+	if detector.override != "" {
+		return detector.override
+	}
+	e.detectedRegionOnce.Do(func() {
+		e.detectedRegion = detector.DetectRegion()
+	})
+	return e.detectedRegion
 }
 
 func (e *Environment) GetSystemInfo() (map[string]interface{}, error) {
@@ -143,6 +192,56 @@ func (e *Environment) CleanupTempPath(tempPath string) error {
 	return os.RemoveAll(tempPath)
 }
 
+// CleanupStaleTemp removes the directories GetTempPath created under the
+// config dir in which nothing has changed for longer than olderThan, and
+// returns the removed paths. The newest modification time of anything in a
+// directory counts, so temp dirs of running workflows are kept.
+func (e *Environment) CleanupStaleTemp(olderThan time.Duration) ([]string, error) {
+	tempRoot := e.crossPlatform.JoinPath(e.userConfigDir, "temp")
+	entries, err := os.ReadDir(tempRoot)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read temp directory: %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var removed []string
+	var errs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(tempRoot, entry.Name())
+		if latestModTime(path).After(cutoff) {
+			continue
+		}
+		if err := e.CleanupTempPath(path); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		removed = append(removed, path)
+	}
+
+	if len(errs) > 0 {
+		return removed, fmt.Errorf("failed to remove stale temp directories: %s", strings.Join(errs, "; "))
+	}
+	return removed, nil
+}
+
+// latestModTime returns the newest modification time of dir and anything in it
+func latestModTime(dir string) time.Time {
+	var latest time.Time
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest
+}
+
 func getUserConfigDir(crossPlatform *CrossPlatformUtils) (string, error) {
 	homeDir, err := crossPlatform.GetHomeDir()
 	if err != nil {
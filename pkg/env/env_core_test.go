@@ -0,0 +1,86 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanupStaleTemp(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	environment, err := NewEnvironment()
+	if err != nil {
+		t.Fatalf("NewEnvironment failed: %v", err)
+	}
+
+	stale, err := environment.GetTempPath()
+	if err != nil {
+		t.Fatalf("GetTempPath failed: %v", err)
+	}
+	// A stale directory whose file changed recently is still in use
+	busy, err := environment.GetTempPath()
+	if err != nil {
+		t.Fatalf("GetTempPath failed: %v", err)
+	}
+	fresh, err := environment.GetTempPath()
+	if err != nil {
+		t.Fatalf("GetTempPath failed: %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	busyFile := filepath.Join(busy, "output.tmp")
+	if err := os.WriteFile(busyFile, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{stale, busy} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := environment.CleanupStaleTemp(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("CleanupStaleTemp failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != stale {
+		t.Errorf("CleanupStaleTemp removed %v; expected only %s", removed, stale)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Stale temp dir %s still exists", stale)
+	}
+	for _, dir := range []string{busy, fresh} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("Temp dir in use %s was removed: %v", dir, err)
+		}
+	}
+}
+
+func TestShared(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	results := make(chan *Environment, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			environment, err := Shared()
+			if err != nil {
+				t.Errorf("Shared failed: %v", err)
+			}
+			results <- environment
+		}()
+	}
+
+	first := <-results
+	for i := 1; i < cap(results); i++ {
+		if environment := <-results; environment != first {
+			t.Errorf("Shared returned different instances: %p and %p", first, environment)
+		}
+	}
+	if first == nil || first.GetUserConfigDir() == "" {
+		t.Errorf("Shared returned an unusable environment: %+v", first)
+	}
+}
@@ -0,0 +1,91 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoNotificationBackend is returned when the platform has no desktop notification tool.
+var ErrNoNotificationBackend = errors.New("no desktop notification backend found")
+
+// Notifier sends desktop notifications using the platform's native tooling.
+type Notifier struct {
+	goos     string
+	lookPath func(file string) (string, error)
+	run      func(name string, args ...string) error
+}
+
+// NewNotifier creates a new desktop notification helper.
+func NewNotifier() *Notifier {
+	return &Notifier{
+		goos:     runtime.GOOS,
+		lookPath: exec.LookPath,
+		run: func(name string, args ...string) error {
+			return exec.Command(name, args...).Run()
+		},
+	}
+}
+
+// Desktop shows a desktop notification. It returns ErrNoNotificationBackend
+// when osascript, PowerShell or notify-send is not available.
+func (n *Notifier) Desktop(title, message string) error {
+	name, args, err := n.desktopCommand(title, message)
+	if err != nil {
+		return err
+	}
+	if err := n.run(name, args...); err != nil {
+		return fmt.Errorf("failed to show notification: %w", err)
+	}
+	return nil
+}
+
+// desktopCommand builds the platform command that displays a notification.
+func (n *Notifier) desktopCommand(title, message string) (string, []string, error) {
+	switch n.goos {
+	case "darwin":
+		path, err := n.lookPath("osascript")
+		if err != nil {
+			return "", nil, ErrNoNotificationBackend
+		}
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title))
+		return path, []string{"-e", script}, nil
+
+	case "windows":
+		path, err := n.lookPath("powershell")
+		if err != nil {
+			return "", nil, ErrNoNotificationBackend
+		}
+		script := strings.Join([]string{
+			"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null",
+			"$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)",
+			"$texts = $template.GetElementsByTagName('text')",
+			"$texts.Item(0).AppendChild($template.CreateTextNode(" + powerShellQuote(title) + ")) > $null",
+			"$texts.Item(1).AppendChild($template.CreateTextNode(" + powerShellQuote(message) + ")) > $null",
+			"$toast = [Windows.UI.Notifications.ToastNotification]::new($template)",
+			"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('" + AppName + "').Show($toast)",
+		}, "; ")
+		return path, []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", script}, nil
+
+	default:
+		path, err := n.lookPath("notify-send")
+		if err != nil {
+			return "", nil, ErrNoNotificationBackend
+		}
+		return path, []string{title, message}, nil
+	}
+}
+
+// appleScriptQuote returns s as an AppleScript string literal.
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// powerShellQuote returns s as a single-quoted PowerShell string literal.
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
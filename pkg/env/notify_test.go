@@ -0,0 +1,76 @@
+package env
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestNotifier_Desktop(t *testing.T) {
+	testCases := []struct {
+		goos     string
+		tool     string
+		contains []string
+	}{
+		{"darwin", "osascript", []string{`display notification "Done \"ok\"" with title "amo"`}},
+		{"windows", "powershell", []string{"'Done \"ok\"'", "'amo'", "CreateToastNotifier"}},
+		{"linux", "notify-send", []string{"amo", `Done "ok"`}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.goos, func(t *testing.T) {
+			var gotName string
+			var gotArgs []string
+			n := &Notifier{
+				goos: tc.goos,
+				lookPath: func(file string) (string, error) {
+					if file != tc.tool {
+						return "", exec.ErrNotFound
+					}
+					return "/usr/bin/" + file, nil
+				},
+				run: func(name string, args ...string) error {
+					gotName = name
+					gotArgs = args
+					return nil
+				},
+			}
+
+			if err := n.Desktop("amo", `Done "ok"`); err != nil {
+				t.Fatalf("Desktop returned error: %v", err)
+			}
+			if gotName != "/usr/bin/"+tc.tool {
+				t.Errorf("Expected command %s, got %s", "/usr/bin/"+tc.tool, gotName)
+			}
+			joined := strings.Join(gotArgs, " ")
+			for _, want := range tc.contains {
+				if !strings.Contains(joined, want) {
+					t.Errorf("Expected args to contain %q, got %q", want, joined)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifier_NoBackend(t *testing.T) {
+	ran := false
+	n := &Notifier{
+		goos: "linux",
+		lookPath: func(file string) (string, error) {
+			return "", exec.ErrNotFound
+		},
+		run: func(name string, args ...string) error {
+			ran = true
+			return nil
+		},
+	}
+
+	err := n.Desktop("amo", "done")
+	if !errors.Is(err, ErrNoNotificationBackend) {
+		t.Errorf("Expected ErrNoNotificationBackend, got %v", err)
+	}
+	if ran {
+		t.Error("Expected no command to run without a backend")
+	}
+}
@@ -1,9 +1,13 @@
 package env
 
 import (
+	"context"
 	"fmt"
+	"os/exec"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,15 +19,34 @@ type RegionScore struct {
 
 type RegionDetector struct {
 	configs []RegionConfig
+	// override, when set, is returned by DetectRegion without detecting
+	override string
+
+	// systemLocale reads the OS locale setting on macOS and Windows, which
+	// need not match LANG; it runs at most once per detector
+	systemLocale func() string
+	localeOnce   sync.Once
+	locale       string
 }
 
 func NewRegionDetector() *RegionDetector {
+	return NewRegionDetectorWithOverride("")
+}
+
+// NewRegionDetectorWithOverride creates a detector whose DetectRegion returns
+// override, e.g. the region config value, when it is not empty
+func NewRegionDetectorWithOverride(override string) *RegionDetector {
 	return &RegionDetector{
-		configs: GetAllRegionConfigs(),
+		configs:      GetAllRegionConfigs(),
+		override:     strings.ToLower(strings.TrimSpace(override)),
+		systemLocale: readSystemLocale,
 	}
 }
 
 func (d *RegionDetector) DetectRegion() string {
+	if d.override != "" {
+		return d.override
+	}
 	region, _ := d.DetectRegionWithScore()
 	return region
 }
@@ -221,6 +244,9 @@ func (d *RegionDetector) calculateCountryScore(config RegionConfig) float64 {
 	return 0.0
 }
 
+// calculateMiscScore averages the weak signals: AMO_REGION, the country of
+// the LC_MONETARY locale (the currency in use) and the country of the OS
+// locale setting. Signals that are not available do not count.
 func (d *RegionDetector) calculateMiscScore(config RegionConfig) float64 {
 	var score float64
 	var factors int
@@ -232,6 +258,20 @@ func (d *RegionDetector) calculateMiscScore(config RegionConfig) float64 {
 		}
 	}
 
+	for _, locale := range []string{d.getEnvVar("LC_MONETARY"), d.getSystemLocale()} {
+		country := localeCountry(locale)
+		if country == "" {
+			continue
+		}
+		factors++
+		for _, code := range config.CountryCodes {
+			if country == code {
+				score += 1.0
+				break
+			}
+		}
+	}
+
 	if factors > 0 {
 		return score / float64(factors)
 	}
@@ -239,6 +279,56 @@ func (d *RegionDetector) calculateMiscScore(config RegionConfig) float64 {
 	return 0.0
 }
 
+// getSystemLocale returns the OS locale setting, read once
+func (d *RegionDetector) getSystemLocale() string {
+	d.localeOnce.Do(func() {
+		if d.systemLocale != nil {
+			d.locale = d.systemLocale()
+		}
+	})
+	return d.locale
+}
+
+// readSystemLocale asks macOS or Windows for the configured locale, e.g.
+// en_CN or zh-CN. Elsewhere the locale environment variables already cover it.
+func readSystemLocale() string {
+	var name string
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		name, args = "defaults", []string{"read", "-g", "AppleLocale"}
+	case "windows":
+		name, args = "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", "(Get-Culture).Name"}
+	default:
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// localeCountry returns the upper-case country code of a locale such as
+// zh_CN.UTF-8, en-US or zh-Hans_CN, or "" when it has none
+func localeCountry(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '_' || r == '-' })
+	if len(parts) < 2 {
+		return ""
+	}
+	country := parts[len(parts)-1]
+	if len(country) != 2 {
+		return ""
+	}
+	return strings.ToUpper(country)
+}
+
 func (d *RegionDetector) getEnvVar(name string) string {
 	crossPlatform := NewCrossPlatformUtils()
 	return crossPlatform.GetEnvironmentVariable(name)
@@ -273,6 +363,9 @@ func (d *RegionDetector) DebugInfo() map[string]interface{} {
 		"timezone":        func() string { tz, _ := time.Now().Zone(); return tz }(),
 		"utc_offset":      func() int { _, offset := time.Now().Zone(); return offset }(),
 		"region_override": d.getEnvVar("AMO_REGION"),
+		"region_config":   d.override,
+		"monetary_locale": d.getEnvVar("LC_MONETARY"),
+		"system_locale":   d.getSystemLocale(),
 		"scores":          make([]map[string]interface{}, 0, len(scores)),
 	}
 
@@ -89,6 +89,27 @@ func TestRegionDetector_WithEnvOverride(t *testing.T) {
 	}
 }
 
+func TestRegionDetector_WithConfiguredRegion(t *testing.T) {
+	if got := NewRegionDetectorWithOverride(" CN ").DetectRegion(); got != "cn" {
+		t.Errorf("Detector with override CN returned %q; expected cn", got)
+	}
+
+	defer SetConfiguredRegion("")
+	SetConfiguredRegion("jp")
+
+	t.Setenv("AMO_REGION", "")
+	environment, _ := NewEnvironment()
+	if got := environment.DetectRegion(); got != "jp" {
+		t.Errorf("DetectRegion with region config jp returned %q", got)
+	}
+
+	// The environment variable takes precedence over the config value
+	t.Setenv("AMO_REGION", "us")
+	if got := environment.DetectRegion(); got != "us" {
+		t.Errorf("DetectRegion with AMO_REGION=us and region config jp returned %q", got)
+	}
+}
+
 func TestRegionDetector_ChineseRegion(t *testing.T) {
 	originalLang := os.Getenv("LANG")
 	originalTZ := os.Getenv("TZ")
@@ -424,3 +445,77 @@ func TestRegionDetector_ThailandRegion(t *testing.T) {
 		t.Errorf("Expected region to be 'th' for Thai system, got '%s'", region)
 	}
 }
+
+func TestLocaleCountry(t *testing.T) {
+	testCases := []struct {
+		locale   string
+		expected string
+	}{
+		{"zh_CN.UTF-8", "CN"},
+		{"en-US", "US"},
+		{"zh-Hans_CN", "CN"},
+		{"de_DE@euro", "DE"},
+		{"en", ""},
+		{"C.UTF-8", ""},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := localeCountry(tc.locale); got != tc.expected {
+			t.Errorf("localeCountry(%q) = %q; expected %q", tc.locale, got, tc.expected)
+		}
+	}
+}
+
+func TestRegionDetector_MiscSignals(t *testing.T) {
+	t.Setenv("AMO_REGION", "")
+	t.Setenv("LC_MONETARY", "zh_CN.UTF-8")
+
+	calls := 0
+	detector := NewRegionDetector()
+	detector.systemLocale = func() string {
+		calls++
+		return "en-SG"
+	}
+
+	cn := GetRegionConfig("cn")
+	sg := GetRegionConfig("sg")
+	us := GetRegionConfig("us")
+	if got := detector.calculateMiscScore(*cn); got != 0.5 {
+		t.Errorf("cn misc score = %.2f; expected 0.5 from LC_MONETARY", got)
+	}
+	if got := detector.calculateMiscScore(*sg); got != 0.5 {
+		t.Errorf("sg misc score = %.2f; expected 0.5 from the system locale", got)
+	}
+	if got := detector.calculateMiscScore(*us); got != 0 {
+		t.Errorf("us misc score = %.2f; expected 0", got)
+	}
+	if calls != 1 {
+		t.Errorf("System locale was read %d times; expected once", calls)
+	}
+}
+
+func TestEnvironment_DetectRegionIsMemoized(t *testing.T) {
+	t.Setenv("AMO_REGION", "")
+	t.Setenv("LANG", "ja_JP.UTF-8")
+	environment, _ := NewEnvironment()
+	first := environment.DetectRegion()
+
+	// A later change of system signals does not trigger a new detection
+	t.Setenv("LANG", "pt_BR.UTF-8")
+	if got := environment.DetectRegion(); got != first {
+		t.Errorf("DetectRegion = %q after the first call returned %q; expected the cached region", got, first)
+	}
+
+	// Overrides still apply on every call
+	t.Setenv("AMO_REGION", "us")
+	if got := environment.DetectRegion(); got != "us" {
+		t.Errorf("DetectRegion with AMO_REGION=us returned %q", got)
+	}
+	t.Setenv("AMO_REGION", "")
+	defer SetConfiguredRegion("")
+	SetConfiguredRegion("cn")
+	if got := environment.DetectRegion(); got != "cn" {
+		t.Errorf("DetectRegion with region config cn returned %q", got)
+	}
+}
@@ -0,0 +1,330 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SafeArchivePath joins an archive entry name onto destDir, rejecting entries
+// that would escape it (absolute paths or ".." components), i.e. zip-slip
+func SafeArchivePath(destDir, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || filepath.VolumeName(cleaned) != "" || strings.HasPrefix(cleaned, string(filepath.Separator)) ||
+		cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry escapes extraction directory: %s", name)
+	}
+	return filepath.Join(destDir, cleaned), nil
+}
+
+// Zip packs the contents of sourceDir into a zip archive at outputPath and
+// returns the number of files added. Entry names are relative to sourceDir;
+// symlinks and other special files are skipped.
+func (fs *FileSystem) Zip(sourceDir, outputPath string) (int, error) {
+	var count int
+	err := fs.writeArchive(sourceDir, outputPath, func(out io.Writer) (addArchiveEntry, func() error) {
+		zw := zip.NewWriter(out)
+		add := func(rel string, info os.FileInfo, file io.Reader) error {
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = rel
+			if info.IsDir() {
+				header.Name += "/"
+			} else {
+				header.Method = zip.Deflate
+				count++
+			}
+			w, err := zw.CreateHeader(header)
+			if err != nil || file == nil {
+				return err
+			}
+			_, err = io.Copy(w, file)
+			return err
+		}
+		return add, zw.Close
+	})
+	return count, err
+}
+
+// TarGz packs the contents of sourceDir into a gzip-compressed tar archive at
+// outputPath and returns the number of files added, like Zip
+func (fs *FileSystem) TarGz(sourceDir, outputPath string) (int, error) {
+	var count int
+	err := fs.writeArchive(sourceDir, outputPath, func(out io.Writer) (addArchiveEntry, func() error) {
+		gz := gzip.NewWriter(out)
+		tw := tar.NewWriter(gz)
+		add := func(rel string, info os.FileInfo, file io.Reader) error {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = rel
+			if info.IsDir() {
+				header.Name += "/"
+			} else {
+				count++
+			}
+			if err := tw.WriteHeader(header); err != nil || file == nil {
+				return err
+			}
+			_, err = io.Copy(tw, file)
+			return err
+		}
+		return add, func() error {
+			if err := tw.Close(); err != nil {
+				return err
+			}
+			return gz.Close()
+		}
+	})
+	return count, err
+}
+
+// EntryCheck vets the destination path of each archive entry before it is
+// written, e.g. against a sandbox; an error stops the extraction
+type EntryCheck func(destPath string) error
+
+// Unzip extracts a zip archive into destDir and returns the extracted entry
+// names. Entries that would land outside destDir, directly or through a
+// symlink already on disk, fail the extraction, as do entries check rejects;
+// check may be nil. Symlinks and other special entries are skipped.
+func (fs *FileSystem) Unzip(archivePath, destDir string, check EntryCheck) ([]string, error) {
+	archivePath = fs.crossPlatform.NormalizePath(archivePath)
+	destDir = fs.crossPlatform.NormalizePath(destDir)
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file %s: %w", archivePath, err)
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(destDir, fs.crossPlatform.GetDefaultDirPermissions()); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", destDir, err)
+	}
+
+	extracted := []string{}
+	for _, file := range reader.File {
+		mode := file.FileInfo().Mode()
+		if !mode.IsDir() && !mode.IsRegular() {
+			continue
+		}
+		destPath, err := fs.archiveEntryPath(destDir, file.Name, check)
+		if err != nil {
+			return extracted, err
+		}
+
+		if mode.IsDir() {
+			if err := os.MkdirAll(destPath, fs.crossPlatform.GetDefaultDirPermissions()); err != nil {
+				return extracted, fmt.Errorf("failed to create directory %s: %w", destPath, err)
+			}
+		} else {
+			src, err := file.Open()
+			if err != nil {
+				return extracted, fmt.Errorf("failed to open %s in zip: %w", file.Name, err)
+			}
+			err = fs.writeArchiveEntry(destPath, src, mode)
+			src.Close()
+			if err != nil {
+				return extracted, err
+			}
+		}
+		extracted = append(extracted, file.Name)
+	}
+	return extracted, nil
+}
+
+// UnTarGz extracts a gzip-compressed tar archive into destDir, like Unzip
+func (fs *FileSystem) UnTarGz(archivePath, destDir string, check EntryCheck) ([]string, error) {
+	return fs.unTar(archivePath, destDir, true, check)
+}
+
+// UnTar extracts an uncompressed tar archive into destDir, like Unzip
+func (fs *FileSystem) UnTar(archivePath, destDir string, check EntryCheck) ([]string, error) {
+	return fs.unTar(archivePath, destDir, false, check)
+}
+
+func (fs *FileSystem) unTar(archivePath, destDir string, gzipped bool, check EntryCheck) ([]string, error) {
+	archivePath = fs.crossPlatform.NormalizePath(archivePath)
+	destDir = fs.crossPlatform.NormalizePath(destDir)
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream of %s: %w", archivePath, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	if err := os.MkdirAll(destDir, fs.crossPlatform.GetDefaultDirPermissions()); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", destDir, err)
+	}
+
+	extracted := []string{}
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return extracted, nil
+		}
+		if err != nil {
+			return extracted, fmt.Errorf("failed to read tar archive %s: %w", archivePath, err)
+		}
+		if header.Typeflag != tar.TypeDir && header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		destPath, err := fs.archiveEntryPath(destDir, header.Name, check)
+		if err != nil {
+			return extracted, err
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(destPath, fs.crossPlatform.GetDefaultDirPermissions()); err != nil {
+				return extracted, fmt.Errorf("failed to create directory %s: %w", destPath, err)
+			}
+		} else if err := fs.writeArchiveEntry(destPath, tr, header.FileInfo().Mode()); err != nil {
+			return extracted, err
+		}
+		extracted = append(extracted, header.Name)
+	}
+}
+
+// archiveEntryPath returns where an entry is extracted to. Besides the
+// lexical zip-slip check, symlinks already under destDir are resolved, so an
+// entry such as link/file cannot be written through a link pointing elsewhere.
+func (fs *FileSystem) archiveEntryPath(destDir, name string, check EntryCheck) (string, error) {
+	destPath, err := SafeArchivePath(destDir, name)
+	if err != nil {
+		return "", err
+	}
+
+	root, err := filepath.Abs(destDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", destDir, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	absPath, err := filepath.Abs(destPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", destPath, err)
+	}
+	if !IsWithinDir(ResolveExistingPath(absPath), root) {
+		return "", fmt.Errorf("archive entry escapes extraction directory through a symlink: %s", name)
+	}
+
+	if check != nil {
+		if err := check(destPath); err != nil {
+			return "", err
+		}
+	}
+	return destPath, nil
+}
+
+// addArchiveEntry writes one walked entry to an archive; file is nil for
+// directories
+type addArchiveEntry func(rel string, info os.FileInfo, file io.Reader) error
+
+// writeArchive walks sourceDir into the archive that newArchive starts on the
+// file at outputPath; the returned func finishes it. A failed archive is
+// removed rather than left half-written.
+func (fs *FileSystem) writeArchive(sourceDir, outputPath string, newArchive func(io.Writer) (addArchiveEntry, func() error)) (err error) {
+	sourceDir = fs.crossPlatform.NormalizePath(sourceDir)
+	outputPath = fs.crossPlatform.NormalizePath(outputPath)
+
+	if !fs.IsDir(sourceDir) {
+		return fmt.Errorf("source is not a directory: %s", sourceDir)
+	}
+	absOutput, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", outputPath, err)
+	}
+
+	if err := fs.MakeDir(filepath.Dir(outputPath)); err != nil {
+		return err
+	}
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive %s: %w", outputPath, err)
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to write archive %s: %w", outputPath, closeErr)
+		}
+		if err != nil {
+			os.Remove(outputPath)
+		}
+	}()
+
+	add, finish := newArchive(out)
+
+	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		// Archiving into the source directory must not pick up the archive itself
+		if absPath, absErr := filepath.Abs(path); absErr == nil && absPath == absOutput {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case info.IsDir():
+			return add(rel, info, nil)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return add(rel, info, file)
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", sourceDir, err)
+	}
+
+	if err := finish(); err != nil {
+		return fmt.Errorf("failed to write archive %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// writeArchiveEntry writes one extracted file, keeping its permission bits
+func (fs *FileSystem) writeArchiveEntry(destPath string, src io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), fs.crossPlatform.GetDefaultDirPermissions()); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	dst, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", destPath, err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to extract file %s: %w", destPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	fs := NewFileSystem()
+
+	testCases := []struct {
+		name    string
+		ext     string
+		create  func(string, string) (int, error)
+		extract func(string, string, EntryCheck) ([]string, error)
+	}{
+		{"zip", ".zip", fs.Zip, fs.Unzip},
+		{"tar.gz", ".tar.gz", fs.TarGz, fs.UnTarGz},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := t.TempDir()
+			files := map[string]string{
+				"report.txt":        "summary",
+				"media/clip.txt":    "frames",
+				"media/deep/a.json": "{}",
+			}
+			for name, content := range files {
+				path := filepath.Join(source, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("Failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to create test file: %v", err)
+				}
+			}
+
+			// The archive is written into the source directory it packs
+			archive := filepath.Join(source, "out"+tc.ext)
+			count, err := tc.create(source, archive)
+			if err != nil {
+				t.Fatalf("Creating archive failed: %v", err)
+			}
+			if count != len(files) {
+				t.Errorf("Added %d files; expected %d", count, len(files))
+			}
+
+			dest := t.TempDir()
+			if _, err := tc.extract(archive, dest, nil); err != nil {
+				t.Fatalf("Extracting archive failed: %v", err)
+			}
+			for name, content := range files {
+				data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+				if err != nil {
+					t.Errorf("Missing %s after extraction: %v", name, err)
+					continue
+				}
+				if string(data) != content {
+					t.Errorf("%s = %q; expected %q", name, data, content)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dest, "out"+tc.ext)); err == nil {
+				t.Errorf("Archive contains itself")
+			}
+		})
+	}
+
+	if _, err := fs.Zip(filepath.Join(t.TempDir(), "missing"), filepath.Join(t.TempDir(), "out.zip")); err == nil {
+		t.Errorf("Expected an error for a missing source directory")
+	}
+}
+
+func TestArchiveExtractionRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	fs := NewFileSystem()
+
+	zipPath := filepath.Join(dir, "evil.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	zw := zip.NewWriter(zipFile)
+	w, err := zw.Create("../escaped.txt")
+	if err != nil {
+		t.Fatalf("Failed to add zip entry: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("Failed to write zip entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	zipFile.Close()
+
+	tarPath := filepath.Join(dir, "evil.tar.gz")
+	tarFile, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatalf("Failed to create tar.gz: %v", err)
+	}
+	gz := gzip.NewWriter(tarFile)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "../escaped.txt", Mode: 0644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("Failed to add tar entry: %v", err)
+	}
+	if _, err := tw.Write([]byte("x")); err != nil {
+		t.Fatalf("Failed to write tar entry: %v", err)
+	}
+	tw.Close()
+	gz.Close()
+	tarFile.Close()
+
+	dest := filepath.Join(dir, "dest")
+	if _, err := fs.Unzip(zipPath, dest, nil); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Errorf("Unzip error = %v; expected a path traversal error", err)
+	}
+	if _, err := fs.UnTarGz(tarPath, dest, nil); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Errorf("UnTarGz error = %v; expected a path traversal error", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.txt")); err == nil {
+		t.Errorf("Entry was written outside the extraction directory")
+	}
+}
+
+func TestArchiveExtractionRejectsSymlinkedParents(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	dest := filepath.Join(dir, "dest")
+	for _, d := range []string{outside, dest} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", d, err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(dest, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	zipPath := filepath.Join(dir, "evil.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	zw := zip.NewWriter(zipFile)
+	w, err := zw.Create("link/pwned.txt")
+	if err != nil {
+		t.Fatalf("Failed to add zip entry: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("Failed to write zip entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	zipFile.Close()
+
+	fs := NewFileSystem()
+	if _, err := fs.Unzip(zipPath, dest, nil); err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Errorf("Unzip error = %v; expected the symlinked parent to be refused", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned.txt")); err == nil {
+		t.Error("Entry was written through the symlink")
+	}
+
+	// The check hook sees every entry before it is written
+	denied := errors.New("denied")
+	var checked []string
+	check := func(path string) error {
+		checked = append(checked, path)
+		return denied
+	}
+	other := t.TempDir()
+	if _, err := fs.Unzip(zipPath, other, check); !errors.Is(err, denied) {
+		t.Errorf("Unzip error = %v; expected the check error", err)
+	}
+	if len(checked) != 1 || checked[0] != filepath.Join(other, "link", "pwned.txt") {
+		t.Errorf("Checked %v; expected the entry path", checked)
+	}
+	if _, err := os.Stat(filepath.Join(other, "link")); err == nil {
+		t.Error("A rejected entry was written")
+	}
+}
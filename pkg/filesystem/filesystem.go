@@ -5,10 +5,15 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +30,22 @@ type FileInfo struct {
 	Mode    string `json:"mode"`
 }
 
+// DiskUsageEntry is the size of one immediate child of a directory; Size
+// covers everything below it for directories
+type DiskUsageEntry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"is_dir"`
+}
+
+// FileInfoResult is the outcome of stat-ing one path in a batch
+type FileInfoResult struct {
+	Path  string    `json:"path"`
+	Info  *FileInfo `json:"info,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
 // FileSystem provides file system operations
 type FileSystem struct {
 	crossPlatform *env.CrossPlatformUtils
@@ -57,6 +78,31 @@ func (fs *FileSystem) IsDir(path string) bool {
 	return info.IsDir()
 }
 
+// CheckWritableDir returns an error unless path is an existing directory the
+// current user can create files in. Writability is tested by creating and
+// removing a file, which also covers ACLs and read-only mounts.
+func (fs *FileSystem) CheckWritableDir(path string) error {
+	path = fs.crossPlatform.NormalizePath(path)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("directory does not exist: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to access %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", path)
+	}
+
+	probe, err := os.CreateTemp(path, ".amo-write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %s", path)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
 // Exists checks if the given path exists
 func (fs *FileSystem) Exists(path string) bool {
 	path = fs.crossPlatform.NormalizePath(path)
@@ -88,6 +134,22 @@ func (fs *FileSystem) GetFileInfo(path string) (*FileInfo, error) {
 	}, nil
 }
 
+// StatMany returns file information for each path, in the same order.
+// Every path gets a result; failures are reported per path instead of aborting the batch.
+func (fs *FileSystem) StatMany(paths []string) []FileInfoResult {
+	results := make([]FileInfoResult, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+		info, err := fs.GetFileInfo(path)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Info = info
+	}
+	return results
+}
+
 // List returns a list of files and directories in the given directory
 func (fs *FileSystem) List(dirPath string) ([]FileInfo, error) {
 	dirPath = fs.crossPlatform.NormalizePath(dirPath)
@@ -137,8 +199,24 @@ func (fs *FileSystem) MakeDir(dirPath string) error {
 	return nil
 }
 
-// Copy copies a file or directory from src to dst
+// CopyOptions controls which attributes Copy carries over besides content
+type CopyOptions struct {
+	// PreserveTime keeps the source modification times
+	PreserveTime bool
+	// PreserveMode keeps the source permission bits
+	PreserveMode bool
+}
+
+// DefaultCopyOptions keeps modification times and permissions, like cp -p
+var DefaultCopyOptions = CopyOptions{PreserveTime: true, PreserveMode: true}
+
+// Copy copies a file or directory from src to dst with DefaultCopyOptions
 func (fs *FileSystem) Copy(src, dst string) error {
+	return fs.CopyWithOptions(src, dst, DefaultCopyOptions)
+}
+
+// CopyWithOptions copies a file or directory from src to dst
+func (fs *FileSystem) CopyWithOptions(src, dst string, opts CopyOptions) error {
 	src = fs.crossPlatform.NormalizePath(src)
 	dst = fs.crossPlatform.NormalizePath(dst)
 
@@ -148,13 +226,13 @@ func (fs *FileSystem) Copy(src, dst string) error {
 	}
 
 	if srcInfo.IsDir() {
-		return fs.copyDir(src, dst)
+		return fs.copyDir(src, dst, opts)
 	}
-	return fs.copyFile(src, dst)
+	return fs.copyFile(src, dst, opts)
 }
 
 // copyFile copies a single file from src to dst
-func (fs *FileSystem) copyFile(src, dst string) error {
+func (fs *FileSystem) copyFile(src, dst string, opts CopyOptions) error {
 	// Create destination directory if it doesn't exist
 	dstDir := filepath.Dir(dst)
 	if err := fs.MakeDir(dstDir); err != nil {
@@ -177,23 +255,36 @@ func (fs *FileSystem) copyFile(src, dst string) error {
 	if err != nil {
 		return fmt.Errorf("failed to copy file content: %w", err)
 	}
+	// Close before setting times; a later write-back would bump them again
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("failed to write destination file %s: %w", dst, err)
+	}
 
-	// Copy file permissions
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return fmt.Errorf("failed to get source file info: %w", err)
 	}
+	return fs.copyAttributes(dst, srcInfo, opts)
+}
 
-	err = os.Chmod(dst, srcInfo.Mode())
-	if err != nil {
-		return fmt.Errorf("failed to set file permissions: %w", err)
+// copyAttributes applies the permissions and modification time of srcInfo
+// to dst as far as opts asks for them
+func (fs *FileSystem) copyAttributes(dst string, srcInfo os.FileInfo, opts CopyOptions) error {
+	if opts.PreserveMode {
+		if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
+			return fmt.Errorf("failed to set file permissions: %w", err)
+		}
+	}
+	if opts.PreserveTime {
+		if err := os.Chtimes(dst, time.Now(), srcInfo.ModTime()); err != nil {
+			return fmt.Errorf("failed to set modification time of %s: %w", dst, err)
+		}
 	}
-
 	return nil
 }
 
 // copyDir recursively copies a directory from src to dst
-func (fs *FileSystem) copyDir(src, dst string) error {
+func (fs *FileSystem) copyDir(src, dst string, opts CopyOptions) error {
 	srcInfo, err := os.Stat(src)
 	if err != nil {
 		return fmt.Errorf("failed to get source directory info: %w", err)
@@ -205,6 +296,21 @@ func (fs *FileSystem) copyDir(src, dst string) error {
 		return fmt.Errorf("failed to create destination directory %s: %w", dst, err)
 	}
 
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return fmt.Errorf("failed to get destination directory info: %w", err)
+	}
+	if err := fs.copyDirEntries(src, dst, dstInfo, opts); err != nil {
+		return err
+	}
+	return fs.copyAttributes(dst, srcInfo, opts)
+}
+
+// copyDirEntries copies the contents of src into the existing dst. Symlinks
+// are recreated as symlinks rather than followed, so a link cannot pull files
+// from outside the tree into the copy, nor recurse into a loop. dstRoot is
+// skipped, for copies into a subdirectory of the source.
+func (fs *FileSystem) copyDirEntries(src, dst string, dstRoot os.FileInfo, opts CopyOptions) error {
 	entries, err := os.ReadDir(src)
 	if err != nil {
 		return fmt.Errorf("failed to read source directory %s: %w", src, err)
@@ -214,13 +320,35 @@ func (fs *FileSystem) copyDir(src, dst string) error {
 		srcPath := fs.crossPlatform.JoinPath(src, entry.Name())
 		dstPath := fs.crossPlatform.JoinPath(dst, entry.Name())
 
-		if entry.IsDir() {
-			err = fs.copyDir(srcPath, dstPath)
-		} else {
-			err = fs.copyFile(srcPath, dstPath)
+		info, err := os.Lstat(srcPath)
+		if err != nil {
+			return fmt.Errorf("failed to get source info for %s: %w", srcPath, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err := copySymlink(srcPath, dstPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.IsDir() {
+			if err := fs.copyFile(srcPath, dstPath, opts); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if err != nil {
+		if os.SameFile(info, dstRoot) {
+			continue
+		}
+
+		if err := os.MkdirAll(dstPath, info.Mode()); err != nil {
+			return fmt.Errorf("failed to create destination directory %s: %w", dstPath, err)
+		}
+		if err := fs.copyDirEntries(srcPath, dstPath, dstRoot, opts); err != nil {
+			return err
+		}
+		// Directory times are set last, since copying the entries changes them
+		if err := fs.copyAttributes(dstPath, info, opts); err != nil {
 			return err
 		}
 	}
@@ -228,6 +356,24 @@ func (fs *FileSystem) copyDir(src, dst string) error {
 	return nil
 }
 
+// copySymlink recreates the symlink src at dst with the same target. An
+// existing file or symlink at dst is replaced.
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return fmt.Errorf("failed to read symlink %s: %w", src, err)
+	}
+	if info, err := os.Lstat(dst); err == nil && !info.IsDir() {
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dst, err)
+		}
+	}
+	if err := os.Symlink(target, dst); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", dst, err)
+	}
+	return nil
+}
+
 // Move moves a file or directory from src to dst
 func (fs *FileSystem) Move(src, dst string) error {
 	src = fs.crossPlatform.NormalizePath(src)
@@ -280,6 +426,41 @@ func (fs *FileSystem) ReadFile(path string) (string, error) {
 	return string(content), nil
 }
 
+// ReadRange reads up to length bytes of a file starting at offset, without
+// reading the rest of it. Fewer bytes come back when the range runs past the
+// end of the file, and none when offset is at or beyond it.
+func (fs *FileSystem) ReadRange(path string, offset, length int64) ([]byte, error) {
+	path = fs.crossPlatform.NormalizePath(path)
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("offset and length cannot be negative")
+	}
+	if !fs.IsFile(path) {
+		return nil, fmt.Errorf("path is not a file: %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	// Size the buffer by what is there, so a huge length on a small file is cheap
+	if remaining := info.Size() - offset; remaining < length {
+		length = max(remaining, 0)
+	}
+
+	buf := make([]byte, length)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	return buf[:n], nil
+}
+
 // WriteFile writes content to a file
 func (fs *FileSystem) WriteFile(path, content string) error {
 	path = fs.crossPlatform.NormalizePath(path)
@@ -316,6 +497,64 @@ func (fs *FileSystem) AppendFile(path, content string) error {
 	return nil
 }
 
+// ReadJSON reads a file and parses its content as JSON
+func (fs *FileSystem) ReadJSON(path string) (interface{}, error) {
+	content, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON in %s: %w", path, err)
+	}
+	return value, nil
+}
+
+// WriteFileAtomic replaces path with content without ever exposing a partial
+// file: the data goes to a temporary file in the same directory, which is then
+// renamed over the target. The permissions of an existing target are kept.
+func (fs *FileSystem) WriteFileAtomic(path, content string) error {
+	path = fs.crossPlatform.NormalizePath(path)
+
+	randomBytes := make([]byte, 6)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return fmt.Errorf("failed to generate temp file name: %w", err)
+	}
+	tempPath := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp-"+hex.EncodeToString(randomBytes))
+
+	if err := fs.WriteFile(tempPath, content); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		_ = os.Chmod(tempPath, info.Mode().Perm())
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSON marshals value as JSON (indented with indent unless it is empty)
+// and writes it with WriteFileAtomic
+func (fs *FileSystem) WriteJSON(path string, value interface{}, indent string) error {
+	var data []byte
+	var err error
+	if indent != "" {
+		data, err = json.MarshalIndent(value, "", indent)
+	} else {
+		data, err = json.Marshal(value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON for %s: %w", path, err)
+	}
+	data = append(data, '\n')
+
+	return fs.WriteFileAtomic(path, string(data))
+}
+
 // GetSize returns the size of a file or directory
 func (fs *FileSystem) GetSize(path string) (int64, error) {
 	path = fs.crossPlatform.NormalizePath(path)
@@ -348,6 +587,59 @@ func (fs *FileSystem) GetSize(path string) (int64, error) {
 	return totalSize, nil
 }
 
+// FormatBytes formats a byte count for people, e.g. "1.5 MB"
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// DiskUsage returns the size of every immediate child of dir, largest first,
+// and their total. Symlinks are counted by their own size, not followed.
+func (fs *FileSystem) DiskUsage(dir string) ([]DiskUsageEntry, int64, error) {
+	dir = fs.crossPlatform.NormalizePath(dir)
+
+	children, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	entries := make([]DiskUsageEntry, 0, len(children))
+	var total int64
+	for _, child := range children {
+		entry := DiskUsageEntry{
+			Name:  child.Name(),
+			Path:  filepath.Join(dir, child.Name()),
+			IsDir: child.IsDir(),
+		}
+		if entry.IsDir {
+			entry.Size, err = fs.GetSize(entry.Path)
+		} else {
+			var info os.FileInfo
+			if info, err = child.Info(); err == nil {
+				entry.Size = info.Size()
+			}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		total += entry.Size
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Size > entries[j].Size
+	})
+	return entries, total, nil
+}
+
 // Find searches for files and directories matching a pattern
 func (fs *FileSystem) Find(rootPath, pattern string) ([]string, error) {
 	rootPath = fs.crossPlatform.NormalizePath(rootPath)
@@ -387,6 +679,118 @@ func (fs *FileSystem) Find(rootPath, pattern string) ([]string, error) {
 	return matches, nil
 }
 
+// Glob returns the files and directories matching pattern, which may contain
+// directories (media/*/clip.mp4) and ** for any number of directories
+// (media/**/*.mp4). Relative patterns are resolved against the working
+// directory. Matches are absolute paths in walk order; no match is not an error.
+func (fs *FileSystem) Glob(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("glob pattern is empty")
+	}
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+
+	// Walk from the longest leading part of the pattern without wildcards
+	literal := 0
+	for literal < len(segments) && !hasGlobMeta(segments[literal]) {
+		literal++
+	}
+	rest := segments[literal:]
+	for _, segment := range rest {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+
+	base := strings.Join(segments[:literal], "/")
+	switch {
+	case base == "" && literal > 0:
+		// Absolute pattern whose first directory already has wildcards
+		base = "/"
+	case base == "":
+		base = "."
+	case strings.HasSuffix(base, ":"):
+		base += "/"
+	}
+	base = filepath.FromSlash(base)
+
+	var matches []string
+	addMatch := func(p string) {
+		absPath, err := filepath.Abs(p)
+		if err != nil {
+			absPath = p
+		}
+		matches = append(matches, fs.crossPlatform.NormalizePath(absPath))
+	}
+
+	if len(rest) == 0 {
+		if fs.Exists(base) {
+			addMatch(base)
+		}
+		return matches, nil
+	}
+	if !fs.IsDir(base) {
+		return matches, nil
+	}
+
+	recursive := false
+	for _, segment := range rest {
+		if segment == "**" {
+			recursive = true
+		}
+	}
+
+	err := filepath.Walk(base, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		relSegments := strings.Split(filepath.ToSlash(rel), "/")
+		if matchGlobSegments(rest, relSegments) {
+			addMatch(p)
+		}
+		// Without ** nothing deeper than the pattern can match
+		if info.IsDir() && !recursive && len(relSegments) >= len(rest) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob %s: %w", pattern, err)
+	}
+
+	return matches, nil
+}
+
+// hasGlobMeta reports whether a pattern segment contains wildcards
+func hasGlobMeta(segment string) bool {
+	return strings.ContainsAny(segment, "*?[\\")
+}
+
+// matchGlobSegments matches path segments against pattern segments, where a
+// ** segment matches zero or more path segments
+func matchGlobSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		if matchGlobSegments(pattern[1:], segments) {
+			return true
+		}
+		return len(segments) > 0 && matchGlobSegments(pattern, segments[1:])
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(pattern[0], segments[0])
+	return err == nil && matched && matchGlobSegments(pattern[1:], segments[1:])
+}
+
 // GetWorkingDir returns the current working directory
 func (fs *FileSystem) GetWorkingDir() (string, error) {
 	cwd, err := os.Getwd()
@@ -505,6 +909,43 @@ func (fs *FileSystem) CreateExecutableFile(path string, content []byte) error {
 	return nil
 }
 
+// ParseFileMode parses an octal permission string such as "0755", "755" or "0o644".
+// Only permission bits are accepted; setuid, setgid and sticky bits are rejected.
+func ParseFileMode(mode string) (os.FileMode, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(mode), "0o"), "0O")
+	value, err := strconv.ParseUint(trimmed, 8, 32)
+	if err != nil || trimmed == "" {
+		return 0, fmt.Errorf("invalid file mode %q: expected an octal string like \"0755\"", mode)
+	}
+	if value > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q: only permission bits (0000-0777) are supported", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// Chmod changes the permissions of a file or directory. The mode is an octal
+// string such as "0755". On Windows, which has no POSIX permission bits, it
+// only validates its arguments and returns success.
+func (fs *FileSystem) Chmod(path, mode string) error {
+	path = fs.crossPlatform.NormalizePath(path)
+
+	perm, err := ParseFileMode(mode)
+	if err != nil {
+		return err
+	}
+	if !fs.Exists(path) {
+		return fmt.Errorf("path does not exist: %s", path)
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	if err := os.Chmod(path, perm); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", path, err)
+	}
+	return nil
+}
+
 // GetFileMD5 calculates the MD5 hash of a file
 func (fs *FileSystem) GetFileMD5(path string) (string, error) {
 	path = fs.crossPlatform.NormalizePath(path)
@@ -612,3 +1053,30 @@ func (fs *FileSystem) GenerateUniqueFilename(path string, maxAttempts int) (stri
 
 	return "", fmt.Errorf("failed to generate unique filename after %d attempts", maxAttempts)
 }
+
+// ResolveExistingPath evaluates symlinks in the longest existing prefix of an
+// absolute path and re-appends the components that do not exist yet
+func ResolveExistingPath(absPath string) string {
+	current := absPath
+	var missing []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return absPath
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
+
+// IsWithinDir reports whether path is dir or lies beneath it
+func IsWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
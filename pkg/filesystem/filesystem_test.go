@@ -0,0 +1,529 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatMany(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(filePath, []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	missingPath := filepath.Join(dir, "missing.txt")
+
+	fs := NewFileSystem()
+	paths := []string{filePath, missingPath, dir, missingPath}
+	results := fs.StatMany(paths)
+
+	if len(results) != len(paths) {
+		t.Fatalf("Expected %d results, got %d", len(paths), len(results))
+	}
+
+	testCases := []struct {
+		name   string
+		exists bool
+		isDir  bool
+		size   int64
+	}{
+		{"existing file", true, false, 5},
+		{"missing file", false, false, 0},
+		{"existing dir", true, true, 0},
+		{"missing file again", false, false, 0},
+	}
+
+	for i, tc := range testCases {
+		r := results[i]
+		if r.Path != paths[i] {
+			t.Errorf("%s: result path = %q; expected %q", tc.name, r.Path, paths[i])
+		}
+		if !tc.exists {
+			if r.Info != nil || r.Error == "" {
+				t.Errorf("%s: expected an error and no info, got info=%v error=%q", tc.name, r.Info, r.Error)
+			}
+			continue
+		}
+		if r.Info == nil || r.Error != "" {
+			t.Errorf("%s: expected info and no error, got error=%q", tc.name, r.Error)
+			continue
+		}
+		if r.Info.IsDir != tc.isDir {
+			t.Errorf("%s: IsDir = %v; expected %v", tc.name, r.Info.IsDir, tc.isDir)
+		}
+		if !tc.isDir && r.Info.Size != tc.size {
+			t.Errorf("%s: Size = %d; expected %d", tc.name, r.Info.Size, tc.size)
+		}
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	testCases := []struct {
+		mode     string
+		expected os.FileMode
+		wantErr  bool
+	}{
+		{"0755", 0755, false},
+		{"755", 0755, false},
+		{"0o644", 0644, false},
+		{" 0600 ", 0600, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"rwxr-xr-x", 0, true},
+		{"0999", 0, true},
+		{"4755", 0, true},
+	}
+
+	for _, tc := range testCases {
+		mode, err := ParseFileMode(tc.mode)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseFileMode(%q) = %o; expected an error", tc.mode, mode)
+			}
+			continue
+		}
+		if err != nil || mode != tc.expected {
+			t.Errorf("ParseFileMode(%q) = %o, %v; expected %o", tc.mode, mode, err, tc.expected)
+		}
+	}
+}
+
+func TestChmod(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	fs := NewFileSystem()
+	if err := fs.Chmod(path, "0755"); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		if info.Mode().Perm() != 0755 {
+			t.Errorf("Mode = %o; expected 0755", info.Mode().Perm())
+		}
+	}
+
+	if err := fs.Chmod(path, "bogus"); err == nil {
+		t.Error("Expected an error for an invalid mode")
+	}
+	if err := fs.Chmod(filepath.Join(t.TempDir(), "missing"), "0755"); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+}
+
+func TestWriteJSONReadJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	fs := NewFileSystem()
+	value := map[string]interface{}{"name": "amo", "count": 3}
+	if err := fs.WriteJSON(path, value, "  "); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	expected := "{\n  \"count\": 3,\n  \"name\": \"amo\"\n}\n"
+	if string(data) != expected {
+		t.Errorf("Content = %q; expected %q", string(data), expected)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("Mode = %o; expected the original 0600", info.Mode().Perm())
+		}
+	}
+
+	got, err := fs.ReadJSON(path)
+	if err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	obj, ok := got.(map[string]interface{})
+	if !ok || obj["name"] != "amo" || obj["count"] != float64(3) {
+		t.Errorf("ReadJSON = %v; expected the written value", got)
+	}
+
+	if err := fs.WriteJSON(path, []int{1, 2}, ""); err != nil {
+		t.Fatalf("Compact WriteJSON failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "[1,2]\n" {
+		t.Errorf("Compact content = %q; expected %q", string(data), "[1,2]\n")
+	}
+
+	if err := fs.WriteJSON(path, func() {}, "  "); err == nil {
+		t.Error("Expected an error for a value that cannot be encoded")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "[1,2]\n" {
+		t.Errorf("Failed write modified the file: %q", string(data))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file, found %d entries", len(entries))
+	}
+
+	if err := os.WriteFile(path, []byte("{broken"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := fs.ReadJSON(path); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "cache.json")
+
+	fs := NewFileSystem()
+	if err := fs.WriteFileAtomic(path, "first"); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if err := fs.WriteFileAtomic(path, "second"); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+
+	if data, err := os.ReadFile(path); err != nil || string(data) != "second" {
+		t.Errorf("Content = %q, %v; expected %q", string(data), err, "second")
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("Mode = %o; expected the original 0600", info.Mode().Perm())
+		}
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file, found %d entries", len(entries))
+	}
+
+	// A directory in the way makes the rename fail; the temp file must not linger
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := fs.WriteFileAtomic(blocked, "x"); err == nil {
+		t.Error("Expected an error when the target is a non-empty directory")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected the temp file to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestCheckWritableDir(t *testing.T) {
+	fs := NewFileSystem()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := fs.CheckWritableDir(dir); err != nil {
+		t.Errorf("CheckWritableDir(%s) = %v; expected nil", dir, err)
+	}
+	if err := fs.CheckWritableDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+	if err := fs.CheckWritableDir(file); err == nil {
+		t.Error("Expected an error for a file")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("CheckWritableDir left files behind: %v, %v", entries, err)
+	}
+
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		readOnly := filepath.Join(dir, "ro")
+		if err := os.Mkdir(readOnly, 0555); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.CheckWritableDir(readOnly); err == nil {
+			t.Error("Expected an error for a read-only directory")
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"media/a.mp4",
+		"media/b.txt",
+		"media/2024/c.mp4",
+		"media/2024/deep/d.mp4",
+		"other/e.mp4",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		pattern  string
+		expected []string
+	}{
+		{"recursive", "media/**/*.mp4", []string{"media/2024/c.mp4", "media/2024/deep/d.mp4", "media/a.mp4"}},
+		{"single level", "media/*.mp4", []string{"media/a.mp4"}},
+		{"wildcard directory", "*/*.mp4", []string{"media/a.mp4", "other/e.mp4"}},
+		{"leading doublestar", "**/d.mp4", []string{"media/2024/deep/d.mp4"}},
+		{"literal path", "media/b.txt", []string{"media/b.txt"}},
+		{"no match", "media/**/*.mkv", nil},
+		{"missing base", "missing/**/*.mp4", nil},
+	}
+
+	fs := NewFileSystem()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Relative and absolute patterns must give the same matches
+			for _, pattern := range []string{tc.pattern, filepath.ToSlash(dir) + "/" + tc.pattern} {
+				t.Chdir(dir)
+				matches, err := fs.Glob(pattern)
+				if err != nil {
+					t.Fatalf("Glob(%q) failed: %v", pattern, err)
+				}
+				var got []string
+				for _, match := range matches {
+					rel, err := filepath.Rel(dir, match)
+					if err != nil {
+						t.Fatalf("Match %q is not under %q: %v", match, dir, err)
+					}
+					got = append(got, filepath.ToSlash(rel))
+				}
+				if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+					t.Errorf("Glob(%q) = %v; expected %v", pattern, got, tc.expected)
+				}
+			}
+		})
+	}
+
+	if _, err := fs.Glob("media/[.mp4"); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"small.txt":          10,
+		"media/a.mp4":        300,
+		"media/deep/b.mp4":   200,
+		"docs/readme.md":     50,
+		"docs/notes/todo.md": 5,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	entries, total, err := NewFileSystem().DiskUsage(dir)
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if total != 565 {
+		t.Errorf("total = %d; expected 565", total)
+	}
+
+	expected := []DiskUsageEntry{
+		{Name: "media", Path: filepath.Join(dir, "media"), Size: 500, IsDir: true},
+		{Name: "docs", Path: filepath.Join(dir, "docs"), Size: 55, IsDir: true},
+		{Name: "small.txt", Path: filepath.Join(dir, "small.txt"), Size: 10},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("entries = %+v; expected %+v", entries, expected)
+	}
+
+	if _, _, err := NewFileSystem().DiskUsage(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+}
+
+func TestCopyDirSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	shared := filepath.Join(dir, "shared")
+	for _, path := range []string{filepath.Join(src, "sub"), shared} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, "b.txt"), []byte("b"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Symlink(shared, filepath.Join(src, "linked")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	fs := NewFileSystem()
+
+	// A symlinked directory outside the tree is copied as a symlink
+	dst := filepath.Join(dir, "dst")
+	if err := fs.Copy(src, dst); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "linked")); err != nil || target != shared {
+		t.Errorf("linked = %q, %v; expected a symlink to %s", target, err, shared)
+	}
+
+	// A symlink back up the tree is copied as a link, not followed
+	if err := os.Symlink(src, filepath.Join(src, "sub", "loop")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := fs.Copy(src, filepath.Join(dir, "looped")); err != nil {
+		t.Errorf("Copy with a symlink loop failed: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "looped", "sub", "loop")); err != nil || target != src {
+		t.Errorf("sub/loop = %q, %v; expected a symlink to %s", target, err, src)
+	}
+	if err := os.Remove(filepath.Join(src, "sub", "loop")); err != nil {
+		t.Fatalf("Failed to remove symlink: %v", err)
+	}
+
+	// Copying into a subdirectory of the source does not copy the copy
+	nested := filepath.Join(src, "backup")
+	if err := fs.Copy(src, nested); err != nil {
+		t.Fatalf("Copy into a subdirectory failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(nested, "backup")); err == nil {
+		t.Errorf("Copy into a subdirectory recursed into its own destination")
+	}
+	if _, err := os.Stat(filepath.Join(nested, "sub", "a.txt")); err != nil {
+		t.Errorf("Copy into a subdirectory missed sub/a.txt: %v", err)
+	}
+}
+
+func TestCopyPreservesTimes(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "album"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	photo := filepath.Join(src, "album", "photo.jpg")
+	if err := os.WriteFile(photo, []byte("jpeg"), 0640); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	taken := time.Date(2019, 7, 14, 10, 30, 0, 0, time.UTC)
+	for _, path := range []string{photo, filepath.Join(src, "album")} {
+		if err := os.Chtimes(path, taken, taken); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+	}
+
+	fs := NewFileSystem()
+	testCases := []struct {
+		name      string
+		opts      CopyOptions
+		keepTimes bool
+	}{
+		{"default", DefaultCopyOptions, true},
+		{"reset", CopyOptions{PreserveMode: true}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := filepath.Join(dir, tc.name)
+			if err := fs.CopyWithOptions(src, dst, tc.opts); err != nil {
+				t.Fatalf("Copy failed: %v", err)
+			}
+			for _, rel := range []string{"album/photo.jpg", "album"} {
+				info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(rel)))
+				if err != nil {
+					t.Fatalf("Missing %s: %v", rel, err)
+				}
+				if kept := info.ModTime().Equal(taken); kept != tc.keepTimes {
+					t.Errorf("%s modified %v; preserved = %v, expected %v", rel, info.ModTime(), kept, tc.keepTimes)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	fs := NewFileSystem()
+	tests := []struct {
+		offset, length int64
+		expected       string
+	}{
+		{0, 4, "0123"},
+		{6, 2, "67"},
+		{8, 100, "89"},
+		{10, 4, ""},
+		{50, 4, ""},
+		{3, 0, ""},
+	}
+	for _, tt := range tests {
+		data, err := fs.ReadRange(path, tt.offset, tt.length)
+		if err != nil {
+			t.Errorf("ReadRange(%d, %d) failed: %v", tt.offset, tt.length, err)
+			continue
+		}
+		if string(data) != tt.expected {
+			t.Errorf("ReadRange(%d, %d) = %q; expected %q", tt.offset, tt.length, data, tt.expected)
+		}
+	}
+
+	if _, err := fs.ReadRange(path, -1, 4); err == nil {
+		t.Error("Expected an error for a negative offset")
+	}
+	if _, err := fs.ReadRange(path, 0, -1); err == nil {
+		t.Error("Expected an error for a negative length")
+	}
+	if _, err := fs.ReadRange(filepath.Dir(path), 0, 4); err == nil {
+		t.Error("Expected an error for a directory")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KB",
+		1536:            "1.5 KB",
+		5 * 1024 * 1024: "5.0 MB",
+		3 << 30:         "3.0 GB",
+		1<<40 + 1<<39:   "1.5 TB",
+	}
+	for size, expected := range testCases {
+		if got := FormatBytes(size); got != expected {
+			t.Errorf("FormatBytes(%d) = %q; expected %q", size, got, expected)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package filesystem
+
+import (
+	"os"
+	"sort"
+	"sync"
+)
+
+// trackedTemp holds temporary files and directories of in-flight operations
+// so they can be removed when the process is interrupted
+var trackedTemp = struct {
+	sync.Mutex
+	paths map[string]int
+}{paths: make(map[string]int)}
+
+// TrackTempPath registers a temporary file or directory that should not outlive
+// an interrupted operation. Call the returned function once the path has been
+// renamed into place or removed; calling it more than once is harmless.
+func TrackTempPath(path string) (untrack func()) {
+	trackedTemp.Lock()
+	trackedTemp.paths[path]++
+	trackedTemp.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			trackedTemp.Lock()
+			defer trackedTemp.Unlock()
+			if trackedTemp.paths[path] <= 1 {
+				delete(trackedTemp.paths, path)
+			} else {
+				trackedTemp.paths[path]--
+			}
+		})
+	}
+}
+
+// RemoveTrackedTempPaths removes every tracked path that still exists and
+// returns the removed paths. It is meant for interrupt handlers.
+func RemoveTrackedTempPaths() []string {
+	trackedTemp.Lock()
+	defer trackedTemp.Unlock()
+
+	var removed []string
+	for path := range trackedTemp.paths {
+		if _, err := os.Lstat(path); err == nil {
+			if err := os.RemoveAll(path); err == nil {
+				removed = append(removed, path)
+			}
+		}
+		delete(trackedTemp.paths, path)
+	}
+	sort.Strings(removed)
+	return removed
+}
@@ -0,0 +1,47 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveTrackedTempPaths(t *testing.T) {
+	dir := t.TempDir()
+	tracked := filepath.Join(dir, "wf.js.download")
+	trackedDir := filepath.Join(dir, "extract")
+	released := filepath.Join(dir, "done.bin")
+
+	for _, path := range []string{tracked, released} {
+		if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(trackedDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+
+	TrackTempPath(tracked)
+	TrackTempPath(trackedDir)
+	untrack := TrackTempPath(released)
+	untrack()
+	untrack()
+	TrackTempPath(filepath.Join(dir, "never-created"))
+
+	removed := RemoveTrackedTempPaths()
+	expected := []string{trackedDir, tracked}
+	if len(removed) != len(expected) || removed[0] != expected[0] || removed[1] != expected[1] {
+		t.Errorf("RemoveTrackedTempPaths = %v; expected %v", removed, expected)
+	}
+	for _, path := range expected {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(released); err != nil {
+		t.Errorf("Untracked file should be kept: %v", err)
+	}
+	if again := RemoveTrackedTempPaths(); len(again) != 0 {
+		t.Errorf("Expected nothing left to remove, got %v", again)
+	}
+}
@@ -1,6 +1,10 @@
 package network
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -9,20 +13,45 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"amo/pkg/config"
 	"amo/pkg/env"
 )
 
+// Default copy buffer size (in KB) for network_download_buffer_kb
+const defaultDownloadBufferKB = 32
+
+// DefaultUserAgent is sent unless network_user_agent or a request header overrides it
+const DefaultUserAgent = "amo-cli/1.0"
+
 // NetworkClient provides secure HTTP client functionality
 type NetworkClient struct {
-	client         *http.Client
-	environment    *env.Environment
-	allowedHosts   []string
-	allowedSchemes []string
+	client             *http.Client
+	environment        *env.Environment
+	allowedHosts       []string
+	allowedSchemes     []string
+	downloadBufferSize int
+	// allowPrivate disables the guard against loopback, private and
+	// link-local addresses (network_allow_private)
+	allowPrivate bool
+	// proxyAddrs holds the host:port of every proxy a request was routed
+	// through; dials to them are exempt from the private address guard
+	proxyAddrs *sync.Map
+	// userAgent is sent with every request unless the caller sets its own
+	userAgent string
+	// githubToken authenticates GraphQL requests to the GitHub API
+	githubToken string
+	// minInterval is kept between requests to the same host, tracked by throttle
+	minInterval time.Duration
+	throttle    *hostThrottle
+	// ctx cancels requests, including their wait for the per-host interval
+	ctx context.Context
 }
 
 // HTTPResponse represents the response from an HTTP request
@@ -31,6 +60,32 @@ type HTTPResponse struct {
 	Headers    map[string]string `json:"headers"`
 	Body       string            `json:"body"`
 	Error      string            `json:"error,omitempty"`
+	// Cause is the error behind Error when the request could not be made,
+	// e.g. one wrapping ErrURLNotAllowed or ErrOffline
+	Cause error `json:"-"`
+}
+
+// Err returns the failure of the request as an error, or nil if it succeeded.
+// errors.Is sees through it to Cause.
+func (r *HTTPResponse) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	return &responseError{message: r.Error, cause: r.Cause}
+}
+
+// responseError is an HTTPResponse failure with its underlying cause
+type responseError struct {
+	message string
+	cause   error
+}
+
+func (e *responseError) Error() string {
+	return e.message
+}
+
+func (e *responseError) Unwrap() error {
+	return e.cause
 }
 
 // NewNetworkClient creates a new network client with security controls
@@ -48,16 +103,40 @@ func NewNetworkClient() (*NetworkClient, error) {
 	tlsTimeout := resolveTimeoutSeconds(cfg, config.KeyNetworkTLSHandshakeTimeoutSeconds, "AMO_NET_TLS_TIMEOUT", 15)
 	headerTimeout := resolveTimeoutSeconds(cfg, config.KeyNetworkResponseHeaderTimeoutSecond, "AMO_NET_HEADER_TIMEOUT", 60)
 	idleTimeout := resolveTimeoutSeconds(cfg, config.KeyNetworkIdleTimeoutSeconds, "AMO_NET_IDLE_TIMEOUT", 300)
+	downloadBufferSize := resolveDownloadBufferSize(cfg)
 
-	baseDialer := &net.Dialer{
+	nc := &NetworkClient{
+		environment:        environment,
+		allowedSchemes:     []string{"https", "http"},
+		downloadBufferSize: downloadBufferSize,
+		allowPrivate:       resolveAllowPrivate(cfg),
+		userAgent:          resolveUserAgent(cfg),
+		githubToken:        config.GitHubToken(cfg),
+		minInterval:        resolveMinInterval(cfg),
+		throttle:           newHostThrottle(),
+		proxyAddrs:         &sync.Map{},
+	}
+
+	// The guarded dialer checks the address actually connected to, so a DNS
+	// answer that changes after validateURL cannot reach a private address
+	guardedDialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   nc.dialControl,
+	}
+	proxyDialer := &net.Dialer{
 		Timeout:   dialTimeout,
 		KeepAlive: 30 * time.Second,
 	}
 
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: nc.proxyForRequest,
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, dErr := baseDialer.DialContext(ctx, network, addr)
+			dialer := guardedDialer
+			if _, isProxy := nc.proxyAddrs.Load(addr); isProxy {
+				dialer = proxyDialer
+			}
+			conn, dErr := dialer.DialContext(ctx, network, addr)
 			if dErr != nil {
 				return nil, dErr
 			}
@@ -70,30 +149,36 @@ func NewNetworkClient() (*NetworkClient, error) {
 		ResponseHeaderTimeout: headerTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
-	client := &http.Client{
-		Transport: transport,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
-	}
-
-	nc := &NetworkClient{
-		client:         client,
-		environment:    environment,
-		allowedSchemes: []string{"https", "http"},
+	nc.client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: nc.checkRedirect,
 	}
 
 	// Load allowed hosts from whitelist
-	if err := nc.loadAllowedHosts(); err != nil {
+	if err := nc.loadAllowedHosts(cfg); err != nil {
 		return nil, fmt.Errorf("failed to load network whitelist: %w", err)
 	}
 
 	return nc, nil
 }
 
+// WithContext returns a client whose requests are cancelled with ctx, e.g.
+// when a workflow times out. It shares the connection pool and the per-host
+// request times with nc.
+func (nc *NetworkClient) WithContext(ctx context.Context) *NetworkClient {
+	clone := *nc
+	clone.ctx = ctx
+	return &clone
+}
+
+// requestContext returns the context new requests are created with
+func (nc *NetworkClient) requestContext() context.Context {
+	if nc.ctx == nil {
+		return context.Background()
+	}
+	return nc.ctx
+}
+
 // Get performs an HTTP GET request
 func (nc *NetworkClient) Get(urlStr string, headers map[string]string) *HTTPResponse {
 	return nc.request("GET", urlStr, nil, headers)
@@ -104,6 +189,33 @@ func (nc *NetworkClient) Post(urlStr string, body string, headers map[string]str
 	return nc.request("POST", urlStr, strings.NewReader(body), headers)
 }
 
+// AllowedMethods lists the HTTP methods accepted by Request
+var AllowedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+
+// Request performs an HTTP request with any method in AllowedMethods. The
+// method is case-insensitive; an empty body sends no body.
+func (nc *NetworkClient) Request(method, urlStr, body string, headers map[string]string) *HTTPResponse {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	allowed := false
+	for _, m := range AllowedMethods {
+		if method == m {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return &HTTPResponse{
+			Error: fmt.Sprintf("unsupported HTTP method %q (allowed: %s)", method, strings.Join(AllowedMethods, ", ")),
+		}
+	}
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	return nc.request(method, urlStr, reader, headers)
+}
+
 // GetJSON performs a GET request and parses JSON response
 func (nc *NetworkClient) GetJSON(urlStr string, headers map[string]string) map[string]interface{} {
 	response := nc.Get(urlStr, headers)
@@ -133,14 +245,12 @@ func (nc *NetworkClient) GetJSON(urlStr string, headers map[string]string) map[s
 // request performs the actual HTTP request
 func (nc *NetworkClient) request(method, urlStr string, body io.Reader, headers map[string]string) *HTTPResponse {
 	// Validate URL
-	if !nc.isURLAllowed(urlStr) {
-		return &HTTPResponse{
-			Error: fmt.Sprintf("URL not in allowed hosts whitelist: %s", urlStr),
-		}
+	if err := nc.validateURL(urlStr); err != nil {
+		return &HTTPResponse{Error: err.Error(), Cause: err}
 	}
 
 	// Create request
-	req, err := http.NewRequest(method, urlStr, body)
+	req, err := http.NewRequestWithContext(nc.requestContext(), method, urlStr, body)
 	if err != nil {
 		return &HTTPResponse{
 			Error: fmt.Sprintf("failed to create request: %v", err),
@@ -148,27 +258,29 @@ func (nc *NetworkClient) request(method, urlStr string, body io.Reader, headers
 	}
 
 	// Set default headers
-	req.Header.Set("User-Agent", "amo-cli/1.0")
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
-
-	// Set custom headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
+	nc.setHeaders(req, headers)
 
 	// Execute request
+	if err := nc.awaitTurn(req); err != nil {
+		return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err), Cause: err}
+	}
 	resp, err := nc.client.Do(req)
 	if err != nil {
 		return &HTTPResponse{
 			Error: fmt.Sprintf("request failed: %v", err),
+			Cause: err,
 		}
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	bodyBytes, err := io.ReadAll(resp.Body)
+	if err == nil {
+		bodyBytes, err = decodeContentEncoding(resp, bodyBytes)
+	}
 	if err != nil {
 		return &HTTPResponse{
 			StatusCode: resp.StatusCode,
@@ -184,11 +296,63 @@ func (nc *NetworkClient) request(method, urlStr string, body io.Reader, headers
 	}
 }
 
+// decodeContentEncoding decompresses a gzip or deflate body. The transport
+// does this itself unless the caller set Accept-Encoding, in which case the
+// body arrives still encoded. The Content-Encoding header is dropped once the
+// body is decoded, as the transport does.
+func decodeContentEncoding(resp *http.Response, body []byte) ([]byte, error) {
+	if resp.Uncompressed || len(body) == 0 {
+		return body, nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// "deflate" is zlib-wrapped by the spec, but some servers send raw deflate
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s body: %w", resp.Header.Get("Content-Encoding"), err)
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s body: %w", resp.Header.Get("Content-Encoding"), err)
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return decoded, nil
+}
+
+// URLCheck describes how a URL was matched against the allowed hosts whitelist
+type URLCheck struct {
+	Allowed bool
+	// MatchedEntry is the whitelist entry that allowed the URL, if any
+	MatchedEntry string
+	// Reason explains the decision in a form suitable for users
+	Reason string
+}
+
 // isURLAllowed checks if a URL is in the allowed hosts whitelist
 func (nc *NetworkClient) isURLAllowed(urlStr string) bool {
+	return nc.CheckURL(urlStr).Allowed
+}
+
+// CheckURL matches a URL against the allowed hosts whitelist and reports
+// which entry allowed it, or why no entry did
+func (nc *NetworkClient) CheckURL(urlStr string) URLCheck {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return false
+		return URLCheck{Reason: fmt.Sprintf("invalid URL: %v", err)}
 	}
 
 	// Check scheme
@@ -200,61 +364,88 @@ func (nc *NetworkClient) isURLAllowed(urlStr string) bool {
 		}
 	}
 	if !schemeAllowed {
-		return false
+		return URLCheck{Reason: fmt.Sprintf("scheme %q is not allowed (allowed: %s)", parsedURL.Scheme, strings.Join(nc.allowedSchemes, ", "))}
 	}
 
 	// If no hosts are configured, allow all (for initial setup)
 	if len(nc.allowedHosts) == 0 {
-		return true
+		return URLCheck{Allowed: true, Reason: "no allowed hosts are configured, so every host is allowed"}
 	}
 
 	// Check host and path using domain and path matching pattern
 	host := parsedURL.Hostname()
 	urlPath := parsedURL.Path
+	var pathMismatches []string
 
 	for _, allowedEntry := range nc.allowedHosts {
-		// Check if the allowed entry contains a path
-		hostPart := allowedEntry
-		pathPart := ""
-
-		if strings.Contains(allowedEntry, "/") {
-			parts := strings.SplitN(allowedEntry, "/", 2)
-			hostPart = parts[0]
-			pathPart = "/" + parts[1]
+		if matchesHostEntry(host, allowedEntry) {
+			if entryAllowsPath(allowedEntry, urlPath) {
+				return URLCheck{Allowed: true, MatchedEntry: allowedEntry, Reason: fmt.Sprintf("matched entry %q", allowedEntry)}
+			}
+			// Path doesn't match, continue checking other entries
+			pathMismatches = append(pathMismatches, allowedEntry)
 		}
+	}
 
-		// First check if hostname matches
-		hostnameMatches := false
-		if host == hostPart {
-			hostnameMatches = true
-		} else if strings.HasSuffix(host, "."+hostPart) {
-			// Domain suffix match (e.g., "github.com" matches "api.github.com" or "user.github.com")
-			hostnameMatches = true
-		}
+	if len(pathMismatches) > 0 {
+		return URLCheck{Reason: fmt.Sprintf("host %q matches %s, but path %q is outside the allowed path(s)", host, strings.Join(pathMismatches, ", "), urlPath)}
+	}
+	return URLCheck{Reason: fmt.Sprintf("no entry matches host %q (entries match the domain itself and its subdomains)", host)}
+}
+
+// AllowedHosts returns the loaded whitelist entries
+func (nc *NetworkClient) AllowedHosts() []string {
+	return append([]string(nil), nc.allowedHosts...)
+}
 
-		// If hostname matches, check path if necessary
-		if hostnameMatches {
-			if pathPart == "" {
-				// No path restriction in this entry, allow access
-				return true
-			} else {
-				// Path restriction exists, check if URL path starts with the allowed path
-				// Make sure we match exact paths or subdirectories, not partial path segments
-				if strings.HasPrefix(urlPath, pathPart) &&
-					(len(urlPath) == len(pathPart) || urlPath[len(pathPart)] == '/' || pathPart[len(pathPart)-1] == '/') {
-					return true
-				}
-				// Path doesn't match, continue checking other entries
+// AddAllowedHosts extends the whitelist of this client only, e.g. for
+// amo run --allow-host; the whitelist files are not changed
+func (nc *NetworkClient) AddAllowedHosts(hosts ...string) {
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range nc.allowedHosts {
+			if existing == host {
+				duplicate = true
+				break
 			}
 		}
+		if !duplicate {
+			nc.allowedHosts = append(nc.allowedHosts, host)
+		}
 	}
+}
+
+// matchesHostEntry reports whether host matches the host part of a whitelist
+// entry, either exactly or as a subdomain (e.g. "github.com" matches "api.github.com")
+func matchesHostEntry(host, entry string) bool {
+	hostPart, _, _ := strings.Cut(entry, "/")
+	return host == hostPart || strings.HasSuffix(host, "."+hostPart)
+}
 
-	return false
+// entryAllowsPath reports whether urlPath is within the path restriction of an
+// entry. Exact paths and subdirectories match, partial path segments do not.
+func entryAllowsPath(entry, urlPath string) bool {
+	_, rest, hasPath := strings.Cut(entry, "/")
+	if !hasPath {
+		// No path restriction in this entry
+		return true
+	}
+	pathPart := "/" + rest
+	return strings.HasPrefix(urlPath, pathPart) &&
+		(len(urlPath) == len(pathPart) || urlPath[len(pathPart)] == '/' || pathPart[len(pathPart)-1] == '/')
 }
 
-// loadAllowedHosts loads the allowed hosts from the whitelist file
-func (nc *NetworkClient) loadAllowedHosts() error {
-	filePath := nc.environment.JoinPath(nc.environment.GetUserConfigDir(), "allowed_hosts.txt")
+// loadAllowedHosts loads the allowed hosts from the whitelist file of the
+// active profile (allowed_hosts.txt, or allowed_hosts.<profile>.txt)
+func (nc *NetworkClient) loadAllowedHosts(cfg *config.Manager) error {
+	filePath := nc.environment.JoinPath(nc.environment.GetUserConfigDir(), config.AllowedHostsFileName(""))
+	if cfg != nil {
+		filePath = cfg.GetAllowedHostsFile()
+	}
 
 	// Keep a single source of truth for default hosts
 	defaultHosts := []string{
@@ -296,36 +487,24 @@ func (nc *NetworkClient) loadAllowedHosts() error {
 	}
 
 	// Parse existing hosts (ignore comments/blank lines)
-	lines := strings.Split(string(content), "\n")
-	existing := make([]string, 0, len(lines))
+	var existing []string
 	seen := make(map[string]bool)
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		if !seen[line] {
-			existing = append(existing, line)
-			seen[line] = true
-		}
-	}
+	existing = mergeHostList(existing, seen, content)
 
 	// Also merge allowed workflow download sources to honor `amo workflow source` configuration
 	// File: allowed_workflow_hosts.txt (same directory)
 	wfFilePath := nc.environment.JoinPath(nc.environment.GetUserConfigDir(), "allowed_workflow_hosts.txt")
-	if _, err := os.Stat(wfFilePath); err == nil {
-		if wfContent, rerr := os.ReadFile(wfFilePath); rerr == nil {
-			wfLines := strings.Split(string(wfContent), "\n")
-			for _, l := range wfLines {
-				l = strings.TrimSpace(l)
-				if l == "" || strings.HasPrefix(l, "#") {
-					continue
-				}
-				if !seen[l] {
-					existing = append(existing, l)
-					seen[l] = true
-				}
-			}
+	if wfContent, err := os.ReadFile(wfFilePath); err == nil {
+		existing = mergeHostList(existing, seen, wfContent)
+	}
+
+	// Drop-in host lists, e.g. one file per vendor: allowed_hosts.d/*.txt,
+	// merged in file name order
+	dropIns, _ := filepath.Glob(nc.environment.JoinPath(nc.environment.GetUserConfigDir(), AllowedHostsDir, "*.txt"))
+	sort.Strings(dropIns)
+	for _, dropIn := range dropIns {
+		if dropInContent, err := os.ReadFile(dropIn); err == nil {
+			existing = mergeHostList(existing, seen, dropInContent)
 		}
 	}
 
@@ -363,6 +542,26 @@ func (nc *NetworkClient) loadAllowedHosts() error {
 	return nil
 }
 
+// AllowedHostsDir is the directory under ~/.amo whose *.txt host lists are
+// merged into the network whitelist
+const AllowedHostsDir = "allowed_hosts.d"
+
+// mergeHostList appends the entries of a host list file (one per line, #
+// comments) to hosts, skipping entries already in seen
+func mergeHostList(hosts []string, seen map[string]bool, content []byte) []string {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !seen[line] {
+			hosts = append(hosts, line)
+			seen[line] = true
+		}
+	}
+	return hosts
+}
+
 func (nc *NetworkClient) extractHeaders(headers http.Header) map[string]string {
 	result := make(map[string]string)
 	for key, values := range headers {
@@ -396,6 +595,68 @@ func resolveTimeoutSeconds(cfg *config.Manager, configKey, envKey string, defaul
 	return time.Duration(defaultSeconds) * time.Second
 }
 
+// resolveUserAgent returns the network_user_agent config value, or DefaultUserAgent
+func resolveUserAgent(cfg *config.Manager) string {
+	if cfg != nil {
+		if userAgent := strings.TrimSpace(cfg.GetString(config.KeyNetworkUserAgent)); userAgent != "" {
+			return userAgent
+		}
+	}
+	return DefaultUserAgent
+}
+
+// setHeaders sets the User-Agent and then the caller's headers, which may
+// replace it
+func (nc *NetworkClient) setHeaders(req *http.Request, headers map[string]string) {
+	userAgent := nc.userAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// resolveDownloadBufferSize returns the copy buffer size in bytes used by the download loops.
+// AMO_NET_DOWNLOAD_BUFFER_KB overrides the network_download_buffer_kb config value.
+func resolveDownloadBufferSize(cfg *config.Manager) int {
+	if value := strings.TrimSpace(os.Getenv("AMO_NET_DOWNLOAD_BUFFER_KB")); value != "" {
+		if kb, err := strconv.Atoi(value); err == nil {
+			return downloadBufferSizeFromKB(kb)
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: ignoring invalid AMO_NET_DOWNLOAD_BUFFER_KB value %q\n", value)
+	}
+
+	if cfg != nil {
+		if kb := cfg.GetInt(config.KeyNetworkDownloadBufferKB); kb != 0 {
+			return downloadBufferSizeFromKB(kb)
+		}
+	}
+
+	return defaultDownloadBufferKB * 1024
+}
+
+// downloadBufferSizeFromKB converts a configured size in KB to bytes,
+// warning and falling back to the default when the value is outside the supported range
+func downloadBufferSizeFromKB(kb int) int {
+	if kb < config.MinNetworkDownloadBufferKB || kb > config.MaxNetworkDownloadBufferKB {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: download buffer size %d KB is outside %d-%d KB, using %d KB\n",
+			kb, config.MinNetworkDownloadBufferKB, config.MaxNetworkDownloadBufferKB, defaultDownloadBufferKB)
+		kb = defaultDownloadBufferKB
+	}
+	return kb * 1024
+}
+
+// newDownloadBuffer allocates a copy buffer for a single download
+func (nc *NetworkClient) newDownloadBuffer() []byte {
+	size := nc.downloadBufferSize
+	if size <= 0 {
+		size = defaultDownloadBufferKB * 1024
+	}
+	return make([]byte, size)
+}
+
 type idleTimeoutConn struct {
 	net.Conn
 	idleTimeout time.Duration
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"amo/pkg/env"
+	"amo/pkg/filesystem"
+)
+
+// cachedResponse is the on-disk record of a GET response that carried an ETag
+type cachedResponse struct {
+	URL     string            `json:"url"`
+	ETag    string            `json:"etag"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// GetCached performs an HTTP GET request backed by the on-disk response cache.
+// A cached ETag is sent as If-None-Match and the cached body is returned on 304 Not Modified.
+func (nc *NetworkClient) GetCached(urlStr string, headers map[string]string) *HTTPResponse {
+	cachePath, err := nc.responseCachePath(urlStr)
+	if err != nil {
+		return nc.Get(urlStr, headers)
+	}
+
+	cached := readCachedResponse(cachePath, urlStr)
+
+	requestHeaders := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		requestHeaders[key] = value
+	}
+	if cached != nil && cached.ETag != "" {
+		if _, exists := requestHeaders["If-None-Match"]; !exists {
+			requestHeaders["If-None-Match"] = cached.ETag
+		}
+	}
+
+	response := nc.Get(urlStr, requestHeaders)
+	if response.Error != "" {
+		return response
+	}
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		return &HTTPResponse{
+			StatusCode: http.StatusOK,
+			Headers:    cached.Headers,
+			Body:       cached.Body,
+		}
+	}
+
+	if response.StatusCode == http.StatusOK {
+		if etag := response.Headers[http.CanonicalHeaderKey("ETag")]; etag != "" {
+			writeCachedResponse(cachePath, &cachedResponse{
+				URL:     urlStr,
+				ETag:    etag,
+				Headers: response.Headers,
+				Body:    response.Body,
+			})
+		}
+	}
+
+	return response
+}
+
+// responseCachePath returns the cache file for a URL under the user cache directory
+func (nc *NetworkClient) responseCachePath(urlStr string) (string, error) {
+	cacheDir, err := nc.environment.GetCrossPlatformUtils().GetCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(urlStr))
+	return filepath.Join(cacheDir, env.AppName, "http", fmt.Sprintf("%x.json", sum)), nil
+}
+
+// readCachedResponse loads a cache entry, ignoring missing or corrupt files
+func readCachedResponse(cachePath, urlStr string) *cachedResponse {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil
+	}
+
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil || cached.URL != urlStr {
+		return nil
+	}
+	return &cached
+}
+
+// writeCachedResponse stores a cache entry; failures only cost a cache miss later
+func writeCachedResponse(cachePath string, cached *cachedResponse) {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	_ = filesystem.NewFileSystem().WriteFileAtomic(cachePath, string(data))
+}
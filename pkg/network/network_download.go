@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"amo/pkg/filesystem"
 )
 
 type DownloadProgress struct {
@@ -19,26 +21,30 @@ type DownloadProgress struct {
 	Speed      string `json:"speed"`
 }
 
-func (nc *NetworkClient) DownloadFile(urlStr, outputPath string, progressCallback func(DownloadProgress)) *HTTPResponse {
-	if !nc.isURLAllowed(urlStr) {
-		return &HTTPResponse{
-			Error: fmt.Sprintf("URL not in allowed hosts whitelist: %s", urlStr),
-		}
+// DownloadFile downloads urlStr to outputPath. headers are sent with the
+// request and may override the User-Agent.
+func (nc *NetworkClient) DownloadFile(urlStr, outputPath string, headers map[string]string, progressCallback func(DownloadProgress)) *HTTPResponse {
+	if err := nc.validateURL(urlStr); err != nil {
+		return &HTTPResponse{Error: err.Error(), Cause: err}
 	}
 
-	req, err := http.NewRequest("GET", urlStr, nil)
+	req, err := http.NewRequestWithContext(nc.requestContext(), "GET", urlStr, nil)
 	if err != nil {
 		return &HTTPResponse{
 			Error: fmt.Sprintf("failed to create request: %v", err),
 		}
 	}
 
-	req.Header.Set("User-Agent", "amo-cli/1.0")
+	nc.setHeaders(req, headers)
 
+	if err := nc.awaitTurn(req); err != nil {
+		return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err), Cause: err}
+	}
 	resp, err := nc.client.Do(req)
 	if err != nil {
 		return &HTTPResponse{
 			Error: fmt.Sprintf("request failed: %v", err),
+			Cause: err,
 		}
 	}
 	defer resp.Body.Close()
@@ -68,7 +74,7 @@ func (nc *NetworkClient) DownloadFile(urlStr, outputPath string, progressCallbac
 	contentLength := resp.ContentLength
 
 	var downloaded int64
-	buffer := make([]byte, 32*1024)
+	buffer := nc.newDownloadBuffer()
 	startTime := time.Now()
 
 	for {
@@ -90,7 +96,7 @@ func (nc *NetworkClient) DownloadFile(urlStr, outputPath string, progressCallbac
 					Downloaded: downloaded,
 					Total:      contentLength,
 					Percentage: percentage,
-					Speed:      formatBytes(int64(speed)) + "/s",
+					Speed:      filesystem.FormatBytes(int64(speed)) + "/s",
 				}
 				progressCallback(progress)
 			}
@@ -113,9 +119,36 @@ func (nc *NetworkClient) DownloadFile(urlStr, outputPath string, progressCallbac
 	}
 }
 
-func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressCallback func(DownloadProgress)) *HTTPResponse {
-	if !nc.isURLAllowed(urlStr) {
-		return &HTTPResponse{Error: fmt.Sprintf("URL not in allowed hosts whitelist: %s", urlStr)}
+// partialDownloadPaths returns the .part data file and .part.meta validator
+// file DownloadFileResume keeps for an unfinished download of outputPath
+func partialDownloadPaths(outputPath string) (string, string) {
+	return outputPath + ".part", outputPath + ".part.meta"
+}
+
+// ClearPartialDownload removes the .part and .part.meta files of an
+// unfinished download so the next DownloadFileResume starts from scratch.
+// It reports whether anything was removed.
+func ClearPartialDownload(outputPath string) (bool, error) {
+	removed := false
+	partPath, metaPath := partialDownloadPaths(outputPath)
+	for _, path := range []string{partPath, metaPath} {
+		err := os.Remove(path)
+		switch {
+		case err == nil:
+			removed = true
+		case !os.IsNotExist(err):
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return removed, nil
+}
+
+// DownloadFileResume downloads urlStr to outputPath, resuming a partial
+// download left in outputPath.part. headers are sent with every request; the
+// Range and If-Range headers used for resuming always win.
+func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, headers map[string]string, progressCallback func(DownloadProgress)) *HTTPResponse {
+	if err := nc.validateURL(urlStr); err != nil {
+		return &HTTPResponse{Error: err.Error(), Cause: err}
 	}
 
 	outputDir := filepath.Dir(outputPath)
@@ -123,8 +156,7 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 		return &HTTPResponse{Error: fmt.Sprintf("failed to create output directory: %v", err)}
 	}
 
-	partPath := outputPath + ".part"
-	metaPath := outputPath + ".part.meta"
+	partPath, metaPath := partialDownloadPaths(outputPath)
 
 	var offset int64
 	if info, err := os.Stat(partPath); err == nil {
@@ -132,11 +164,11 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 	}
 
 	buildReq := func(withRange bool) (*http.Request, error) {
-		r, e := http.NewRequest("GET", urlStr, nil)
+		r, e := http.NewRequestWithContext(nc.requestContext(), "GET", urlStr, nil)
 		if e != nil {
 			return nil, e
 		}
-		r.Header.Set("User-Agent", "amo-cli/1.0")
+		nc.setHeaders(r, headers)
 		if withRange && offset > 0 {
 			r.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 			if metaBytes, e2 := os.ReadFile(metaPath); e2 == nil && len(metaBytes) > 0 {
@@ -170,10 +202,14 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 		_ = f.Close()
 		return &HTTPResponse{Error: fmt.Sprintf("failed to create request: %v", err)}
 	}
+	if err := nc.awaitTurn(req); err != nil {
+		_ = f.Close()
+		return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err), Cause: err}
+	}
 	resp, err := nc.client.Do(req)
 	if err != nil {
 		_ = f.Close()
-		return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err)}
+		return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err), Cause: err}
 	}
 
 	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
@@ -213,10 +249,14 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 			_ = f.Close()
 			return &HTTPResponse{Error: fmt.Sprintf("failed to create request: %v", err)}
 		}
+		if err := nc.awaitTurn(req); err != nil {
+			_ = f.Close()
+			return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err), Cause: err}
+		}
 		resp, err = nc.client.Do(req)
 		if err != nil {
 			_ = f.Close()
-			return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err)}
+			return &HTTPResponse{Error: fmt.Sprintf("request failed: %v", err), Cause: err}
 		}
 	}
 
@@ -266,7 +306,7 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 	}
 
 	var downloaded int64
-	buf := make([]byte, 32*1024)
+	buf := nc.newDownloadBuffer()
 	startTime := time.Now()
 	lastReport := startTime
 
@@ -296,7 +336,7 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 						Downloaded: offset + downloaded,
 						Total:      total,
 						Percentage: percent,
-						Speed:      formatBytes(int64(speed)) + "/s",
+						Speed:      filesystem.FormatBytes(int64(speed)) + "/s",
 					})
 					lastReport = now
 				}
@@ -331,16 +371,3 @@ func (nc *NetworkClient) DownloadFileResume(urlStr, outputPath string, progressC
 		Body:       fmt.Sprintf("Downloaded %d bytes to %s", offset+downloaded, outputPath),
 	}
 }
-
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
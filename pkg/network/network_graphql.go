@@ -0,0 +1,106 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"amo/pkg/config"
+)
+
+// githubAPIHost is the host GraphQL requests get the GitHub token for
+const githubAPIHost = "api.github.com"
+
+// PostJSON encodes payload as JSON and POSTs it to urlStr
+func (nc *NetworkClient) PostJSON(urlStr string, payload interface{}, headers map[string]string) *HTTPResponse {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return &HTTPResponse{Error: fmt.Sprintf("failed to encode request body: %v", err)}
+	}
+	return nc.Post(urlStr, string(data), headers)
+}
+
+// GraphQL sends query and variables to a GraphQL endpoint and returns the
+// parsed data and errors of the response. Requests to api.github.com carry
+// the GITHUB_TOKEN or github_token token unless headers set Authorization.
+// error is set when the request fails, the response is not JSON, or the
+// server answered with GraphQL errors and no data.
+func (nc *NetworkClient) GraphQL(urlStr, query string, variables map[string]interface{}, headers map[string]string) map[string]interface{} {
+	payload := map[string]interface{}{"query": query}
+	if len(variables) > 0 {
+		payload["variables"] = variables
+	}
+	response := nc.PostJSON(urlStr, payload, nc.graphQLHeaders(urlStr, headers))
+
+	result := map[string]interface{}{
+		"status_code": response.StatusCode,
+		"headers":     response.Headers,
+	}
+	if response.Error != "" {
+		result["error"] = response.Error
+		return result
+	}
+
+	var body struct {
+		Data   interface{}   `json:"data"`
+		Errors []interface{} `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		result["error"] = fmt.Sprintf("failed to parse GraphQL response (HTTP %d): %v", response.StatusCode, err)
+		result["raw_body"] = response.Body
+		return result
+	}
+	result["data"] = body.Data
+	result["errors"] = body.Errors
+
+	switch {
+	case body.Data == nil && len(body.Errors) > 0:
+		result["error"] = "GraphQL request failed: " + graphQLErrorMessages(body.Errors)
+	case response.StatusCode >= 400:
+		result["error"] = fmt.Sprintf("GraphQL request failed with HTTP %d", response.StatusCode)
+	}
+	return result
+}
+
+// graphQLHeaders adds the GitHub token to requests for the GitHub API
+func (nc *NetworkClient) graphQLHeaders(urlStr string, headers map[string]string) map[string]string {
+	parsed, err := url.Parse(urlStr)
+	if err != nil || !strings.EqualFold(parsed.Hostname(), githubAPIHost) {
+		return headers
+	}
+	for key := range headers {
+		if strings.EqualFold(key, "Authorization") {
+			return headers
+		}
+	}
+	token := nc.githubToken
+	if token == "" {
+		token = config.GitHubToken(nil)
+	}
+	if token == "" {
+		return headers
+	}
+
+	withToken := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		withToken[key] = value
+	}
+	withToken["Authorization"] = "Bearer " + token
+	return withToken
+}
+
+// graphQLErrorMessages joins the message fields of GraphQL errors
+func graphQLErrorMessages(errs []interface{}) string {
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if m, ok := e.(map[string]interface{}); ok {
+			if message, ok := m["message"].(string); ok && message != "" {
+				messages = append(messages, message)
+				continue
+			}
+		}
+		messages = append(messages, fmt.Sprint(e))
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"amo/pkg/config"
+)
+
+// ErrURLNotAllowed is wrapped by the error for a URL whose host is not in the
+// allowed hosts whitelist
+var ErrURLNotAllowed = errors.New("URL not in allowed hosts whitelist")
+
+// Maximum redirects followed by a single request
+const maxRedirects = 10
+
+// How long the private address guard waits for DNS before giving up
+const guardLookupTimeout = 5 * time.Second
+
+// carrierGradeNAT is 100.64.0.0/10 (RFC 6598), which net.IP.IsPrivate does not cover
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPrivateIP reports whether ip is a loopback, private, link-local (including
+// the 169.254.169.254 cloud metadata endpoint) or unspecified address
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() ||
+		carrierGradeNAT.Contains(ip)
+}
+
+// validateURL checks a URL against offline mode, the allowed hosts whitelist
+// and the private address guard; it is applied to the initial URL and to
+// every redirect hop
+func (nc *NetworkClient) validateURL(urlStr string) error {
+	if err := CheckOnline(urlStr); err != nil {
+		return err
+	}
+	if !nc.isURLAllowed(urlStr) {
+		return fmt.Errorf("%w: %s", ErrURLNotAllowed, urlStr)
+	}
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	return nc.checkHostAddress(parsedURL.Hostname())
+}
+
+// checkHostAddress rejects literal private addresses and localhost names
+// unless network_allow_private is enabled. It fails a request early; the
+// authoritative check is dialControl, which sees the address connected to.
+func (nc *NetworkClient) checkHostAddress(host string) error {
+	if nc.allowPrivate || host == "" {
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("host %s is a loopback address; set %s to allow private addresses", host, config.KeyNetworkAllowPrivate)
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return privateAddressError(host, ip)
+	}
+	return nil
+}
+
+// checkResolvedHost resolves host and rejects it if any of its addresses is
+// private. It is used for proxied requests, where the proxy and not our
+// dialer connects to the host; a failed lookup blocks the request.
+func (nc *NetworkClient) checkResolvedHost(host string) error {
+	if err := nc.checkHostAddress(host); err != nil || nc.allowPrivate || net.ParseIP(host) != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), guardLookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot verify that host %s is not a private address: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return privateAddressError(host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// dialControl is the net.Dialer Control hook. It runs for every connection
+// attempt with the resolved address and refuses private addresses unless
+// network_allow_private is enabled; an address it cannot parse is refused.
+func (nc *NetworkClient) dialControl(network, address string, _ syscall.RawConn) error {
+	if nc.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("refusing to dial %s: %w", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("refusing to dial %s: not an IP address", address)
+	}
+	if isPrivateIP(ip) {
+		return privateAddressError(host, ip)
+	}
+	return nil
+}
+
+// proxyForRequest is the http.Transport Proxy hook. It picks the proxy from
+// the environment, records its address so the dialer lets it through, and
+// checks the target host by DNS since the proxy will connect to it.
+func (nc *NetworkClient) proxyForRequest(req *http.Request) (*url.URL, error) {
+	proxyURL, err := http.ProxyFromEnvironment(req)
+	if err != nil || proxyURL == nil {
+		return proxyURL, err
+	}
+	if err := nc.checkResolvedHost(req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	nc.proxyAddrs.Store(proxyDialAddr(proxyURL), struct{}{})
+	return proxyURL, nil
+}
+
+// proxyDialAddr returns the host:port the transport dials for proxyURL
+func proxyDialAddr(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// privateAddressError describes a host that is or resolves to a private ip
+func privateAddressError(host string, ip net.IP) error {
+	if ip.String() == host {
+		return fmt.Errorf("address %s is private, loopback or link-local; set %s to allow it", host, config.KeyNetworkAllowPrivate)
+	}
+	return fmt.Errorf("host %s resolves to private, loopback or link-local address %s; set %s to allow it", host, ip, config.KeyNetworkAllowPrivate)
+}
+
+// checkRedirect is the http.Client CheckRedirect hook. Each hop must pass the
+// same checks as the original URL, so a whitelisted host cannot bounce a
+// request to a host that is not whitelisted or to an internal address.
+func (nc *NetworkClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("too many redirects")
+	}
+	if err := nc.validateURL(req.URL.String()); err != nil {
+		return fmt.Errorf("redirect blocked: %w", err)
+	}
+	return nil
+}
+
+// resolveAllowPrivate reports whether requests to private addresses are allowed.
+// AMO_NET_ALLOW_PRIVATE overrides the network_allow_private config value.
+func resolveAllowPrivate(cfg *config.Manager) bool {
+	if value := strings.TrimSpace(os.Getenv("AMO_NET_ALLOW_PRIVATE")); value != "" {
+		if allow, err := strconv.ParseBool(value); err == nil {
+			return allow
+		}
+	}
+	if cfg != nil {
+		return cfg.GetBool(config.KeyNetworkAllowPrivate)
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvOffline enables offline mode like the --offline flag
+const EnvOffline = "AMO_OFFLINE"
+
+// ErrOffline is returned instead of making a network request in offline mode
+var ErrOffline = errors.New("offline mode: network access is disabled")
+
+// offline is set from the --offline flag
+var offline bool
+
+// SetOffline turns offline mode on or off for the whole process. AMO_OFFLINE
+// enables it as well.
+func SetOffline(enabled bool) {
+	offline = enabled
+}
+
+// IsOffline reports whether network access is disabled by --offline or AMO_OFFLINE
+func IsOffline() bool {
+	if offline {
+		return true
+	}
+	if value := strings.TrimSpace(os.Getenv(EnvOffline)); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		return err == nil && enabled
+	}
+	return false
+}
+
+// CheckOnline returns an error wrapping ErrOffline in offline mode, so callers
+// fail immediately instead of waiting for a connection to time out
+func CheckOnline(urlStr string) error {
+	if IsOffline() {
+		return fmt.Errorf("%w, not fetching %s", ErrOffline, urlStr)
+	}
+	return nil
+}
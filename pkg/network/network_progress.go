@@ -0,0 +1,95 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"amo/pkg/filesystem"
+)
+
+// How often a download of unknown size logs a line when stderr is not a terminal
+const progressLogInterval = 5 * time.Second
+
+// quietProgress is set from the --quiet flag
+var quietProgress bool
+
+// SetQuietProgress turns off all output from ProgressPrinter
+func SetQuietProgress(quiet bool) {
+	quietProgress = quiet
+}
+
+// ProgressPrinter renders download progress on stderr. On a terminal the line
+// is redrawn in place with \r; otherwise (CI logs, redirected output) a plain
+// line is printed every 10%, or every progressLogInterval when the size is
+// unknown, so logs are not filled with carriage returns.
+type ProgressPrinter struct {
+	label       string
+	out         io.Writer
+	tty         bool
+	lastPercent int
+	lastLine    time.Time
+	redrawn     bool
+}
+
+// NewProgressPrinter returns a printer whose lines start with label
+func NewProgressPrinter(label string) *ProgressPrinter {
+	return &ProgressPrinter{
+		label:       label,
+		out:         os.Stderr,
+		tty:         isTerminal(os.Stderr),
+		lastPercent: -1,
+	}
+}
+
+// Update renders a progress report; it can be passed as a download progress callback
+func (p *ProgressPrinter) Update(progress DownloadProgress) {
+	if quietProgress {
+		return
+	}
+
+	if p.tty {
+		if progress.Total > 0 {
+			if progress.Percentage == p.lastPercent {
+				return
+			}
+			p.lastPercent = progress.Percentage
+			fmt.Fprintf(p.out, "\r%s... %3d%% (%s/%s) - %s", p.label, progress.Percentage,
+				filesystem.FormatBytes(progress.Downloaded), filesystem.FormatBytes(progress.Total), progress.Speed)
+		} else {
+			fmt.Fprintf(p.out, "\r%s... %s - %s", p.label, filesystem.FormatBytes(progress.Downloaded), progress.Speed)
+		}
+		p.redrawn = true
+		return
+	}
+
+	if progress.Total > 0 {
+		step := progress.Percentage / 10 * 10
+		if step <= p.lastPercent {
+			return
+		}
+		p.lastPercent = step
+		fmt.Fprintf(p.out, "%s... %d%% (%s/%s) - %s\n", p.label, progress.Percentage,
+			filesystem.FormatBytes(progress.Downloaded), filesystem.FormatBytes(progress.Total), progress.Speed)
+		return
+	}
+	if now := time.Now(); now.Sub(p.lastLine) >= progressLogInterval {
+		p.lastLine = now
+		fmt.Fprintf(p.out, "%s... %s - %s\n", p.label, filesystem.FormatBytes(progress.Downloaded), progress.Speed)
+	}
+}
+
+// Done ends a progress line that was redrawn in place
+func (p *ProgressPrinter) Done() {
+	if p.redrawn {
+		fmt.Fprintln(p.out)
+		p.redrawn = false
+	}
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,8 +1,26 @@
 package network
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"amo/pkg/config"
 	"amo/pkg/env"
 )
 
@@ -142,3 +160,952 @@ func TestPathBasedWhitelist(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckURLReportsMatchedEntry(t *testing.T) {
+	nc := &NetworkClient{
+		allowedSchemes: []string{"https", "http"},
+		allowedHosts:   []string{"github.com/nodewee", "api.github.com/v3", "example.com"},
+	}
+
+	testCases := []struct {
+		url     string
+		allowed bool
+		entry   string
+		reason  string
+	}{
+		{"https://github.com/nodewee/repo", true, "github.com/nodewee", "matched entry"},
+		{"https://api.github.com/v3/users", true, "api.github.com/v3", "matched entry"},
+		{"https://cdn.example.com/file", true, "example.com", "matched entry"},
+		{"https://github.com/other/repo", false, "", `path "/other/repo" is outside`},
+		{"https://notgithub.com/nodewee", false, "", `no entry matches host "notgithub.com"`},
+		{"ftp://example.com/file", false, "", `scheme "ftp" is not allowed`},
+		{"://bad", false, "", "invalid URL"},
+	}
+
+	for _, tc := range testCases {
+		check := nc.CheckURL(tc.url)
+		if check.Allowed != tc.allowed || check.MatchedEntry != tc.entry {
+			t.Errorf("CheckURL(%s) = %+v; expected allowed=%v entry=%q", tc.url, check, tc.allowed, tc.entry)
+		}
+		if !strings.Contains(check.Reason, tc.reason) {
+			t.Errorf("CheckURL(%s).Reason = %q; expected it to contain %q", tc.url, check.Reason, tc.reason)
+		}
+	}
+}
+
+func TestAddAllowedHosts(t *testing.T) {
+	nc := &NetworkClient{
+		allowedSchemes: []string{"https"},
+		allowedHosts:   []string{"github.com"},
+	}
+	if nc.isURLAllowed("https://api.example.com/v1") {
+		t.Fatalf("api.example.com allowed before AddAllowedHosts")
+	}
+
+	nc.AddAllowedHosts(" API.example.com ", "", "github.com", "api.example.com")
+	if !nc.isURLAllowed("https://api.example.com/v1") {
+		t.Errorf("api.example.com not allowed after AddAllowedHosts")
+	}
+	if got := strings.Join(nc.AllowedHosts(), ","); got != "github.com,api.example.com" {
+		t.Errorf("AllowedHosts = %s; expected github.com,api.example.com", got)
+	}
+}
+
+func TestLoadAllowedHostsMergesDropInDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	dropInDir := filepath.Join(home, ".amo", AllowedHostsDir)
+	if err := os.MkdirAll(dropInDir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dropInDir, err)
+	}
+	files := map[string]string{
+		"b-vendor.txt": "# vendor B\ncdn.vendor-b.com\nshared.example.com\n",
+		"a-vendor.txt": "api.vendor-a.com\nshared.example.com\ngithub.com\n",
+		"notes.md":     "ignored.example.com\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dropInDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	client, err := NewNetworkClient()
+	if err != nil {
+		t.Fatalf("NewNetworkClient failed: %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, host := range client.AllowedHosts() {
+		counts[host]++
+	}
+	for _, host := range []string{"api.vendor-a.com", "cdn.vendor-b.com", "shared.example.com", "github.com"} {
+		if counts[host] != 1 {
+			t.Errorf("%s appears %d times in the whitelist; expected once", host, counts[host])
+		}
+	}
+	if counts["ignored.example.com"] != 0 {
+		t.Errorf("Hosts from non-.txt files must not be merged")
+	}
+}
+
+func TestLoadAllowedHostsFollowsActiveProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(config.EnvConfigFile, "")
+
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", configDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("default.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write default whitelist: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.work.txt"), []byte("work.example.com\n"), 0644); err != nil {
+		t.Fatalf("Failed to write profile whitelist: %v", err)
+	}
+	if err := config.SetActiveProfile(configDir, "work"); err != nil {
+		t.Fatalf("SetActiveProfile failed: %v", err)
+	}
+
+	client, err := NewNetworkClient()
+	if err != nil {
+		t.Fatalf("NewNetworkClient failed: %v", err)
+	}
+	if !client.isURLAllowed("https://work.example.com/file") {
+		t.Errorf("Hosts from allowed_hosts.work.txt should be allowed under the work profile")
+	}
+	if client.isURLAllowed("https://default.example.com/file") {
+		t.Errorf("Hosts from the default profile's whitelist must not apply to the work profile")
+	}
+}
+
+func TestDownloadBufferSizeFromKB(t *testing.T) {
+	testCases := []struct {
+		kb       int
+		expected int
+	}{
+		{32, 32 * 1024},
+		{4, 4 * 1024},
+		{1024, 1024 * 1024},
+		{256, 256 * 1024},
+		{0, defaultDownloadBufferKB * 1024},
+		{3, defaultDownloadBufferKB * 1024},
+		{1025, defaultDownloadBufferKB * 1024},
+		{-8, defaultDownloadBufferKB * 1024},
+	}
+
+	for _, tc := range testCases {
+		if got := downloadBufferSizeFromKB(tc.kb); got != tc.expected {
+			t.Errorf("downloadBufferSizeFromKB(%d) = %d; expected %d", tc.kb, got, tc.expected)
+		}
+	}
+}
+
+func TestDownloadFileUsesConfiguredBuffer(t *testing.T) {
+	payload := strings.Repeat("a", 20*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Progress is only reported when the length is known
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		if _, err := w.Write([]byte(payload)); err != nil {
+			t.Errorf("Failed to write payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	bufferSize := downloadBufferSizeFromKB(4)
+	client := &NetworkClient{
+		client:             server.Client(),
+		environment:        environment,
+		allowedSchemes:     []string{"https", "http"},
+		downloadBufferSize: bufferSize,
+		allowPrivate:       true,
+	}
+
+	if got := len(client.newDownloadBuffer()); got != bufferSize {
+		t.Fatalf("Expected download buffer of %d bytes, got %d", bufferSize, got)
+	}
+
+	var last int64
+	var reads int
+	outputPath := filepath.Join(t.TempDir(), "payload.bin")
+	resp := client.DownloadFile(server.URL, outputPath, nil, func(p DownloadProgress) {
+		if chunk := p.Downloaded - last; chunk > int64(bufferSize) {
+			t.Errorf("Read chunk of %d bytes exceeds configured buffer size %d", chunk, bufferSize)
+		}
+		last = p.Downloaded
+		reads++
+	})
+	if resp.Error != "" {
+		t.Fatalf("DownloadFile failed: %s", resp.Error)
+	}
+	if last != int64(len(payload)) {
+		t.Errorf("Expected %d bytes downloaded, got %d", len(payload), last)
+	}
+	if reads < len(payload)/bufferSize {
+		t.Errorf("Expected at least %d reads with a %d byte buffer, got %d", len(payload)/bufferSize, bufferSize, reads)
+	}
+}
+
+func TestNewNetworkClientDownloadBufferSize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		envValue string
+		expected int
+	}{
+		{"Default", "", "", defaultDownloadBufferKB * 1024},
+		{"Config value", "network_download_buffer_kb: 64\n", "", 64 * 1024},
+		{"Config out of range", "network_download_buffer_kb: 5000\n", "", defaultDownloadBufferKB * 1024},
+		{"Env override", "network_download_buffer_kb: 64\n", "128", 128 * 1024},
+		{"Invalid env falls back to config", "network_download_buffer_kb: 64\n", "abc", 64 * 1024},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("USERPROFILE", home)
+			t.Setenv("AMO_NET_DOWNLOAD_BUFFER_KB", tc.envValue)
+
+			configDir := filepath.Join(home, ".amo")
+			if err := os.MkdirAll(configDir, 0755); err != nil {
+				t.Fatalf("Failed to create config dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(configDir, config.ConfigFileName), []byte(tc.config), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			client, err := NewNetworkClient()
+			if err != nil {
+				t.Fatalf("NewNetworkClient failed: %v", err)
+			}
+			if client.downloadBufferSize != tc.expected {
+				t.Errorf("downloadBufferSize = %d; expected %d", client.downloadBufferSize, tc.expected)
+			}
+		})
+	}
+}
+
+func TestGetCachedRevalidatesWithETag(t *testing.T) {
+	const etag = `"v1"`
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if _, err := w.Write([]byte("payload")); err != nil {
+			t.Errorf("Failed to write payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	t.Setenv("HOME", cacheHome)
+	environment, err := env.NewEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create environment: %v", err)
+	}
+	client := &NetworkClient{
+		client:         server.Client(),
+		environment:    environment,
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := client.GetCached(server.URL, nil)
+		if resp.Error != "" {
+			t.Fatalf("GetCached failed: %s", resp.Error)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Body != "payload" {
+			t.Errorf("Request %d: got status %d body %q; expected 200 \"payload\"", i+1, resp.StatusCode, resp.Body)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests to the server, got %d", requests)
+	}
+}
+
+func TestCheckHostAddressBlocksPrivateRanges(t *testing.T) {
+	client := &NetworkClient{allowedSchemes: []string{"https", "http"}}
+
+	testCases := []struct {
+		host    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"localhost", true},
+		{"api.localhost", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			err := client.checkHostAddress(tc.host)
+			if tc.blocked && err == nil {
+				t.Errorf("Expected %s to be blocked", tc.host)
+			}
+			if !tc.blocked && err != nil {
+				t.Errorf("Expected %s to be allowed, got: %v", tc.host, err)
+			}
+		})
+	}
+
+	client.allowPrivate = true
+	if err := client.checkHostAddress("169.254.169.254"); err != nil {
+		t.Errorf("Expected private address to be allowed with allowPrivate, got: %v", err)
+	}
+}
+
+func TestDialControlBlocksPrivateAddresses(t *testing.T) {
+	client := &NetworkClient{}
+
+	testCases := []struct {
+		address string
+		blocked bool
+	}{
+		{"127.0.0.1:80", true},
+		{"[::1]:443", true},
+		{"169.254.169.254:80", true},
+		{"10.0.0.8:8080", true},
+		{"93.184.216.34:443", false},
+		{"[2001:4860:4860::8888]:443", false},
+		// Anything that is not a resolved ip:port fails closed
+		{"example.com:443", true},
+		{"127.0.0.1", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.address, func(t *testing.T) {
+			err := client.dialControl("tcp", tc.address, nil)
+			if tc.blocked && err == nil {
+				t.Errorf("Expected dial to %s to be blocked", tc.address)
+			}
+			if !tc.blocked && err != nil {
+				t.Errorf("Expected dial to %s to be allowed, got: %v", tc.address, err)
+			}
+		})
+	}
+
+	client.allowPrivate = true
+	if err := client.dialControl("tcp", "127.0.0.1:80", nil); err != nil {
+		t.Errorf("Expected private address to be allowed with allowPrivate, got: %v", err)
+	}
+}
+
+func TestTransportRefusesPrivateAddressAtDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The request reached a loopback server")
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "false")
+
+	client, err := NewNetworkClient()
+	if err != nil {
+		t.Fatalf("NewNetworkClient failed: %v", err)
+	}
+
+	// Bypass validateURL to check that the transport enforces the guard itself
+	resp, err := client.client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected the dial to a loopback address to fail")
+	}
+	if !strings.Contains(err.Error(), "loopback") {
+		t.Errorf("Expected a private address error, got: %v", err)
+	}
+}
+
+func TestProxyDialAddr(t *testing.T) {
+	testCases := map[string]string{
+		"http://proxy.internal:3128": "proxy.internal:3128",
+		"http://proxy.internal":      "proxy.internal:80",
+		"https://proxy.internal":     "proxy.internal:443",
+		"socks5://127.0.0.1":         "127.0.0.1:1080",
+	}
+	for raw, expected := range testCases {
+		proxyURL, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", raw, err)
+		}
+		if got := proxyDialAddr(proxyURL); got != expected {
+			t.Errorf("proxyDialAddr(%s) = %s; expected %s", raw, got, expected)
+		}
+	}
+}
+
+func TestCheckRedirectBlocksMetadataEndpoint(t *testing.T) {
+	client := &NetworkClient{allowedSchemes: []string{"https", "http"}}
+
+	req, err := http.NewRequest("GET", "http://169.254.169.254/latest/meta-data/", nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if err := client.checkRedirect(req, []*http.Request{req}); err == nil {
+		t.Error("Expected redirect to the metadata endpoint to be blocked")
+	}
+}
+
+func TestRedirectOffWhitelistIsBlocked(t *testing.T) {
+	var leaked bool
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/allowed/same-host":
+			http.Redirect(w, r, "/secret", http.StatusFound)
+		case "/allowed/other-host":
+			http.Redirect(w, r, strings.Replace(serverURL, "127.0.0.1", "localhost", 1)+"/allowed/ok", http.StatusFound)
+		case "/allowed/ok":
+			if _, err := w.Write([]byte("ok")); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		default:
+			leaked = true
+			if _, err := w.Write([]byte("secret")); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		}
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	// httptest listens on loopback; only the whitelist is under test here
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1/allowed\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+
+	client, err := NewNetworkClient()
+	if err != nil {
+		t.Fatalf("NewNetworkClient failed: %v", err)
+	}
+
+	for _, path := range []string{"/allowed/same-host", "/allowed/other-host"} {
+		t.Run(path, func(t *testing.T) {
+			resp := client.Get(server.URL+path, nil)
+			if resp.Error == "" || !strings.Contains(resp.Error, "redirect blocked") {
+				t.Errorf("Expected Get to be blocked at the redirect, got status %d error %q", resp.StatusCode, resp.Error)
+			}
+
+			outputPath := filepath.Join(t.TempDir(), "out.bin")
+			if resp := client.DownloadFile(server.URL+path, outputPath, nil, nil); resp.Error == "" {
+				t.Error("Expected DownloadFile to be blocked at the redirect")
+			}
+			if resp := client.DownloadFileResume(server.URL+path, outputPath, nil, nil); resp.Error == "" {
+				t.Error("Expected DownloadFileResume to be blocked at the redirect")
+			}
+		})
+	}
+
+	if leaked {
+		t.Error("A request reached a path outside the whitelist")
+	}
+
+	if resp := client.Get(server.URL+"/allowed/ok", nil); resp.Error != "" || resp.Body != "ok" {
+		t.Errorf("Expected whitelisted request to succeed, got body %q error %q", resp.Body, resp.Error)
+	}
+}
+
+func TestRequestMethods(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read request body: %v", err)
+		}
+		w.Header().Set("X-Method", r.Method)
+		if _, err := w.Write([]byte(r.Method + ":" + string(body))); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	tests := []struct {
+		method   string
+		body     string
+		wantBody string
+	}{
+		{"put", `{"a":1}`, `PUT:{"a":1}`},
+		{"PATCH", "x", "PATCH:x"},
+		{"DELETE", "", "DELETE:"},
+		{"HEAD", "", ""},
+	}
+	for _, tt := range tests {
+		resp := client.Request(tt.method, server.URL, tt.body, nil)
+		if resp.Error != "" {
+			t.Errorf("%s failed: %s", tt.method, resp.Error)
+			continue
+		}
+		if resp.Body != tt.wantBody || resp.Headers["X-Method"] != strings.ToUpper(tt.method) {
+			t.Errorf("%s: got body %q method %q", tt.method, resp.Body, resp.Headers["X-Method"])
+		}
+	}
+
+	if resp := client.Request("TRACE", server.URL, "", nil); !strings.Contains(resp.Error, "unsupported HTTP method") {
+		t.Errorf("Expected TRACE to be rejected, got %+v", resp)
+	}
+}
+
+func TestRequestDecodesContentEncoding(t *testing.T) {
+	payload := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0xfe}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			t.Errorf("Failed to compress payload: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Errorf("Failed to compress payload: %v", err)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	// Setting Accept-Encoding turns off the transport's own decompression
+	for _, headers := range []map[string]string{nil, {"Accept-Encoding": "gzip"}} {
+		resp := client.Get(server.URL, headers)
+		if resp.Error != "" {
+			t.Fatalf("Get with headers %v failed: %s", headers, resp.Error)
+		}
+		if resp.Body != string(payload) {
+			t.Errorf("Get with headers %v: got body %q; expected %q", headers, resp.Body, payload)
+		}
+		if _, ok := resp.Headers["Content-Encoding"]; ok {
+			t.Errorf("Get with headers %v kept Content-Encoding after decoding", headers)
+		}
+	}
+}
+
+func TestUserAgentAndDownloadHeaders(t *testing.T) {
+	var gotAgent, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAgent = r.Header.Get("User-Agent")
+		gotAuth = r.Header.Get("Authorization")
+		if _, err := w.Write([]byte("ok")); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		environment:    &env.Environment{},
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	client.Get(server.URL, nil)
+	if gotAgent != DefaultUserAgent {
+		t.Errorf("Got User-Agent %q; expected the default %q", gotAgent, DefaultUserAgent)
+	}
+
+	client.userAgent = "custom-agent/2.0"
+	client.Get(server.URL, nil)
+	if gotAgent != "custom-agent/2.0" {
+		t.Errorf("Got User-Agent %q; expected the configured one", gotAgent)
+	}
+
+	headers := map[string]string{"User-Agent": "per-request/3.0", "Authorization": "Bearer t"}
+	outputDir := t.TempDir()
+	downloads := map[string]func() *HTTPResponse{
+		"DownloadFile": func() *HTTPResponse {
+			return client.DownloadFile(server.URL, filepath.Join(outputDir, "a.bin"), headers, nil)
+		},
+		"DownloadFileResume": func() *HTTPResponse {
+			return client.DownloadFileResume(server.URL, filepath.Join(outputDir, "b.bin"), headers, nil)
+		},
+	}
+	for name, download := range downloads {
+		gotAgent, gotAuth = "", ""
+		if resp := download(); resp.Error != "" {
+			t.Fatalf("%s failed: %s", name, resp.Error)
+		}
+		if gotAgent != "per-request/3.0" || gotAuth != "Bearer t" {
+			t.Errorf("%s sent User-Agent %q Authorization %q; expected the request headers", name, gotAgent, gotAuth)
+		}
+	}
+}
+
+func TestOfflineModeFailsFast(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	t.Setenv(EnvOffline, "1")
+	outputPath := filepath.Join(t.TempDir(), "out.bin")
+	responses := map[string]*HTTPResponse{
+		"Get":                client.Get(server.URL, nil),
+		"Request":            client.Request("PUT", server.URL, "x", nil),
+		"DownloadFile":       client.DownloadFile(server.URL, outputPath, nil, nil),
+		"DownloadFileResume": client.DownloadFileResume(server.URL, outputPath, nil, nil),
+	}
+	for name, resp := range responses {
+		if !strings.Contains(resp.Error, ErrOffline.Error()) {
+			t.Errorf("%s in offline mode returned error %q; expected the offline error", name, resp.Error)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Offline mode still sent %d request(s)", requests)
+	}
+
+	t.Setenv(EnvOffline, "")
+	SetOffline(true)
+	err := CheckOnline(server.URL)
+	SetOffline(false)
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("CheckOnline with SetOffline(true) returned %v; expected ErrOffline", err)
+	}
+	if err := CheckOnline(server.URL); err != nil {
+		t.Errorf("CheckOnline returned %v after offline mode was turned off", err)
+	}
+}
+
+func TestProgressPrinterWithoutTerminal(t *testing.T) {
+	var out bytes.Buffer
+	printer := &ProgressPrinter{label: "Downloading", out: &out, lastPercent: -1}
+	for percent := 0; percent <= 100; percent += 3 {
+		printer.Update(DownloadProgress{Downloaded: int64(percent), Total: 100, Percentage: percent, Speed: "1 B/s"})
+	}
+	printer.Done()
+
+	text := out.String()
+	if strings.Contains(text, "\r") {
+		t.Errorf("Progress for a non-terminal contains carriage returns: %q", text)
+	}
+	if lines := strings.Count(text, "\n"); lines != 10 {
+		t.Errorf("Got %d progress lines; expected one per 10%% step (10):\n%s", lines, text)
+	}
+
+	out.Reset()
+	SetQuietProgress(true)
+	printer = &ProgressPrinter{label: "Downloading", out: &out, tty: true, lastPercent: -1}
+	printer.Update(DownloadProgress{Downloaded: 50, Total: 100, Percentage: 50})
+	printer.Done()
+	SetQuietProgress(false)
+	if out.Len() != 0 {
+		t.Errorf("Quiet progress printed %q", out.String())
+	}
+}
+
+func TestClearPartialDownload(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "file.zip")
+
+	removed, err := ClearPartialDownload(outputPath)
+	if err != nil || removed {
+		t.Fatalf("ClearPartialDownload without partial files = %v, %v; expected false, nil", removed, err)
+	}
+
+	partPath, metaPath := partialDownloadPaths(outputPath)
+	for _, path := range []string{partPath, metaPath} {
+		if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err = ClearPartialDownload(outputPath)
+	if err != nil || !removed {
+		t.Fatalf("ClearPartialDownload = %v, %v; expected true, nil", removed, err)
+	}
+	for _, path := range []string{partPath, metaPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after ClearPartialDownload", path)
+		}
+	}
+}
+
+func TestResponseErrWrapsCause(t *testing.T) {
+	client := &NetworkClient{
+		client:         http.DefaultClient,
+		allowedHosts:   []string{"example.com"},
+		allowedSchemes: []string{"https", "http"},
+	}
+
+	resp := client.Get("https://not-allowed.invalid/file", nil)
+	if err := resp.Err(); !errors.Is(err, ErrURLNotAllowed) {
+		t.Errorf("Err() = %v; expected it to wrap ErrURLNotAllowed", err)
+	} else if err.Error() != resp.Error {
+		t.Errorf("Err() text %q differs from Error %q", err.Error(), resp.Error)
+	}
+
+	if err := (&HTTPResponse{StatusCode: 200}).Err(); err != nil {
+		t.Errorf("Err() of a successful response = %v", err)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		body := "not json"
+		switch req.Query {
+		case "ok":
+			body = fmt.Sprintf(`{"data":{"login":%q}}`, req.Variables["login"])
+		case "partial":
+			body = `{"data":{"a":1},"errors":[{"message":"field b denied"}]}`
+		case "denied":
+			w.WriteHeader(http.StatusUnauthorized)
+			body = `{"data":null,"errors":[{"message":"bad credentials"}]}`
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	tests := []struct {
+		query     string
+		wantErr   string
+		wantData  bool
+		numErrors int
+	}{
+		{"ok", "", true, 0},
+		{"partial", "", true, 1},
+		{"denied", "bad credentials", false, 1},
+		{"garbage", "failed to parse GraphQL response", false, 0},
+	}
+	for _, tt := range tests {
+		result := client.GraphQL(server.URL, tt.query, map[string]interface{}{"login": "octocat"}, nil)
+		errMsg, _ := result["error"].(string)
+		if tt.wantErr == "" && errMsg != "" || !strings.Contains(errMsg, tt.wantErr) {
+			t.Errorf("%s: got error %q; expected %q", tt.query, errMsg, tt.wantErr)
+		}
+		if hasData := result["data"] != nil; hasData != tt.wantData {
+			t.Errorf("%s: got data %v", tt.query, result["data"])
+		}
+		if errs, _ := result["errors"].([]interface{}); len(errs) != tt.numErrors {
+			t.Errorf("%s: got errors %v; expected %d", tt.query, result["errors"], tt.numErrors)
+		}
+	}
+
+	result := client.GraphQL(server.URL, "ok", map[string]interface{}{"login": "octocat"}, nil)
+	if data, _ := result["data"].(map[string]interface{}); data["login"] != "octocat" {
+		t.Errorf("Variables were not sent: got %v", result["data"])
+	}
+}
+
+func TestGraphQLHeadersAddGitHubToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	client := &NetworkClient{githubToken: "secret"}
+
+	tests := []struct {
+		url      string
+		headers  map[string]string
+		wantAuth string
+	}{
+		{"https://api.github.com/graphql", nil, "Bearer secret"},
+		{"https://api.github.com/graphql", map[string]string{"authorization": "token mine"}, ""},
+		{"https://example.com/graphql", nil, ""},
+	}
+	for _, tt := range tests {
+		headers := client.graphQLHeaders(tt.url, tt.headers)
+		if got := headers["Authorization"]; got != tt.wantAuth {
+			t.Errorf("%s %v: got Authorization %q; expected %q", tt.url, tt.headers, got, tt.wantAuth)
+		}
+	}
+	if _, ok := (&NetworkClient{}).graphQLHeaders("https://api.github.com/graphql", nil)["Authorization"]; ok {
+		t.Errorf("Expected no Authorization header without a token")
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Failed to parse multipart form: %v", err)
+			return
+		}
+		file, header, err := r.FormFile("audio")
+		if err != nil {
+			t.Errorf("Missing file part: %v", err)
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		fmt.Fprintf(w, `{"name":%q,"content":%q,"language":%q,"token":%q}`,
+			header.Filename, content, r.FormValue("language"), r.Header.Get("X-Token"))
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+	}
+
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, []byte("RIFF"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// A caller's Content-Type must not replace the multipart boundary
+	headers := map[string]string{"X-Token": "abc", "content-type": "application/json"}
+	response := client.UploadFile(server.URL, "audio", path, map[string]string{"language": "en"}, headers)
+	if response.Error != "" {
+		t.Fatalf("UploadFile failed: %s", response.Error)
+	}
+	expected := `{"name":"clip.wav","content":"RIFF","language":"en","token":"abc"}`
+	if response.Body != expected {
+		t.Errorf("Body = %s; expected %s", response.Body, expected)
+	}
+
+	if response := client.UploadFile(server.URL, "audio", filepath.Join(t.TempDir(), "missing.wav"), nil, nil); response.Error == "" {
+		t.Errorf("Expected an error for a missing file")
+	}
+	if response := client.UploadFile(server.URL, "", path, nil, nil); response.Error == "" {
+		t.Errorf("Expected an error for an empty field name")
+	}
+}
+
+func TestMinIntervalSpacesRequestsPerHost(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+		throttle:       newHostThrottle(),
+	}
+	const interval = 60 * time.Millisecond
+	client.SetMinInterval(interval)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := client.Get(server.URL, nil); resp.Error != "" {
+				t.Errorf("Get failed: %s", resp.Error)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(arrivals, func(i, j int) bool { return arrivals[i].Before(arrivals[j]) })
+	for i := 1; i < len(arrivals); i++ {
+		// Arrivals jitter a little around the reserved start times
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < interval-10*time.Millisecond {
+			t.Errorf("Request %d arrived %v after the previous one; expected at least %v", i, gap, interval)
+		}
+	}
+
+	// A copy without an interval shares the request times but does not wait
+	unthrottled := client.WithMinInterval(0)
+	start := time.Now()
+	if resp := unthrottled.Get(server.URL, nil); resp.Error != "" {
+		t.Fatalf("Get failed: %s", resp.Error)
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("Request with minInterval 0 took %v; expected no delay", elapsed)
+	}
+	if client.MinInterval() != interval {
+		t.Errorf("WithMinInterval changed the original client's interval to %v", client.MinInterval())
+	}
+}
+
+func TestMinIntervalWaitEndsWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	client := &NetworkClient{
+		client:         server.Client(),
+		allowedSchemes: []string{"https", "http"},
+		allowPrivate:   true,
+		throttle:       newHostThrottle(),
+	}
+	client.SetMinInterval(time.Hour)
+	if resp := client.Get(server.URL, nil); resp.Error != "" {
+		t.Fatalf("Get failed: %s", resp.Error)
+	}
+
+	// Another port on the same host has its own interval
+	start := time.Now()
+	if resp := client.Get(other.URL, nil); resp.Error != "" {
+		t.Fatalf("Get to another port failed: %s", resp.Error)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request to another port waited %v; expected no delay", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	resp := client.WithContext(ctx).Get(server.URL, nil)
+	if !errors.Is(resp.Err(), context.DeadlineExceeded) {
+		t.Errorf("Expected the throttled request to end with the context, got %q", resp.Error)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Throttled request took %v after its context ended", elapsed)
+	}
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"amo/pkg/config"
+)
+
+// hostThrottle spaces out requests to the same host. It is shared by a
+// client and the copies made with WithMinInterval.
+type hostThrottle struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newHostThrottle() *hostThrottle {
+	return &hostThrottle{last: make(map[string]time.Time)}
+}
+
+// wait blocks until a request to host may start, interval after the previous
+// request to it, or until ctx is done. The start time is reserved before
+// waiting, so concurrent requests to one host queue up instead of going out
+// together.
+func (t *hostThrottle) wait(ctx context.Context, host string, interval time.Duration) error {
+	if t == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+
+	t.mu.Lock()
+	start := time.Now()
+	if last, ok := t.last[host]; ok && interval > 0 && last.Add(interval).After(start) {
+		start = last.Add(interval)
+	}
+	t.last[host] = start
+	t.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SetMinInterval sets the least time between the starts of two requests to
+// the same host; 0 turns the delay off. It defaults to network_min_interval_ms.
+func (nc *NetworkClient) SetMinInterval(interval time.Duration) {
+	if interval < 0 {
+		interval = 0
+	}
+	nc.minInterval = interval
+}
+
+// MinInterval returns the delay kept between requests to the same host
+func (nc *NetworkClient) MinInterval() time.Duration {
+	return nc.minInterval
+}
+
+// WithMinInterval returns a client for requests that need a different
+// per-host interval, e.g. a workflow's opts.minInterval. It shares the
+// connection pool and the per-host request times with nc.
+func (nc *NetworkClient) WithMinInterval(interval time.Duration) *NetworkClient {
+	clone := *nc
+	clone.SetMinInterval(interval)
+	return &clone
+}
+
+// awaitTurn applies the per-host minimum interval before req is sent. Hosts
+// are keyed with their port, and the wait ends early when req is cancelled.
+func (nc *NetworkClient) awaitTurn(req *http.Request) error {
+	return nc.throttle.wait(req.Context(), req.URL.Host, nc.minInterval)
+}
+
+// resolveMinInterval returns network_min_interval_ms as a duration
+func resolveMinInterval(cfg *config.Manager) time.Duration {
+	if cfg != nil {
+		if ms := cfg.GetInt(config.KeyNetworkMinIntervalMs); ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UploadFile POSTs filePath as the fieldName part of a multipart/form-data
+// request, with fields sent as plain form values ahead of it. The file is
+// streamed rather than read into memory. headers cannot override the
+// Content-Type, which carries the multipart boundary.
+func (nc *NetworkClient) UploadFile(urlStr, fieldName, filePath string, fields, headers map[string]string) *HTTPResponse {
+	if fieldName == "" {
+		return &HTTPResponse{Error: "upload field name cannot be empty"}
+	}
+	// Fail before the writer goroutine starts, so nothing is left blocked on the pipe
+	if err := nc.validateURL(urlStr); err != nil {
+		return &HTTPResponse{Error: err.Error(), Cause: err}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return &HTTPResponse{Error: fmt.Sprintf("failed to open upload file: %v", err), Cause: err}
+	}
+	defer file.Close()
+
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	form := multipart.NewWriter(writer)
+
+	go func() {
+		writer.CloseWithError(writeMultipartForm(form, fieldName, file, fields))
+	}()
+
+	withType := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		if !strings.EqualFold(key, "Content-Type") {
+			withType[key] = value
+		}
+	}
+	withType["Content-Type"] = form.FormDataContentType()
+
+	return nc.request("POST", urlStr, reader, withType)
+}
+
+// writeMultipartForm writes fields, sorted by name, then the file part
+func writeMultipartForm(form *multipart.Writer, fieldName string, file *os.File, fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := form.WriteField(name, fields[name]); err != nil {
+			return fmt.Errorf("failed to write form field %s: %w", name, err)
+		}
+	}
+
+	part, err := form.CreateFormFile(fieldName, filepath.Base(file.Name()))
+	if err != nil {
+		return fmt.Errorf("failed to create file part: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to read upload file: %w", err)
+	}
+	return form.Close()
+}
@@ -3,21 +3,48 @@ package tool
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
+
+	"amo/pkg/config"
+	"amo/pkg/network"
 )
 
-// installViaHomebrew installs a tool using Homebrew
-func (m *Manager) installViaHomebrew(packageName string) error {
+// installViaHomebrew installs a tool's formulae, or casks, using Homebrew.
+// Packages brew already has are skipped.
+func (m *Manager) installViaHomebrew(installInfo InstallInfo) error {
 	if _, err := exec.LookPath("brew"); err != nil {
 		return fmt.Errorf("homebrew not found, install from: https://brew.sh/")
 	}
 
-	cmd := exec.Command("brew", "install", packageName)
+	packages := strings.Fields(installInfo.Package)
+	if len(packages) == 0 {
+		return fmt.Errorf("no homebrew package configured")
+	}
+
+	var missing []string
+	for _, pkg := range packages {
+		// brew list exits non-zero for packages that are not installed
+		if err := exec.Command("brew", brewListArgs(pkg, installInfo.Cask)...).Run(); err == nil {
+			fmt.Printf("✅ %s is already installed via homebrew\n", pkg)
+			continue
+		}
+		missing = append(missing, pkg)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	cmd := exec.Command("brew", brewInstallArgs(missing, installInfo.Cask)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -28,6 +55,23 @@ func (m *Manager) installViaHomebrew(packageName string) error {
 	return nil
 }
 
+// brewListArgs returns the brew arguments that check whether pkg is installed
+func brewListArgs(pkg string, cask bool) []string {
+	if cask {
+		return []string{"list", "--cask", pkg}
+	}
+	return []string{"list", "--formula", pkg}
+}
+
+// brewInstallArgs returns the brew arguments that install packages
+func brewInstallArgs(packages []string, cask bool) []string {
+	args := []string{"install"}
+	if cask {
+		args = append(args, "--cask")
+	}
+	return append(args, packages...)
+}
+
 // installViaPackageManager installs a tool using system package manager
 func (m *Manager) installViaPackageManager(packages map[string]string) error {
 	packageManagers := []string{"apt", "yum", "pacman"}
@@ -42,25 +86,24 @@ func (m *Manager) installViaPackageManager(packages map[string]string) error {
 			continue
 		}
 
-		var cmd *exec.Cmd
-		switch pm {
-		case "apt":
-			cmd = exec.Command("sudo", "apt", "update")
-			cmd.Run()
-			cmd = exec.Command("sudo", "apt", "install", "-y", packageName)
-		case "yum":
-			cmd = exec.Command("sudo", "yum", "install", "-y", packageName)
-		case "pacman":
-			cmd = exec.Command("sudo", "pacman", "-S", "--noconfirm", packageName)
-		default:
+		commands := packageManagerCommands(pm, packageName)
+		if commands == nil {
 			continue
 		}
+		commands, err := m.sudoCommands(commands)
+		if err != nil {
+			return err
+		}
 
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		for i, args := range commands {
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("%s installation failed: %v", pm, err)
+			// Only the last command installs; apt update failing is not fatal
+			if err := cmd.Run(); err != nil && i == len(commands)-1 {
+				return fmt.Errorf("%s installation failed: %v", pm, err)
+			}
 		}
 
 		return nil
@@ -69,27 +112,130 @@ func (m *Manager) installViaPackageManager(packages map[string]string) error {
 	return fmt.Errorf("no suitable package manager found or allowed")
 }
 
-// installViaPip installs a tool using pip
-func (m *Manager) installViaPip(packageName string) error {
-	pipCommands := []string{"pip3", "pip"}
+// packageManagerCommands returns the commands that install packageName with pm
+func packageManagerCommands(pm, packageName string) [][]string {
+	switch pm {
+	case "apt":
+		return [][]string{{"apt", "update"}, {"apt", "install", "-y", packageName}}
+	case "yum":
+		return [][]string{{"yum", "install", "-y", packageName}}
+	case "pacman":
+		return [][]string{{"pacman", "-S", "--noconfirm", packageName}}
+	}
+	return nil
+}
 
-	for _, pip := range pipCommands {
-		if _, err := exec.LookPath(pip); err != nil {
-			continue
-		}
+// sudoCommands prefixes commands with sudo, unless amo already runs as root,
+// after showing them and getting confirmation
+func (m *Manager) sudoCommands(commands [][]string) ([][]string, error) {
+	_, lookErr := exec.LookPath("sudo")
+	return m.prepareSudo(commands, os.Geteuid() == 0, lookErr == nil)
+}
 
-		cmd := exec.Command(pip, "install", packageName)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+func (m *Manager) prepareSudo(commands [][]string, isRoot, haveSudo bool) ([][]string, error) {
+	if isRoot {
+		return commands, nil
+	}
+	if !haveSudo {
+		return nil, fmt.Errorf("this installation needs root but sudo was not found; run it as root instead: %s", formatCommands(commands))
+	}
 
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("pip installation failed: %v", err)
-		}
+	sudo := make([][]string, len(commands))
+	for i, args := range commands {
+		sudo[i] = append([]string{"sudo"}, args...)
+	}
+	line := formatCommands(sudo)
+	fmt.Printf("🔐 This installation runs: %s\n", line)
+	if m.assumeYes {
+		return sudo, nil
+	}
+	if m.confirmSudo == nil || !m.confirmSudo(line) {
+		return nil, fmt.Errorf("sudo installation not confirmed; rerun with --yes to allow it")
+	}
+	return sudo, nil
+}
+
+// formatCommands renders commands as one shell line
+func formatCommands(commands [][]string) string {
+	lines := make([]string, len(commands))
+	for i, args := range commands {
+		lines[i] = strings.Join(args, " ")
+	}
+	return strings.Join(lines, " && ")
+}
 
+// installViaPip installs a tool using pip, or python -m pip for the
+// interpreter set with SetPythonInterpreter. When the environment refuses a
+// system-wide install (PEP 668 externally managed Python, or no permission)
+// it retries with --user, unless a virtualenv is active.
+func (m *Manager) installViaPip(packageName string) error {
+	pip, err := m.pipCommand()
+	if err != nil {
+		return err
+	}
+
+	output, err := runPip(pip, "install", packageName)
+	if err == nil {
 		return nil
 	}
+	if !pipNeedsUserInstall(output) || os.Getenv("VIRTUAL_ENV") != "" {
+		return fmt.Errorf("pip installation failed: %v", err)
+	}
+
+	fmt.Printf("⚠️  %s cannot install into this Python environment, retrying with --user\n", strings.Join(pip, " "))
+	output, err = runPip(pip, "install", "--user", packageName)
+	if err == nil {
+		fmt.Println("💡 Installed with --user; make sure the user scripts directory (python3 -m site --user-base, then bin) is on PATH")
+		return nil
+	}
+	if strings.Contains(output, pipExternallyManaged) {
+		return fmt.Errorf("pip installation failed: this Python is externally managed (PEP 668) and refuses --user installs too; "+
+			"install %s with pipx, or create a virtualenv and rerun with --python <venv>/bin/python", packageName)
+	}
+	return fmt.Errorf("pip --user installation failed: %v", err)
+}
+
+// pipExternallyManaged is the error pip reports for PEP 668 environments
+const pipExternallyManaged = "externally-managed-environment"
+
+// pipCommand returns the command that runs pip: the configured interpreter's
+// pip module, pip3 or pip, or python3's pip module
+func (m *Manager) pipCommand() ([]string, error) {
+	if m.pythonInterpreter != "" {
+		if _, err := exec.LookPath(m.pythonInterpreter); err != nil {
+			return nil, fmt.Errorf("python interpreter %s not found: %v", m.pythonInterpreter, err)
+		}
+		return []string{m.pythonInterpreter, "-m", "pip"}, nil
+	}
+	for _, pip := range []string{"pip3", "pip"} {
+		if _, err := exec.LookPath(pip); err == nil {
+			return []string{pip}, nil
+		}
+	}
+	for _, python := range []string{"python3", "python"} {
+		if _, err := exec.LookPath(python); err == nil {
+			return []string{python, "-m", "pip"}, nil
+		}
+	}
+	return nil, fmt.Errorf("pip not found")
+}
+
+// runPip runs pip with args, showing its output and returning what it wrote
+// to stderr
+func runPip(pip []string, args ...string) (string, error) {
+	var stderr strings.Builder
+	cmd := exec.Command(pip[0], append(pip[1:], args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err := cmd.Run()
+	return stderr.String(), err
+}
 
-	return fmt.Errorf("pip not found")
+// pipNeedsUserInstall reports whether pip failed because it may not write to
+// the environment's site-packages
+func pipNeedsUserInstall(stderr string) bool {
+	return strings.Contains(stderr, pipExternallyManaged) ||
+		strings.Contains(stderr, "[Errno 13] Permission denied")
 }
 
 func (m *Manager) installViaGitHub(toolName string, installInfo InstallInfo) error {
@@ -111,12 +257,16 @@ func (m *Manager) installViaGitHub(toolName string, installInfo InstallInfo) err
 }
 
 func (m *Manager) installFromGitHub(toolName string, installInfo InstallInfo, installDir string) error {
-	release, err := m.getLatestGitHubRelease(installInfo.Repo)
+	release, err := m.getGitHubRelease(installInfo.Repo, installInfo.Tag)
 	if err != nil {
 		return fmt.Errorf("failed to get GitHub release info: %w", err)
 	}
 
-	assetName := m.expandPattern(installInfo.Pattern, release.TagName)
+	version := release.TagName
+	if installInfo.Tag != "" {
+		version = installInfo.Tag
+	}
+	assetName := m.expandPattern(installInfo.Pattern, version)
 	asset := m.findMatchingAsset(release.Assets, assetName)
 	if asset == nil {
 		fmt.Printf("⚠️  Available GitHub assets:\n")
@@ -128,13 +278,13 @@ func (m *Manager) installFromGitHub(toolName string, installInfo InstallInfo, in
 
 	fmt.Printf("📥 Downloading from GitHub: %s (version %s)\n", asset.Name, release.TagName)
 
-	tempFile, err := m.downloadFile(asset.BrowserDownloadURL)
+	tempFile, err := m.downloadFromSources(m.githubAssetSources(installInfo.Repo, installInfo.Tag, asset))
 	if err != nil {
 		return fmt.Errorf("GitHub download failed: %w", err)
 	}
 	defer os.Remove(tempFile)
 
-	targetName := installInfo.Target
+	targetName := m.expandPattern(installInfo.Target, version)
 	if targetName == "" {
 		targetName = toolName
 		if runtime.GOOS == "windows" {
@@ -157,11 +307,29 @@ func (m *Manager) installFromGitHub(toolName string, installInfo InstallInfo, in
 	return nil
 }
 
+// githubAssetSources returns the URLs to try for a release asset, in order. The mirror
+// site is used as a fallback, or as the first choice when the region prefers it.
+// The mirror only carries the latest release, so pinned tags download from GitHub only,
+// as does everything when the mirror is disabled.
+func (m *Manager) githubAssetSources(repo, tag string, asset *GitHubReleaseAsset) []string {
+	if tag != "" || m.noMirror {
+		return []string{asset.BrowserDownloadURL}
+	}
+	mirrorURL := fmt.Sprintf("https://toolchains.mirror.toulan.fun/%s/latest/%s", strings.Trim(repo, "/"), asset.Name)
+	if m.shouldPreferMirror() {
+		fmt.Printf("🌏 Preferring mirror site: toolchains.mirror.toulan.fun\n")
+		return []string{mirrorURL, asset.BrowserDownloadURL}
+	}
+	return []string{asset.BrowserDownloadURL, mirrorURL}
+}
+
 // installViaDownload installs a tool via direct download
 func (m *Manager) installViaDownload(toolName string, installInfo InstallInfo) error {
 	if strings.TrimSpace(installInfo.URL) == "" {
 		return fmt.Errorf("no download URL specified. Provide --url to specify the installer or binary source")
 	}
+	installInfo.URL = m.expandPattern(installInfo.URL, installInfo.Tag)
+	installInfo.Target = m.expandPattern(installInfo.Target, installInfo.Tag)
 	fmt.Printf("📦 Installing %s via download from: %s\n", toolName, installInfo.URL)
 
 	installDir := m.getInstallDir()
@@ -207,6 +375,7 @@ func (m *Manager) installViaInstaller(installInfo InstallInfo) error {
 	if strings.TrimSpace(installInfo.URL) == "" {
 		return fmt.Errorf("no installer URL specified. Provide --url to open a specific installer page")
 	}
+	installInfo.URL = m.expandPattern(installInfo.URL, installInfo.Tag)
 	fmt.Printf("📦 Opening installer download page: %s\n", installInfo.URL)
 	fmt.Printf("💡 Please download and run the installer manually\n")
 	fmt.Printf("   After installation, the tool should be available in your PATH\n")
@@ -231,17 +400,41 @@ func (m *Manager) installViaInstaller(installInfo InstallInfo) error {
 	return fmt.Errorf("manual installation required")
 }
 
-// installViaWorkflow runs a workflow to install the tool
-// getLatestGitHubRelease gets the latest release from a GitHub repository
-func (m *Manager) getLatestGitHubRelease(repo string) (*GitHubRelease, error) {
+// getGitHubRelease gets a release from a GitHub repository: the one tagged tag,
+// or the latest release when tag is empty
+func (m *Manager) getGitHubRelease(repo, tag string) (*GitHubRelease, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+	if tag != "" {
+		url = fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, neturl.PathEscape(tag))
+	}
+
+	if err := network.CheckOnline(url); err != nil {
+		return nil, err
+	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	cfg, _ := config.NewManager()
+	token := config.GitHubToken(cfg)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch release info: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := githubRateLimitError(resp, token != ""); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && tag != "" {
+		return nil, fmt.Errorf("release %s not found in %s", tag, repo)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
 	}
@@ -254,11 +447,42 @@ func (m *Manager) getLatestGitHubRelease(repo string) (*GitHubRelease, error) {
 	return &release, nil
 }
 
-// expandPattern expands placeholders in asset filename patterns
+// githubRateLimitError returns an actionable error when the GitHub API rejected
+// the request because the rate limit is exhausted
+func githubRateLimitError(resp *http.Response, authenticated bool) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return nil
+	}
+
+	resetInfo := ""
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		resetInfo = fmt.Sprintf(" (resets at %s)", time.Unix(reset, 0).Format(time.Kitchen))
+	}
+	if authenticated {
+		return fmt.Errorf("GitHub API rate limit exceeded for the configured token%s", resetInfo)
+	}
+	return fmt.Errorf("GitHub API rate limit exceeded%s. Set the GITHUB_TOKEN environment variable or run `amo config %s <token>` to raise the limit", resetInfo, config.KeyGitHubToken)
+}
+
+// envPlaceholder matches ${NAME} placeholders in install URLs, patterns and targets
+var envPlaceholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Only environment variables with this prefix are expanded, so a tools.json
+// entry cannot send e.g. GITHUB_TOKEN to a download host
+const expandableEnvPrefix = "AMO_"
+
+// expandPattern expands placeholders in install URLs, asset filename patterns
+// and targets: {version} or ${VERSION} (without a leading v), {arch} or
+// ${ARCH}, {os} or ${OS}, and ${AMO_NAME} for environment variables with the
+// AMO_ prefix. Other placeholders and unset variables are left as they are.
 func (m *Manager) expandPattern(pattern, version string) string {
 	result := pattern
 
-	result = strings.ReplaceAll(result, "{version}", strings.TrimPrefix(version, "v"))
+	version = strings.TrimPrefix(version, "v")
+	result = strings.ReplaceAll(result, "{version}", version)
 
 	arch := runtime.GOARCH
 	if arch == "amd64" {
@@ -267,8 +491,26 @@ func (m *Manager) expandPattern(pattern, version string) string {
 		}
 	}
 	result = strings.ReplaceAll(result, "{arch}", arch)
-
-	return result
+	result = strings.ReplaceAll(result, "{os}", runtime.GOOS)
+
+	return envPlaceholder.ReplaceAllStringFunc(result, func(placeholder string) string {
+		name := envPlaceholder.FindStringSubmatch(placeholder)[1]
+		switch name {
+		case "VERSION":
+			return version
+		case "ARCH":
+			return arch
+		case "OS":
+			return runtime.GOOS
+		}
+		if !strings.HasPrefix(name, expandableEnvPrefix) {
+			return placeholder
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return placeholder
+	})
 }
 
 // findMatchingAsset finds an asset that matches the given name pattern
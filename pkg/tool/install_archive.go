@@ -0,0 +1,158 @@
+package tool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"amo/pkg/filesystem"
+)
+
+// Archive formats recognised by installDownloadedFile
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+	archiveTar   = "tar"
+)
+
+// detectArchive returns the archive format of a downloaded file, using the
+// original file name first and falling back to magic bytes. It returns an
+// empty string for anything that should be installed as a raw binary.
+func detectArchive(path, originalName string) string {
+	lower := strings.ToLower(originalName)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	header := make([]byte, 262)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return archiveZip
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return archiveTarGz
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return archiveTar
+	}
+	return ""
+}
+
+// extractAndInstallArchive extracts an archive into a temporary directory, locates
+// the binary matching the target name and installs it at targetPath
+func (m *Manager) extractAndInstallArchive(archivePath, targetPath, format string) error {
+	extractDir, err := os.MkdirTemp("", "amo-extract-*")
+	if err != nil {
+		return fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	defer os.RemoveAll(extractDir)
+	defer filesystem.TrackTempPath(extractDir)()
+
+	// Symlinks and other special entries are skipped; they are never needed to locate a binary
+	files := filesystem.NewFileSystem()
+	switch format {
+	case archiveZip:
+		_, err = files.Unzip(archivePath, extractDir, nil)
+	case archiveTarGz:
+		_, err = files.UnTarGz(archivePath, extractDir, nil)
+	case archiveTar:
+		_, err = files.UnTar(archivePath, extractDir, nil)
+	default:
+		err = fmt.Errorf("unsupported archive format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	binaryPath, err := findExtractedBinary(extractDir, filepath.Base(targetPath))
+	if err != nil {
+		return err
+	}
+
+	return copyExecutable(binaryPath, targetPath)
+}
+
+// findExtractedBinary locates the binary to install inside an extracted archive.
+// A file named like the target wins; otherwise the first executable file is used.
+func findExtractedBinary(dir, targetName string) (string, error) {
+	targetBase := strings.TrimSuffix(targetName, filepath.Ext(targetName))
+	var nameMatch, executable string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || nameMatch != "" {
+			return err
+		}
+
+		fileName := d.Name()
+		if strings.EqualFold(fileName, targetName) ||
+			strings.EqualFold(strings.TrimSuffix(fileName, filepath.Ext(fileName)), targetBase) {
+			nameMatch = path
+			return nil
+		}
+
+		if executable == "" {
+			if runtime.GOOS == "windows" {
+				if strings.HasSuffix(strings.ToLower(fileName), ".exe") {
+					executable = path
+				}
+			} else if info, infoErr := d.Info(); infoErr == nil && info.Mode()&0111 != 0 {
+				executable = path
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan extracted archive: %w", err)
+	}
+
+	if nameMatch != "" {
+		return nameMatch, nil
+	}
+	if executable != "" {
+		return executable, nil
+	}
+	return "", fmt.Errorf("no executable named %s found in archive", targetName)
+}
+
+// copyExecutable copies src to dst and marks it executable
+func copyExecutable(src, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	targetFile, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create target file: %w", err)
+	}
+	defer targetFile.Close()
+
+	if _, err := io.Copy(targetFile, sourceFile); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(dst, 0755); err != nil {
+			return fmt.Errorf("failed to make file executable: %w", err)
+		}
+	}
+	return nil
+}
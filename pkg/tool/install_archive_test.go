@@ -0,0 +1,141 @@
+package tool
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content string
+	mode    int64
+}
+
+func writeTarGz(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
+
+func writeZip(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		header.SetMode(os.FileMode(e.mode))
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
+
+func TestInstallDownloadedFileExtractsArchives(t *testing.T) {
+	entries := []archiveEntry{
+		{"tool-1.0/README.md", "readme", 0644},
+		{"tool-1.0/bin/helper", "helper", 0755},
+		{"tool-1.0/bin/mytool", "binary", 0755},
+	}
+
+	testCases := []struct {
+		name         string
+		originalName string
+		write        func(*testing.T, string, []archiveEntry)
+	}{
+		{"tar.gz by extension", "mytool-linux.tar.gz", writeTarGz},
+		{"tar.gz by magic bytes", "mytool-linux", writeTarGz},
+		{"zip by extension", "mytool-linux.zip", writeZip},
+		{"zip by magic bytes", "download.bin", writeZip},
+	}
+
+	m := &Manager{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, "download")
+			tc.write(t, archivePath, entries)
+
+			targetPath := filepath.Join(dir, "mytool")
+			if err := m.installDownloadedFile(archivePath, targetPath, tc.originalName); err != nil {
+				t.Fatalf("installDownloadedFile failed: %v", err)
+			}
+
+			data, err := os.ReadFile(targetPath)
+			if err != nil {
+				t.Fatalf("Failed to read installed file: %v", err)
+			}
+			if string(data) != "binary" {
+				t.Errorf("Installed content = %q; expected %q", string(data), "binary")
+			}
+		})
+	}
+}
+
+func TestInstallDownloadedFileRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "evil.tar.gz")
+	writeTarGz(t, archivePath, []archiveEntry{
+		{"../../escaped", "evil", 0755},
+		{"mytool", "binary", 0755},
+	})
+
+	m := &Manager{}
+	err := m.installDownloadedFile(archivePath, filepath.Join(dir, "mytool"), "evil.tar.gz")
+	if err == nil {
+		t.Fatal("Expected an error for an archive entry escaping the extraction directory")
+	}
+	if _, statErr := os.Stat(filepath.Join(filepath.Dir(filepath.Dir(dir)), "escaped")); statErr == nil {
+		t.Error("Archive entry was written outside the extraction directory")
+	}
+}
+
+func TestInstallDownloadedFileRawBinary(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "download")
+	if err := os.WriteFile(sourcePath, []byte("#!/bin/sh\necho hi\n"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	targetPath := filepath.Join(dir, "mytool")
+	m := &Manager{}
+	if err := m.installDownloadedFile(sourcePath, targetPath, "mytool"); err != nil {
+		t.Fatalf("installDownloadedFile failed: %v", err)
+	}
+	if data, err := os.ReadFile(targetPath); err != nil || string(data) != "#!/bin/sh\necho hi\n" {
+		t.Errorf("Raw binary not copied as-is: %q, %v", string(data), err)
+	}
+}
@@ -1,15 +1,13 @@
 package tool
 
 import (
-	"archive/zip"
 	"crypto/sha1"
+	"errors"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 
+	"amo/pkg/filesystem"
 	"amo/pkg/network"
 )
 
@@ -23,46 +21,42 @@ func (m *Manager) downloadFile(url string) (string, error) {
 	short := fmt.Sprintf("%x", h)[:10]
 	safeBase := sanitizeFilename(base)
 	tempPath := filepath.Join(tempDir, "amo-"+safeBase+"-"+short)
+	// Removed on Ctrl-C; callers delete it once installed
+	filesystem.TrackTempPath(tempPath)
 
 	nc, err := network.NewNetworkClient()
 	if err != nil {
 		return "", fmt.Errorf("failed to init network client: %w", err)
 	}
 
-	var lastPercent = -1
-	resp := nc.DownloadFileResume(url, tempPath, func(p network.DownloadProgress) {
-		var totalStr string
-		if p.Total > 0 {
-			totalStr = "/" + formatBytes(p.Total)
-		}
-		if p.Total > 0 {
-			if p.Percentage != lastPercent {
-				fmt.Printf("\r⬇️  Downloading... %3d%% (%s%s) - %s", p.Percentage, formatBytes(p.Downloaded), totalStr, p.Speed)
-				lastPercent = p.Percentage
-			}
-		} else {
-			fmt.Printf("\r⬇️  Downloading... %s%s - %s", formatBytes(p.Downloaded), totalStr, p.Speed)
-		}
-	})
-	if resp.Error != "" {
-		fmt.Println()
-		return "", fmt.Errorf("%s", resp.Error)
+	progress := network.NewProgressPrinter("⬇️  Downloading")
+	resp := nc.DownloadFileResume(url, tempPath, nil, progress.Update)
+	progress.Done()
+	if err := resp.Err(); err != nil {
+		return "", err
 	}
-	fmt.Println()
 	return tempPath, nil
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// downloadFromSources downloads the first source that succeeds and returns its temp file path
+func (m *Manager) downloadFromSources(sources []string) (string, error) {
+	var errs []error
+	for i, source := range sources {
+		if i > 0 {
+			fmt.Printf("🔄 Trying %s\n", source)
+		}
+		tempPath, err := m.downloadFile(source)
+		if err == nil {
+			return tempPath, nil
+		}
+		fmt.Printf("⚠️  Download from %s failed: %v\n", source, err)
+		errs = append(errs, err)
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+
+	if len(errs) == 1 {
+		return "", errs[0]
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return "", fmt.Errorf("all download sources failed: %w", errors.Join(errs...))
 }
 
 func sanitizeFilename(name string) string {
@@ -82,89 +76,12 @@ func sanitizeFilename(name string) string {
 	return name
 }
 
+// installDownloadedFile installs a downloaded asset at targetPath. Archives are
+// extracted and the binary matching the target name is installed; anything else
+// is treated as the binary itself.
 func (m *Manager) installDownloadedFile(sourcePath, targetPath, originalName string) error {
-	if strings.HasSuffix(strings.ToLower(originalName), ".zip") {
-		return m.extractAndInstallZip(sourcePath, targetPath, originalName)
-	}
-
-	sourceFile, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer sourceFile.Close()
-
-	targetFile, err := os.Create(targetPath)
-	if err != nil {
-		return fmt.Errorf("failed to create target file: %w", err)
-	}
-	defer targetFile.Close()
-
-	_, err = io.Copy(targetFile, sourceFile)
-	if err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
-	}
-
-	if runtime.GOOS != "windows" {
-		if err := os.Chmod(targetPath, 0755); err != nil {
-			return fmt.Errorf("failed to make file executable: %w", err)
-		}
-	}
-
-	return nil
-}
-
-func (m *Manager) extractAndInstallZip(zipPath, targetPath, originalName string) error {
-	reader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return fmt.Errorf("failed to open zip file: %w", err)
+	if format := detectArchive(sourcePath, originalName); format != "" {
+		return m.extractAndInstallArchive(sourcePath, targetPath, format)
 	}
-	defer reader.Close()
-
-	var executableFile *zip.File
-	targetBaseName := strings.TrimSuffix(filepath.Base(targetPath), filepath.Ext(filepath.Base(targetPath)))
-
-	for _, file := range reader.File {
-		if file.FileInfo().IsDir() {
-			continue
-		}
-
-		fileName := filepath.Base(file.Name)
-		fileNameWithoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-
-		if strings.EqualFold(fileNameWithoutExt, targetBaseName) ||
-			(runtime.GOOS == "windows" && strings.HasSuffix(strings.ToLower(fileName), ".exe")) ||
-			(runtime.GOOS != "windows" && (file.FileInfo().Mode()&0111) != 0) {
-			executableFile = file
-			break
-		}
-	}
-
-	if executableFile == nil {
-		return fmt.Errorf("no executable file found in zip archive")
-	}
-
-	srcFile, err := executableFile.Open()
-	if err != nil {
-		return fmt.Errorf("failed to open file from zip: %w", err)
-	}
-	defer srcFile.Close()
-
-	dstFile, err := os.Create(targetPath)
-	if err != nil {
-		return fmt.Errorf("failed to create target file: %w", err)
-	}
-	defer dstFile.Close()
-
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return fmt.Errorf("failed to extract file: %w", err)
-	}
-
-	if runtime.GOOS != "windows" {
-		if err := os.Chmod(targetPath, 0755); err != nil {
-			return fmt.Errorf("failed to make file executable: %w", err)
-		}
-	}
-
-	return nil
+	return copyExecutable(sourcePath, targetPath)
 }
@@ -1,21 +1,36 @@
 package tool
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
+// installViaWorkflow installs a tool by running an installer workflow, usually
+// one embedded in assets/workflow. The workflow receives toolName, installDir,
+// targetFile and pattern as variables and must leave the tool where its check
+// command finds it; the result is verified with checkToolStatus.
 func (m *Manager) installViaWorkflow(toolName string, installInfo InstallInfo) error {
-	workflowName := installInfo.Workflow
+	workflowName := strings.TrimSpace(installInfo.Workflow)
 	if workflowName == "" {
 		return fmt.Errorf("no workflow specified for tool: %s", toolName)
 	}
+	if !strings.HasSuffix(strings.ToLower(workflowName), ".js") {
+		workflowName += ".js"
+	}
 
 	workflowEngine, err := m.getWorkflowEngine()
 	if err != nil {
 		return fmt.Errorf("failed to get workflow engine: %w", err)
 	}
 
+	installDir := m.getInstallDir()
+	if err := m.environment.GetCrossPlatformUtils().CreateDirWithPermissions(installDir); err != nil {
+		return fmt.Errorf("failed to create install directory: %w", err)
+	}
+
 	params := map[string]interface{}{
 		"toolName":   toolName,
-		"installDir": m.getInstallDir(),
+		"installDir": installDir,
 		"targetFile": installInfo.Target,
 		"pattern":    installInfo.Pattern,
 	}
@@ -33,6 +48,15 @@ func (m *Manager) installViaWorkflow(toolName string, installInfo InstallInfo) e
 		return fmt.Errorf("workflow installation failed: unknown error")
 	}
 
+	// The workflow reporting success is not enough: the tool must be runnable
+	if tool, exists := m.config.Tools[toolName]; exists {
+		m.clearCachedToolPath(tool.Check.Command)
+		status := m.checkToolStatus(toolName, tool)
+		if !status.Installed {
+			return fmt.Errorf("workflow %s completed but %s was not found afterwards: %s", workflowName, tool.Check.Command, status.Error)
+		}
+	}
+
 	fmt.Printf("✅ Workflow completed successfully\n")
 	return nil
 }
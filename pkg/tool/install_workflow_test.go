@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeWorkflowEngine records the workflow run and optionally installs a stub binary
+type fakeWorkflowEngine struct {
+	workflowName string
+	params       map[string]interface{}
+	install      bool
+}
+
+func (f *fakeWorkflowEngine) RunWorkflow(workflowName string, params map[string]interface{}) (map[string]interface{}, error) {
+	f.workflowName = workflowName
+	f.params = params
+	if f.install {
+		installDir := params["installDir"].(string)
+		script := "#!/bin/sh\necho \"faketool 1.2.3\"\n"
+		if err := os.WriteFile(filepath.Join(installDir, "faketool"), []byte(script), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"success": true}, nil
+}
+
+func TestInstallViaWorkflow(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub binary is a shell script")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	testCases := []struct {
+		name        string
+		install     bool
+		expectError string
+	}{
+		{"Workflow installs the tool", true, ""},
+		{"Workflow succeeds without installing", false, "was not found afterwards"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manager, err := NewManager()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			manager.config = &ToolConfig{Tools: map[string]Tool{
+				"faketool": {
+					Name:  "Fake Tool",
+					Check: CheckConfig{Command: "faketool", Args: []string{"--version"}, Pattern: `faketool (\S+)`},
+				},
+			}}
+			installDir := filepath.Join(t.TempDir(), "tools")
+			if err := manager.SetInstallDir(installDir); err != nil {
+				t.Fatalf("SetInstallDir failed: %v", err)
+			}
+			engine := &fakeWorkflowEngine{install: tc.install}
+			manager.SetWorkflowEngine(engine)
+
+			err = manager.installViaWorkflow("faketool", InstallInfo{Method: "script", Workflow: "faketool-installer", Target: "faketool"})
+			if tc.expectError == "" && err != nil {
+				t.Fatalf("installViaWorkflow failed: %v", err)
+			}
+			if tc.expectError != "" && (err == nil || !strings.Contains(err.Error(), tc.expectError)) {
+				t.Fatalf("Expected error containing %q, got: %v", tc.expectError, err)
+			}
+
+			if engine.workflowName != "faketool-installer.js" {
+				t.Errorf("Workflow name = %q; expected faketool-installer.js", engine.workflowName)
+			}
+			if engine.params["toolName"] != "faketool" || engine.params["installDir"] != installDir || engine.params["targetFile"] != "faketool" {
+				t.Errorf("Unexpected workflow params: %v", engine.params)
+			}
+		})
+	}
+}
+
+func TestInstallViaWorkflowRequiresEngine(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	manager.config = &ToolConfig{Tools: map[string]Tool{}}
+
+	if err := manager.installViaWorkflow("x", InstallInfo{Method: "workflow"}); err == nil {
+		t.Error("Expected error when no workflow is specified")
+	}
+	if err := manager.installViaWorkflow("x", InstallInfo{Method: "workflow", Workflow: "x-installer"}); err == nil {
+		t.Error("Expected error when no workflow engine is set")
+	}
+}
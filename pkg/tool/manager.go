@@ -1,25 +1,62 @@
 package tool
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"amo/pkg/env"
+	"amo/pkg/filesystem"
 )
 
+// ErrToolNotFound is wrapped by errors for tool names that are not in the
+// tool configuration
+var ErrToolNotFound = errors.New("unknown tool")
+
+// ErrCheckTimeout is wrapped by errors for check commands that did not finish
+// in time, e.g. a tool prompting for input on --version
+var ErrCheckTimeout = errors.New("check command timed out")
+
+// DefaultCheckTimeout bounds check commands without check.timeout_seconds
+const DefaultCheckTimeout = 10 * time.Second
+
 // Manager handles tool management operations
 type Manager struct {
 	config         *ToolConfig
 	environment    *env.Environment
 	pathCache      *ToolPathCache
 	workflowEngine WorkflowEngine
+
+	// cacheMu guards pathCache, which tool checks update while others read it
+	cacheMu sync.RWMutex
+
+	// preferMirror is resolved from the detected region on first GitHub download
+	// unless SetPreferMirror has been called
+	preferMirror    bool
+	preferMirrorSet bool
+	// noMirror downloads releases from GitHub only, without mirror fallback
+	noMirror bool
+
+	// installDirOverride replaces the configured install directory when set
+	installDirOverride string
+
+	// confirmSudo is asked before package manager commands run under sudo;
+	// without it, or with a false answer, they do not run unless assumeYes
+	confirmSudo func(command string) bool
+	assumeYes   bool
+
+	// pythonInterpreter, when set, runs pip installs as <interpreter> -m pip
+	pythonInterpreter string
 }
 
 // InstallOptions represents optional parameters to override installation behavior
@@ -28,6 +65,12 @@ type InstallOptions struct {
 	// the manager will install directly from this URL regardless of
 	// the method defined in assets/tools.json.
 	URL string
+	// Tag pins a GitHub release (e.g. v1.2.3) instead of the latest one.
+	// Only applies to tools installed via the github method.
+	Tag string
+	// InstallDir overrides the configured install directory for placement,
+	// path caching and PATH setup.
+	InstallDir string
 }
 
 // NewManager creates a new tool manager
@@ -45,7 +88,7 @@ func NewManager() (*Manager, error) {
 	if err := manager.loadPathCache(); err != nil {
 		// If cache loading fails, create a new empty cache
 		manager.pathCache = &ToolPathCache{
-			Version:   "1.0.0",
+			Version:   PathCacheVersion,
 			Timestamp: time.Now().Unix(),
 			Paths:     make(map[string]string),
 		}
@@ -59,6 +102,40 @@ func (m *Manager) SetWorkflowEngine(engine WorkflowEngine) {
 	m.workflowEngine = engine
 }
 
+// SetPreferMirror controls whether the mirror site is tried before GitHub for
+// release downloads. It is enabled automatically when the detected region is cn.
+func (m *Manager) SetPreferMirror(prefer bool) {
+	m.preferMirror = prefer
+	m.preferMirrorSet = true
+}
+
+// SetNoMirror disables the mirror site, so release downloads only use GitHub
+func (m *Manager) SetNoMirror(noMirror bool) {
+	m.noMirror = noMirror
+}
+
+// SetSudoConfirm sets the question asked before a package manager install
+// runs commands under sudo. assumeYes answers it without asking, as --yes does.
+func (m *Manager) SetSudoConfirm(assumeYes bool, confirm func(command string) bool) {
+	m.assumeYes = assumeYes
+	m.confirmSudo = confirm
+}
+
+// SetPythonInterpreter makes pip installs use interpreter -m pip, e.g. the
+// python of a virtualenv. An empty interpreter restores the pip on PATH.
+func (m *Manager) SetPythonInterpreter(interpreter string) {
+	m.pythonInterpreter = strings.TrimSpace(interpreter)
+}
+
+// shouldPreferMirror detects the region once, on first use
+func (m *Manager) shouldPreferMirror() bool {
+	if !m.preferMirrorSet {
+		m.preferMirror = m.environment.DetectRegion() == "cn"
+		m.preferMirrorSet = true
+	}
+	return m.preferMirror
+}
+
 // LoadConfig loads tool configuration from embedded assets
 func (m *Manager) LoadConfig(configData []byte) error {
 	var config ToolConfig
@@ -92,12 +169,80 @@ func (m *Manager) loadPathCache() error {
 		return fmt.Errorf("failed to parse cache file: %w", err)
 	}
 
+	changed, err := migratePathCache(&cache)
+	if err != nil {
+		return err
+	}
+
+	m.cacheMu.Lock()
 	m.pathCache = &cache
+	m.cacheMu.Unlock()
+
+	if changed {
+		// Best effort: the migrated cache is in use either way
+		_ = m.savePathCache()
+	}
 	return nil
 }
 
+// migratePathCache brings a cache written by an older version up to
+// PathCacheVersion and drops entries that are not absolute paths. It reports
+// whether the cache changed and should be rewritten. A cache from a newer
+// version is rejected, as its entries may mean something else.
+func migratePathCache(cache *ToolPathCache) (bool, error) {
+	changed := false
+
+	switch cmp := compareCacheVersions(cache.Version, PathCacheVersion); {
+	case cmp > 0:
+		return false, fmt.Errorf("cache version %s is newer than supported version %s", cache.Version, PathCacheVersion)
+	case cmp < 0:
+		// Caches before 1.0.0 had the same layout; only the version is new
+		cache.Version = PathCacheVersion
+		changed = true
+	}
+
+	if cache.Paths == nil {
+		cache.Paths = make(map[string]string)
+		changed = true
+	}
+	for name, path := range cache.Paths {
+		if name == "" || path == "" || !filepath.IsAbs(path) {
+			delete(cache.Paths, name)
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// compareCacheVersions compares dotted numeric versions such as 1.0.0.
+// Missing or non-numeric parts count as 0, so an empty version is the oldest.
+func compareCacheVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
 // savePathCache saves the tool path cache to file
 func (m *Manager) savePathCache() error {
+	// Held for the write too, so concurrent saves cannot interleave
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
 	if m.pathCache == nil {
 		return fmt.Errorf("path cache is nil")
 	}
@@ -112,7 +257,8 @@ func (m *Manager) savePathCache() error {
 		return fmt.Errorf("failed to marshal cache: %w", err)
 	}
 
-	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+	// Written atomically so an interrupted save cannot leave a corrupt cache
+	if err := filesystem.NewFileSystem().WriteFileAtomic(cacheFile, string(data)); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
@@ -121,6 +267,9 @@ func (m *Manager) savePathCache() error {
 
 // getCachedToolPath returns the cached path for a tool
 func (m *Manager) getCachedToolPath(toolName string) (string, bool) {
+	m.cacheMu.RLock()
+	defer m.cacheMu.RUnlock()
+
 	if m.pathCache == nil {
 		return "", false
 	}
@@ -136,19 +285,28 @@ func (m *Manager) GetCachedToolPath(toolName string) (string, bool) {
 
 // setCachedToolPath sets the cached path for a tool
 func (m *Manager) setCachedToolPath(toolName, path string) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
 	if m.pathCache == nil {
 		m.pathCache = &ToolPathCache{
-			Version:   "1.0.0",
+			Version:   PathCacheVersion,
 			Timestamp: time.Now().Unix(),
 			Paths:     make(map[string]string),
 		}
 	}
+	if m.pathCache.Paths == nil {
+		m.pathCache.Paths = make(map[string]string)
+	}
 
 	m.pathCache.Paths[toolName] = path
 }
 
 // clearCachedToolPath removes the cached path for a tool
 func (m *Manager) clearCachedToolPath(toolName string) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
 	if m.pathCache != nil {
 		delete(m.pathCache.Paths, toolName)
 	}
@@ -156,6 +314,21 @@ func (m *Manager) clearCachedToolPath(toolName string) {
 
 // findToolExecutable searches for tool executable in common locations
 func (m *Manager) findToolExecutable(tool Tool) string {
+	installDir := m.getInstallDir()
+	customPath := filepath.Join(installDir, tool.Check.Command)
+	if runtime.GOOS == "windows" && !strings.HasSuffix(customPath, ".exe") {
+		customPath += ".exe"
+	}
+
+	// An overridden install directory takes precedence over the cache,
+	// which may point at a copy installed elsewhere
+	if m.installDirOverride != "" {
+		if _, err := os.Stat(customPath); err == nil {
+			m.setCachedToolPath(tool.Check.Command, customPath)
+			return customPath
+		}
+	}
+
 	// First check cached path
 	if cachedPath, exists := m.getCachedToolPath(tool.Check.Command); exists {
 		if _, err := os.Stat(cachedPath); err == nil {
@@ -166,11 +339,6 @@ func (m *Manager) findToolExecutable(tool Tool) string {
 	}
 
 	// Check custom install directory first
-	installDir := m.getInstallDir()
-	customPath := filepath.Join(installDir, tool.Check.Command)
-	if runtime.GOOS == "windows" && !strings.HasSuffix(customPath, ".exe") {
-		customPath += ".exe"
-	}
 	if _, err := os.Stat(customPath); err == nil {
 		m.setCachedToolPath(tool.Check.Command, customPath)
 		return customPath
@@ -280,7 +448,7 @@ func (m *Manager) CheckTool(toolName string) (*ToolStatus, error) {
 
 	tool, exists := m.config.Tools[toolName]
 	if !exists {
-		return nil, fmt.Errorf("tool '%s' not found", toolName)
+		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, toolName)
 	}
 
 	status := m.checkToolStatus(toolName, tool)
@@ -294,6 +462,30 @@ func (m *Manager) CheckTool(toolName string) (*ToolStatus, error) {
 	return &status, nil
 }
 
+// timeout returns how long each check command may run
+func (c CheckConfig) timeout() time.Duration {
+	if c.TimeoutSeconds > 0 {
+		return time.Duration(c.TimeoutSeconds) * time.Second
+	}
+	return DefaultCheckTimeout
+}
+
+// runCheckCommand runs a check command with no stdin, killing it after
+// timeout. A timeout is reported as ErrCheckTimeout.
+func runCheckCommand(command string, args []string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	// Do not wait for children that inherited the output pipes after the kill
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("%w after %s: %s", ErrCheckTimeout, timeout, command)
+	}
+	return output, err
+}
+
 // checkToolStatus performs the actual tool status check
 func (m *Manager) checkToolStatus(toolName string, tool Tool) ToolStatus {
 	status := ToolStatus{
@@ -316,10 +508,8 @@ func (m *Manager) checkToolStatus(toolName string, tool Tool) ToolStatus {
 		args = []string{"--version"}
 	}
 
-	// No timeout restriction for tool management operations
-	cmd := exec.Command(command, args...)
-
-	output, err := cmd.CombinedOutput()
+	timeout := tool.Check.timeout()
+	output, err := runCheckCommand(command, args, timeout)
 	if err != nil {
 		// If primary command failed, try fallback commands
 		if len(tool.Check.FallbackCommands) > 0 {
@@ -333,8 +523,7 @@ func (m *Manager) checkToolStatus(toolName string, tool Tool) ToolStatus {
 					fallbackArgs = []string{"--version"}
 				}
 
-				fallbackCmd := exec.Command(fallbackCommand, fallbackArgs...)
-				if fallbackOutput, fallbackErr := fallbackCmd.CombinedOutput(); fallbackErr == nil {
+				if fallbackOutput, fallbackErr := runCheckCommand(fallbackCommand, fallbackArgs, timeout); fallbackErr == nil {
 					// Fallback command succeeded, use its output
 					command = fallbackCommand
 					output = fallbackOutput
@@ -345,6 +534,11 @@ func (m *Manager) checkToolStatus(toolName string, tool Tool) ToolStatus {
 		}
 
 		// If still no success, return error
+		if errors.Is(err, ErrCheckTimeout) {
+			// The executable exists but hung, so its cached path stays valid
+			status.Error = err.Error()
+			return status
+		}
 		if err != nil {
 			status.Error = fmt.Sprintf("command failed: %v", err)
 			// Clear cached path if command failed
@@ -383,9 +577,60 @@ func (m *Manager) checkToolStatus(toolName string, tool Tool) ToolStatus {
 		status.Version = "unknown"
 	}
 
+	if status.Installed && tool.Check.MinVersion != "" {
+		status.Outdated = versionLess(status.Version, tool.Check.MinVersion)
+	}
+
 	return status
 }
 
+// versionLess reports whether version is below min, comparing the numbers of
+// dotted versions such as 6.1.1 or 7.1.0-20. A version without a leading
+// number ("available", "unknown") is never reported as less.
+func versionLess(version, min string) bool {
+	a, b := versionNumbers(version), versionNumbers(min)
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return false
+}
+
+// versionNumbers parses the leading dotted numbers of a version, skipping a v prefix
+func versionNumbers(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	var numbers []int
+	for _, part := range strings.Split(version, ".") {
+		digits := 0
+		for digits < len(part) && part[digits] >= '0' && part[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 {
+			break
+		}
+		n, err := strconv.Atoi(part[:digits])
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+		if digits < len(part) {
+			break
+		}
+	}
+	return numbers
+}
+
 // InstallTool installs a specific tool
 func (m *Manager) InstallTool(toolName string, forceReinstall bool) error {
 	return m.InstallToolWithOptions(toolName, forceReinstall, nil)
@@ -399,7 +644,7 @@ func (m *Manager) InstallToolWithOptions(toolName string, forceReinstall bool, o
 
 	tool, exists := m.config.Tools[toolName]
 	if !exists {
-		return fmt.Errorf("unknown tool: %s", toolName)
+		return fmt.Errorf("%w: %s", ErrToolNotFound, toolName)
 	}
 
 	// Check if already installed and not forcing reinstall
@@ -417,6 +662,12 @@ func (m *Manager) InstallToolWithOptions(toolName string, forceReinstall bool, o
 
 	fmt.Printf("📦 Installing %s...\n", tool.Name)
 
+	if opts != nil && strings.TrimSpace(opts.InstallDir) != "" {
+		if err := m.SetInstallDir(opts.InstallDir); err != nil {
+			return err
+		}
+	}
+
 	// Get platform-specific install info
 	osName := m.environment.GetOperatingSystem()
 	installInfo, exists := tool.Install[osName]
@@ -424,6 +675,14 @@ func (m *Manager) InstallToolWithOptions(toolName string, forceReinstall bool, o
 		return fmt.Errorf("installation not supported for platform: %s", osName)
 	}
 
+	// A pinned tag selects a specific GitHub release
+	if opts != nil && strings.TrimSpace(opts.Tag) != "" {
+		if installInfo.Method != "github" {
+			return fmt.Errorf("--tag is only supported for tools installed from GitHub releases (%s uses %s)", toolName, installInfo.Method)
+		}
+		installInfo.Tag = strings.TrimSpace(opts.Tag)
+	}
+
 	// If a URL override is provided, install directly from that URL
 	if opts != nil && strings.TrimSpace(opts.URL) != "" {
 		ovr := InstallInfo{URL: strings.TrimSpace(opts.URL), Target: installInfo.Target}
@@ -447,7 +706,7 @@ func (m *Manager) InstallToolWithOptions(toolName string, forceReinstall bool, o
 	var err error
 	switch installInfo.Method {
 	case "homebrew":
-		err = m.installViaHomebrew(installInfo.Package)
+		err = m.installViaHomebrew(installInfo)
 	case "package":
 		err = m.installViaPackageManager(installInfo.Packages)
 	case "pip":
@@ -458,7 +717,7 @@ func (m *Manager) InstallToolWithOptions(toolName string, forceReinstall bool, o
 		err = m.installViaDownload(toolName, installInfo)
 	case "installer":
 		err = m.installViaInstaller(installInfo)
-	case "workflow":
+	case "workflow", "script":
 		err = m.installViaWorkflow(toolName, installInfo)
 	default:
 		m.printManualInstallInstructions(toolName, installInfo)
@@ -488,8 +747,31 @@ func (m *Manager) InstallToolWithOptions(toolName string, forceReinstall bool, o
 	return nil
 }
 
+// SetInstallDir overrides the configured install directory for this manager.
+// Relative paths are resolved against the current working directory; an empty
+// dir restores the configured directory.
+func (m *Manager) SetInstallDir(dir string) error {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		m.installDirOverride = ""
+		return nil
+	}
+
+	crossPlatform := m.environment.GetCrossPlatformUtils()
+	absDir, err := filepath.Abs(crossPlatform.NormalizePath(dir))
+	if err != nil {
+		return fmt.Errorf("invalid install directory %s: %w", dir, err)
+	}
+	m.installDirOverride = absDir
+	return nil
+}
+
 // getInstallDir returns the installation directory for tools
 func (m *Manager) getInstallDir() string {
+	if m.installDirOverride != "" {
+		return m.installDirOverride
+	}
+
 	config := m.config.Config
 	installDirConfig, ok := config["install_dir"].(map[string]interface{})
 	if !ok {
@@ -593,6 +875,8 @@ func (m *Manager) GetToolPathCacheInfo() map[string]interface{} {
 		"tool_count": 0,
 	}
 
+	m.cacheMu.RLock()
+	defer m.cacheMu.RUnlock()
 	if m.pathCache != nil {
 		info["version"] = m.pathCache.Version
 		info["timestamp"] = time.Unix(m.pathCache.Timestamp, 0).Format("2006-01-02 15:04:05")
@@ -604,6 +888,9 @@ func (m *Manager) GetToolPathCacheInfo() map[string]interface{} {
 
 // GetCachedToolPaths returns all cached tool command-to-path mappings
 func (m *Manager) GetCachedToolPaths() map[string]string {
+	m.cacheMu.RLock()
+	defer m.cacheMu.RUnlock()
+
 	if m.pathCache == nil {
 		return make(map[string]string)
 	}
@@ -617,6 +904,59 @@ func (m *Manager) GetCachedToolPaths() map[string]string {
 	return paths
 }
 
+// CheckExecutablePath returns the absolute form of path after checking that
+// it is an existing executable file
+func CheckExecutablePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot use %s: %w", absPath, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, not an executable", absPath)
+	}
+	if !isExecutableFile(absPath, info) {
+		return "", fmt.Errorf("%s is not executable", absPath)
+	}
+	return absPath, nil
+}
+
+// PinToolPath caches path as the location of command and saves the cache,
+// for tools installed somewhere amo does not search. Check the path with
+// CheckExecutablePath first.
+func (m *Manager) PinToolPath(command, path string) error {
+	m.setCachedToolPath(command, path)
+	return m.savePathCache()
+}
+
+// UnpinToolPath removes the cached path of command and saves the cache. It
+// reports whether a path was cached.
+func (m *Manager) UnpinToolPath(command string) (bool, error) {
+	if _, exists := m.getCachedToolPath(command); !exists {
+		return false, nil
+	}
+	m.clearCachedToolPath(command)
+	if err := m.savePathCache(); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// isExecutableFile checks the execute bits, or the extension on Windows
+func isExecutableFile(path string, info os.FileInfo) bool {
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd", ".com":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
 // GetToolNames returns a list of all available tool names
 func (m *Manager) GetToolNames() []string {
 	if m.config == nil {
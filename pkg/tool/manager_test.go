@@ -0,0 +1,362 @@
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPathCacheConcurrentAccess(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				command := fmt.Sprintf("tool%d", i)
+				manager.setCachedToolPath(command, fmt.Sprintf("/bin/%s-%d", command, j))
+				manager.GetCachedToolPaths()
+				manager.GetToolPathCacheInfo()
+				if j%10 == 0 {
+					if err := manager.savePathCache(); err != nil {
+						t.Errorf("savePathCache failed: %v", err)
+					}
+				}
+				if i%2 == 0 {
+					manager.clearCachedToolPath(command)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := manager.savePathCache(); err != nil {
+		t.Fatalf("savePathCache failed: %v", err)
+	}
+	data, err := os.ReadFile(manager.getToolPathCacheFile())
+	if err != nil {
+		t.Fatalf("Failed to read cache file: %v", err)
+	}
+	var cache ToolPathCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		t.Fatalf("Cache file is not valid JSON: %v", err)
+	}
+	if len(cache.Paths) != 4 {
+		t.Errorf("Cached %d tools; expected the 4 that were not cleared", len(cache.Paths))
+	}
+
+	paths := manager.GetCachedToolPaths()
+	paths["injected"] = "/tmp/x"
+	if _, ok := manager.GetCachedToolPath("injected"); ok {
+		t.Error("Modifying the returned map changed the cache")
+	}
+}
+
+func TestLoadPathCacheMigratesOldCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	absPath, err := filepath.Abs(filepath.Join(home, "bin", "ffmpeg"))
+	if err != nil {
+		t.Fatalf("Failed to build absolute path: %v", err)
+	}
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	cacheFile := manager.getToolPathCacheFile()
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	old := map[string]interface{}{
+		"timestamp": 1,
+		"paths":     map[string]string{"ffmpeg": absPath, "jq": "bin/jq", "yq": ""},
+	}
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatalf("Failed to marshal cache: %v", err)
+	}
+	if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	manager, err = NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	paths := manager.GetCachedToolPaths()
+	if len(paths) != 1 || paths["ffmpeg"] != absPath {
+		t.Errorf("Got cached paths %v; expected only the absolute ffmpeg path", paths)
+	}
+
+	var rewritten ToolPathCache
+	data, err = os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatalf("Failed to read rewritten cache: %v", err)
+	}
+	if err := json.Unmarshal(data, &rewritten); err != nil {
+		t.Fatalf("Failed to parse rewritten cache: %v", err)
+	}
+	if rewritten.Version != PathCacheVersion || len(rewritten.Paths) != 1 {
+		t.Errorf("Rewritten cache has version %q and %d paths; expected %s and 1", rewritten.Version, len(rewritten.Paths), PathCacheVersion)
+	}
+
+	newer := `{"version": "99.0.0", "paths": {"ffmpeg": "` + filepath.ToSlash(absPath) + `"}}`
+	if err := os.WriteFile(cacheFile, []byte(newer), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+	if err := manager.loadPathCache(); err == nil {
+		t.Error("Expected a cache from a newer version to be rejected")
+	}
+}
+
+func TestCheckExecutablePath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("checks execute permission bits")
+	}
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write executable: %v", err)
+	}
+	plain := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(plain, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{executable, false},
+		{plain, true},
+		{dir, true},
+		{filepath.Join(dir, "missing"), true},
+	}
+	for _, tt := range tests {
+		got, err := CheckExecutablePath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckExecutablePath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+		if err == nil && got != tt.path {
+			t.Errorf("CheckExecutablePath(%q) = %q", tt.path, got)
+		}
+	}
+}
+
+func TestUnknownToolIsErrToolNotFound(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	manager.config = &ToolConfig{Tools: map[string]Tool{}}
+
+	if _, err := manager.CheckTool("no-such-tool"); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("CheckTool error = %v; expected ErrToolNotFound", err)
+	}
+	if err := manager.InstallTool("no-such-tool", false); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("InstallTool error = %v; expected ErrToolNotFound", err)
+	}
+}
+
+func TestPrepareSudo(t *testing.T) {
+	commands := packageManagerCommands("apt", "pandoc")
+	sudoLine := "sudo apt update && sudo apt install -y pandoc"
+
+	testCases := []struct {
+		name      string
+		isRoot    bool
+		haveSudo  bool
+		assumeYes bool
+		answer    bool
+		want      string
+		wantErr   bool
+	}{
+		{"Root needs no sudo", true, false, false, false, "apt update && apt install -y pandoc", false},
+		{"Missing sudo", false, false, true, true, "", true},
+		{"Confirmed", false, true, false, true, sudoLine, false},
+		{"Declined", false, true, false, false, "", true},
+		{"Assume yes", false, true, true, false, sudoLine, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var asked string
+			m := &Manager{}
+			m.SetSudoConfirm(tc.assumeYes, func(command string) bool {
+				asked = command
+				return tc.answer
+			})
+
+			got, err := m.prepareSudo(commands, tc.isRoot, tc.haveSudo)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("prepareSudo error = %v; expected error %v", err, tc.wantErr)
+			}
+			if err == nil && formatCommands(got) != tc.want {
+				t.Errorf("prepareSudo = %q; expected %q", formatCommands(got), tc.want)
+			}
+			if tc.assumeYes && asked != "" {
+				t.Errorf("Asked %q despite assumeYes", asked)
+			}
+		})
+	}
+}
+
+func TestBrewArgs(t *testing.T) {
+	testCases := []struct {
+		packages []string
+		cask     bool
+		install  string
+		list     string
+	}{
+		{[]string{"ffmpeg"}, false, "install ffmpeg", "list --formula ffmpeg"},
+		{[]string{"calibre"}, true, "install --cask calibre", "list --cask calibre"},
+		{[]string{"ghostscript", "imagemagick"}, false, "install ghostscript imagemagick", "list --formula ghostscript"},
+	}
+
+	for _, tc := range testCases {
+		if got := strings.Join(brewInstallArgs(tc.packages, tc.cask), " "); got != tc.install {
+			t.Errorf("brewInstallArgs(%v, %v) = %q; expected %q", tc.packages, tc.cask, got, tc.install)
+		}
+		if got := strings.Join(brewListArgs(tc.packages[0], tc.cask), " "); got != tc.list {
+			t.Errorf("brewListArgs(%q, %v) = %q; expected %q", tc.packages[0], tc.cask, got, tc.list)
+		}
+	}
+}
+
+func TestPipNeedsUserInstall(t *testing.T) {
+	testCases := []struct {
+		stderr   string
+		expected bool
+	}{
+		{"error: externally-managed-environment\n\n× This environment is externally managed", true},
+		{"ERROR: Could not install packages due to an OSError: [Errno 13] Permission denied: '/usr/lib/python3'", true},
+		{"ERROR: No matching distribution found for surya-ocr", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		if got := pipNeedsUserInstall(tc.stderr); got != tc.expected {
+			t.Errorf("pipNeedsUserInstall(%q) = %v; expected %v", tc.stderr, got, tc.expected)
+		}
+	}
+}
+
+func TestPipCommandUsesInterpreter(t *testing.T) {
+	m := &Manager{}
+	m.SetPythonInterpreter(filepath.Join(t.TempDir(), "missing-python"))
+	if _, err := m.pipCommand(); err == nil {
+		t.Errorf("Expected an error for a missing interpreter")
+	}
+
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	m.SetPythonInterpreter(python)
+	pip, err := m.pipCommand()
+	if err != nil {
+		t.Fatalf("pipCommand failed: %v", err)
+	}
+	if got := strings.Join(pip, " "); got != python+" -m pip" {
+		t.Errorf("pipCommand = %q; expected %q", got, python+" -m pip")
+	}
+}
+
+func TestRunCheckCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	start := time.Now()
+	_, err := runCheckCommand("sh", []string{"-c", "sleep 30"}, 200*time.Millisecond)
+	if !errors.Is(err, ErrCheckTimeout) {
+		t.Errorf("Expected ErrCheckTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Check took %s; expected it to be killed after the timeout", elapsed)
+	}
+
+	output, err := runCheckCommand("sh", []string{"-c", "echo v1.2.3"}, time.Second)
+	if err != nil || strings.TrimSpace(string(output)) != "v1.2.3" {
+		t.Errorf("runCheckCommand = %q, %v; expected v1.2.3", output, err)
+	}
+
+	if got := (CheckConfig{}).timeout(); got != DefaultCheckTimeout {
+		t.Errorf("Default timeout = %s; expected %s", got, DefaultCheckTimeout)
+	}
+	if got := (CheckConfig{TimeoutSeconds: 3}).timeout(); got != 3*time.Second {
+		t.Errorf("Configured timeout = %s; expected 3s", got)
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	testCases := []struct {
+		version  string
+		min      string
+		expected bool
+	}{
+		{"6.1.1", "7.0", true},
+		{"7.0", "7.0.0", false},
+		{"7.1.0-20", "7.1", false},
+		{"v1.2.3", "1.10", true},
+		{"10.02", "9.5", false},
+		{"available", "1.0", false},
+		{"unknown", "1.0", false},
+	}
+
+	for _, tc := range testCases {
+		if got := versionLess(tc.version, tc.min); got != tc.expected {
+			t.Errorf("versionLess(%q, %q) = %v; expected %v", tc.version, tc.min, got, tc.expected)
+		}
+	}
+}
+
+func TestExpandPattern(t *testing.T) {
+	t.Setenv("AMO_TEST_MIRROR", "https://mirror.example.com")
+	t.Setenv("TEST_SECRET_TOKEN", "secret")
+
+	testCases := []struct {
+		name     string
+		pattern  string
+		expected string
+	}{
+		{"braces", "tool-{version}-{os}-{arch}.zip", "tool-1.2.3-" + runtime.GOOS + "-" + runtime.GOARCH + ".zip"},
+		{"dollar", "tool-${VERSION}-${OS}-${ARCH}.tar.gz", "tool-1.2.3-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.gz"},
+		{"environment", "${AMO_TEST_MIRROR}/tool/v${VERSION}", "https://mirror.example.com/tool/v1.2.3"},
+		{"unset variable", "${AMO_TEST_UNSET}/tool", "${AMO_TEST_UNSET}/tool"},
+		{"non-AMO variable", "https://example.com/tool?token=${TEST_SECRET_TOKEN}", "https://example.com/tool?token=${TEST_SECRET_TOKEN}"},
+		{"regex untouched", "ImageMagick-([0-9.]+-[0-9]+).zip", "ImageMagick-([0-9.]+-[0-9]+).zip"},
+	}
+
+	m := &Manager{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := m.expandPattern(tc.pattern, "v1.2.3"); got != tc.expected {
+				t.Errorf("expandPattern(%q) = %q; expected %q", tc.pattern, got, tc.expected)
+			}
+		})
+	}
+}
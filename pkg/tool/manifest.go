@@ -0,0 +1,102 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ManifestVersion is the schema version written to tool manifests.
+// Bump it when the format changes and keep ParseManifest reading the old one.
+const ManifestVersion = "1"
+
+// ToolManifest records the installed tools of one machine, as written by
+// amo tool export and read by amo tool import
+type ToolManifest struct {
+	Version string `json:"version"`
+	// ConfigVersion is the tools.json version of the exporting amo
+	ConfigVersion string         `json:"config_version,omitempty"`
+	Tools         []ManifestTool `json:"tools"`
+}
+
+// ManifestTool is one installed tool; Name is the tools.json key used by
+// amo tool install, not the display name
+type ManifestTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// MatchesVersion reports whether version satisfies the pinned version of the
+// entry. An entry without a version matches anything; a leading v is ignored.
+func (t ManifestTool) MatchesVersion(version string) bool {
+	if t.Version == "" {
+		return true
+	}
+	return strings.TrimPrefix(t.Version, "v") == strings.TrimPrefix(version, "v")
+}
+
+// ExportManifest checks every configured tool and returns a manifest of the
+// installed ones, sorted by name
+func (m *Manager) ExportManifest() (*ToolManifest, error) {
+	if m.config == nil {
+		return nil, fmt.Errorf("tool configuration not loaded")
+	}
+
+	manifest := &ToolManifest{
+		Version:       ManifestVersion,
+		ConfigVersion: m.config.Version,
+		Tools:         []ManifestTool{},
+	}
+	for toolName, tool := range m.config.Tools {
+		status := m.checkToolStatus(toolName, tool)
+		if !status.Installed {
+			continue
+		}
+		path, _ := m.getCachedToolPath(status.Command)
+		manifest.Tools = append(manifest.Tools, ManifestTool{
+			Name:    toolName,
+			Version: status.Version,
+			Path:    path,
+		})
+	}
+	sort.Slice(manifest.Tools, func(i, j int) bool {
+		return manifest.Tools[i].Name < manifest.Tools[j].Name
+	})
+
+	// Save path cache after checking all tools. The manifest goes to stdout,
+	// so the warning must not.
+	if err := m.savePathCache(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save tool path cache: %v\n", err)
+	}
+
+	return manifest, nil
+}
+
+// ParseManifest reads a manifest written by ExportManifest, rejecting
+// unknown schema versions and entries without a name
+func ParseManifest(data []byte) (*ToolManifest, error) {
+	var manifest ToolManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid tool manifest: %w", err)
+	}
+	if manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported tool manifest version %q (expected %s)", manifest.Version, ManifestVersion)
+	}
+
+	seen := make(map[string]bool)
+	for i, tool := range manifest.Tools {
+		name := strings.TrimSpace(tool.Name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid tool manifest: tool %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid tool manifest: %s is listed twice", name)
+		}
+		seen[name] = true
+		manifest.Tools[i].Name = name
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,106 @@
+package tool
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected []ManifestTool
+		errText  string
+	}{
+		{
+			name:     "valid",
+			data:     `{"version": "1", "tools": [{"name": " ffmpeg ", "version": "6.1"}, {"name": "pandoc"}]}`,
+			expected: []ManifestTool{{Name: "ffmpeg", Version: "6.1"}, {Name: "pandoc"}},
+		},
+		{name: "empty", data: `{"version": "1", "tools": []}`, expected: []ManifestTool{}},
+		{name: "not json", data: `ffmpeg`, errText: "invalid tool manifest"},
+		{name: "unknown version", data: `{"version": "2", "tools": []}`, errText: "unsupported tool manifest version"},
+		{name: "missing name", data: `{"version": "1", "tools": [{"version": "1.0"}]}`, errText: "has no name"},
+		{name: "duplicate", data: `{"version": "1", "tools": [{"name": "jq"}, {"name": "jq"}]}`, errText: "listed twice"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest, err := ParseManifest([]byte(tc.data))
+			if tc.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errText) {
+					t.Errorf("ParseManifest error = %v; expected it to mention %q", err, tc.errText)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseManifest failed: %v", err)
+			}
+			if !reflect.DeepEqual(manifest.Tools, tc.expected) {
+				t.Errorf("Tools = %+v; expected %+v", manifest.Tools, tc.expected)
+			}
+		})
+	}
+}
+
+func TestManifestToolMatchesVersion(t *testing.T) {
+	testCases := []struct {
+		pinned   string
+		version  string
+		expected bool
+	}{
+		{"", "6.1.1", true},
+		{"6.1.1", "6.1.1", true},
+		{"v1.2.3", "1.2.3", true},
+		{"6.1.1", "7.0", false},
+		{"6.1.1", "", false},
+	}
+	for _, tc := range testCases {
+		entry := ManifestTool{Name: "ffmpeg", Version: tc.pinned}
+		if got := entry.MatchesVersion(tc.version); got != tc.expected {
+			t.Errorf("MatchesVersion(%q) with pinned %q = %v; expected %v", tc.version, tc.pinned, got, tc.expected)
+		}
+	}
+}
+
+func TestExportManifestRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+
+	manager, err := NewManager()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	config := `{"version": "9.9", "tools": {
+		"shell": {"name": "Shell", "check": {"command": "sh", "args": ["-c", "echo 1.0"]}},
+		"missing": {"name": "Missing", "check": {"command": "amo-missing-tool-xyz"}}
+	}}`
+	if err := manager.LoadConfig([]byte(config)); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	manifest, err := manager.ExportManifest()
+	if err != nil {
+		t.Fatalf("ExportManifest failed: %v", err)
+	}
+	if manifest.Version != ManifestVersion || manifest.ConfigVersion != "9.9" {
+		t.Errorf("manifest versions = %q, %q; expected %q, 9.9", manifest.Version, manifest.ConfigVersion, ManifestVersion)
+	}
+	if len(manifest.Tools) != 1 || manifest.Tools[0].Name != "shell" || manifest.Tools[0].Path == "" {
+		t.Fatalf("Tools = %+v; expected only the installed shell tool with its path", manifest.Tools)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("Failed to marshal manifest: %v", err)
+	}
+	parsed, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("ParseManifest of an exported manifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, manifest) {
+		t.Errorf("Round trip = %+v; expected %+v", parsed, manifest)
+	}
+}
@@ -30,6 +30,10 @@ type CheckConfig struct {
 	Args             []string `json:"args"`
 	Pattern          string   `json:"pattern,omitempty"`
 	FallbackCommands []string `json:"fallback_commands,omitempty"`
+	// MinVersion marks installed versions below it as outdated
+	MinVersion string `json:"min_version,omitempty"`
+	// TimeoutSeconds bounds each check command; 0 means DefaultCheckTimeout
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
 }
 
 // InstallInfo represents installation information for a platform
@@ -43,6 +47,11 @@ type InstallInfo struct {
 	Pattern  string            `json:"pattern,omitempty"`
 	Target   string            `json:"target,omitempty"`
 	Workflow string            `json:"workflow,omitempty"`
+	Tag      string            `json:"tag,omitempty"`
+
+	// Cask installs homebrew packages with brew install --cask. For the
+	// homebrew method Package may list several names separated by spaces.
+	Cask bool `json:"cask,omitempty"`
 }
 
 // ToolStatus represents the status of a tool
@@ -52,8 +61,14 @@ type ToolStatus struct {
 	Installed bool   `json:"installed"`
 	Version   string `json:"version"`
 	Error     string `json:"error,omitempty"`
+	// Outdated is set when the installed version is below check.min_version
+	Outdated bool `json:"outdated,omitempty"`
 }
 
+// PathCacheVersion is the schema version written to the tool path cache.
+// Bump it when the format changes and teach migratePathCache the old one.
+const PathCacheVersion = "1.0.0"
+
 // ToolPathCache represents cached tool paths
 type ToolPathCache struct {
 	Version   string            `json:"version"`
@@ -68,6 +83,9 @@ func FormatToolStatus(status ToolStatus) string {
 		if version == "" {
 			version = "unknown"
 		}
+		if status.Outdated {
+			return fmt.Sprintf("⚠️  %s (%s) - outdated (%s)", status.Command, status.Name, version)
+		}
 		return fmt.Sprintf("✅ %s (%s) - installed (%s)", status.Command, status.Name, version)
 	}
 
@@ -43,6 +43,11 @@ func (w *WorkflowEngineWrapper) RunWorkflow(workflowName string, params map[stri
 	}
 	w.engine.SetVars(vars)
 
+	// Installation workflows write into the tool directory, which may be outside the sandbox root
+	if installDir, ok := params["installDir"].(string); ok {
+		w.engine.AllowWritePath(installDir)
+	}
+
 	if err := w.engine.RunWorkflow(workflowName); err != nil {
 		return nil, err
 	}
@@ -1,18 +1,27 @@
 package workflow
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"amo/pkg/config"
 	"amo/pkg/env"
+	"amo/pkg/filesystem"
+	"amo/pkg/network"
+
+	"github.com/dop251/goja"
 )
 
 // Core API functions (getVar, cliCommand, console)
@@ -21,8 +30,32 @@ func (e *Engine) getVar(key string) string {
 	return e.vars[key]
 }
 
+// getVarObject returns a structured variable (objects, arrays, numbers,
+// booleans) as-is, falling back to the string variable of the same name.
+// Missing keys return undefined.
+func (e *Engine) getVarObject(key string) goja.Value {
+	if value, ok := e.varObjects[key]; ok {
+		return e.vm.ToValue(value)
+	}
+	if value, ok := e.vars[key]; ok {
+		return e.vm.ToValue(value)
+	}
+	return goja.Undefined()
+}
+
+// getArgs returns the positional arguments given after -- on the run command
+// line, or an empty array
+func (e *Engine) getArgs() []string {
+	args := make([]string, len(e.args))
+	copy(args, e.args)
+	return args
+}
+
 func (e *Engine) getRegion() string {
-	environment, err := env.NewEnvironment()
+	if e.region != "" {
+		return e.region
+	}
+	environment, err := env.Shared()
 	if err != nil {
 		return "global"
 	}
@@ -30,7 +63,7 @@ func (e *Engine) getRegion() string {
 }
 
 func (e *Engine) getOS() string {
-	environment, err := env.NewEnvironment()
+	environment, err := env.Shared()
 	if err != nil {
 		return "unknown"
 	}
@@ -38,23 +71,23 @@ func (e *Engine) getOS() string {
 }
 
 func (e *Engine) getArch() string {
-	environment, err := env.NewEnvironment()
+	environment, err := env.Shared()
 	if err != nil {
 		return "unknown"
 	}
 	return environment.GetArchitecture()
 }
 func (e *Engine) consoleLog(args ...interface{}) {
-	fmt.Println(args...)
+	fmt.Fprintln(e.stdout, args...)
 }
 
 func (e *Engine) consoleError(args ...interface{}) {
-	fmt.Fprintln(os.Stderr, args...)
+	fmt.Fprintln(e.stderr, args...)
 }
 
 func (e *Engine) consoleWarn(args ...interface{}) {
-	fmt.Fprint(os.Stderr, "WARNING: ")
-	fmt.Fprintln(os.Stderr, args...)
+	fmt.Fprint(e.stderr, "WARNING: ")
+	fmt.Fprintln(e.stderr, args...)
 }
 
 func (e *Engine) cliCommand(name string, args []string, opts map[string]interface{}) map[string]interface{} {
@@ -64,7 +97,7 @@ func (e *Engine) cliCommand(name string, args []string, opts map[string]interfac
 	}
 
 	if useWhitelist {
-		environment, err := env.NewEnvironment()
+		environment, err := env.Shared()
 		if err != nil {
 			return map[string]interface{}{
 				"error": fmt.Sprintf("failed to initialize environment for security check: %v", err),
@@ -86,6 +119,7 @@ func (e *Engine) cliCommand(name string, args []string, opts map[string]interfac
 	var envVars []string
 	interactive := false
 	var stdin string
+	maxOutputBytes := int64(DefaultMaxOutputBytes)
 
 	if opts != nil {
 		if t, ok := opts["timeout"].(int); ok {
@@ -110,11 +144,25 @@ func (e *Engine) cliCommand(name string, args []string, opts map[string]interfac
 		if s, ok := opts["stdin"].(string); ok {
 			stdin = s
 		}
+		switch m := opts["maxOutputBytes"].(type) {
+		case int64:
+			maxOutputBytes = m
+		case float64:
+			maxOutputBytes = int64(m)
+		}
 	}
 
 	// Get the actual command path - try direct execution first, then tool cache
 	commandPath := e.resolveCommandPath(name)
 
+	// Wait for a subprocess slot so parallel workflows cannot fork without bound
+	if err := e.acquireProc(); err != nil {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("cancelled while waiting to run '%s': %v", name, err),
+		}
+	}
+	defer e.releaseProc()
+
 	// Create command with independent timeout context
 	// Note: Use context.Background() to ensure cliCommand timeout is independent
 	// of the workflow-level timeout, allowing individual commands to have their own timeout limits
@@ -146,28 +194,42 @@ func (e *Engine) cliCommand(name string, args []string, opts map[string]interfac
 
 		err = cmd.Run()
 		if err != nil {
-			return map[string]interface{}{
+			result := map[string]interface{}{
 				"error": err.Error(),
 			}
+			setExitStatus(result, err)
+			return result
 		}
 
 		return map[string]interface{}{
-			"stdout": "",
-			"stderr": "",
+			"stdout":   "",
+			"stderr":   "",
+			"exitCode": 0,
 		}
 	}
 
-	// Execute command and capture output separately for stdout and stderr
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err = cmd.Run()
+	// Execute command and capture output separately for stdout and stderr,
+	// keeping at most maxOutputBytes of each
+	stdout := &cappedBuffer{limit: maxOutputBytes}
+	stderr := &cappedBuffer{limit: maxOutputBytes}
+
+	// Stream lines to opts.onStdout/opts.onStderr as they arrive
+	onStdout := e.lineCallback(opts, "onStdout")
+	onStderr := e.lineCallback(opts, "onStderr")
+	if onStdout != nil || onStderr != nil {
+		err = runStreaming(cmd, stdout, stderr, onStdout, onStderr)
+	} else {
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = cmd.Run()
+	}
 
 	result := map[string]interface{}{
-		"stdout": stdout.String(),
-		"stderr": stderr.String(),
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+		"truncated": stdout.truncated || stderr.truncated,
 	}
+	setExitStatus(result, err)
 
 	if err != nil {
 		// Check if it's a timeout
@@ -181,6 +243,188 @@ func (e *Engine) cliCommand(name string, args []string, opts map[string]interfac
 	return result
 }
 
+// DefaultMaxOutputBytes is how much of each of stdout and stderr cliCommand
+// keeps unless opts.maxOutputBytes says otherwise
+const DefaultMaxOutputBytes = 4 << 20
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a command flooding its output cannot exhaust memory. Writes always
+// succeed so the process is not killed by a broken pipe. A limit <= 0 keeps
+// everything.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	if room := b.limit - int64(b.buf.Len()); room < int64(len(p)) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
+
+// maxStreamLineBytes is the longest line passed to an output callback; longer
+// lines are delivered in pieces of this size
+const maxStreamLineBytes = 1 << 20
+
+// lineCallback returns opts[key] as a Go function when it is a JS function,
+// or nil when the option is not set
+func (e *Engine) lineCallback(opts map[string]interface{}, key string) func(string) error {
+	if opts == nil || e.vm == nil {
+		return nil
+	}
+	fn, ok := goja.AssertFunction(e.vm.ToValue(opts[key]))
+	if !ok {
+		return nil
+	}
+	return func(line string) error {
+		_, err := fn(goja.Undefined(), e.vm.ToValue(line))
+		return err
+	}
+}
+
+// outputLine is a line read from a command, with the callback it is for
+type outputLine struct {
+	emit func(string) error
+	text string
+}
+
+// runStreaming runs cmd and passes each line of its output to onStdout or
+// onStderr while it runs. A stream without a callback is only buffered.
+// Callbacks are called on the calling goroutine, as the JS runtime is not
+// safe for concurrent use; the output is still kept in stdout and stderr.
+// If a callback fails the command is killed and the callback error returned.
+func runStreaming(cmd *exec.Cmd, stdout, stderr *cappedBuffer, onStdout, onStderr func(string) error) error {
+	type stream struct {
+		r    io.Reader
+		emit func(string) error
+	}
+	var streams []stream
+
+	if onStdout != nil {
+		pipe, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		streams = append(streams, stream{io.TeeReader(pipe, stdout), onStdout})
+	} else {
+		cmd.Stdout = stdout
+	}
+	if onStderr != nil {
+		pipe, err := cmd.StderrPipe()
+		if err != nil {
+			return err
+		}
+		streams = append(streams, stream{io.TeeReader(pipe, stderr), onStderr})
+	} else {
+		cmd.Stderr = stderr
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	lines := make(chan outputLine, 64)
+	var wg sync.WaitGroup
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s stream) {
+			defer wg.Done()
+			scanner := bufio.NewScanner(s.r)
+			scanner.Buffer(make([]byte, 0, 4096), maxStreamLineBytes)
+			scanner.Split(scanOutputLines)
+			for scanner.Scan() {
+				lines <- outputLine{emit: s.emit, text: scanner.Text()}
+			}
+			// Keep reading after a scan error so the output is still buffered
+			// and the process does not block on a full pipe
+			_, _ = io.Copy(io.Discard, s.r)
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	var callbackErr error
+	for line := range lines {
+		if callbackErr != nil {
+			continue
+		}
+		if err := line.emit(line.text); err != nil {
+			callbackErr = err
+			_ = cmd.Process.Kill()
+		}
+	}
+
+	err := cmd.Wait()
+	if callbackErr != nil {
+		return fmt.Errorf("output callback failed: %w", callbackErr)
+	}
+	return err
+}
+
+// scanOutputLines is a bufio.SplitFunc that ends lines at \n, \r\n or a lone
+// \r, so progress output that redraws a line is delivered as it updates
+func scanOutputLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF || len(data) >= maxStreamLineBytes {
+			return i + 1, data[:i], nil
+		}
+		// Wait for the next byte to tell \r\n from a lone \r
+		return 0, nil, nil
+	}
+	if len(data) >= maxStreamLineBytes {
+		return maxStreamLineBytes, data[:maxStreamLineBytes], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// setExitStatus adds the exit status of a finished command to its result:
+// exitCode (0 when err is nil) and, for a process killed by a signal, signal
+// with exitCode -1. Nothing is added when the command could not be started.
+func setExitStatus(result map[string]interface{}, err error) {
+	if err == nil {
+		result["exitCode"] = 0
+		return
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return
+	}
+	result["exitCode"] = exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		result["signal"] = status.Signal().String()
+	}
+}
+
 // resolveCommandPath attempts to resolve command path using the following priority:
 // 1. Try direct execution (exec.LookPath)
 // 2. Try tool path cache lookup if direct execution fails
@@ -223,20 +467,202 @@ func (e *Engine) getToolPathFromCache(commandName string) string {
 	return ""
 }
 
-// checkFileOperationSecurity validates file paths for security
+// checkFileOperationSecurity rejects writes outside the workflow sandbox. The
+// path is made absolute and symlinks in its existing part are resolved, so
+// neither ".." nor a link pointing elsewhere can escape the allowed roots.
 func (e *Engine) checkFileOperationSecurity(path string) error {
-	// Clean the path
-	cleanPath := filepath.Clean(path)
+	if path == "" {
+		return fmt.Errorf("empty path")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	resolved := filesystem.ResolveExistingPath(absPath)
+
+	roots := e.sandboxRoots()
+	if e.isProtectedPath(resolved) {
+		return fmt.Errorf("write to %s denied: amo's whitelist and config files cannot be changed by workflows", path)
+	}
+	for _, root := range roots {
+		if filesystem.IsWithinDir(resolved, root) {
+			return nil
+		}
+	}
+
+	if e.restrictReads {
+		return fmt.Errorf("write to %s denied: outside %s (%s)", path, config.KeySecurityFSRoots, strings.Join(roots, ", "))
+	}
+	return fmt.Errorf("write to %s denied: outside the workflow sandbox (%s); set %s to allow another directory",
+		path, roots[0], config.KeySecurityFSSandboxRoot)
+}
+
+// checkFileReadSecurity rejects reads outside security_fs_roots. Reads are
+// only restricted when that key (or SetFSRoots) lists directories; the same
+// roots as for writes apply, including amo's temp directory.
+func (e *Engine) checkFileReadSecurity(path string) error {
+	roots := e.sandboxRoots()
+	if !e.restrictReads {
+		return nil
+	}
+	if path == "" {
+		return fmt.Errorf("empty path")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	resolved := filesystem.ResolveExistingPath(absPath)
+	for _, root := range roots {
+		if filesystem.IsWithinDir(resolved, root) {
+			return nil
+		}
+	}
+	return fmt.Errorf("read of %s denied: outside %s (%s)", path, config.KeySecurityFSRoots, strings.Join(roots, ", "))
+}
+
+// SetSandboxRoot sets the directory workflows may write under. An empty root
+// restores the default (security_fs_sandbox_root, or the working directory).
+func (e *Engine) SetSandboxRoot(root string) {
+	e.sandboxRoot = root
+	e.writeRoots = nil
+}
+
+// SetFSRoots restricts workflow reads and writes to the given directories,
+// overriding security_fs_roots. An empty list keeps reads unrestricted and
+// writes limited to the sandbox root; nil restores the config value.
+func (e *Engine) SetFSRoots(roots []string) {
+	e.fsRoots = roots
+	e.fsRootsSet = roots != nil
+	e.writeRoots = nil
+}
+
+// AllowWritePath adds a directory, outside the sandbox root, that workflows may write under
+func (e *Engine) AllowWritePath(dir string) {
+	if dir == "" {
+		return
+	}
+	e.extraWriteRoots = append(e.extraWriteRoots, dir)
+	e.writeRoots = nil
+}
+
+// sandboxRoots returns the resolved directories writes are allowed under.
+// With security_fs_roots those are the listed roots, amo's temp directory and
+// any paths added with AllowWritePath. Otherwise the sandbox root comes first,
+// then amo's tools, downloads and temp directories, the system temp directory
+// and the AllowWritePath paths. It also decides whether reads are restricted
+// and collects the files no root may expose.
+func (e *Engine) sandboxRoots() []string {
+	if e.writeRoots != nil {
+		return e.writeRoots
+	}
+
+	fsRoots := e.fsRoots
+	root := e.sandboxRoot
+	manager, managerErr := config.NewManager()
+	if managerErr == nil {
+		if !e.fsRootsSet {
+			fsRoots = parseFSRoots(manager.GetString(config.KeySecurityFSRoots))
+		}
+		if root == "" {
+			root = manager.GetString(config.KeySecurityFSSandboxRoot)
+		}
+	}
+	if root == "" {
+		if wd, err := os.Getwd(); err == nil {
+			root = wd
+		}
+	}
+
+	var configDir string
+	if environment, err := env.NewEnvironment(); err == nil {
+		configDir = environment.GetUserConfigDir()
+	}
+
+	candidates := []string{root}
+	e.restrictReads = len(fsRoots) > 0
+	if e.restrictReads {
+		// Explicit roots are all a workflow gets besides its own temp files
+		candidates = append([]string{}, fsRoots...)
+		if configDir != "" {
+			candidates = append(candidates, filepath.Join(configDir, "temp"))
+		}
+	} else {
+		// Only the directories installer workflows use, never ~/.amo itself
+		if configDir != "" {
+			for _, name := range []string{"tools", "downloads", "temp"} {
+				candidates = append(candidates, filepath.Join(configDir, name))
+			}
+		}
+		candidates = append(candidates, os.TempDir())
+	}
+	candidates = append(candidates, e.extraWriteRoots...)
+
+	var roots []string
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(candidate); err == nil {
+			roots = append(roots, filesystem.ResolveExistingPath(abs))
+		}
+	}
 
-	// Check for path traversal attempts
-	if strings.Contains(cleanPath, "..") {
-		return fmt.Errorf("path traversal not allowed: %s", path)
+	e.protectedPaths = nil
+	e.configDir = ""
+	if configDir != "" {
+		e.configDir = filesystem.ResolveExistingPath(configDir)
+		for _, name := range []string{"allowed_cli.txt", "allowed_hosts.txt", network.AllowedHostsDir, AllowedSourcesFileName, config.ActiveProfileFileName} {
+			e.protectedPaths = append(e.protectedPaths, filepath.Join(e.configDir, name))
+		}
+	}
+	if managerErr == nil {
+		e.protectedPaths = append(e.protectedPaths, filesystem.ResolveExistingPath(manager.GetConfigFile()))
 	}
 
-	// Additional security checks can be added here
-	// For example, restricting access to certain directories
+	e.writeRoots = roots
+	return roots
+}
+
+// isProtectedPath reports whether a resolved path is one of amo's whitelist
+// or config files, or inside allowed_hosts.d. Writing them would let a
+// workflow lift the whitelists and the sandbox for its next run.
+func (e *Engine) isProtectedPath(resolved string) bool {
+	for _, protected := range e.protectedPaths {
+		if filesystem.IsWithinDir(resolved, protected) {
+			return true
+		}
+	}
+	// Every profile's config and host list, not only the active one's
+	if e.configDir != "" && filepath.Dir(resolved) == e.configDir {
+		for _, pattern := range []string{"config.*.yaml", "allowed_hosts.*.txt"} {
+			if matched, _ := filepath.Match(pattern, filepath.Base(resolved)); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	return nil
+// parseFSRoots splits the comma separated security_fs_roots value, expanding a
+// leading ~ to the home directory
+func parseFSRoots(value string) []string {
+	var roots []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "~" || strings.HasPrefix(entry, "~/") || strings.HasPrefix(entry, `~\`) {
+			if home, err := os.UserHomeDir(); err == nil {
+				entry = filepath.Join(home, entry[1:])
+			}
+		}
+		roots = append(roots, entry)
+	}
+	return roots
 }
 
 // getCurrentUser returns the current username
@@ -249,6 +675,8 @@ func getCurrentUser() string {
 
 func (e *Engine) registerCoreAPI() {
 	e.vm.Set("getVar", e.getVar)
+	e.vm.Set("getVarObject", e.getVarObject)
+	e.vm.Set("getArgs", e.getArgs)
 	e.vm.Set("getRegion", e.getRegion)
 	e.vm.Set("getOS", e.getOS)
 	e.vm.Set("getArch", e.getArch)
@@ -0,0 +1,425 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCliCommandMaxProcs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := os.MkdirAll(filepath.Join(home, ".amo"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".amo", "allowed_cli.txt"), []byte("sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed CLI file: %v", err)
+	}
+
+	// Each process registers itself in slots, reports how many processes are
+	// registered while it holds its slot, then unregisters
+	slots := t.TempDir()
+	script := `touch "$1/$2"; sleep 0.2; ls "$1" | wc -l; rm "$1/$2"`
+
+	const maxProcs = 2
+	engine := NewEngine(context.Background())
+	engine.SetMaxProcs(maxProcs)
+
+	var wg sync.WaitGroup
+	results := make([]map[string]interface{}, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = engine.cliCommand("sh", []string{"-c", script, "sh", slots, strconv.Itoa(i)}, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if errMsg, ok := result["error"]; ok {
+			t.Fatalf("Command %d failed: %v (stderr: %v)", i, errMsg, result["stderr"])
+		}
+		running, err := strconv.Atoi(strings.TrimSpace(result["stdout"].(string)))
+		if err != nil {
+			t.Fatalf("Command %d printed unexpected output %q", i, result["stdout"])
+		}
+		if running > maxProcs {
+			t.Errorf("Command %d saw %d concurrent processes; cap is %d", i, running, maxProcs)
+		}
+	}
+}
+
+func TestAcquireProcRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	engine := NewEngine(ctx)
+	engine.SetMaxProcs(1)
+
+	if err := engine.acquireProc(); err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+	cancel()
+	if err := engine.acquireProc(); err == nil {
+		t.Error("Expected acquire to fail once the context is cancelled")
+	}
+	engine.releaseProc()
+}
+
+func TestCheckFileOperationSecuritySandbox(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside, filepath.Join(base, "tmp"), filepath.Join(base, "home")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	// Keep the implicit temp and config roots away from the test directories
+	t.Setenv("TMPDIR", filepath.Join(base, "tmp"))
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	t.Setenv("USERPROFILE", filepath.Join(base, "home"))
+
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(root)
+
+	testCases := []struct {
+		name    string
+		path    string
+		allowed bool
+	}{
+		{"File in root", filepath.Join(root, "out.txt"), true},
+		{"New nested file in root", filepath.Join(root, "a", "b", "out.txt"), true},
+		{"Root itself", root, true},
+		{"Sibling directory", filepath.Join(outside, "out.txt"), false},
+		{"Dot-dot escape", filepath.Join(root, "..", "outside", "out.txt"), false},
+		{"Prefix lookalike", root + "-evil", false},
+		{"Amo tools dir", filepath.Join(base, "home", ".amo", "tools", "x"), true},
+		{"Amo temp dir", filepath.Join(base, "home", ".amo", "temp", "x"), true},
+		{"Rest of amo config dir", filepath.Join(base, "home", ".amo", "notes.txt"), false},
+		{"Temp dir", filepath.Join(base, "tmp", "x"), true},
+	}
+	if runtime.GOOS != "windows" {
+		testCases = append(testCases, struct {
+			name    string
+			path    string
+			allowed bool
+		}{"Symlink escape", filepath.Join(root, "escape", "out.txt"), false})
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := engine.checkFileOperationSecurity(tc.path)
+			if tc.allowed && err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", tc.path, err)
+			}
+			if !tc.allowed && err == nil {
+				t.Errorf("Expected %s to be rejected", tc.path)
+			}
+		})
+	}
+
+	engine.AllowWritePath(outside)
+	if err := engine.checkFileOperationSecurity(filepath.Join(outside, "out.txt")); err != nil {
+		t.Errorf("Expected AllowWritePath to permit writes to %s, got %v", outside, err)
+	}
+}
+
+func TestWhitelistAndConfigFilesAreNotWritable(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	allowedCLI := filepath.Join(configDir, "allowed_cli.txt")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(allowedCLI, []byte("ffmpeg\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed CLI file: %v", err)
+	}
+
+	// Even a sandbox root that covers ~/.amo does not expose these files
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(home)
+
+	for _, path := range []string{
+		allowedCLI,
+		filepath.Join(configDir, "allowed_hosts.txt"),
+		filepath.Join(configDir, "allowed_hosts.d", "extra.txt"),
+		filepath.Join(configDir, AllowedSourcesFileName),
+		filepath.Join(configDir, "config.yaml"),
+		filepath.Join(configDir, "config.work.yaml"),
+		filepath.Join(configDir, "allowed_hosts.work.txt"),
+		filepath.Join(configDir, "active_profile"),
+	} {
+		if err := engine.checkFileOperationSecurity(path); err == nil {
+			t.Errorf("Expected a write to %s to be denied", path)
+		}
+	}
+
+	if result := engine.writeFile(allowedCLI, "sh\n", nil); result["success"] != false {
+		t.Errorf("Overwriting allowed_cli.txt succeeded: %v", result)
+	}
+	if result := engine.deleteFile(allowedCLI); result["success"] != false {
+		t.Errorf("Removing allowed_cli.txt succeeded: %v", result)
+	}
+	if data, err := os.ReadFile(allowedCLI); err != nil || string(data) != "ffmpeg\n" {
+		t.Errorf("allowed_cli.txt was modified: %q, %v", string(data), err)
+	}
+
+	if err := engine.checkFileOperationSecurity(filepath.Join(configDir, "tools", "bin")); err != nil {
+		t.Errorf("Expected writes to the tools dir to be allowed, got %v", err)
+	}
+}
+
+func TestFileSystemWritesRespectSandbox(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside, filepath.Join(base, "tmp"), filepath.Join(base, "home")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	t.Setenv("TMPDIR", filepath.Join(base, "tmp"))
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	t.Setenv("USERPROFILE", filepath.Join(base, "home"))
+
+	victim := filepath.Join(outside, "victim.txt")
+	if err := os.WriteFile(victim, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to write victim file: %v", err)
+	}
+	inside := filepath.Join(root, "inside.txt")
+	if err := os.WriteFile(inside, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write inside file: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(root)
+
+	rejected := map[string]map[string]interface{}{
+		"write":  engine.writeFile(filepath.Join(outside, "new.txt"), "x", nil),
+		"append": engine.appendFile(victim, "x"),
+		"copy":   engine.copyFile(inside, filepath.Join(outside, "copy.txt"), nil),
+		"move":   engine.moveFile(victim, filepath.Join(root, "stolen.txt")),
+		"remove": engine.deleteFile(victim),
+		"mkdir":  engine.makeDir(filepath.Join(outside, "dir")),
+		"json":   engine.writeJSON(victim, map[string]interface{}{"x": 1}, nil),
+	}
+	for name, result := range rejected {
+		if result["success"] != false {
+			t.Errorf("%s outside the sandbox succeeded: %v", name, result)
+		}
+	}
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "keep" {
+		t.Errorf("File outside the sandbox was modified: %q, %v", string(data), err)
+	}
+
+	if result := engine.writeFile(filepath.Join(root, "ok.txt"), "x", nil); result["success"] != true {
+		t.Errorf("Write inside the sandbox failed: %v", result)
+	}
+}
+
+func TestFSRootsRestrictReadsAndWrites(t *testing.T) {
+	base := t.TempDir()
+	rootA := filepath.Join(base, "a")
+	rootB := filepath.Join(base, "b")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{rootA, rootB, outside, filepath.Join(base, "tmp"), filepath.Join(base, "home")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	t.Setenv("TMPDIR", filepath.Join(base, "tmp"))
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	t.Setenv("USERPROFILE", filepath.Join(base, "home"))
+
+	secret := filepath.Join(outside, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	inside := filepath.Join(rootA, "inside.txt")
+	if err := os.WriteFile(inside, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetFSRoots([]string{rootA, rootB})
+
+	rejected := map[string]map[string]interface{}{
+		"read":    engine.readFile(secret),
+		"readdir": engine.listDir(outside),
+		"stat":    engine.getFileInfo(secret),
+		"sha256":  engine.getFileSHA256(secret),
+		"copy":    engine.copyFile(secret, filepath.Join(rootA, "copy.txt"), nil),
+		"write":   engine.writeFile(filepath.Join(outside, "new.txt"), "x", nil),
+	}
+	for name, result := range rejected {
+		if result["success"] != false {
+			t.Errorf("%s outside security_fs_roots succeeded: %v", name, result)
+		}
+	}
+	if engine.exists(secret) {
+		t.Error("exists() reported a file outside security_fs_roots")
+	}
+
+	if result := engine.readFile(inside); result["success"] != true {
+		t.Errorf("Read inside the first root failed: %v", result)
+	}
+	if result := engine.copyFile(inside, filepath.Join(rootB, "copy.txt"), nil); result["success"] != true {
+		t.Errorf("Copy between roots failed: %v", result)
+	}
+
+	// The listed roots replace ~/.amo and the system temp directory, so a
+	// restricted workflow cannot edit the config that restricts it
+	for _, path := range []string{
+		filepath.Join(base, "home", ".amo", "tools", "x"),
+		filepath.Join(base, "home", ".amo", "downloads", "x"),
+		filepath.Join(base, "tmp", "x"),
+	} {
+		if err := engine.checkFileOperationSecurity(path); err == nil {
+			t.Errorf("Expected a write to %s to be denied under security_fs_roots", path)
+		}
+	}
+	temp := engine.getTempFilePath("test_")
+	tempPath, _ := temp["path"].(string)
+	if !strings.HasPrefix(tempPath, filepath.Join(base, "home", ".amo", "temp")) {
+		t.Errorf("getTempFilePath = %v; expected a path in amo's temp directory", temp)
+	}
+	if result := engine.writeFile(tempPath, "x", nil); result["success"] != true {
+		t.Errorf("Write to the engine's temp file failed: %v", result)
+	}
+
+	// Without configured roots only writes are sandboxed
+	engine.SetFSRoots([]string{})
+	engine.SetSandboxRoot(rootA)
+	if result := engine.readFile(secret); result["success"] != true {
+		t.Errorf("Read was restricted without security_fs_roots: %v", result)
+	}
+	if result := engine.writeFile(filepath.Join(rootB, "new.txt"), "x", nil); result["success"] != false {
+		t.Errorf("Write outside the sandbox root succeeded: %v", result)
+	}
+}
+
+func TestParseFSRoots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	got := parseFSRoots(" /data , ,~/projects,~")
+	expected := []string{"/data", filepath.Join(home, "projects"), home}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("parseFSRoots() = %v; expected %v", got, expected)
+	}
+	if roots := parseFSRoots(""); len(roots) != 0 {
+		t.Errorf("parseFSRoots(\"\") = %v; expected none", roots)
+	}
+}
+
+func TestCliCommandOutputCapAndExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := os.MkdirAll(filepath.Join(home, ".amo"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".amo", "allowed_cli.txt"), []byte("sh\namo-no-such-command\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed CLI file: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+
+	flood := engine.cliCommand("sh", []string{"-c", "head -c 100000 /dev/zero | tr '\\0' x; echo done >&2"},
+		map[string]interface{}{"maxOutputBytes": int64(1000)})
+	if _, ok := flood["error"]; ok {
+		t.Fatalf("Flooding command failed: %v", flood)
+	}
+	if out := flood["stdout"].(string); len(out) != 1000 {
+		t.Errorf("Kept %d bytes of stdout; expected 1000", len(out))
+	}
+	if flood["truncated"] != true || flood["stderr"] != "done\n" || flood["exitCode"] != 0 {
+		t.Errorf("Unexpected result for a truncated command: truncated=%v stderr=%q exitCode=%v",
+			flood["truncated"], flood["stderr"], flood["exitCode"])
+	}
+
+	failed := engine.cliCommand("sh", []string{"-c", "echo partial; exit 3"}, nil)
+	if failed["exitCode"] != 3 || failed["stdout"] != "partial\n" || failed["truncated"] != false {
+		t.Errorf("Unexpected result for exit 3: %v", failed)
+	}
+	if _, ok := failed["error"]; !ok {
+		t.Error("Expected an error message for a non-zero exit")
+	}
+
+	killed := engine.cliCommand("sh", []string{"-c", "kill -TERM $$"}, nil)
+	if killed["exitCode"] != -1 || killed["signal"] != "terminated" {
+		t.Errorf("Unexpected result for a killed command: exitCode=%v signal=%v", killed["exitCode"], killed["signal"])
+	}
+	if _, ok := failed["signal"]; ok {
+		t.Errorf("A normal exit reported a signal: %v", failed)
+	}
+
+	missing := engine.cliCommand("amo-no-such-command", nil, nil)
+	if _, ok := missing["exitCode"]; ok {
+		t.Errorf("A command that never started reported an exit code: %v", missing)
+	}
+	if _, ok := missing["error"]; !ok {
+		t.Error("Expected an error for a command that cannot be started")
+	}
+}
+
+func TestCliCommandStreamingCallbacks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := os.MkdirAll(filepath.Join(home, ".amo"), 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".amo", "allowed_cli.txt"), []byte("sh\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed CLI file: %v", err)
+	}
+
+	script := `//!amo
+var out = [], errs = [];
+var result = cliCommand("sh", ["-c", "printf 'one\\ntwo\\r\\nthree'; printf '10%%\\r20%%\\r' >&2"], {
+	onStdout: function(line) { out.push(line); },
+	onStderr: function(line) { errs.push(line); }
+});
+if (result.error) throw new Error("command failed: " + result.error);
+if (out.join("|") !== "one|two|three") throw new Error("stdout lines: " + JSON.stringify(out));
+if (errs.join("|") !== "10%|20%") throw new Error("stderr lines: " + JSON.stringify(errs));
+if (result.stdout !== "one\ntwo\r\nthree") throw new Error("buffered stdout: " + JSON.stringify(result.stdout));
+
+var stopped = cliCommand("sh", ["-c", "echo first; sleep 5; echo second"], {
+	onStdout: function(line) { throw new Error("stop at " + line); }
+});
+if (!stopped.error || stopped.error.indexOf("stop at first") < 0) throw new Error("callback error not reported: " + JSON.stringify(stopped));
+`
+	scriptPath := filepath.Join(t.TempDir(), "stream.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	if err := NewEngine(context.Background()).RunWorkflow(scriptPath); err != nil {
+		t.Fatalf("Streaming workflow failed: %v", err)
+	}
+}
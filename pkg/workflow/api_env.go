@@ -0,0 +1,90 @@
+package workflow
+
+import (
+	"sort"
+	"strings"
+
+	"amo/pkg/config"
+	"amo/pkg/env"
+)
+
+// registerEnvAPI registers read-only access to allowlisted environment variables
+func (e *Engine) registerEnvAPI() {
+	if e.envAllowlist == nil {
+		allowlist := config.DefaultWorkflowEnvAllowlist
+		if manager, err := config.NewManager(); err == nil {
+			allowlist = manager.GetString(config.KeyWorkflowEnvAllowlist)
+		}
+		e.envAllowlist = parseEnvAllowlist(allowlist)
+	}
+
+	e.vm.Set("env", map[string]interface{}{
+		"get":    e.envGet,
+		"getAll": e.envGetAll,
+	})
+}
+
+// SetEnvAllowlist overrides the environment variables visible to workflows.
+// Entries ending in * match a name prefix.
+func (e *Engine) SetEnvAllowlist(names []string) {
+	e.envAllowlist = append([]string{}, names...)
+}
+
+// envGet returns an allowlisted environment variable, or null when it is unset or not allowed
+func (e *Engine) envGet(name string) interface{} {
+	if !e.isEnvAllowed(name) {
+		return nil
+	}
+	value := env.NewCrossPlatformUtils().GetEnvironmentVariable(name)
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// envGetAll returns all allowlisted environment variables whose names start with prefix
+func (e *Engine) envGetAll(prefix string) map[string]interface{} {
+	result := make(map[string]interface{})
+	vars := env.NewCrossPlatformUtils().GetEnvironmentVariables()
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && e.isEnvAllowed(name) {
+			result[name] = vars[name]
+		}
+	}
+	return result
+}
+
+// isEnvAllowed reports whether name matches an allowlist entry
+func (e *Engine) isEnvAllowed(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, entry := range e.envAllowlist {
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+			if strings.HasPrefix(strings.ToUpper(name), strings.ToUpper(prefix)) {
+				return true
+			}
+		} else if strings.EqualFold(name, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnvAllowlist splits a comma separated allowlist, dropping empty entries
+func parseEnvAllowlist(value string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
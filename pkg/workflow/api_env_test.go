@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnvAPIAllowlist(t *testing.T) {
+	t.Setenv("AMO_TEST_VISIBLE", "yes")
+	t.Setenv("AMO_TEST_SECRET", "hunter2")
+	t.Setenv("AMO_PREFIXED_ONE", "1")
+	t.Setenv("AMO_PREFIXED_TWO", "2")
+
+	engine := NewEngine(context.Background())
+	engine.SetEnvAllowlist([]string{"AMO_TEST_VISIBLE", "AMO_PREFIXED_*"})
+
+	testCases := []struct {
+		name     string
+		expected interface{}
+	}{
+		{"AMO_TEST_VISIBLE", "yes"},
+		{"AMO_TEST_SECRET", nil},
+		{"AMO_PREFIXED_ONE", "1"},
+		{"AMO_TEST_UNSET", nil},
+		{"", nil},
+	}
+	for _, tc := range testCases {
+		if got := engine.envGet(tc.name); got != tc.expected {
+			t.Errorf("envGet(%q) = %v; expected %v", tc.name, got, tc.expected)
+		}
+	}
+
+	all := engine.envGetAll("AMO_")
+	if len(all) != 3 {
+		t.Errorf("envGetAll returned %v; expected the 3 allowlisted AMO_ variables", all)
+	}
+	if _, ok := all["AMO_TEST_SECRET"]; ok {
+		t.Error("envGetAll exposed a variable outside the allowlist")
+	}
+	if prefixed := engine.envGetAll("AMO_PREFIXED_"); len(prefixed) != 2 {
+		t.Errorf("envGetAll(\"AMO_PREFIXED_\") = %v; expected 2 entries", prefixed)
+	}
+}
+
+func TestParseEnvAllowlist(t *testing.T) {
+	got := parseEnvAllowlist(" HOME, ,XDG_* ,")
+	if len(got) != 2 || got[0] != "HOME" || got[1] != "XDG_*" {
+		t.Errorf("parseEnvAllowlist = %q; expected [HOME XDG_*]", got)
+	}
+	if got := parseEnvAllowlist(""); len(got) != 0 {
+		t.Errorf("parseEnvAllowlist(\"\") = %q; expected empty", got)
+	}
+}
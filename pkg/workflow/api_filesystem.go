@@ -1,9 +1,8 @@
 package workflow
 
 import (
-	"archive/zip"
+	"encoding/base64"
 	"fmt"
-	"io"
 	"path/filepath"
 	"strings"
 
@@ -15,30 +14,36 @@ func (e *Engine) registerFileSystemAPI() {
 	// File/Directory checks
 	e.vm.Set("fs", map[string]interface{}{
 		// File/Directory checks
-		"exists": e.exists,
-		"isFile": e.isFile,
-		"isDir":  e.isDir,
-		"info":   e.getFileInfo,
-		"stat":   e.getFileInfo, // alias
+		"exists":   e.exists,
+		"isFile":   e.isFile,
+		"isDir":    e.isDir,
+		"info":     e.getFileInfo,
+		"stat":     e.getFileInfo, // alias
+		"statMany": e.statMany,
 
 		// Directory operations
 		"readdir": e.listDir,
 		"list":    e.listDir, // alias
 		"mkdir":   e.makeDir,
+		"watch":   e.watchDir,
 
 		// File operations
 		"read":       e.readFile,
 		"readFile":   e.readFile, // alias
+		"readRange":  e.readRange,
 		"write":      e.writeFile,
 		"writeFile":  e.writeFile, // alias
 		"append":     e.appendFile,
 		"appendFile": e.appendFile, // alias
+		"readJSON":   e.readJSON,
+		"writeJSON":  e.writeJSON,
 		"copy":       e.copyFile,
 		"move":       e.moveFile,
 		"rename":     e.moveFile, // alias
 		"remove":     e.deleteFile,
 		"delete":     e.deleteFile, // alias
 		"rm":         e.deleteFile, // alias
+		"chmod":      e.chmod,
 
 		// Path operations
 		"join":     e.joinPath,
@@ -54,12 +59,19 @@ func (e *Engine) registerFileSystemAPI() {
 		"dirname":  e.getDirName,
 
 		// Utilities
-		"size":   e.getFileSize,
-		"find":   e.findFiles,
-		"search": e.findFiles, // alias
+		"size":      e.getFileSize,
+		"sizeHuman": e.getFileSizeHuman,
+		"diskUsage": e.diskUsage,
+		"find":      e.findFiles,
+		"search":    e.findFiles, // alias
+		"glob":      e.globFiles,
 
 		// Archive operations
-		"extractZip": e.extractZip,
+		"zip":        e.zipDir,
+		"unzip":      e.unzip,
+		"extractZip": e.unzip, // alias
+		"targz":      e.tarGzDir,
+		"untargz":    e.unTarGz,
 
 		// Remove: cwd, getcwd, chdir, cd
 		// "cwd":    e.getWorkingDir,
@@ -92,20 +104,23 @@ func (e *Engine) createResult(success bool, data interface{}, err error) map[str
 	return result
 }
 
-// File/Directory checks
+// File/Directory checks; paths outside security_fs_roots report false
 func (e *Engine) isFile(path string) bool {
-	return e.filesystem.IsFile(path)
+	return e.checkFileReadSecurity(path) == nil && e.filesystem.IsFile(path)
 }
 
 func (e *Engine) isDir(path string) bool {
-	return e.filesystem.IsDir(path)
+	return e.checkFileReadSecurity(path) == nil && e.filesystem.IsDir(path)
 }
 
 func (e *Engine) exists(path string) bool {
-	return e.filesystem.Exists(path)
+	return e.checkFileReadSecurity(path) == nil && e.filesystem.Exists(path)
 }
 
 func (e *Engine) getFileInfo(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	info, err := e.filesystem.GetFileInfo(path)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -113,8 +128,32 @@ func (e *Engine) getFileInfo(path string) map[string]interface{} {
 	return e.createResult(true, fileInfoToMap(*info), nil)
 }
 
+func (e *Engine) statMany(paths []string) []interface{} {
+	results := e.filesystem.StatMany(paths)
+	interfaceResults := make([]interface{}, len(results))
+	for i, r := range results {
+		if err := e.checkFileReadSecurity(r.Path); err != nil {
+			r.Info, r.Error = nil, err.Error()
+		}
+		item := map[string]interface{}{
+			"success": r.Info != nil,
+			"path":    r.Path,
+		}
+		if r.Info != nil {
+			item["info"] = fileInfoToMap(*r.Info)
+		} else {
+			item["error"] = r.Error
+		}
+		interfaceResults[i] = item
+	}
+	return interfaceResults
+}
+
 // Directory operations
 func (e *Engine) listDir(dirPath string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(dirPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	files, err := e.filesystem.List(dirPath)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -133,27 +172,59 @@ func (e *Engine) listDir(dirPath string) map[string]interface{} {
 }
 
 func (e *Engine) makeDir(dirPath string) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(dirPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	err := e.filesystem.MakeDir(dirPath)
 	return e.createResult(err == nil, nil, err)
 }
 
 // File operations
-func (e *Engine) copyFile(src, dst string) map[string]interface{} {
-	err := e.filesystem.Copy(src, dst)
+// copyFile copies a file or directory, keeping modification times and
+// permissions unless options.preserveTime or options.preserveMode is false
+func (e *Engine) copyFile(src, dst string, options interface{}) map[string]interface{} {
+	if err := e.checkFileReadSecurity(src); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	if err := e.checkFileOperationSecurity(dst); err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	copyOptions := filesystem.DefaultCopyOptions
+	opts, _ := options.(map[string]interface{})
+	if val, ok := opts["preserveTime"].(bool); ok {
+		copyOptions.PreserveTime = val
+	}
+	if val, ok := opts["preserveMode"].(bool); ok {
+		copyOptions.PreserveMode = val
+	}
+	err := e.filesystem.CopyWithOptions(src, dst, copyOptions)
 	return e.createResult(err == nil, nil, err)
 }
 
 func (e *Engine) moveFile(src, dst string) map[string]interface{} {
+	// Moving removes the source, so both ends must be inside the sandbox
+	for _, path := range []string{src, dst} {
+		if err := e.checkFileOperationSecurity(path); err != nil {
+			return e.createResult(false, nil, err)
+		}
+	}
 	err := e.filesystem.Move(src, dst)
 	return e.createResult(err == nil, nil, err)
 }
 
 func (e *Engine) deleteFile(path string) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	err := e.filesystem.Delete(path)
 	return e.createResult(err == nil, nil, err)
 }
 
 func (e *Engine) readFile(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	content, err := e.filesystem.ReadFile(path)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -164,18 +235,131 @@ func (e *Engine) readFile(path string) map[string]interface{} {
 	}
 }
 
-func (e *Engine) writeFile(path, content string) map[string]interface{} {
-	err := e.filesystem.WriteFile(path, content)
+// maxReadRangeLength caps one fs.readRange call, which is meant for headers
+// and other small pieces of large files
+const maxReadRangeLength = 64 * 1024 * 1024
+
+// readRange reads length bytes of a file from offset, e.g. to check the magic
+// bytes of a large video. Content is base64 unless encoding is "utf8" or "text".
+func (e *Engine) readRange(path string, offset, length int64, encoding string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	if length > maxReadRangeLength {
+		return e.createResult(false, nil, fmt.Errorf("length %d exceeds the readRange limit of %s", length, filesystem.FormatBytes(maxReadRangeLength)))
+	}
+
+	var text bool
+	switch strings.ToLower(encoding) {
+	case "", "base64":
+	case "utf8", "utf-8", "text":
+		text = true
+	default:
+		return e.createResult(false, nil, fmt.Errorf("unsupported encoding %q, expected base64 or utf8", encoding))
+	}
+
+	data, err := e.filesystem.ReadRange(path, offset, length)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	content := base64.StdEncoding.EncodeToString(data)
+	if text {
+		content = string(data)
+	}
+	return map[string]interface{}{
+		"success":    true,
+		"content":    content,
+		"offset":     offset,
+		"bytes_read": len(data),
+		"eof":        int64(len(data)) < length,
+	}
+}
+
+// writeFile writes content to a file; options.mode (e.g. "0755") sets its permissions
+func (e *Engine) writeFile(path, content string, options interface{}) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	// Older scripts pass a non-object third argument; only an options object is used
+	opts, _ := options.(map[string]interface{})
+	mode, hasMode := opts["mode"].(string)
+	if hasMode {
+		// Validate before writing so a bad mode leaves no file behind
+		if _, err := filesystem.ParseFileMode(mode); err != nil {
+			return e.createResult(false, nil, err)
+		}
+	}
+
+	if err := e.filesystem.WriteFile(path, content); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	if hasMode {
+		if err := e.filesystem.Chmod(path, mode); err != nil {
+			return e.createResult(false, nil, err)
+		}
+	}
+	return e.createResult(true, nil, nil)
+}
+
+func (e *Engine) readJSON(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	value, err := e.filesystem.ReadJSON(path)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	return map[string]interface{}{
+		"success": true,
+		"data":    value,
+	}
+}
+
+// writeJSON writes value as JSON, replacing the file atomically. indent is a
+// number of spaces or an indent string as in JSON.stringify; it defaults to 2
+// spaces, and 0 writes compact JSON.
+func (e *Engine) writeJSON(path string, value interface{}, indent interface{}) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	indentStr := "  "
+	switch v := indent.(type) {
+	case int64:
+		indentStr = strings.Repeat(" ", int(min(max(v, 0), 10)))
+	case float64:
+		indentStr = strings.Repeat(" ", int(min(max(v, 0), 10)))
+	case string:
+		indentStr = v
+	}
+
+	err := e.filesystem.WriteJSON(path, value, indentStr)
+	return e.createResult(err == nil, nil, err)
+}
+
+// chmod sets file permissions from an octal string such as "0755" (no-op on Windows)
+func (e *Engine) chmod(path, mode string) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	err := e.filesystem.Chmod(path, mode)
 	return e.createResult(err == nil, nil, err)
 }
 
 func (e *Engine) appendFile(path, content string) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	err := e.filesystem.AppendFile(path, content)
 	return e.createResult(err == nil, nil, err)
 }
 
 // Utilities
 func (e *Engine) getFileSize(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	size, err := e.filesystem.GetSize(path)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -186,7 +370,54 @@ func (e *Engine) getFileSize(path string) map[string]interface{} {
 	}
 }
 
+// getFileSizeHuman is fs.size formatted for people, e.g. "1.5 MB"
+func (e *Engine) getFileSizeHuman(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	size, err := e.filesystem.GetSize(path)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	return map[string]interface{}{
+		"success": true,
+		"size":    size,
+		"human":   filesystem.FormatBytes(size),
+	}
+}
+
+// diskUsage breaks the size of a directory down by its immediate children,
+// largest first
+func (e *Engine) diskUsage(dir string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(dir); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	entries, total, err := e.filesystem.DiskUsage(dir)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	items := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		items[i] = map[string]interface{}{
+			"name":   entry.Name,
+			"path":   entry.Path,
+			"size":   entry.Size,
+			"human":  filesystem.FormatBytes(entry.Size),
+			"is_dir": entry.IsDir,
+		}
+	}
+	return map[string]interface{}{
+		"success": true,
+		"total":   total,
+		"human":   filesystem.FormatBytes(total),
+		"entries": items,
+	}
+}
+
 func (e *Engine) findFiles(rootPath, pattern string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(rootPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	files, err := e.filesystem.Find(rootPath, pattern)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -197,6 +428,25 @@ func (e *Engine) findFiles(rootPath, pattern string) map[string]interface{} {
 	}
 }
 
+// globFiles matches a pattern with ** support; matches outside
+// security_fs_roots are left out
+func (e *Engine) globFiles(pattern string) map[string]interface{} {
+	matches, err := e.filesystem.Glob(pattern)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if e.checkFileReadSecurity(match) == nil {
+			files = append(files, match)
+		}
+	}
+	return map[string]interface{}{
+		"success": true,
+		"files":   files,
+	}
+}
+
 // Working directory operations - renamed for clarity
 func (e *Engine) getCurrentWorkingPath() map[string]interface{} {
 	dir, err := e.filesystem.GetWorkingDir()
@@ -209,12 +459,17 @@ func (e *Engine) getCurrentWorkingPath() map[string]interface{} {
 	}
 }
 
-// Temporary file path generation
+// Temporary file path generation. With security_fs_roots the system temp
+// directory is off limits, so the path is in amo's temp directory instead.
 func (e *Engine) getTempFilePath(prefix string) map[string]interface{} {
 	tempPath, err := e.filesystem.GetTempFilePath(prefix)
 	if err != nil {
 		return e.createResult(false, nil, err)
 	}
+	e.sandboxRoots()
+	if e.restrictReads && e.configDir != "" {
+		tempPath = filepath.Join(e.configDir, "temp", filepath.Base(tempPath))
+	}
 	return map[string]interface{}{
 		"success": true,
 		"path":    tempPath,
@@ -295,6 +550,9 @@ func fileInfoToMap(info filesystem.FileInfo) map[string]interface{} {
 
 // Hash functions
 func (e *Engine) getFileMD5(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	hash, err := e.filesystem.GetFileMD5(path)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -306,6 +564,9 @@ func (e *Engine) getFileMD5(path string) map[string]interface{} {
 }
 
 func (e *Engine) getFileSHA256(path string) map[string]interface{} {
+	if err := e.checkFileReadSecurity(path); err != nil {
+		return e.createResult(false, nil, err)
+	}
 	hash, err := e.filesystem.GetFileSHA256(path)
 	if err != nil {
 		return e.createResult(false, nil, err)
@@ -338,78 +599,63 @@ func (e *Engine) generateUniqueFilename(path string, maxAttemptsInput interface{
 	}
 }
 
-// extractZip extracts a ZIP file to a target directory
-func (e *Engine) extractZip(zipPath string, targetDir string) map[string]interface{} {
-	// Validate inputs
-	if !e.filesystem.Exists(zipPath) {
-		return e.createResult(false, nil, fmt.Errorf("ZIP file not found: %s", zipPath))
-	}
+// zipDir packs a directory into a zip archive
+func (e *Engine) zipDir(sourceDir, outputPath string) map[string]interface{} {
+	return e.createArchive(sourceDir, outputPath, e.filesystem.Zip)
+}
 
-	if !e.filesystem.IsFile(zipPath) {
-		return e.createResult(false, nil, fmt.Errorf("path is not a file: %s", zipPath))
-	}
+// tarGzDir packs a directory into a .tar.gz archive
+func (e *Engine) tarGzDir(sourceDir, outputPath string) map[string]interface{} {
+	return e.createArchive(sourceDir, outputPath, e.filesystem.TarGz)
+}
 
-	// Ensure target directory exists
-	if err := e.filesystem.MakeDir(targetDir); err != nil {
-		return e.createResult(false, nil, fmt.Errorf("failed to create target directory: %w", err))
+func (e *Engine) createArchive(sourceDir, outputPath string, create func(string, string) (int, error)) map[string]interface{} {
+	if err := e.checkFileReadSecurity(sourceDir); err != nil {
+		return e.createResult(false, nil, err)
 	}
-
-	// Open ZIP file
-	reader, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return e.createResult(false, nil, fmt.Errorf("failed to open ZIP file: %w", err))
+	if err := e.checkFileOperationSecurity(outputPath); err != nil {
+		return e.createResult(false, nil, err)
 	}
-	defer reader.Close()
-
-	extractedFiles := []string{}
-
-	// Extract each file
-	for _, file := range reader.File {
-		if err := e.extractZipFile(file, targetDir); err != nil {
-			return e.createResult(false, nil, fmt.Errorf("failed to extract %s: %w", file.Name, err))
-		}
-		extractedFiles = append(extractedFiles, file.Name)
+	count, err := create(sourceDir, outputPath)
+	if err != nil {
+		return e.createResult(false, nil, err)
 	}
-
 	return map[string]interface{}{
 		"success": true,
-		"files":   extractedFiles,
-		"count":   len(extractedFiles),
+		"path":    outputPath,
+		"count":   count,
 	}
 }
 
-// extractZipFile extracts a single file from a ZIP archive
-func (e *Engine) extractZipFile(file *zip.File, targetDir string) error {
-	// Validate file path to prevent directory traversal
-	cleanPath := filepath.Clean(file.Name)
-	if strings.Contains(cleanPath, "..") {
-		return fmt.Errorf("invalid file path: %s", file.Name)
-	}
+// unzip extracts a zip archive; entries escaping targetDir fail the extraction
+func (e *Engine) unzip(archivePath, targetDir string) map[string]interface{} {
+	return e.extractArchive(archivePath, targetDir, e.filesystem.Unzip)
+}
 
-	targetPath := filepath.Join(targetDir, cleanPath)
+// unTarGz extracts a .tar.gz archive like unzip
+func (e *Engine) unTarGz(archivePath, targetDir string) map[string]interface{} {
+	return e.extractArchive(archivePath, targetDir, e.filesystem.UnTarGz)
+}
 
-	// Create directory if needed
-	if file.FileInfo().IsDir() {
-		return e.filesystem.MakeDir(targetPath)
+func (e *Engine) extractArchive(archivePath, targetDir string, extract func(string, string, filesystem.EntryCheck) ([]string, error)) map[string]interface{} {
+	if err := e.checkFileReadSecurity(archivePath); err != nil {
+		return e.createResult(false, nil, err)
 	}
-
-	// Ensure parent directory exists
-	parentDir := filepath.Dir(targetPath)
-	if err := e.filesystem.MakeDir(parentDir); err != nil {
-		return err
+	if !e.filesystem.IsFile(archivePath) {
+		return e.createResult(false, nil, fmt.Errorf("archive not found: %s", archivePath))
 	}
-
-	// Extract file content
-	reader, err := file.Open()
-	if err != nil {
-		return err
+	if err := e.checkFileOperationSecurity(targetDir); err != nil {
+		return e.createResult(false, nil, err)
 	}
-	defer reader.Close()
-
-	content, err := io.ReadAll(reader)
+	// Every entry is checked too: one named link/file must not follow a
+	// symlink out of the sandbox or land on a protected file
+	files, err := extract(archivePath, targetDir, e.checkFileOperationSecurity)
 	if err != nil {
-		return err
+		return e.createResult(false, nil, err)
+	}
+	return map[string]interface{}{
+		"success": true,
+		"files":   files,
+		"count":   len(files),
 	}
-
-	return e.filesystem.WriteFile(targetPath, string(content))
 }
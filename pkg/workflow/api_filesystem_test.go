@@ -0,0 +1,131 @@
+package workflow
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFSReadRange(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	header := []byte{0x00, 0x00, 0x00, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}
+	if err := os.WriteFile(filepath.Join(dir, "clip.mp4"), header, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	script := `//!amo
+var head = fs.readRange("clip.mp4", 4, 4, "utf8");
+if (!head.success || head.content !== "ftyp") throw new Error("text: " + JSON.stringify(head));
+if (head.bytes_read !== 4 || head.eof) throw new Error("count: " + JSON.stringify(head));
+
+var raw = fs.readRange("clip.mp4", 0, 4);
+if (raw.content !== "AAAAGA==") throw new Error("base64: " + raw.content);
+
+var tail = fs.readRange("clip.mp4", 8, 100, "text");
+if (tail.content !== "mp42" || tail.bytes_read !== 4 || !tail.eof) throw new Error("tail: " + JSON.stringify(tail));
+
+if (fs.readRange("clip.mp4", -1, 4).success) throw new Error("negative offset accepted");
+if (fs.readRange("clip.mp4", 0, 4, "hex").success) throw new Error("unknown encoding accepted");
+if (fs.readRange("clip.mp4", 0, 1024 * 1024 * 1024).success) throw new Error("huge length accepted");
+if (fs.readRange("missing.mp4", 0, 4).success) throw new Error("missing file read");
+`
+	if err := NewEngine(context.Background()).RunWorkflowSource(script, "range.js"); err != nil {
+		t.Errorf("RunWorkflowSource failed: %v", err)
+	}
+}
+
+func TestUnzipDoesNotFollowSymlinksOutOfSandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside, filepath.Join(base, "tmp"), filepath.Join(base, "home")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	t.Setenv("TMPDIR", filepath.Join(base, "tmp"))
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	t.Setenv("USERPROFILE", filepath.Join(base, "home"))
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	zipPath := filepath.Join(root, "evil.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	zw := zip.NewWriter(zipFile)
+	w, err := zw.Create("link/pwned.txt")
+	if err != nil {
+		t.Fatalf("Failed to add zip entry: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("Failed to write zip entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	zipFile.Close()
+
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(root)
+	if result := engine.writeFile(filepath.Join(root, "link", "x"), "x", nil); result["success"] != false {
+		t.Errorf("Write through the symlink succeeded: %v", result)
+	}
+	if result := engine.unzip(zipPath, root); result["success"] != false {
+		t.Errorf("Unzip through the symlink succeeded: %v", result)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned.txt")); err == nil {
+		t.Error("Archive entry was written outside the sandbox")
+	}
+}
+
+func TestCopyDoesNotPullSymlinkTargetsIntoSandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(root, "tree"), outside, filepath.Join(base, "tmp"), filepath.Join(base, "home")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	t.Setenv("TMPDIR", filepath.Join(base, "tmp"))
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	t.Setenv("USERPROFILE", filepath.Join(base, "home"))
+	secret := filepath.Join(outside, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	if err := os.Symlink(secret, filepath.Join(root, "tree", "secret.txt")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(root)
+	copied := filepath.Join(root, "copy")
+	if result := engine.copyFile(filepath.Join(root, "tree"), copied, nil); result["success"] != true {
+		t.Fatalf("Copy failed: %v", result)
+	}
+	info, err := os.Lstat(filepath.Join(copied, "secret.txt"))
+	if err != nil {
+		t.Fatalf("Copied entry missing: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Copy followed the symlink and copied the file outside the tree")
+	}
+}
@@ -0,0 +1,147 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"amo/pkg/filesystem"
+
+	"github.com/dop251/goja"
+)
+
+// defaultWatchIntervalMs is how often fs.watch polls when options.interval is not set
+const defaultWatchIntervalMs = 1000
+
+// watchChanges is one poll's difference between two directory listings
+type watchChanges struct {
+	created  []interface{}
+	modified []interface{}
+	deleted  []interface{}
+}
+
+func (c watchChanges) empty() bool {
+	return len(c.created) == 0 && len(c.modified) == 0 && len(c.deleted) == 0
+}
+
+// watchDir polls dirPath every options.interval milliseconds and calls
+// callback with {created, modified, deleted} lists of file info objects
+// whenever entries changed. Changes are found by comparing the modification
+// time, size and mode reported by FileSystem.List, so the watch needs no
+// OS-specific notification support. It runs until the workflow is cancelled
+// or times out, or until the callback returns false.
+func (e *Engine) watchDir(dirPath string, callback goja.Value, options map[string]interface{}) map[string]interface{} {
+	if err := e.checkFileReadSecurity(dirPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	fn, ok := goja.AssertFunction(callback)
+	if !ok {
+		return e.createResult(false, nil, fmt.Errorf("fs.watch needs a callback function"))
+	}
+
+	intervalMs := defaultWatchIntervalMs
+	if options != nil {
+		switch v := options["interval"].(type) {
+		case nil:
+		case int64:
+			intervalMs = int(v)
+		case float64:
+			intervalMs = int(v)
+		default:
+			intervalMs = 0
+		}
+		if intervalMs <= 0 {
+			return e.createResult(false, nil, fmt.Errorf("interval must be a positive number of milliseconds, got %v", options["interval"]))
+		}
+	}
+
+	files, err := e.filesystem.List(dirPath)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	known := indexFileInfos(files)
+
+	ctx := e.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// A result could be ignored by the script; the interrupt ends the
+			// run with the deadline or cancellation as its error
+			e.vm.Interrupt(ctx.Err())
+			return e.createResult(false, nil, ctx.Err())
+		case <-ticker.C:
+		}
+
+		files, err := e.filesystem.List(dirPath)
+		if err != nil {
+			return e.createResult(false, nil, err)
+		}
+		current := indexFileInfos(files)
+		changes := diffFileInfos(known, current)
+		known = current
+		if changes.empty() {
+			continue
+		}
+
+		result, err := fn(goja.Undefined(), e.vm.ToValue(map[string]interface{}{
+			"created":  changes.created,
+			"modified": changes.modified,
+			"deleted":  changes.deleted,
+		}))
+		if err != nil {
+			return e.createResult(false, nil, fmt.Errorf("watch callback failed: %w", err))
+		}
+		// Returning false (not just a falsy value) stops the watch
+		if result != nil && result.Export() == false {
+			return e.createResult(true, nil, nil)
+		}
+	}
+}
+
+// indexFileInfos maps a directory listing by entry name
+func indexFileInfos(files []filesystem.FileInfo) map[string]filesystem.FileInfo {
+	index := make(map[string]filesystem.FileInfo, len(files))
+	for _, f := range files {
+		index[f.Name] = f
+	}
+	return index
+}
+
+// diffFileInfos lists the entries created, modified and deleted between two
+// listings of the same directory, each sorted by name
+func diffFileInfos(before, after map[string]filesystem.FileInfo) watchChanges {
+	changes := watchChanges{created: []interface{}{}, modified: []interface{}{}, deleted: []interface{}{}}
+	for _, name := range sortedKeys(after) {
+		info := after[name]
+		old, existed := before[name]
+		switch {
+		case !existed:
+			changes.created = append(changes.created, fileInfoToMap(info))
+		case old.ModTime != info.ModTime || old.Size != info.Size || old.Mode != info.Mode:
+			changes.modified = append(changes.modified, fileInfoToMap(info))
+		}
+	}
+	for _, name := range sortedKeys(before) {
+		if _, exists := after[name]; !exists {
+			changes.deleted = append(changes.deleted, fileInfoToMap(before[name]))
+		}
+	}
+	return changes
+}
+
+// sortedKeys returns the names of a listing in order
+func sortedKeys(files map[string]filesystem.FileInfo) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
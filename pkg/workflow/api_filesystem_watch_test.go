@@ -0,0 +1,111 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"amo/pkg/filesystem"
+)
+
+func TestDiffFileInfos(t *testing.T) {
+	before := indexFileInfos([]filesystem.FileInfo{
+		{Name: "same.txt", Size: 1, ModTime: "2024-01-01T00:00:00Z"},
+		{Name: "grown.txt", Size: 1, ModTime: "2024-01-01T00:00:00Z"},
+		{Name: "touched.txt", Size: 1, ModTime: "2024-01-01T00:00:00Z"},
+		{Name: "gone.txt", Size: 1, ModTime: "2024-01-01T00:00:00Z"},
+	})
+	after := indexFileInfos([]filesystem.FileInfo{
+		{Name: "same.txt", Size: 1, ModTime: "2024-01-01T00:00:00Z"},
+		{Name: "grown.txt", Size: 5, ModTime: "2024-01-01T00:00:00Z"},
+		{Name: "touched.txt", Size: 1, ModTime: "2024-01-02T00:00:00Z"},
+		{Name: "new.txt", Size: 1, ModTime: "2024-01-02T00:00:00Z"},
+	})
+
+	names := func(entries []interface{}) []string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.(map[string]interface{})["name"].(string))
+		}
+		return result
+	}
+	changes := diffFileInfos(before, after)
+	if got := names(changes.created); !reflect.DeepEqual(got, []string{"new.txt"}) {
+		t.Errorf("created = %v", got)
+	}
+	if got := names(changes.modified); !reflect.DeepEqual(got, []string{"grown.txt", "touched.txt"}) {
+		t.Errorf("modified = %v", got)
+	}
+	if got := names(changes.deleted); !reflect.DeepEqual(got, []string{"gone.txt"}) {
+		t.Errorf("deleted = %v", got)
+	}
+	if !diffFileInfos(after, after).empty() {
+		t.Error("Identical listings reported changes")
+	}
+}
+
+func TestWatchDirCallsBackUntilFalse(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	dir := t.TempDir()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(filepath.Join(dir, "new.txt"), []byte("1"), 0644)
+	}()
+
+	script := `//!amo
+var created = [];
+var result = fs.watch(getVar("dir"), function(changes) {
+	changes.created.forEach(function(f) { created.push(f.name); });
+	return false;
+}, {interval: 20});
+if (!result.success) throw new Error("watch failed: " + result.error);
+if (created.join(",") !== "new.txt") throw new Error("created: " + created.join(","));
+`
+	scriptPath := filepath.Join(t.TempDir(), "watch.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	engine := NewEngine(ctx)
+	engine.SetVars(map[string]string{"dir": dir})
+	if err := engine.RunWorkflow(scriptPath); err != nil {
+		t.Fatalf("Watch workflow failed: %v", err)
+	}
+}
+
+func TestWatchDirStopsOnCancel(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	script := `//!amo
+fs.watch(getVar("dir"), function(changes) {}, {interval: 10});
+`
+	scriptPath := filepath.Join(t.TempDir(), "watch.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	engine := NewEngine(ctx)
+	engine.SetVars(map[string]string{"dir": t.TempDir()})
+
+	start := time.Now()
+	err := engine.RunWorkflow(scriptPath)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the watch to end with the workflow deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Watch kept running %v after the deadline", elapsed)
+	}
+}
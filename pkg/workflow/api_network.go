@@ -1,9 +1,18 @@
 package workflow
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
 
+	"amo/pkg/filesystem"
 	"amo/pkg/network"
+
+	"github.com/dop251/goja"
 )
 
 // registerNetworkAPI registers network functions for JavaScript
@@ -11,42 +20,92 @@ func (e *Engine) registerNetworkAPI() {
 	if e.network == nil {
 		// Network not available, register placeholder functions
 		e.vm.Set("http", map[string]interface{}{
-			"get":          e.networkNotAvailable,
-			"post":         e.networkNotAvailable,
-			"getJSON":      e.networkNotAvailable,
-			"downloadFile": e.networkNotAvailable,
+			"get":                  e.networkNotAvailable,
+			"post":                 e.networkNotAvailable,
+			"getJSON":              e.networkNotAvailable,
+			"request":              e.networkNotAvailable,
+			"downloadFile":         e.networkNotAvailable,
+			"downloadResume":       e.networkNotAvailable,
+			"downloadAll":          e.networkNotAvailable,
+			"downloadClearPartial": e.networkNotAvailable,
+			"graphql":              e.networkNotAvailable,
+			"uploadFile":           e.networkNotAvailable,
 		})
 		return
 	}
 
 	// Network available, register actual functions
 	e.vm.Set("http", map[string]interface{}{
-		"get":                e.httpGet,
-		"post":               e.httpPost,
-		"getJSON":            e.httpGetJSON,
-		"downloadFile":       e.httpDownloadFile,
-		"downloadFileResume": e.httpDownloadFileResume,
+		"get":                  e.httpGet,
+		"post":                 e.httpPost,
+		"getJSON":              e.httpGetJSON,
+		"request":              e.httpRequest,
+		"downloadFile":         e.httpDownloadFile,
+		"downloadFileResume":   e.httpDownloadFileResume,
+		"downloadResume":       e.httpDownloadResume,
+		"downloadAll":          e.httpDownloadAll,
+		"downloadClearPartial": e.httpDownloadClearPartial,
+		"graphql":              e.httpGraphQL,
+		"uploadFile":           e.httpUploadFile,
 	})
 }
 
 // Network operation functions
 
-func (e *Engine) httpGet(url string, headers map[string]interface{}) map[string]interface{} {
+// requestClient returns the network client for one call. options.minInterval
+// overrides network_min_interval_ms, the milliseconds kept between requests
+// to the same host.
+func (e *Engine) requestClient(options map[string]interface{}) (*network.NetworkClient, error) {
+	var ms float64
+	switch n := options["minInterval"].(type) {
+	case nil:
+		return e.network, nil
+	case int64:
+		ms = float64(n)
+	case float64:
+		ms = n
+	default:
+		ms = -1
+	}
+	if ms < 0 {
+		return nil, fmt.Errorf("minInterval must be a non-negative number of milliseconds, got %v", options["minInterval"])
+	}
+	return e.network.WithMinInterval(time.Duration(ms * float64(time.Millisecond))), nil
+}
+
+func (e *Engine) httpGet(url string, headers map[string]interface{}, options map[string]interface{}) map[string]interface{} {
 	if e.network == nil {
 		return map[string]interface{}{
 			"error": "Network client not available",
 		}
 	}
 
-	headerMap := convertHeaders(headers)
-	response := e.network.Get(url, headerMap)
+	// Parse options
+	useCache := false
+	binary := false
+	if options != nil {
+		if val, ok := options["cache"].(bool); ok {
+			useCache = val
+		}
+		if val, ok := options["binary"].(bool); ok {
+			binary = val
+		}
+	}
 
-	return map[string]interface{}{
-		"status_code": response.StatusCode,
-		"headers":     response.Headers,
-		"body":        response.Body,
-		"error":       response.Error,
+	client, err := e.requestClient(options)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	headerMap := convertHeaders(headers)
+	var response *network.HTTPResponse
+	if useCache {
+		response = client.GetCached(url, headerMap)
+	} else {
+		response = client.Get(url, headerMap)
 	}
+
+	return httpResult(response, binary)
 }
 
 func (e *Engine) httpPost(url string, body string, headers map[string]interface{}) map[string]interface{} {
@@ -67,6 +126,50 @@ func (e *Engine) httpPost(url string, body string, headers map[string]interface{
 	}
 }
 
+// httpRequest sends a request with any supported method. options.body is sent
+// as-is when it is a string and as JSON otherwise; options.headers sets headers
+// and options.binary returns the body base64 encoded.
+func (e *Engine) httpRequest(method string, url string, options map[string]interface{}) map[string]interface{} {
+	if e.network == nil {
+		return map[string]interface{}{
+			"error": "Network client not available",
+		}
+	}
+
+	var body string
+	var headers map[string]interface{}
+	binary := false
+	if options != nil {
+		switch b := options["body"].(type) {
+		case nil:
+		case string:
+			body = b
+		default:
+			data, err := json.Marshal(b)
+			if err != nil {
+				return map[string]interface{}{
+					"error": fmt.Sprintf("failed to encode request body: %v", err),
+				}
+			}
+			body = string(data)
+		}
+		if h, ok := options["headers"].(map[string]interface{}); ok {
+			headers = h
+		}
+		if val, ok := options["binary"].(bool); ok {
+			binary = val
+		}
+	}
+
+	client, err := e.requestClient(options)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	response := client.Request(method, url, body, convertHeaders(headers))
+
+	return httpResult(response, binary)
+}
+
 func (e *Engine) httpGetJSON(url string, headers map[string]interface{}) map[string]interface{} {
 	if e.network == nil {
 		return map[string]interface{}{
@@ -78,37 +181,77 @@ func (e *Engine) httpGetJSON(url string, headers map[string]interface{}) map[str
 	return e.network.GetJSON(url, headerMap)
 }
 
+// httpGraphQL posts a GraphQL query with its variables and returns the
+// parsed data and errors
+func (e *Engine) httpGraphQL(url string, query string, variables map[string]interface{}, headers map[string]interface{}) map[string]interface{} {
+	if e.network == nil {
+		return map[string]interface{}{
+			"error": "Network client not available",
+		}
+	}
+
+	return e.network.GraphQL(url, query, variables, convertHeaders(headers))
+}
+
+// httpUploadFile uploads filePath as a multipart/form-data POST; extraFields
+// are sent as form values. A JSON response body is also returned parsed in data.
+func (e *Engine) httpUploadFile(url, fieldName, filePath string, extraFields map[string]interface{}, headers map[string]interface{}) map[string]interface{} {
+	if e.network == nil {
+		return map[string]interface{}{
+			"error": "Network client not available",
+		}
+	}
+	if err := e.checkFileReadSecurity(filePath); err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	response := e.network.UploadFile(url, fieldName, filePath, convertHeaders(extraFields), convertHeaders(headers))
+	result := httpResult(response, false)
+	var data interface{}
+	if response.Error == "" && json.Unmarshal([]byte(response.Body), &data) == nil {
+		result["data"] = data
+	}
+	return result
+}
+
 func (e *Engine) httpDownloadFile(url string, outputPath string, options map[string]interface{}) map[string]interface{} {
 	if e.network == nil {
 		return map[string]interface{}{
 			"error": "Network client not available",
 		}
 	}
+	if err := e.checkFileOperationSecurity(outputPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
 
 	// Parse options
 	showProgress := false
+	var headers map[string]interface{}
 	if options != nil {
 		if val, ok := options["show_progress"].(bool); ok {
 			showProgress = val
 		}
+		if h, ok := options["headers"].(map[string]interface{}); ok {
+			headers = h
+		}
 	}
 
 	// Progress callback
 	var progressCallback func(network.DownloadProgress)
+	var progress *network.ProgressPrinter
 	if showProgress {
-		progressCallback = func(progress network.DownloadProgress) {
-			fmt.Printf("\rDownloading... %d%% (%s/%s) - %s",
-				progress.Percentage,
-				formatBytes(progress.Downloaded),
-				formatBytes(progress.Total),
-				progress.Speed)
-		}
+		progress = network.NewProgressPrinter("Downloading")
+		progressCallback = progress.Update
 	}
 
-	response := e.network.DownloadFile(url, outputPath, progressCallback)
+	client, err := e.requestClient(options)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	response := client.DownloadFile(url, outputPath, convertHeaders(headers), progressCallback)
 
-	if showProgress && response.Error == "" {
-		fmt.Println() // New line after progress
+	if progress != nil {
+		progress.Done()
 	}
 
 	return map[string]interface{}{
@@ -125,31 +268,38 @@ func (e *Engine) httpDownloadFileResume(url string, outputPath string, options m
 			"error": "Network client not available",
 		}
 	}
+	if err := e.checkFileOperationSecurity(outputPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
 
 	// Parse options
 	showProgress := false
+	var headers map[string]interface{}
 	if options != nil {
 		if val, ok := options["show_progress"].(bool); ok {
 			showProgress = val
 		}
+		if h, ok := options["headers"].(map[string]interface{}); ok {
+			headers = h
+		}
 	}
 
 	// Progress callback
 	var progressCallback func(network.DownloadProgress)
+	var progress *network.ProgressPrinter
 	if showProgress {
-		progressCallback = func(progress network.DownloadProgress) {
-			fmt.Printf("\rDownloading... %d%% (%s/%s) - %s",
-				progress.Percentage,
-				formatBytes(progress.Downloaded),
-				formatBytes(progress.Total),
-				progress.Speed)
-		}
+		progress = network.NewProgressPrinter("Downloading")
+		progressCallback = progress.Update
 	}
 
-	response := e.network.DownloadFileResume(url, outputPath, progressCallback)
+	client, err := e.requestClient(options)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	response := client.DownloadFileResume(url, outputPath, convertHeaders(headers), progressCallback)
 
-	if showProgress && response.Error == "" {
-		fmt.Println() // New line after progress
+	if progress != nil {
+		progress.Done()
 	}
 
 	return map[string]interface{}{
@@ -160,6 +310,236 @@ func (e *Engine) httpDownloadFileResume(url string, outputPath string, options m
 	}
 }
 
+// httpDownloadResume continues an interrupted download from its .part file.
+// progress, if a function, is called with {downloaded, total, percentage,
+// speed} as data arrives; if it throws the error is reported in the result.
+// options.headers are sent with the Range request, e.g. the Authorization
+// header the interrupted download used.
+func (e *Engine) httpDownloadResume(url string, outputPath string, progress goja.Value, options map[string]interface{}) map[string]interface{} {
+	if e.network == nil {
+		return map[string]interface{}{
+			"error": "Network client not available",
+		}
+	}
+	if err := e.checkFileOperationSecurity(outputPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	var progressCallback func(network.DownloadProgress)
+	var callbackErr error
+	if fn, ok := goja.AssertFunction(progress); ok {
+		progressCallback = func(p network.DownloadProgress) {
+			if callbackErr != nil {
+				return
+			}
+			_, callbackErr = fn(goja.Undefined(), e.vm.ToValue(map[string]interface{}{
+				"downloaded": p.Downloaded,
+				"total":      p.Total,
+				"percentage": p.Percentage,
+				"speed":      p.Speed,
+			}))
+		}
+	}
+
+	var headers map[string]interface{}
+	if h, ok := options["headers"].(map[string]interface{}); ok {
+		headers = h
+	}
+	client, err := e.requestClient(options)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	response := client.DownloadFileResume(url, outputPath, convertHeaders(headers), progressCallback)
+
+	result := map[string]interface{}{
+		"status_code": response.StatusCode,
+		"headers":     response.Headers,
+		"body":        response.Body,
+		"error":       response.Error,
+	}
+	if callbackErr != nil && response.Error == "" {
+		result["error"] = fmt.Sprintf("progress callback failed: %v", callbackErr)
+	}
+	return result
+}
+
+// httpDownloadClearPartial discards the .part and .part.meta files of an
+// interrupted download so the next resume starts from the beginning
+func (e *Engine) httpDownloadClearPartial(outputPath string) map[string]interface{} {
+	if err := e.checkFileOperationSecurity(outputPath); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	removed, err := network.ClearPartialDownload(outputPath)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+	return e.createResult(true, map[string]interface{}{"removed": removed}, nil)
+}
+
+// defaultDownloadConcurrency is the number of parallel downloads
+// http.downloadAll runs when options.concurrency is not set
+const defaultDownloadConcurrency = 4
+
+// downloadJob is one {url, output} entry passed to http.downloadAll
+type downloadJob struct {
+	index  int
+	url    string
+	output string
+}
+
+// downloadJobResult is a finished downloadJob
+type downloadJobResult struct {
+	job      downloadJob
+	response *network.HTTPResponse
+}
+
+// httpDownloadAll downloads jobs of {url, output} through a pool of at most
+// options.concurrency workers using the resume downloader. Each URL is checked
+// against the whitelist when it is fetched. options.onComplete is called with
+// ({index, url, output, status_code, error}) as every job finishes; callbacks
+// run on the calling goroutine, as the JS runtime is not safe for concurrent
+// use. If a callback throws, no further jobs are started.
+func (e *Engine) httpDownloadAll(jobs []interface{}, options map[string]interface{}) map[string]interface{} {
+	if e.network == nil {
+		return map[string]interface{}{
+			"error": "Network client not available",
+		}
+	}
+
+	concurrency := defaultDownloadConcurrency
+	var onComplete goja.Callable
+	if options != nil {
+		switch n := options["concurrency"].(type) {
+		case nil:
+		case int64:
+			concurrency = int(n)
+		case float64:
+			concurrency = int(n)
+		default:
+			concurrency = 0
+		}
+		if concurrency < 1 {
+			return e.createResult(false, nil, fmt.Errorf("concurrency must be a positive number, got %v", options["concurrency"]))
+		}
+		if fn, ok := goja.AssertFunction(e.vm.ToValue(options["onComplete"])); ok {
+			onComplete = fn
+		}
+	}
+	client, err := e.requestClient(options)
+	if err != nil {
+		return e.createResult(false, nil, err)
+	}
+
+	// Validate every job before starting any download. Two jobs writing the
+	// same file would interleave their .part files, so outputs must differ.
+	parsed := make([]downloadJob, 0, len(jobs))
+	outputs := make(map[string]int, len(jobs))
+	for i, raw := range jobs {
+		spec, ok := raw.(map[string]interface{})
+		if !ok {
+			return e.createResult(false, nil, fmt.Errorf("job %d must be an object with url and output", i))
+		}
+		url, _ := spec["url"].(string)
+		output, _ := spec["output"].(string)
+		if url == "" || output == "" {
+			return e.createResult(false, nil, fmt.Errorf("job %d needs both url and output", i))
+		}
+		if err := e.checkFileOperationSecurity(output); err != nil {
+			return e.createResult(false, nil, fmt.Errorf("job %d: %w", i, err))
+		}
+		absOutput, err := filepath.Abs(output)
+		if err != nil {
+			return e.createResult(false, nil, fmt.Errorf("job %d: invalid output path: %w", i, err))
+		}
+		resolved := filesystem.ResolveExistingPath(absOutput)
+		if first, dup := outputs[resolved]; dup {
+			return e.createResult(false, nil, fmt.Errorf("job %d writes the same file as job %d: %s", i, first, output))
+		}
+		outputs[resolved] = i
+		parsed = append(parsed, downloadJob{index: i, url: url, output: output})
+	}
+	if concurrency > len(parsed) {
+		concurrency = len(parsed)
+	}
+
+	pending := make(chan downloadJob)
+	done := make(chan downloadJobResult)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range pending {
+				done <- downloadJobResult{job: job, response: client.DownloadFileResume(job.url, job.output, nil, nil)}
+			}
+		}()
+	}
+
+	// Jobs already running when the workflow is cancelled or a callback
+	// fails are waited for; the remaining ones are not started
+	results := make([]interface{}, len(parsed))
+	ctx := e.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var stopErr error
+	cancelled := ctx.Done()
+	next, finished, failed := 0, 0, 0
+	for finished < next || (stopErr == nil && next < len(parsed)) {
+		// Offer the next job while listening for finished ones
+		var feed chan downloadJob
+		var job downloadJob
+		if stopErr == nil && next < len(parsed) {
+			feed, job = pending, parsed[next]
+		}
+
+		select {
+		case feed <- job:
+			next++
+		case <-cancelled:
+			stopErr = ctx.Err()
+			cancelled = nil
+		case r := <-done:
+			finished++
+			info := downloadJobInfo(r)
+			results[r.job.index] = info
+			if r.response.Error != "" {
+				failed++
+			}
+			if onComplete != nil && stopErr == nil {
+				if _, err := onComplete(goja.Undefined(), e.vm.ToValue(info)); err != nil {
+					stopErr = fmt.Errorf("onComplete callback failed: %w", err)
+				}
+			}
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	data := map[string]interface{}{
+		"results": results,
+		"failed":  failed,
+	}
+	if stopErr != nil {
+		return e.createResult(false, data, stopErr)
+	}
+	if failed > 0 {
+		return e.createResult(false, data, fmt.Errorf("%d of %d downloads failed", failed, len(parsed)))
+	}
+	return e.createResult(true, data, nil)
+}
+
+// downloadJobInfo is the JavaScript view of a finished download job
+func downloadJobInfo(r downloadJobResult) map[string]interface{} {
+	return map[string]interface{}{
+		"index":       r.job.index,
+		"url":         r.job.url,
+		"output":      r.job.output,
+		"status_code": r.response.StatusCode,
+		"error":       r.response.Error,
+	}
+}
+
 func (e *Engine) networkNotAvailable(args ...interface{}) map[string]interface{} {
 	return map[string]interface{}{
 		"error": "Network functionality not available",
@@ -168,6 +548,21 @@ func (e *Engine) networkNotAvailable(args ...interface{}) map[string]interface{}
 
 // Helper functions
 
+// httpResult converts a response for JavaScript. With binary the body is
+// base64 encoded, as JS strings cannot hold arbitrary bytes.
+func httpResult(response *network.HTTPResponse, binary bool) map[string]interface{} {
+	body := response.Body
+	if binary {
+		body = base64.StdEncoding.EncodeToString([]byte(response.Body))
+	}
+	return map[string]interface{}{
+		"status_code": response.StatusCode,
+		"headers":     response.Headers,
+		"body":        body,
+		"error":       response.Error,
+	}
+}
+
 func convertHeaders(headers map[string]interface{}) map[string]string {
 	if headers == nil {
 		return nil
@@ -183,16 +578,3 @@ func convertHeaders(headers map[string]interface{}) map[string]string {
 	}
 	return result
 }
-
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
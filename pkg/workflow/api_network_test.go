@@ -0,0 +1,189 @@
+package workflow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPDownloadAll(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	// httptest servers listen on loopback, which the network guard blocks by default
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	outDir := t.TempDir()
+	script := `//!amo
+var base = getVar("base"), dir = getVar("dir");
+var jobs = [];
+for (var i = 0; i < 6; i++) {
+	jobs.push({url: base + "/file" + i, output: dir + "/file" + i + ".txt"});
+}
+var completed = 0;
+var result = http.downloadAll(jobs, {concurrency: 2, onComplete: function(job) {
+	if (job.error) throw new Error("job " + job.index + " failed: " + job.error);
+	completed++;
+}});
+if (!result.success) throw new Error("downloadAll failed: " + result.error);
+if (completed !== 6) throw new Error("onComplete called " + completed + " times");
+
+var failing = http.downloadAll([
+	{url: base + "/missing", output: dir + "/missing.txt"},
+	{url: "https://not-whitelisted.invalid/x", output: dir + "/x.txt"}
+]);
+if (failing.success || failing.data.failed !== 2) throw new Error("failures not reported: " + JSON.stringify(failing));
+
+var bad = http.downloadAll([{url: base + "/file0"}]);
+if (bad.success || bad.error.indexOf("needs both url and output") < 0) throw new Error("invalid job accepted: " + JSON.stringify(bad));
+
+var dup = http.downloadAll([
+	{url: base + "/file0", output: dir + "/same.txt"},
+	{url: base + "/file1", output: dir + "/sub/../same.txt"}
+]);
+if (dup.success || dup.error.indexOf("same file as job 0") < 0) throw new Error("duplicate output accepted: " + JSON.stringify(dup));
+`
+	scriptPath := filepath.Join(t.TempDir(), "download-all.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(outDir)
+	engine.SetVars(map[string]string{"base": server.URL, "dir": outDir})
+	if err := engine.RunWorkflow(scriptPath); err != nil {
+		t.Fatalf("downloadAll workflow failed: %v", err)
+	}
+
+	if peak > 2 {
+		t.Errorf("Saw %d concurrent downloads; concurrency is 2", peak)
+	}
+	data, err := os.ReadFile(filepath.Join(outDir, "file3.txt"))
+	if err != nil || !strings.Contains(string(data), "/file3") {
+		t.Errorf("file3.txt = %q, %v", string(data), err)
+	}
+}
+
+func TestHTTPMinIntervalOption(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	// httptest servers listen on loopback, which the network guard blocks by default
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	script := `//!amo
+var base = getVar("base");
+var start = Date.now();
+for (var i = 0; i < 3; i++) {
+	var res = http.get(base + "/page" + i, {}, {minInterval: 50});
+	if (res.error) throw new Error("get failed: " + res.error);
+}
+if (Date.now() - start < 90) throw new Error("requests were not spaced out: " + (Date.now() - start) + "ms");
+
+var bad = http.request("GET", base, {minInterval: "fast"});
+if (bad.success !== false || bad.error.indexOf("minInterval") < 0) throw new Error("invalid minInterval accepted: " + JSON.stringify(bad));
+`
+	scriptPath := filepath.Join(t.TempDir(), "min-interval.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetVars(map[string]string{"base": server.URL})
+	if err := engine.RunWorkflow(scriptPath); err != nil {
+		t.Fatalf("minInterval workflow failed: %v", err)
+	}
+}
+
+func TestHTTPDownloadResumeSendsHeaders(t *testing.T) {
+	const content = "0123456789"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	outDir := t.TempDir()
+	output := filepath.Join(outDir, "file.bin")
+	// A download that was interrupted after the first four bytes
+	if err := os.WriteFile(output+".part", []byte(content[:4]), 0644); err != nil {
+		t.Fatalf("Failed to write part file: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetSandboxRoot(outDir)
+	engine.SetVars(map[string]string{"url": server.URL + "/file.bin", "output": output})
+	script := `//!amo
+var resumed = http.downloadResume(getVar("url"), getVar("output"), null, {headers: {"Authorization": "Bearer secret"}});
+if (resumed.error) throw new Error("resume failed: " + resumed.error);
+`
+	if err := engine.RunWorkflowSource(script, "resume.js"); err != nil {
+		t.Fatalf("downloadResume workflow failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil || string(data) != content {
+		t.Errorf("Resumed file = %q, %v; expected %q", data, err, content)
+	}
+}
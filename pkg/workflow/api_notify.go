@@ -0,0 +1,55 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"amo/pkg/env"
+)
+
+// registerNotifyAPI registers desktop and webhook notification functions
+func (e *Engine) registerNotifyAPI() {
+	e.vm.Set("notify", map[string]interface{}{
+		"desktop": e.notifyDesktop,
+		"webhook": e.notifyWebhook,
+	})
+}
+
+// notifyDesktop shows a desktop notification using the platform's native tooling
+func (e *Engine) notifyDesktop(title string, message string) map[string]interface{} {
+	if err := env.NewNotifier().Desktop(title, message); err != nil {
+		return e.createResult(false, nil, err)
+	}
+	return e.createResult(true, nil, nil)
+}
+
+// notifyWebhook POSTs a JSON payload to a whitelisted URL
+func (e *Engine) notifyWebhook(url string, payload interface{}) map[string]interface{} {
+	if e.network == nil {
+		return e.createResult(false, nil, fmt.Errorf("network client not available"))
+	}
+
+	var body string
+	switch p := payload.(type) {
+	case string:
+		body = p
+	default:
+		data, err := json.Marshal(p)
+		if err != nil {
+			return e.createResult(false, nil, fmt.Errorf("failed to encode payload: %w", err))
+		}
+		body = string(data)
+	}
+
+	response := e.network.Post(url, body, map[string]string{"Content-Type": "application/json"})
+	if response.Error != "" {
+		return e.createResult(false, nil, fmt.Errorf("%s", response.Error))
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return e.createResult(false, nil, fmt.Errorf("webhook returned status %d", response.StatusCode))
+	}
+	return map[string]interface{}{
+		"success":     true,
+		"status_code": response.StatusCode,
+	}
+}
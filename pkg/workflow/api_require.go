@@ -0,0 +1,179 @@
+package workflow
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"amo/pkg/filesystem"
+
+	"github.com/dop251/goja"
+)
+
+// embeddedPrefix marks the origin of a workflow or module read from the
+// embedded assets rather than a file
+const embeddedPrefix = "embedded:"
+
+// registerRequireAPI sets up require() for a fresh VM. Modules resolve
+// against the directory of the running workflow and cannot leave it.
+func (e *Engine) registerRequireAPI() {
+	e.modules = make(map[string]*goja.Object)
+	e.moduleRoot = ""
+	if e.scriptOrigin != "" {
+		e.moduleRoot = originDir(e.scriptOrigin)
+	}
+	e.vm.Set("require", e.requireFrom(e.moduleRoot))
+}
+
+// requireFrom returns the require function of a module in dir, so nested
+// requires resolve against the requiring file
+func (e *Engine) requireFrom(dir string) func(string) goja.Value {
+	return func(name string) goja.Value {
+		exports, err := e.requireModule(dir, name)
+		if err != nil {
+			if _, ok := err.(*goja.Exception); ok {
+				panic(err)
+			}
+			if _, ok := err.(*goja.InterruptedError); ok {
+				panic(err)
+			}
+			panic(e.vm.NewGoError(err))
+		}
+		return exports
+	}
+}
+
+// requireModule loads and runs a module once per workflow run and returns
+// its module.exports. A module required again while it is still loading, as
+// in a require cycle, gets its exports so far.
+func (e *Engine) requireModule(dir, name string) (goja.Value, error) {
+	origin, err := e.resolveModule(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	if module, ok := e.modules[origin]; ok {
+		return module.Get("exports"), nil
+	}
+
+	source, err := e.readModule(origin)
+	if err != nil {
+		return nil, fmt.Errorf("cannot require %s: %w", name, err)
+	}
+
+	// The wrapper gives each module its own scope, so its top-level
+	// declarations do not leak into the workflow
+	program, err := goja.Compile(moduleDisplayName(origin), "(function (module, exports, require) {"+source+"\n})", false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot require %s: %w", name, err)
+	}
+	wrapper, err := e.vm.RunProgram(program)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		return nil, fmt.Errorf("cannot require %s: module did not compile to a function", name)
+	}
+
+	module := e.vm.NewObject()
+	exports := e.vm.NewObject()
+	module.Set("id", moduleDisplayName(origin))
+	module.Set("exports", exports)
+	e.modules[origin] = module
+
+	if _, err := fn(goja.Undefined(), module, exports, e.vm.ToValue(e.requireFrom(originDir(origin)))); err != nil {
+		delete(e.modules, origin)
+		return nil, err
+	}
+	return module.Get("exports"), nil
+}
+
+// resolveModule turns a require() argument into the origin of a module
+// beneath the workflow directory; .js is added when there is no extension
+func (e *Engine) resolveModule(dir, name string) (string, error) {
+	if e.moduleRoot == "" {
+		return "", fmt.Errorf("require is only available to workflows run from a file")
+	}
+
+	rel := filepath.ToSlash(strings.TrimSpace(name))
+	if rel == "" {
+		return "", fmt.Errorf("require needs a module path")
+	}
+	if path.IsAbs(rel) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("cannot require %s: module paths must be relative to the workflow", name)
+	}
+	if path.Ext(rel) == "" {
+		rel += ".js"
+	}
+	escapes := fmt.Errorf("cannot require %s: modules must be inside the workflow directory", name)
+
+	if strings.HasPrefix(dir, embeddedPrefix) {
+		root := strings.TrimPrefix(e.moduleRoot, embeddedPrefix)
+		target := path.Join(strings.TrimPrefix(dir, embeddedPrefix), rel)
+		if root != "." && target != root && !strings.HasPrefix(target, root+"/") {
+			return "", escapes
+		}
+		if target == ".." || strings.HasPrefix(target, "../") {
+			return "", escapes
+		}
+		return embeddedPrefix + target, nil
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(rel))
+	if !filesystem.IsWithinDir(target, e.moduleRoot) {
+		return "", escapes
+	}
+	// A symlink inside the directory must not lead out of it either
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		root := e.moduleRoot
+		if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolvedRoot
+		}
+		if !filesystem.IsWithinDir(resolved, root) {
+			return "", escapes
+		}
+	}
+	return target, nil
+}
+
+// readModule reads a module from a file or the embedded assets
+func (e *Engine) readModule(origin string) (string, error) {
+	if strings.HasPrefix(origin, embeddedPrefix) {
+		assetPath := strings.TrimPrefix(origin, embeddedPrefix)
+		if e.assetReader == nil || !e.assetReader.Exists(assetPath) {
+			return "", fmt.Errorf("module not found")
+		}
+		return e.assetReader.ReadFileAsString(assetPath)
+	}
+
+	content, err := os.ReadFile(origin)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("module not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// originDir returns the directory of a workflow or module origin, keeping
+// the embedded: prefix of embedded ones
+func originDir(origin string) string {
+	if strings.HasPrefix(origin, embeddedPrefix) {
+		return embeddedPrefix + path.Dir(strings.TrimPrefix(origin, embeddedPrefix))
+	}
+	if abs, err := filepath.Abs(origin); err == nil {
+		origin = abs
+	}
+	return filepath.Dir(origin)
+}
+
+// moduleDisplayName is the name a module has in stack traces
+func moduleDisplayName(origin string) string {
+	if strings.HasPrefix(origin, embeddedPrefix) {
+		return strings.TrimPrefix(origin, embeddedPrefix)
+	}
+	return filepath.Base(origin)
+}
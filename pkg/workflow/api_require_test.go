@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequire(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	base := t.TempDir()
+	dir := filepath.Join(base, "flows")
+	files := map[string]string{
+		"lib/math.js":  "var count = (typeof count === 'number' ? count : 0) + 1;\nexports.double = function (n) { return n * 2; };\nexports.loads = function () { return count; };\n",
+		"lib/greet.js": "var math = require('./math');\nmodule.exports = function (name) { return 'hi ' + name + ' x' + math.double(1); };\n",
+		"lib/cycle.js": "exports.early = true;\nexports.other = require('../cycle-b').seen;\n",
+		"cycle-b.js":   "exports.seen = require('./lib/cycle').early === true;\n",
+		"broken.js":    "throw new Error('module failed');\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create module dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write module: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "outside.js"), []byte("exports.secret = 1;\n"), 0644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+
+	script := `//!amo
+var math = require("./lib/math.js");
+if (math.double(21) !== 42) throw new Error("double");
+if (require("lib/math").loads() !== 1) throw new Error("module ran twice");
+if (require("./lib/greet")("amo") !== "hi amo x2") throw new Error("greet");
+if (typeof count !== "undefined") throw new Error("module scope leaked");
+if (require("./lib/cycle").other !== true) throw new Error("cycle");
+
+function failure(path) {
+	try { require(path); } catch (e) { return String(e); }
+	return "";
+}
+if (failure("../outside").indexOf("inside the workflow directory") < 0) throw new Error("escaped: " + failure("../outside"));
+if (failure("/etc/passwd").indexOf("relative to the workflow") < 0) throw new Error("absolute: " + failure("/etc/passwd"));
+if (failure("./missing").indexOf("module not found") < 0) throw new Error("missing: " + failure("./missing"));
+if (failure("./broken").indexOf("module failed") < 0) throw new Error("broken: " + failure("./broken"));
+`
+	scriptPath := filepath.Join(dir, "main.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	if err := NewEngine(context.Background()).RunWorkflow(scriptPath); err != nil {
+		t.Errorf("RunWorkflow failed: %v", err)
+	}
+}
+
+func TestRequireSymlinkEscape(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	outside := filepath.Join(t.TempDir(), "secret.js")
+	if err := os.WriteFile(outside, []byte("exports.secret = 1;\n"), 0644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link.js")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	scriptPath := filepath.Join(dir, "main.js")
+	if err := os.WriteFile(scriptPath, []byte("//!amo\nrequire('./link');\n"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	err := NewEngine(context.Background()).RunWorkflow(scriptPath)
+	if err == nil || !strings.Contains(err.Error(), "inside the workflow directory") {
+		t.Errorf("RunWorkflow error = %v; expected the symlinked module to be rejected", err)
+	}
+}
+
+func TestRequireEmbedded(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	engine := NewEngine(context.Background())
+	engine.SetAssetReader(fakeAssets{
+		"workflow/main.js":     "//!amo\nif (require('./lib/util').name !== 'util') throw new Error('util');\nrequire('../tools/other');\n",
+		"workflow/lib/util.js": "exports.name = 'util';\n",
+		"tools/other.js":       "exports.x = 1;\n",
+	})
+
+	err := engine.RunWorkflow("workflow/main.js")
+	if err == nil || !strings.Contains(err.Error(), "inside the workflow directory") {
+		t.Errorf("RunWorkflow error = %v; expected ../tools/other to be rejected after ./lib/util loaded", err)
+	}
+}
+
+func TestRequireWithoutWorkflowFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	err := NewEngine(context.Background()).RunWorkflowSource("//!amo\nrequire('./lib');\n", "stdin")
+	if err == nil || !strings.Contains(err.Error(), "only available to workflows run from a file") {
+		t.Errorf("RunWorkflowSource error = %v; expected require to be unavailable", err)
+	}
+}
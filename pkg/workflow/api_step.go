@@ -0,0 +1,83 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// ErrStepAborted is wrapped by the error of a workflow stopped from the step
+// mode prompt
+var ErrStepAborted = errors.New("workflow aborted in step mode")
+
+// maxStepArgLength caps how much of each argument a step prompt shows
+const maxStepArgLength = 120
+
+// SetStepper turns on step mode, e.g. for amo run --step: confirm is called
+// with a description of each cliCommand and http call before it runs, and a
+// false answer stops the workflow with ErrStepAborted. Nil turns it off.
+// Call before RunWorkflow.
+func (e *Engine) SetStepper(confirm func(action string) bool) {
+	e.stepper = confirm
+}
+
+// registerStepHooks wraps the functions that reach outside the workflow, the
+// commands it runs and the requests it makes, so step mode asks before each call
+func (e *Engine) registerStepHooks() {
+	if e.stepper == nil {
+		return
+	}
+
+	if fn, ok := goja.AssertFunction(e.vm.Get("cliCommand")); ok {
+		e.vm.Set("cliCommand", e.stepHook("cliCommand", fn))
+	}
+
+	httpObject := e.vm.Get("http").ToObject(e.vm)
+	keys := httpObject.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		if fn, ok := goja.AssertFunction(httpObject.Get(key)); ok {
+			httpObject.Set(key, e.stepHook("http."+key, fn))
+		}
+	}
+}
+
+// stepHook returns fn wrapped to ask the stepper first
+func (e *Engine) stepHook(name string, fn goja.Callable) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if !e.stepper(describeStep(name, call.Arguments)) {
+			// Interrupting cannot be caught by the script, unlike a thrown error
+			e.vm.Interrupt(ErrStepAborted)
+			return goja.Undefined()
+		}
+		result, err := fn(call.This, call.Arguments...)
+		if err != nil {
+			panic(err)
+		}
+		return result
+	}
+}
+
+// describeStep formats a call as name(arg, ...) with long arguments cut short
+func describeStep(name string, args []goja.Value) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		var text string
+		if goja.IsUndefined(arg) || goja.IsNull(arg) {
+			text = arg.String()
+		} else if data, err := json.Marshal(arg.Export()); err == nil {
+			text = string(data)
+		} else {
+			text = fmt.Sprintf("%v", arg.Export())
+		}
+		if len(text) > maxStepArgLength {
+			text = text[:maxStepArgLength] + "…"
+		}
+		parts = append(parts, text)
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
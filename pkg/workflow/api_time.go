@@ -0,0 +1,65 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the named layouts time.format accepts besides Go layouts
+var timeLayouts = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"rfc1123":  time.RFC1123,
+	"datetime": time.DateTime,
+	"date":     time.DateOnly,
+	"time":     time.TimeOnly,
+	"kitchen":  time.Kitchen,
+}
+
+// registerTimeAPI registers the time functions
+func (e *Engine) registerTimeAPI() {
+	e.vm.Set("time", map[string]interface{}{
+		"sleep":  e.timeSleep,
+		"now":    e.timeNow,
+		"format": e.timeFormat,
+	})
+}
+
+// timeSleep pauses the workflow for ms milliseconds. When the workflow is
+// cancelled or times out meanwhile, it interrupts the VM so the script stops
+// with the context error instead of carrying on.
+func (e *Engine) timeSleep(ms float64) {
+	if ms <= 0 {
+		return
+	}
+	ctx := e.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timer := time.NewTimer(time.Duration(ms * float64(time.Millisecond)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		// Interrupting cannot be caught by the script, unlike a thrown error
+		e.vm.Interrupt(ctx.Err())
+	}
+}
+
+// timeNow returns the current time in milliseconds since the Unix epoch, like Date.now()
+func (e *Engine) timeNow() int64 {
+	return time.Now().UnixMilli()
+}
+
+// timeFormat formats epoch milliseconds in local time with a Go layout such
+// as "2006-01-02 15:04" or a named one (rfc3339, rfc1123, datetime, date,
+// time, kitchen). The layout defaults to rfc3339.
+func (e *Engine) timeFormat(epoch int64, layout string) string {
+	if layout == "" {
+		layout = time.RFC3339
+	} else if named, ok := timeLayouts[strings.ToLower(layout)]; ok {
+		layout = named
+	}
+	return time.UnixMilli(epoch).Format(layout)
+}
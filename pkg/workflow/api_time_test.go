@@ -0,0 +1,73 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	engine := NewEngine(context.Background())
+	epoch := time.Date(2024, 3, 5, 14, 7, 9, 0, time.Local).UnixMilli()
+
+	testCases := []struct {
+		layout   string
+		expected string
+	}{
+		{"date", "2024-03-05"},
+		{"DateTime", "2024-03-05 14:07:09"},
+		{"2006/01/02 15h04", "2024/03/05 14h07"},
+		{"", time.UnixMilli(epoch).Format(time.RFC3339)},
+	}
+	for _, tc := range testCases {
+		if got := engine.timeFormat(epoch, tc.layout); got != tc.expected {
+			t.Errorf("timeFormat(%d, %q) = %q; expected %q", epoch, tc.layout, got, tc.expected)
+		}
+	}
+}
+
+func TestTimeAPI(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	source := `//!amo
+var before = time.now();
+if (Math.abs(before - Date.now()) > 1000) throw new Error("time.now differs from Date.now");
+time.sleep(30);
+if (time.now() - before < 25) throw new Error("time.sleep returned early");
+time.sleep(-5);
+`
+	if err := NewEngine(context.Background()).RunWorkflowSource(source, "time"); err != nil {
+		t.Errorf("RunWorkflowSource failed: %v", err)
+	}
+}
+
+func TestTimeSleepStopsOnTimeout(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	engine := NewEngine(ctx)
+	engine.SetOutput(&stdout, io.Discard)
+
+	start := time.Now()
+	err := engine.RunWorkflowSource("//!amo\ntime.sleep(10000);\nconsole.log('not reached');\n", "sleep")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunWorkflowSource error = %v; expected it to wrap context.DeadlineExceeded", err)
+	}
+	if strings.Contains(stdout.String(), "not reached") {
+		t.Error("The script kept running after time.sleep was interrupted")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("time.sleep kept the workflow running for %v after its timeout", elapsed)
+	}
+}
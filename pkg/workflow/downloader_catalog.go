@@ -0,0 +1,88 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"amo/pkg/network"
+)
+
+// CatalogEntry describes one workflow listed in a catalog index
+type CatalogEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	// Filename overrides the name the workflow is saved under (optional)
+	Filename string `json:"filename,omitempty"`
+}
+
+// catalogIndex is the catalog JSON format: {"workflows": [...]}.
+// A bare array of entries is accepted as well.
+type catalogIndex struct {
+	Workflows []CatalogEntry `json:"workflows"`
+}
+
+// FetchCatalog downloads and parses a workflow catalog index. The catalog URL
+// must be an allowed workflow source, like any workflow URL.
+func (wd *WorkflowDownloader) FetchCatalog(catalogURL string) ([]CatalogEntry, error) {
+	if err := wd.IsValidURL(catalogURL); err != nil {
+		return nil, fmt.Errorf("URL validation failed: %w", err)
+	}
+
+	rawURL, err := wd.ConvertToRawURL(catalogURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert URL: %w", err)
+	}
+
+	nc, err := network.NewNetworkClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to init network client: %w", err)
+	}
+
+	resp := nc.Get(rawURL, map[string]string{"Accept": "application/json"})
+	if err := resp.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch catalog: HTTP %d", resp.StatusCode)
+	}
+
+	return wd.parseCatalog([]byte(resp.Body))
+}
+
+// parseCatalog parses catalog JSON, dropping entries without a URL and
+// naming unnamed entries after their file
+func (wd *WorkflowDownloader) parseCatalog(data []byte) ([]CatalogEntry, error) {
+	var entries []CatalogEntry
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid catalog JSON: %w", err)
+		}
+	} else {
+		var index catalogIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("invalid catalog JSON: %w", err)
+		}
+		entries = index.Workflows
+	}
+
+	result := make([]CatalogEntry, 0, len(entries))
+	for _, entry := range entries {
+		entry.URL = strings.TrimSpace(entry.URL)
+		if entry.URL == "" {
+			continue
+		}
+		if strings.TrimSpace(entry.Name) == "" {
+			if name, err := wd.ExtractFilename(entry.URL); err == nil {
+				entry.Name = strings.TrimSuffix(name, ".js")
+			} else {
+				entry.Name = entry.URL
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
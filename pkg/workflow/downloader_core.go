@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"crypto/sha1"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -9,14 +10,29 @@ import (
 	"sort"
 	"strings"
 
+	"amo/pkg/config"
 	"amo/pkg/env"
+	"amo/pkg/filesystem"
 	"amo/pkg/network"
-
-	"github.com/spf13/viper"
 )
 
 type WorkflowDownloader struct {
 	env *env.Environment
+
+	// preferMirror is resolved from the detected region on first download
+	// unless SetPreferMirror has been called
+	preferMirror    bool
+	preferMirrorSet bool
+	// noMirror downloads from the original URL only, without mirror fallback
+	noMirror bool
+	// namePrefix and nameSuffix wrap names taken from the URL, e.g.
+	// "vendor-" + "workflow" + ".js"
+	namePrefix string
+	nameSuffix string
+	// maxScriptSize caps downloads; resolved from the config on first use
+	// unless SetMaxScriptSize was called
+	maxScriptSize    int64
+	maxScriptSizeSet bool
 }
 
 func NewWorkflowDownloader() (*WorkflowDownloader, error) {
@@ -30,6 +46,36 @@ func NewWorkflowDownloader() (*WorkflowDownloader, error) {
 	}, nil
 }
 
+// SetPreferMirror controls whether the mirror site is tried before the original
+// GitHub URL. It is enabled automatically when the detected region is cn.
+func (wd *WorkflowDownloader) SetPreferMirror(prefer bool) {
+	wd.preferMirror = prefer
+	wd.preferMirrorSet = true
+}
+
+// SetNoMirror disables the mirror site, so GitHub downloads only use the
+// original URL and fail cleanly against it
+func (wd *WorkflowDownloader) SetNoMirror(noMirror bool) {
+	wd.noMirror = noMirror
+}
+
+// SetNameAffixes sets a prefix and suffix that ExtractFilename puts around
+// names taken from the URL; the suffix goes before .js. Explicit filenames
+// are used as given.
+func (wd *WorkflowDownloader) SetNameAffixes(prefix, suffix string) {
+	wd.namePrefix = prefix
+	wd.nameSuffix = suffix
+}
+
+// shouldPreferMirror detects the region once, on first use
+func (wd *WorkflowDownloader) shouldPreferMirror() bool {
+	if !wd.preferMirrorSet {
+		wd.preferMirror = wd.env.DetectRegion() == "cn"
+		wd.preferMirrorSet = true
+	}
+	return wd.preferMirror
+}
+
 func (wd *WorkflowDownloader) GetWorkflowsDir() string {
 	return wd.env.GetCrossPlatformUtils().JoinPath(wd.env.GetUserConfigDir(), "workflows")
 }
@@ -39,36 +85,67 @@ func (wd *WorkflowDownloader) EnsureWorkflowsDir() error {
 	return wd.env.GetCrossPlatformUtils().CreateDirWithPermissions(workflowsDir)
 }
 
+// GetConfiguredWorkflowsDir returns AMO_WORKFLOWS_DIR or else the workflows
+// key of the active config, so --config-file, AMO_CONFIG and profiles apply.
+// It returns "" when neither is set.
 func (wd *WorkflowDownloader) GetConfiguredWorkflowsDir() string {
 	configuredDir := os.Getenv("AMO_WORKFLOWS_DIR")
-	if configuredDir != "" {
-		return wd.env.GetCrossPlatformUtils().NormalizePath(configuredDir)
+	if configuredDir == "" {
+		manager, err := config.NewManager()
+		if err != nil {
+			return ""
+		}
+		configuredDir = manager.GetString(config.KeyWorkflowDir)
 	}
-
-	configDir := wd.env.GetUserConfigDir()
-	configFile := filepath.Join(configDir, "config.yaml")
-
-	v := viper.New()
-	v.SetConfigFile(configFile)
-	v.SetConfigType("yaml")
-
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+	if configuredDir == "" {
 		return ""
 	}
+	return wd.env.GetCrossPlatformUtils().NormalizePath(configuredDir)
+}
 
-	if err := v.ReadInConfig(); err != nil {
-		return ""
-	}
+// DownloadWorkflow downloads a workflow into the default workflows directory
+func (wd *WorkflowDownloader) DownloadWorkflow(urlStr string, filename string) error {
+	return wd.DownloadWorkflowTo(urlStr, filename, "")
+}
 
-	configuredDir = v.GetString("workflows")
-	if configuredDir != "" {
-		return wd.env.GetCrossPlatformUtils().NormalizePath(configuredDir)
+// TargetFilename returns the name DownloadWorkflowTo saves urlStr under
+func (wd *WorkflowDownloader) TargetFilename(urlStr, filename string) (string, error) {
+	rawURL, err := wd.ConvertToRawURL(urlStr)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert URL: %w", err)
 	}
+	return wd.targetFilename(rawURL, filename)
+}
 
-	return ""
+// targetFilename sanitizes filename and adds .js, or takes the name from
+// rawURL when filename is empty
+func (wd *WorkflowDownloader) targetFilename(rawURL, filename string) (string, error) {
+	if filename == "" {
+		name, err := wd.ExtractFilename(rawURL)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract filename: %w", err)
+		}
+		return name, nil
+	}
+	filename = wd.sanitizeFilename(filename)
+	if !strings.HasSuffix(strings.ToLower(filename), ".js") {
+		filename += ".js"
+	}
+	return filename, nil
 }
 
-func (wd *WorkflowDownloader) DownloadWorkflow(urlStr string, filename string) error {
+// DownloadWorkflowTo downloads a workflow into targetDir, which must be an
+// existing writable directory, or into the default workflows directory when
+// targetDir is empty. The //!amo check and filename sanitizing apply either way.
+func (wd *WorkflowDownloader) DownloadWorkflowTo(urlStr, filename, targetDir string) error {
+	if targetDir != "" {
+		if err := filesystem.NewFileSystem().CheckWritableDir(targetDir); err != nil {
+			return err
+		}
+	}
+	if err := network.CheckOnline(urlStr); err != nil {
+		return err
+	}
 	if err := wd.IsValidURL(urlStr); err != nil {
 		return fmt.Errorf("URL validation failed: %w", err)
 	}
@@ -78,44 +155,39 @@ func (wd *WorkflowDownloader) DownloadWorkflow(urlStr string, filename string) e
 		return fmt.Errorf("failed to convert URL: %w", err)
 	}
 
-	if filename == "" {
-		filename, err = wd.ExtractFilename(rawURL)
-		if err != nil {
-			return fmt.Errorf("failed to extract filename: %w", err)
-		}
-	} else {
-		filename = wd.sanitizeFilename(filename)
-		if !strings.HasSuffix(strings.ToLower(filename), ".js") {
-			filename += ".js"
-		}
+	filename, err = wd.targetFilename(rawURL, filename)
+	if err != nil {
+		return err
 	}
 
-	workflowsDir := wd.GetWorkflowsDir()
-
-	if err := wd.EnsureWorkflowsDir(); err != nil {
-		return fmt.Errorf("failed to create workflows directory: %w", err)
+	workflowsDir := targetDir
+	if workflowsDir == "" {
+		workflowsDir = wd.GetWorkflowsDir()
+		if err := wd.EnsureWorkflowsDir(); err != nil {
+			return fmt.Errorf("failed to create workflows directory: %w", err)
+		}
 	}
 
+	return wd.saveWorkflow(wd.downloadSources(rawURL), rawURL, workflowsDir, filename)
+}
+
+// saveWorkflow downloads a workflow into a temporary file next to its final
+// location, checks the //!amo header and only then renames it into place, so an
+// interrupted or invalid download never replaces an existing workflow
+func (wd *WorkflowDownloader) saveWorkflow(sources []string, rawURL, workflowsDir, filename string) error {
 	tempName := wd.buildTempName(filename, rawURL) + ".download"
 	tempPath := wd.env.GetCrossPlatformUtils().JoinPath(workflowsDir, tempName)
+	defer filesystem.TrackTempPath(tempPath)()
 
-	if err := wd.downloadToFileWithResume(rawURL, tempPath); err != nil {
-		fmt.Printf("⚠️  Original URL failed: %v\n", err)
-
-		parsedURL, parseErr := url.Parse(rawURL)
-		if parseErr == nil && wd.isGitHubURL(parsedURL) {
-			fmt.Printf("🔄 Trying mirror site: toolchains.mirror.toulan.fun\n")
-			mirrorURL, mirrorErr := wd.convertToMirrorURL(rawURL)
-			if mirrorErr == nil {
-				if err2 := wd.downloadToFileWithResume(mirrorURL, tempPath); err2 != nil {
-					return fmt.Errorf("both original and mirror download failed: original=%v, mirror=%v", err, err2)
-				}
-				fmt.Printf("✅ Successfully downloaded from mirror site\n")
-			} else {
-				return fmt.Errorf("original download failed and mirror URL conversion failed: original=%v, mirror=%v", err, mirrorErr)
-			}
-		} else {
-			return fmt.Errorf("download failed: %w", err)
+	if err := wd.downloadFromSources(sources, tempPath); err != nil {
+		return err
+	}
+
+	// Checked before reading, so an oversized download is never loaded into memory
+	if info, err := os.Stat(tempPath); err == nil {
+		if err := checkScriptSize("downloaded file", info.Size(), wd.scriptSizeLimit()); err != nil {
+			_ = os.Remove(tempPath)
+			return err
 		}
 	}
 
@@ -125,7 +197,7 @@ func (wd *WorkflowDownloader) DownloadWorkflow(urlStr string, filename string) e
 	}
 	if !strings.HasPrefix(strings.TrimSpace(string(fileBytes)), "//!amo") {
 		_ = os.Remove(tempPath)
-		return fmt.Errorf("downloaded file is not a valid amo workflow (must start with //!amo)")
+		return fmt.Errorf("downloaded file: %w", ErrInvalidWorkflow)
 	}
 
 	workflowPath := wd.env.GetCrossPlatformUtils().JoinPath(workflowsDir, filename)
@@ -139,37 +211,121 @@ func (wd *WorkflowDownloader) DownloadWorkflow(urlStr string, filename string) e
 	return nil
 }
 
-func (wd *WorkflowDownloader) downloadToFileWithResume(urlStr, outputPath string) error {
-	nc, err := network.NewNetworkClient()
+// downloadSources returns the URLs to try for rawURL, in order, and tells the
+// user when the mirror site is skipped or preferred
+func (wd *WorkflowDownloader) downloadSources(rawURL string) []string {
+	plan := &DownloadPlan{RawURL: rawURL}
+	wd.planSources(plan)
+	switch {
+	case plan.MirrorURL != "" && !plan.MirrorAllowed:
+		fmt.Printf("⚠️  Skipping mirror site: toolchains.mirror.toulan.fun is not an allowed workflow source\n")
+		fmt.Printf("   Run 'amo workflow source add toolchains.mirror.toulan.fun' to use it again\n")
+	case plan.MirrorPreferred:
+		fmt.Printf("🌏 Preferring mirror site: toolchains.mirror.toulan.fun\n")
+	}
+	return plan.Sources
+}
+
+// DownloadPlan is how DownloadWorkflowTo would handle a URL, worked out
+// without downloading anything
+type DownloadPlan struct {
+	URL string
+	// MatchedSource is the allowed source entry the URL matched, "" when
+	// SourceErr says why it is not allowed
+	MatchedSource string
+	SourceErr     error
+	// RawURL is the URL after GitHub/GitLab blob links are converted
+	RawURL string
+	// MirrorURL is the mirror copy of a GitHub URL, "" when there is none or
+	// the mirror is disabled
+	MirrorURL       string
+	MirrorAllowed   bool
+	MirrorPreferred bool
+	// Sources are the URLs that would be tried, in order
+	Sources []string
+}
+
+// PlanDownload checks urlStr against the allowed sources and converts it the
+// way DownloadWorkflowTo does, e.g. for amo workflow source test. The raw and
+// mirror URLs are filled in even when the URL is not allowed.
+func (wd *WorkflowDownloader) PlanDownload(urlStr string) (*DownloadPlan, error) {
+	plan := &DownloadPlan{URL: urlStr}
+	plan.MatchedSource, plan.SourceErr = wd.MatchSource(urlStr)
+
+	rawURL, err := wd.ConvertToRawURL(urlStr)
 	if err != nil {
-		return fmt.Errorf("failed to init network client: %w", err)
+		return nil, fmt.Errorf("failed to convert URL: %w", err)
+	}
+	plan.RawURL = rawURL
+	wd.planSources(plan)
+	return plan, nil
+}
+
+// planSources fills in the mirror and the download order for plan.RawURL.
+// GitHub URLs get the mirror site as a fallback, or as the first choice when
+// preferMirror is set, unless noMirror is set. The mirror URL goes through the
+// same source check as rawURL, so removing the mirror from the workflow
+// sources disables it.
+func (wd *WorkflowDownloader) planSources(plan *DownloadPlan) {
+	plan.Sources = []string{plan.RawURL}
+	parsedURL, err := url.Parse(plan.RawURL)
+	if err != nil || wd.noMirror || !wd.isGitHubURL(parsedURL) {
+		return
 	}
 
-	var lastPercent = -1
-	resp := nc.DownloadFileResume(urlStr, outputPath, func(p network.DownloadProgress) {
-		if p.Total > 0 {
-			if p.Percentage != lastPercent {
-				fmt.Printf("\r⬇️  Fetching script... %3d%% (%s/%s) - %s",
-					p.Percentage,
-					formatBytes(p.Downloaded),
-					formatBytes(p.Total),
-					p.Speed,
-				)
-				lastPercent = p.Percentage
+	mirrorURL, err := wd.convertToMirrorURL(plan.RawURL)
+	if err != nil {
+		return
+	}
+	plan.MirrorURL = mirrorURL
+	if err := wd.IsValidURL(mirrorURL); err != nil {
+		return
+	}
+	plan.MirrorAllowed = true
+
+	if wd.shouldPreferMirror() {
+		plan.MirrorPreferred = true
+		plan.Sources = []string{mirrorURL, plan.RawURL}
+		return
+	}
+	plan.Sources = []string{plan.RawURL, mirrorURL}
+}
+
+// downloadFromSources tries each source in order until one succeeds. A partial file
+// left by a failed source is discarded so it is never resumed against another source.
+func (wd *WorkflowDownloader) downloadFromSources(sources []string, outputPath string) error {
+	var errs []error
+	for i, source := range sources {
+		if i > 0 {
+			if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to discard partial download: %w", err)
 			}
-		} else {
-			fmt.Printf("\r⬇️  Fetching script... %s - %s",
-				formatBytes(p.Downloaded),
-				p.Speed,
-			)
+			fmt.Printf("🔄 Trying %s\n", source)
+		}
+		err := wd.downloadToFileWithResume(source, outputPath)
+		if err == nil {
+			return nil
 		}
-	})
-	if resp.Error != "" {
-		fmt.Println()
-		return fmt.Errorf("%s", resp.Error)
+		fmt.Printf("⚠️  Download from %s failed: %v\n", source, err)
+		errs = append(errs, err)
 	}
-	fmt.Println()
-	return nil
+
+	if len(errs) == 1 {
+		return fmt.Errorf("download failed: %w", errs[0])
+	}
+	return fmt.Errorf("all download sources failed: %w", errors.Join(errs...))
+}
+
+func (wd *WorkflowDownloader) downloadToFileWithResume(urlStr, outputPath string) error {
+	nc, err := network.NewNetworkClient()
+	if err != nil {
+		return fmt.Errorf("failed to init network client: %w", err)
+	}
+
+	progress := network.NewProgressPrinter("⬇️  Fetching script")
+	resp := nc.DownloadFileResume(urlStr, outputPath, nil, progress.Update)
+	progress.Done()
+	return resp.Err()
 }
 
 func (wd *WorkflowDownloader) buildTempName(filename, urlStr string) string {
@@ -182,36 +338,25 @@ func (wd *WorkflowDownloader) buildTempName(filename, urlStr string) string {
 	return name + "-" + short
 }
 
+// ListUserWorkflows lists the .js files in the default and configured
+// workflows directories, leaving out those matched by the directory's
+// .amoignore file
 func (wd *WorkflowDownloader) ListUserWorkflows() ([]string, error) {
 	workflowMap := make(map[string]bool)
 	var err1, err2 error
 
 	defaultWorkflowsDir := wd.GetWorkflowsDir()
 	if _, statErr := os.Stat(defaultWorkflowsDir); !os.IsNotExist(statErr) {
-		entries, err := os.ReadDir(defaultWorkflowsDir)
-		if err != nil {
+		if err := addUserWorkflows(workflowMap, defaultWorkflowsDir); err != nil {
 			err1 = fmt.Errorf("failed to read default workflows directory: %w", err)
-		} else {
-			for _, entry := range entries {
-				if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".js") {
-					workflowMap[entry.Name()] = true
-				}
-			}
 		}
 	}
 
 	configuredDir := wd.GetConfiguredWorkflowsDir()
 	if configuredDir != "" && configuredDir != defaultWorkflowsDir {
 		if _, statErr := os.Stat(configuredDir); !os.IsNotExist(statErr) {
-			entries, err := os.ReadDir(configuredDir)
-			if err != nil {
+			if err := addUserWorkflows(workflowMap, configuredDir); err != nil {
 				err2 = fmt.Errorf("failed to read configured workflows directory: %w", err)
-			} else {
-				for _, entry := range entries {
-					if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".js") {
-						workflowMap[entry.Name()] = true
-					}
-				}
 			}
 		}
 	}
@@ -233,3 +378,22 @@ func (wd *WorkflowDownloader) ListUserWorkflows() ([]string, error) {
 
 	return workflows, nil
 }
+
+// addUserWorkflows adds the .js files directly in dir that .amoignore does
+// not exclude
+func addUserWorkflows(workflowMap map[string]bool, dir string) error {
+	ignore, err := LoadIgnoreFile(dir)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".js") && !ignore.Ignored(entry.Name(), false) {
+			workflowMap[entry.Name()] = true
+		}
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package workflow
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 )
@@ -21,6 +23,69 @@ var AllowedDomains = append([]string(nil), DefaultAllowedDomains...)
 
 const AllowedSourcesFileName = "allowed_workflow_hosts.txt"
 
+// domainLabelPattern matches one DNS label such as "github" or "my-host"
+var domainLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// NormalizeSourceEntry turns user input into the "domain" or "domain/path" form
+// stored in the sources file. A leading scheme and trailing slashes are
+// stripped; anything that could never match a download URL is rejected.
+func NormalizeSourceEntry(entry string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(entry))
+	if normalized == "" {
+		return "", fmt.Errorf("source cannot be empty")
+	}
+	if strings.ContainsAny(normalized, " \t") {
+		return "", fmt.Errorf("invalid source %q: must not contain spaces", entry)
+	}
+	if strings.ContainsAny(normalized, "?#") {
+		return "", fmt.Errorf("invalid source %q: remove the query string or fragment; sources are a domain or domain/path", entry)
+	}
+
+	if i := strings.Index(normalized, "://"); i >= 0 {
+		normalized = normalized[i+3:]
+	}
+	normalized = strings.TrimRight(normalized, "/")
+
+	host, path, _ := strings.Cut(normalized, "/")
+	if host == "" {
+		return "", fmt.Errorf("invalid source %q: missing domain", entry)
+	}
+	if strings.Contains(host, "@") {
+		return "", fmt.Errorf("invalid source %q: must not contain credentials", entry)
+	}
+	if strings.Contains(host, ":") {
+		return "", fmt.Errorf("invalid source %q: ports are not supported; use the domain only", entry)
+	}
+	if !isPlausibleDomain(host) {
+		return "", fmt.Errorf("invalid source %q: %q does not look like a domain (expected e.g. github.com or github.com/owner)", entry, host)
+	}
+	if strings.Contains(path, "//") {
+		return "", fmt.Errorf("invalid source %q: path contains an empty segment", entry)
+	}
+
+	if path == "" {
+		return host, nil
+	}
+	return host + "/" + path, nil
+}
+
+// isPlausibleDomain reports whether host is an IP address or a dotted domain name
+func isPlausibleDomain(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 || len(host) > 253 {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) > 63 || !domainLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
 func (wd *WorkflowDownloader) GetAllowedSourcesFilePath() string {
 	return wd.env.GetCrossPlatformUtils().JoinPath(wd.env.GetUserConfigDir(), AllowedSourcesFileName)
 }
@@ -111,9 +176,9 @@ func (wd *WorkflowDownloader) AddAllowedSource(entry string) (bool, error) {
 	if err := wd.EnsureAllowedSourcesFile(); err != nil {
 		return false, err
 	}
-	entry = strings.TrimSpace(strings.ToLower(entry))
-	if entry == "" || strings.HasPrefix(entry, "#") {
-		return false, fmt.Errorf("invalid source entry")
+	entry, err := NormalizeSourceEntry(entry)
+	if err != nil {
+		return false, err
 	}
 
 	entries, err := wd.LoadAllowedSources()
@@ -140,6 +205,12 @@ func (wd *WorkflowDownloader) RemoveAllowedSource(entry string) (bool, error) {
 	if entry == "" || strings.HasPrefix(entry, "#") {
 		return false, fmt.Errorf("invalid source entry")
 	}
+	// Also match the normalized form, so "https://host/" removes "host";
+	// the raw form still removes malformed entries stored before validation
+	canonical, err := NormalizeSourceEntry(entry)
+	if err != nil {
+		canonical = entry
+	}
 
 	entries, err := wd.LoadAllowedSources()
 	if err != nil {
@@ -149,7 +220,7 @@ func (wd *WorkflowDownloader) RemoveAllowedSource(entry string) (bool, error) {
 	removed := false
 	for _, e := range entries {
 		normalized := strings.TrimSpace(strings.ToLower(e))
-		if normalized == entry {
+		if normalized == entry || normalized == canonical {
 			removed = true
 			continue
 		}
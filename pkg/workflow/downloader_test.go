@@ -1,7 +1,16 @@
 package workflow
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"amo/pkg/network"
 )
 
 func TestIsValidURL(t *testing.T) {
@@ -58,6 +67,71 @@ func TestIsValidURL(t *testing.T) {
 	}
 }
 
+func TestTargetFilename(t *testing.T) {
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+
+	testCases := []struct {
+		url      string
+		filename string
+		expected string
+	}{
+		{"https://github.com/user/repo/blob/main/hash-demo.js", "", "hash-demo.js"},
+		{"https://github.com/user/repo/blob/main/other.js", "hash-demo", "hash-demo.js"},
+		{"https://github.com/user/repo/blob/main/other.js", "mine.js", "mine.js"},
+	}
+
+	for _, tc := range testCases {
+		got, err := downloader.TargetFilename(tc.url, tc.filename)
+		if err != nil {
+			t.Errorf("TargetFilename(%q, %q) failed: %v", tc.url, tc.filename, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("TargetFilename(%q, %q) = %q; expected %q", tc.url, tc.filename, got, tc.expected)
+		}
+	}
+}
+
+func TestExtractFilenameAffixes(t *testing.T) {
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+
+	testCases := []struct {
+		url      string
+		prefix   string
+		suffix   string
+		expected string
+	}{
+		{"https://github.com/user/repo/blob/main/convert.js", "vendor-", "", "vendor-convert.js"},
+		{"https://github.com/user/repo/blob/main/convert.JS", "", "-v2", "convert-v2.JS"},
+		{"https://github.com/user/repo/blob/main/convert", "acme_", "-beta", "acme_convert-beta.js"},
+		{"https://github.com/user/repo/blob/main/convert.js", "a/b:", "", "a_b_convert.js"},
+	}
+
+	for _, tc := range testCases {
+		downloader.SetNameAffixes(tc.prefix, tc.suffix)
+		got, err := downloader.ExtractFilename(tc.url)
+		if err != nil {
+			t.Errorf("ExtractFilename(%q) failed: %v", tc.url, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("ExtractFilename(%q) with prefix %q, suffix %q = %q; expected %q", tc.url, tc.prefix, tc.suffix, got, tc.expected)
+		}
+	}
+
+	// Explicit filenames are used as given
+	downloader.SetNameAffixes("vendor-", "")
+	if got, _ := downloader.TargetFilename("https://github.com/user/repo/blob/main/convert.js", "mine.js"); got != "mine.js" {
+		t.Errorf("TargetFilename with an explicit filename = %q; expected mine.js", got)
+	}
+}
+
 func TestConvertToRawURL(t *testing.T) {
 	downloader, err := NewWorkflowDownloader()
 	if err != nil {
@@ -169,3 +243,437 @@ func TestPathBasedDomainValidation(t *testing.T) {
 		}
 	}
 }
+
+func TestDownloadSources(t *testing.T) {
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+
+	rawURL := "https://raw.githubusercontent.com/user/repo/main/workflows/test.js"
+	mirrorURL := "https://toolchains.mirror.toulan.fun/user/repo/latest/test.js"
+
+	testCases := []struct {
+		name         string
+		url          string
+		preferMirror bool
+		noMirror     bool
+		expected     []string
+	}{
+		{"Non-GitHub URL", "https://example.com/test.js", true, false, []string{"https://example.com/test.js"}},
+		{"GitHub URL without mirror preference", rawURL, false, false, []string{rawURL, mirrorURL}},
+		{"GitHub URL with mirror preference", rawURL, true, false, []string{mirrorURL, rawURL}},
+		{"GitHub URL with mirror disabled", rawURL, true, true, []string{rawURL}},
+		{"Invalid URL", "://invalid", true, false, []string{"://invalid"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			downloader.SetPreferMirror(tc.preferMirror)
+			downloader.SetNoMirror(tc.noMirror)
+			if got := downloader.downloadSources(tc.url); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("downloadSources(%q) = %v; expected %v", tc.url, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDownloadSourcesSkipsBlockedMirror(t *testing.T) {
+	originalAllowed := AllowedDomains
+	defer func() {
+		AllowedDomains = originalAllowed
+	}()
+	AllowedDomains = []string{"github.com", "raw.githubusercontent.com"}
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+	downloader.SetPreferMirror(true)
+
+	rawURL := "https://raw.githubusercontent.com/user/repo/main/workflows/test.js"
+	if got := downloader.downloadSources(rawURL); !reflect.DeepEqual(got, []string{rawURL}) {
+		t.Errorf("downloadSources(%q) = %v; expected only the original URL", rawURL, got)
+	}
+}
+
+func TestPlanDownload(t *testing.T) {
+	originalAllowed := AllowedDomains
+	defer func() {
+		AllowedDomains = originalAllowed
+	}()
+	AllowedDomains = []string{"github.com/user", "raw.githubusercontent.com", "toolchains.mirror.toulan.fun"}
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+	downloader.SetPreferMirror(false)
+
+	blobURL := "https://github.com/user/repo/blob/main/workflows/test.js"
+	rawURL := "https://raw.githubusercontent.com/user/repo/main/workflows/test.js"
+	mirrorURL := "https://toolchains.mirror.toulan.fun/user/repo/latest/test.js"
+
+	plan, err := downloader.PlanDownload(blobURL)
+	if err != nil {
+		t.Fatalf("PlanDownload failed: %v", err)
+	}
+	if plan.SourceErr != nil || plan.MatchedSource != "github.com/user" {
+		t.Errorf("MatchedSource = %q, %v; expected github.com/user", plan.MatchedSource, plan.SourceErr)
+	}
+	if plan.RawURL != rawURL || plan.MirrorURL != mirrorURL || !plan.MirrorAllowed {
+		t.Errorf("RawURL = %q, MirrorURL = %q (allowed %v); expected %q and allowed %q", plan.RawURL, plan.MirrorURL, plan.MirrorAllowed, rawURL, mirrorURL)
+	}
+	if !reflect.DeepEqual(plan.Sources, []string{rawURL, mirrorURL}) {
+		t.Errorf("Sources = %v; expected raw URL then mirror", plan.Sources)
+	}
+
+	plan, err = downloader.PlanDownload("https://github.com/other/repo/blob/main/test.js")
+	if err != nil {
+		t.Fatalf("PlanDownload failed: %v", err)
+	}
+	if !errors.Is(plan.SourceErr, network.ErrURLNotAllowed) || plan.MatchedSource != "" {
+		t.Errorf("SourceErr = %v, MatchedSource = %q; expected the URL to be blocked", plan.SourceErr, plan.MatchedSource)
+	}
+	if plan.RawURL != "https://raw.githubusercontent.com/other/repo/main/test.js" {
+		t.Errorf("RawURL = %q; expected it to be converted even when blocked", plan.RawURL)
+	}
+}
+
+func TestDownloadFromSourcesFallsThrough(t *testing.T) {
+	const content = "//!amo\nconsole.log('ok');\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail.js":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case "/ok.js":
+			if r.Header.Get("Range") != "" {
+				t.Errorf("Expected partial file to be discarded before switching sources, got Range %q", r.Header.Get("Range"))
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	// httptest servers listen on loopback, which the network guard blocks by default
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "test.js.download")
+	if err := os.WriteFile(outputPath, []byte("stale"), 0644); err != nil {
+		t.Fatalf("Failed to write partial file: %v", err)
+	}
+
+	sources := []string{server.URL + "/fail.js", server.URL + "/ok.js"}
+	if err := downloader.downloadFromSources(sources, outputPath); err != nil {
+		t.Fatalf("downloadFromSources failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("Downloaded content = %q; expected %q", string(data), content)
+	}
+
+	if err := downloader.downloadFromSources(sources[:1], outputPath); err == nil {
+		t.Errorf("Expected an error when every source fails")
+	}
+}
+
+func TestSaveWorkflowRejectsOversizedScript(t *testing.T) {
+	script := "//!amo\n" + strings.Repeat("// padding\n", 200)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(script))
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	// httptest servers listen on loopback, which the network guard blocks by default
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+	targetDir := t.TempDir()
+	sources := []string{server.URL + "/big.js"}
+
+	downloader.SetMaxScriptSize(1024)
+	err = downloader.saveWorkflow(sources, sources[0], targetDir, "big.js")
+	if !errors.Is(err, ErrScriptTooLarge) {
+		t.Fatalf("saveWorkflow error = %v; expected ErrScriptTooLarge", err)
+	}
+	if entries, _ := os.ReadDir(targetDir); len(entries) != 0 {
+		t.Errorf("Oversized download left %d file(s) behind", len(entries))
+	}
+
+	downloader.SetMaxScriptSize(0)
+	if err := downloader.saveWorkflow(sources, sources[0], targetDir, "big.js"); err != nil {
+		t.Errorf("saveWorkflow without a limit failed: %v", err)
+	}
+}
+
+func TestNormalizeSourceEntry(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"github.com", "github.com", false},
+		{"  GitHub.com  ", "github.com", false},
+		{"https://github.com", "github.com", false},
+		{"https://github.com/", "github.com", false},
+		{"http://gitlab.com/owner/repo//", "gitlab.com/owner/repo", false},
+		{"github.com/owner", "github.com/owner", false},
+		{"192.168.1.10/workflows", "192.168.1.10/workflows", false},
+		{"", "", true},
+		{"github .com", "", true},
+		{"github.com/owner?tab=repos", "", true},
+		{"github.com/#readme", "", true},
+		{"github", "", true},
+		{"https://", "", true},
+		{"github.com:443", "", true},
+		{"user@github.com", "", true},
+		{"-bad-.com", "", true},
+		{"github.com/a//b", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := NormalizeSourceEntry(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("NormalizeSourceEntry(%q) = %q; expected an error", tc.input, got)
+				}
+				return
+			}
+			if err != nil || got != tc.expected {
+				t.Errorf("NormalizeSourceEntry(%q) = %q, %v; expected %q", tc.input, got, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestAddAllowedSourceNormalizes(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	wd, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("NewWorkflowDownloader failed: %v", err)
+	}
+
+	if added, err := wd.AddAllowedSource("https://Example.com/team/"); err != nil || !added {
+		t.Fatalf("AddAllowedSource = %v, %v; expected it to be added", added, err)
+	}
+	if added, err := wd.AddAllowedSource("example.com/team"); err != nil || added {
+		t.Errorf("AddAllowedSource of the normalized duplicate = %v, %v; expected no change", added, err)
+	}
+	if _, err := wd.AddAllowedSource("https://example.com/?q=1"); err == nil {
+		t.Error("Expected AddAllowedSource to reject a query string")
+	}
+
+	entries, err := wd.ListAllowedSources()
+	if err != nil {
+		t.Fatalf("ListAllowedSources failed: %v", err)
+	}
+	found := false
+	for _, e := range entries {
+		if e == "example.com/team" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected example.com/team in %v", entries)
+	}
+
+	if removed, err := wd.RemoveAllowedSource("http://example.com/team/"); err != nil || !removed {
+		t.Errorf("RemoveAllowedSource = %v, %v; expected the normalized entry to be removed", removed, err)
+	}
+}
+
+func TestSaveWorkflowIsAtomic(t *testing.T) {
+	const valid = "//!amo\nconsole.log('new');\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/valid.js":
+			if _, err := w.Write([]byte(valid)); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		case "/invalid.js":
+			if _, err := w.Write([]byte("<html>not a workflow</html>")); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	// httptest servers listen on loopback, which the network guard blocks by default
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+
+	workflowsDir := t.TempDir()
+	workflowPath := filepath.Join(workflowsDir, "wf.js")
+	const existing = "//!amo\nconsole.log('old');\n"
+	if err := os.WriteFile(workflowPath, []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to write existing workflow: %v", err)
+	}
+
+	assertOnlyWorkflow := func(expected string) {
+		t.Helper()
+		data, err := os.ReadFile(workflowPath)
+		if err != nil {
+			t.Fatalf("Failed to read workflow: %v", err)
+		}
+		if string(data) != expected {
+			t.Errorf("Workflow content = %q; expected %q", string(data), expected)
+		}
+		entries, err := os.ReadDir(workflowsDir)
+		if err != nil {
+			t.Fatalf("Failed to list workflows dir: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("Expected only wf.js in the workflows dir, found %d entries (temp file left behind?)", len(entries))
+		}
+	}
+
+	// An invalid download must not replace the existing workflow
+	rawURL := server.URL + "/invalid.js"
+	if err := downloader.saveWorkflow([]string{rawURL}, rawURL, workflowsDir, "wf.js"); err == nil {
+		t.Fatal("Expected an error for a download without the //!amo header")
+	}
+	assertOnlyWorkflow(existing)
+
+	// A valid download replaces it via the temp file
+	rawURL = server.URL + "/valid.js"
+	if err := downloader.saveWorkflow([]string{rawURL}, rawURL, workflowsDir, "wf.js"); err != nil {
+		t.Fatalf("saveWorkflow failed: %v", err)
+	}
+	assertOnlyWorkflow(valid)
+}
+
+func TestFetchCatalog(t *testing.T) {
+	originalAllowed := AllowedDomains
+	defer func() {
+		AllowedDomains = originalAllowed
+	}()
+	AllowedDomains = []string{"127.0.0.1"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body string
+		switch r.URL.Path {
+		case "/catalog.json":
+			body = `{"workflows": [
+				{"name": "Organizer", "description": "Sort files", "url": "https://github.com/u/r/blob/main/organizer.js"},
+				{"url": "https://github.com/u/r/blob/main/video-to-audio.js"},
+				{"name": "No URL"}
+			]}`
+		case "/array.json":
+			body = `[{"name": "Only", "url": "https://example.com/only.js", "filename": "custom.js"}]`
+		case "/broken.json":
+			body = `{not json`
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	configDir := filepath.Join(home, ".amo")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "allowed_hosts.txt"), []byte("127.0.0.1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write allowed hosts: %v", err)
+	}
+	// httptest servers listen on loopback, which the network guard blocks by default
+	t.Setenv("AMO_NET_ALLOW_PRIVATE", "true")
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+
+	entries, err := downloader.FetchCatalog(server.URL + "/catalog.json")
+	if err != nil {
+		t.Fatalf("FetchCatalog failed: %v", err)
+	}
+	expected := []CatalogEntry{
+		{Name: "Organizer", Description: "Sort files", URL: "https://github.com/u/r/blob/main/organizer.js"},
+		{Name: "video-to-audio", URL: "https://github.com/u/r/blob/main/video-to-audio.js"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("FetchCatalog = %+v; expected %+v", entries, expected)
+	}
+
+	entries, err = downloader.FetchCatalog(server.URL + "/array.json")
+	if err != nil {
+		t.Fatalf("FetchCatalog (array) failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Filename != "custom.js" {
+		t.Errorf("FetchCatalog (array) = %+v; expected one entry with filename custom.js", entries)
+	}
+
+	for _, path := range []string{"/broken.json", "/missing.json"} {
+		if _, err := downloader.FetchCatalog(server.URL + path); err == nil {
+			t.Errorf("Expected error for %s", path)
+		}
+	}
+
+	AllowedDomains = []string{"github.com"}
+	if _, err := downloader.FetchCatalog(server.URL + "/catalog.json"); err == nil {
+		t.Error("Expected error for a catalog outside the allowed sources")
+	}
+}
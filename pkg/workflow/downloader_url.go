@@ -6,16 +6,25 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"amo/pkg/network"
 )
 
 func (wd *WorkflowDownloader) IsValidURL(urlStr string) error {
+	_, err := wd.MatchSource(urlStr)
+	return err
+}
+
+// MatchSource returns the allowed source entry (domain or domain/path) that
+// lets workflows be downloaded from urlStr, or why none does
+func (wd *WorkflowDownloader) MatchSource(urlStr string) (string, error) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return fmt.Errorf("invalid URL format: %w", err)
+		return "", fmt.Errorf("invalid URL format: %w", err)
 	}
 
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return fmt.Errorf("only HTTP and HTTPS URLs are allowed")
+		return "", fmt.Errorf("only HTTP and HTTPS URLs are allowed")
 	}
 
 	hostname := strings.ToLower(parsedURL.Hostname())
@@ -64,17 +73,17 @@ func (wd *WorkflowDownloader) IsValidURL(urlStr string) error {
 
 		if hostnameMatches {
 			if pathPart == "" {
-				return nil
+				return allowedEntry, nil
 			} else {
 				if strings.HasPrefix(urlPath, pathPart) &&
 					(len(urlPath) == len(pathPart) || urlPath[len(pathPart)] == '/' || pathPart[len(pathPart)-1] == '/') {
-					return nil
+					return allowedEntry, nil
 				}
 			}
 		}
 	}
 
-	return fmt.Errorf("URL with domain %s and path %s is not in the allowed list", hostname, urlPath)
+	return "", fmt.Errorf("%w: domain %s, path %s", network.ErrURLNotAllowed, hostname, urlPath)
 }
 
 func (wd *WorkflowDownloader) ConvertToRawURL(urlStr string) (string, error) {
@@ -120,6 +129,10 @@ func (wd *WorkflowDownloader) ExtractFilename(urlStr string) (string, error) {
 	if !strings.HasSuffix(strings.ToLower(filename), ".js") {
 		filename += ".js"
 	}
+	if wd.namePrefix != "" || wd.nameSuffix != "" {
+		stem, ext := filename[:len(filename)-3], filename[len(filename)-3:]
+		filename = wd.namePrefix + stem + wd.nameSuffix + ext
+	}
 
 	filename = wd.sanitizeFilename(filename)
 
@@ -3,8 +3,11 @@ package workflow
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"amo/pkg/filesystem"
@@ -23,11 +26,50 @@ type AssetReader interface {
 type Engine struct {
 	vm               *goja.Runtime
 	vars             map[string]string
+	varObjects       map[string]interface{}
+	args             []string
 	context          context.Context
 	filesystem       *filesystem.FileSystem
 	assetReader      AssetReader
 	network          *network.NetworkClient
 	toolPathProvider ToolPathProvider
+	procs            chan struct{}
+	envAllowlist     []string
+	sandboxRoot      string
+	fsRoots          []string
+	fsRootsSet       bool
+	extraWriteRoots  []string
+	writeRoots       []string
+	restrictReads    bool
+	// protectedPaths and configDir are filled in by sandboxRoots for isProtectedPath
+	protectedPaths []string
+	configDir      string
+	// region, when set, is what getRegion() returns instead of detecting it
+	region string
+	// stdout and stderr receive console.log and console.error/warn output
+	stdout io.Writer
+	stderr io.Writer
+	// stepper confirms each cliCommand and http call in step mode
+	stepper func(action string) bool
+	// customAPIs are host-provided functions, registered after the built-ins
+	customAPIs []customAPI
+	// scriptOrigin is where the running workflow was read from, "" for
+	// source text; require() resolves modules against its directory
+	scriptOrigin string
+	moduleRoot   string
+	modules      map[string]*goja.Object
+	// maxScriptSize caps loaded workflows; resolved from the config on first
+	// use unless SetMaxScriptSize was called
+	maxScriptSize    int64
+	maxScriptSizeSet bool
+}
+
+// customAPI is a value added with RegisterFunction; an empty namespace makes
+// it a global
+type customAPI struct {
+	namespace string
+	name      string
+	value     interface{}
 }
 
 func NewEngine(ctx context.Context) *Engine {
@@ -40,6 +82,9 @@ func NewEngine(ctx context.Context) *Engine {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize network client: %v\n", err)
 		networkClient = nil
+	} else {
+		// Requests end with the run, e.g. on a workflow timeout
+		networkClient = networkClient.WithContext(ctx)
 	}
 
 	engine := &Engine{
@@ -49,11 +94,47 @@ func NewEngine(ctx context.Context) *Engine {
 		filesystem:  fs,
 		assetReader: nil,
 		network:     networkClient,
+		procs:       make(chan struct{}, DefaultMaxProcs()),
+		stdout:      os.Stdout,
+		stderr:      os.Stderr,
 	}
 
 	return engine
 }
 
+// DefaultMaxProcs returns the default cap on concurrent cliCommand subprocesses
+func DefaultMaxProcs() int {
+	return runtime.NumCPU()
+}
+
+// SetMaxProcs caps how many cliCommand subprocesses may run at the same time.
+// Values below 1 restore the default. Call before RunWorkflow.
+func (e *Engine) SetMaxProcs(n int) {
+	if n < 1 {
+		n = DefaultMaxProcs()
+	}
+	e.procs = make(chan struct{}, n)
+}
+
+// acquireProc blocks until a subprocess slot is free or the workflow context is done
+func (e *Engine) acquireProc() error {
+	ctx := e.context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case e.procs <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseProc frees a slot taken by acquireProc
+func (e *Engine) releaseProc() {
+	<-e.procs
+}
+
 func (e *Engine) SetAssetReader(reader AssetReader) {
 	e.assetReader = reader
 }
@@ -62,7 +143,73 @@ func (e *Engine) SetVars(vars map[string]string) {
 	e.vars = vars
 }
 
+// SetVarObjects sets structured variables (e.g. from --var-json) returned
+// by getVarObject. getVar still only sees the string values set by SetVars.
+func (e *Engine) SetVarObjects(vars map[string]interface{}) {
+	e.varObjects = vars
+}
+
+// SetOutput sends console.log to stdout and console.error/warn to stderr,
+// e.g. to also write them to amo run --capture's file. Nil keeps the current
+// writer. Interactive cliCommand calls still use the terminal directly.
+func (e *Engine) SetOutput(stdout, stderr io.Writer) {
+	if stdout != nil {
+		e.stdout = stdout
+	}
+	if stderr != nil {
+		e.stderr = stderr
+	}
+}
+
+// AddAllowedHosts lets this engine's network requests reach hosts beyond
+// the network whitelist, e.g. from amo run --allow-host
+func (e *Engine) AddAllowedHosts(hosts []string) {
+	if e.network != nil {
+		e.network.AddAllowedHosts(hosts...)
+	}
+}
+
+// SetRegion fixes the region getRegion() reports for this engine, e.g. from
+// amo run --region. An empty region restores detection.
+func (e *Engine) SetRegion(region string) {
+	e.region = strings.ToLower(strings.TrimSpace(region))
+}
+
+// SetArgs sets the positional arguments returned by getArgs
+func (e *Engine) SetArgs(args []string) {
+	e.args = args
+}
+
 func (e *Engine) RunWorkflow(scriptPath string) error {
+	found, err := e.FindWorkflow(scriptPath)
+	if err != nil {
+		return &WorkflowError{Stage: StageLoad, ScriptPath: scriptPath, Cause: err}
+	}
+	e.scriptOrigin = found.Origin
+	return e.runScript(found.Source, found.Name)
+}
+
+// RunWorkflowSource runs a workflow given as source text, e.g. piped to amo
+// run on stdin. name is used in error messages and stack traces. The //!amo
+// header is required as for workflow files; require() is not available
+// without a workflow directory.
+func (e *Engine) RunWorkflowSource(source, name string) error {
+	if err := checkScriptSize(name, int64(len(source)), e.scriptSizeLimit()); err != nil {
+		return &WorkflowError{Stage: StageLoad, ScriptPath: name, Cause: err}
+	}
+	e.scriptOrigin = ""
+	return e.runScript(source, name)
+}
+
+// maxCallStackSize caps JS call depth, so runaway recursion stops the
+// workflow with an error instead of growing the heap until the process dies
+const maxCallStackSize = 10000
+
+// runScript runs a loaded workflow in a fresh VM that is interrupted when the
+// engine context is cancelled. goja checks for interrupts between
+// instructions, so this also stops loops that never call a function, like
+// while (true) {}.
+func (e *Engine) runScript(script, scriptPath string) error {
 	baseCtx := e.context
 	if baseCtx == nil {
 		baseCtx = context.Background()
@@ -72,6 +219,7 @@ func (e *Engine) RunWorkflow(scriptPath string) error {
 	defer cancel()
 
 	vm := goja.New()
+	vm.SetMaxCallStackSize(maxCallStackSize)
 	e.vm = vm
 	e.registerAPIs()
 
@@ -84,31 +232,64 @@ func (e *Engine) RunWorkflow(scriptPath string) error {
 		}
 	}()
 
-	script, err := e.loadScript(scriptPath)
-	if err != nil {
-		if e.shouldTryJsExtension(scriptPath, err) {
-			altPath := scriptPath + ".js"
-			script, err = e.loadScript(altPath)
-			if err != nil {
-				close(done)
-				return err
-			}
-			scriptPath = altPath
-		} else {
-			close(done)
-			return err
-		}
-	}
-
-	err = e.executeScript(script, scriptPath)
+	err := e.executeScript(script, scriptPath)
 	close(done)
 	return err
 }
 
-func (e *Engine) loadScript(scriptPath string) (string, error) {
+// Kinds of location a workflow name can resolve to, in the order loadScript
+// tries them
+const (
+	SourcePath       = "path"
+	SourceConfigured = "configured"
+	SourceDownloaded = "downloaded"
+	SourceEmbedded   = "embedded"
+)
+
+// scriptLocation is a place findScriptLocations found a workflow
+type scriptLocation struct {
+	kind    string
+	origin  string
+	content string
+	err     error
+}
+
+// loadScript finds a workflow and returns its source and origin: the file it
+// was read from, or "embedded:<path>" for a built-in workflow. Scripts over
+// the size limit are refused.
+func (e *Engine) loadScript(scriptPath string) (string, string, error) {
+	locations := e.findScriptLocations(scriptPath, true)
+	if len(locations) == 0 {
+		return "", "", fmt.Errorf("script not found: %s", scriptPath)
+	}
+	found := locations[0]
+	if found.err != nil {
+		return "", found.origin, found.err
+	}
+	if err := checkScriptSize(found.origin, int64(len(found.content)), e.scriptSizeLimit()); err != nil {
+		return "", found.origin, err
+	}
+	return found.content, found.origin, nil
+}
+
+// findScriptLocations looks scriptPath up in priority order and returns every
+// location that has it, or only the first with firstOnly
+func (e *Engine) findScriptLocations(scriptPath string, firstOnly bool) []scriptLocation {
+	var locations []scriptLocation
+	found := func(loc scriptLocation) bool {
+		locations = append(locations, loc)
+		return firstOnly
+	}
+
 	// First priority: Try direct path (absolute or relative)
 	if content, err := os.ReadFile(scriptPath); err == nil {
-		return string(content), nil
+		origin := scriptPath
+		if absPath, absErr := filepath.Abs(scriptPath); absErr == nil {
+			origin = absPath
+		}
+		if found(scriptLocation{kind: SourcePath, origin: origin, content: string(content)}) {
+			return locations
+		}
 	}
 
 	// Check if this is a path with subdirectories
@@ -117,45 +298,55 @@ func (e *Engine) loadScript(scriptPath string) (string, error) {
 	// For simple filenames without separators, try all locations
 	if !hasSubdirectory {
 		// Second priority: Try user's configured workflow directory
-		if configContent, err := e.tryConfiguredWorkflowPath(scriptPath); err == nil {
-			return configContent, nil
+		if configContent, path, err := e.tryConfiguredWorkflowPath(scriptPath); err == nil {
+			if found(scriptLocation{kind: SourceConfigured, origin: path, content: configContent}) {
+				return locations
+			}
 		}
 
 		// Third priority: Try default downloaded workflows directory
-		if userWorkflowContent, err := e.tryUserWorkflowPath(scriptPath); err == nil {
-			return userWorkflowContent, nil
+		if userWorkflowContent, path, err := e.tryUserWorkflowPath(scriptPath); err == nil {
+			if found(scriptLocation{kind: SourceDownloaded, origin: path, content: userWorkflowContent}) {
+				return locations
+			}
 		}
 
 		// Fourth priority: Try embedded assets
 		if e.assetReader != nil {
 			normalizedPath := filepath.ToSlash(scriptPath)
 			if e.shouldTryEmbeddedAsset(normalizedPath) && e.assetReader.Exists(scriptPath) {
-				return e.assetReader.ReadFileAsString(scriptPath)
+				content, err := e.assetReader.ReadFileAsString(scriptPath)
+				found(scriptLocation{kind: SourceEmbedded, origin: "embedded:" + normalizedPath, content: content, err: err})
 			}
 		}
 	} else {
 		// For paths with subdirectories, check in workflow directories
 
 		// Second priority: Try user's configured workflow directory with full subpath
-		if configContent, err := e.tryConfiguredWorkflowSubpath(scriptPath); err == nil {
-			return configContent, nil
+		if configContent, path, err := e.tryConfiguredWorkflowSubpath(scriptPath); err == nil {
+			if found(scriptLocation{kind: SourceConfigured, origin: path, content: configContent}) {
+				return locations
+			}
 		}
 
 		// Third priority: Try default downloaded workflows directory with full subpath
-		if userWorkflowContent, err := e.tryUserWorkflowSubpath(scriptPath); err == nil {
-			return userWorkflowContent, nil
+		if userWorkflowContent, path, err := e.tryUserWorkflowSubpath(scriptPath); err == nil {
+			if found(scriptLocation{kind: SourceDownloaded, origin: path, content: userWorkflowContent}) {
+				return locations
+			}
 		}
 
 		// Fourth priority: Try embedded assets with normalized path
 		if e.assetReader != nil {
 			normalizedPath := filepath.ToSlash(scriptPath)
 			if e.assetReader.Exists(normalizedPath) {
-				return e.assetReader.ReadFileAsString(normalizedPath)
+				content, err := e.assetReader.ReadFileAsString(normalizedPath)
+				found(scriptLocation{kind: SourceEmbedded, origin: "embedded:" + normalizedPath, content: content, err: err})
 			}
 		}
 	}
 
-	return "", fmt.Errorf("script not found: %s", scriptPath)
+	return locations
 }
 
 func (e *Engine) isScriptNotFoundError(err error) bool {
@@ -182,40 +373,38 @@ func (e *Engine) shouldTryJsExtension(scriptPath string, err error) bool {
 }
 
 // tryConfiguredWorkflowPath attempts to load script from the user's configured workflow directory
-func (e *Engine) tryConfiguredWorkflowPath(filename string) (string, error) {
-	// Import the config package dynamically to avoid circular import
-	configManager, err := createConfigManager()
+func (e *Engine) tryConfiguredWorkflowPath(filename string) (string, string, error) {
+	downloader, err := NewWorkflowDownloader()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Get configured workflows directory
-	workflowsDir := configManager.GetWorkflowsDir()
+	workflowsDir := downloader.GetConfiguredWorkflowsDir()
 	if workflowsDir == "" {
-		return "", fmt.Errorf("no configured workflow directory")
+		return "", "", fmt.Errorf("no configured workflow directory")
 	}
 
 	workflowPath := filepath.Join(workflowsDir, filename)
 
 	if content, err := os.ReadFile(workflowPath); err == nil {
-		return string(content), nil
+		return string(content), workflowPath, nil
 	}
 
-	return "", fmt.Errorf("script not found in configured workflow directory: %s", filename)
+	return "", "", fmt.Errorf("script not found in configured workflow directory: %s", filename)
 }
 
 // tryConfiguredWorkflowSubpath attempts to load script from subdirectories in the user's configured workflow directory
-func (e *Engine) tryConfiguredWorkflowSubpath(relPath string) (string, error) {
-	// Import the config package dynamically to avoid circular import
-	configManager, err := createConfigManager()
+func (e *Engine) tryConfiguredWorkflowSubpath(relPath string) (string, string, error) {
+	downloader, err := NewWorkflowDownloader()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Get configured workflows directory
-	workflowsDir := configManager.GetWorkflowsDir()
+	workflowsDir := downloader.GetConfiguredWorkflowsDir()
 	if workflowsDir == "" {
-		return "", fmt.Errorf("no configured workflow directory")
+		return "", "", fmt.Errorf("no configured workflow directory")
 	}
 
 	// Normalize the path to use OS-specific separators
@@ -223,64 +412,33 @@ func (e *Engine) tryConfiguredWorkflowSubpath(relPath string) (string, error) {
 	workflowPath := filepath.Join(workflowsDir, normalizedPath)
 
 	if content, err := os.ReadFile(workflowPath); err == nil {
-		return string(content), nil
-	}
-
-	return "", fmt.Errorf("script not found in configured workflow directory: %s", relPath)
-}
-
-// workflowDirProvider is a helper struct that provides workflow directories
-// while avoiding circular imports with the config package
-type workflowDirProvider struct {
-	downloader *WorkflowDownloader
-}
-
-// GetWorkflowsDir returns the configured workflow directory or falls back to default
-func (wp *workflowDirProvider) GetWorkflowsDir() string {
-	// First get the configured directory
-	configuredDir := wp.downloader.GetConfiguredWorkflowsDir()
-	if configuredDir != "" {
-		return configuredDir
+		return string(content), workflowPath, nil
 	}
 
-	// Fall back to default directory if no custom directory is configured
-	return wp.downloader.GetWorkflowsDir()
-}
-
-// createConfigManager creates a config manager instance without direct import
-// This avoids circular imports between workflow and config packages
-func createConfigManager() (interface{ GetWorkflowsDir() string }, error) {
-	// Since we can't directly import config package due to circular references,
-	// we'll create a stub that directly reads from the config file
-	downloader, err := NewWorkflowDownloader()
-	if err != nil {
-		return nil, err
-	}
-
-	return &workflowDirProvider{downloader: downloader}, nil
+	return "", "", fmt.Errorf("script not found in configured workflow directory: %s", relPath)
 }
 
 // tryUserWorkflowPath attempts to load script from user downloads workflows directory
-func (e *Engine) tryUserWorkflowPath(filename string) (string, error) {
+func (e *Engine) tryUserWorkflowPath(filename string) (string, string, error) {
 	downloader, err := NewWorkflowDownloader()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	userWorkflowPath := downloader.env.GetCrossPlatformUtils().JoinPath(downloader.GetWorkflowsDir(), filename)
 
 	if content, err := os.ReadFile(userWorkflowPath); err == nil {
-		return string(content), nil
+		return string(content), userWorkflowPath, nil
 	}
 
-	return "", fmt.Errorf("user workflow not found: %s", filename)
+	return "", "", fmt.Errorf("user workflow not found: %s", filename)
 }
 
 // tryUserWorkflowSubpath attempts to load script from subdirectories in the user downloads workflows directory
-func (e *Engine) tryUserWorkflowSubpath(relPath string) (string, error) {
+func (e *Engine) tryUserWorkflowSubpath(relPath string) (string, string, error) {
 	downloader, err := NewWorkflowDownloader()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Normalize the path to use OS-specific separators
@@ -288,10 +446,10 @@ func (e *Engine) tryUserWorkflowSubpath(relPath string) (string, error) {
 	userWorkflowPath := downloader.env.GetCrossPlatformUtils().JoinPath(downloader.GetWorkflowsDir(), normalizedPath)
 
 	if content, err := os.ReadFile(userWorkflowPath); err == nil {
-		return string(content), nil
+		return string(content), userWorkflowPath, nil
 	}
 
-	return "", fmt.Errorf("user workflow not found: %s", relPath)
+	return "", "", fmt.Errorf("user workflow not found: %s", relPath)
 }
 
 // shouldTryEmbeddedAsset determines if we should try loading from embedded assets
@@ -304,19 +462,22 @@ func (e *Engine) shouldTryEmbeddedAsset(path string) bool {
 // executeScript executes a workflow script
 func (e *Engine) executeScript(script, scriptPath string) error {
 	if !strings.HasPrefix(strings.TrimSpace(script), "//!amo") {
-		return fmt.Errorf("invalid amo workflow: %s (must start with //!amo)", scriptPath)
+		return &WorkflowError{
+			Stage:      StageValidate,
+			ScriptPath: scriptPath,
+			Cause:      fmt.Errorf("%s: %w", scriptPath, ErrInvalidWorkflow),
+		}
 	}
 
-	_, err := e.vm.RunString(script)
-	if err == nil {
-		return nil
+	program, err := goja.Compile(filepath.Base(scriptPath), script, false)
+	if err != nil {
+		return newScriptError(StageCompile, scriptPath, script, err)
 	}
 
-	if exception, ok := err.(*goja.Exception); ok {
-		return fmt.Errorf("execution failed for %s: %s", scriptPath, exception.String())
+	if _, err := e.vm.RunProgram(program); err != nil {
+		return newScriptError(StageRuntime, scriptPath, script, err)
 	}
-
-	return fmt.Errorf("execution failed for %s: %w", scriptPath, err)
+	return nil
 }
 
 // registerAPIs registers all JavaScript APIs
@@ -327,4 +488,49 @@ func (e *Engine) registerAPIs() {
 	e.registerNetworkAPI()
 	e.registerEncodingAPI()
 	e.registerClipboardAPI()
+	e.registerNotifyAPI()
+	e.registerEnvAPI()
+	e.registerTimeAPI()
+	e.registerRequireAPI()
+	e.registerCustomAPIs()
+	e.registerStepHooks()
+}
+
+// RegisterFunction makes fn available to workflows as namespace.name, or as
+// the global name when namespace is empty, e.g. for embedders adding their
+// own APIs. A namespace that is a built-in object such as fs is extended; a
+// later registration of the same name wins. Call before RunWorkflow.
+func (e *Engine) RegisterFunction(namespace, name string, fn interface{}) {
+	e.customAPIs = append(e.customAPIs, customAPI{namespace: namespace, name: name, value: fn})
+}
+
+// RegisterObject registers every entry of obj with RegisterFunction under
+// namespace, in name order
+func (e *Engine) RegisterObject(namespace string, obj map[string]interface{}) {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e.RegisterFunction(namespace, name, obj[name])
+	}
+}
+
+// registerCustomAPIs adds the RegisterFunction values to a fresh VM
+func (e *Engine) registerCustomAPIs() {
+	for _, api := range e.customAPIs {
+		if api.namespace == "" {
+			e.vm.Set(api.name, api.value)
+			continue
+		}
+		var target *goja.Object
+		if existing := e.vm.Get(api.namespace); existing != nil && !goja.IsUndefined(existing) && !goja.IsNull(existing) {
+			target = existing.ToObject(e.vm)
+		} else {
+			target = e.vm.NewObject()
+			e.vm.Set(api.namespace, target)
+		}
+		target.Set(api.name, api.value)
+	}
 }
@@ -0,0 +1,531 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"amo/pkg/config"
+)
+
+func TestRunWorkflowErrorStages(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	testCases := []struct {
+		name   string
+		script string
+		stage  string
+		line   int
+	}{
+		{"Missing header", "var a = 1;\n", StageValidate, 0},
+		{"Syntax error", "//!amo\nvar a = 1;\nvar b = ;\n", StageCompile, 3},
+		{"Runtime throw", "//!amo\nvar a = 1;\n\nthrow new Error(\"boom\");\n", StageRuntime, 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scriptPath := filepath.Join(t.TempDir(), "wf.js")
+			if err := os.WriteFile(scriptPath, []byte(tc.script), 0644); err != nil {
+				t.Fatalf("Failed to write script: %v", err)
+			}
+
+			err := NewEngine(context.Background()).RunWorkflow(scriptPath)
+			var wfErr *WorkflowError
+			if !errors.As(err, &wfErr) {
+				t.Fatalf("Expected *WorkflowError, got %T: %v", err, err)
+			}
+			if wfErr.Stage != tc.stage {
+				t.Errorf("Stage = %q; expected %q", wfErr.Stage, tc.stage)
+			}
+			if wfErr.Line != tc.line {
+				t.Errorf("Line = %d; expected %d (error: %v)", wfErr.Line, tc.line, err)
+			}
+			if wfErr.ScriptPath != scriptPath {
+				t.Errorf("ScriptPath = %q; expected %q", wfErr.ScriptPath, scriptPath)
+			}
+			if invalid := errors.Is(err, ErrInvalidWorkflow); invalid != (tc.stage == StageValidate) {
+				t.Errorf("errors.Is(err, ErrInvalidWorkflow) = %v for %v", invalid, err)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorLocation(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	dir := t.TempDir()
+	script := "//!amo\nvar a = 1;\nfunction check() {\n    undefinedFunction();\n}\ncheck();\n"
+	scriptPath := filepath.Join(dir, "wf.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	err := NewEngine(context.Background()).RunWorkflow(scriptPath)
+	var wfErr *WorkflowError
+	if !errors.As(err, &wfErr) {
+		t.Fatalf("Expected *WorkflowError, got %T: %v", err, err)
+	}
+	if wfErr.File != "wf.js" || wfErr.Line != 4 || wfErr.Column != 22 {
+		t.Errorf("Location = %s:%d:%d; expected the call at wf.js:4:22", wfErr.File, wfErr.Line, wfErr.Column)
+	}
+	message := err.Error()
+	for _, expected := range []string{
+		"wf.js:4:22: ReferenceError: undefinedFunction is not defined",
+		"  3 | function check() {\n> 4 |     undefinedFunction();\n    |                      ^\n  5 | }\n  6 | check();",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Error = %q; expected it to contain %q", message, expected)
+		}
+	}
+
+	// A module's own lines are not in the workflow script, so no context is shown
+	if err := os.WriteFile(filepath.Join(dir, "lib.js"), []byte("exports.fail = function () {\n  throw new Error('in module');\n};\n"), 0644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+	if err := os.WriteFile(scriptPath, []byte("//!amo\nrequire('./lib').fail();\n"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	err = NewEngine(context.Background()).RunWorkflow(scriptPath)
+	if !errors.As(err, &wfErr) || wfErr.File != "lib.js" || wfErr.Line != 2 || wfErr.Context != "" {
+		t.Errorf("Module error = %v; expected lib.js:2 without context", err)
+	}
+}
+
+func TestRunWorkflowFromConfiguredWorkflowsDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AMO_WORKFLOWS_DIR", "")
+	t.Chdir(t.TempDir())
+
+	// The workflows dir comes from an AMO_CONFIG file, not ~/.amo/config.yaml
+	workflowsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workflowsDir, "hello.js"), []byte("//!amo\nvar a = 1;\n"), 0644); err != nil {
+		t.Fatalf("Failed to write workflow: %v", err)
+	}
+	configFile := filepath.Join(t.TempDir(), "amo.yaml")
+	if err := os.WriteFile(configFile, []byte("workflows: "+workflowsDir+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv(config.EnvConfigFile, configFile)
+
+	found, err := NewEngine(context.Background()).FindWorkflow("hello.js")
+	if err != nil {
+		t.Fatalf("FindWorkflow failed: %v", err)
+	}
+	if found.Origin != filepath.Join(workflowsDir, "hello.js") {
+		t.Errorf("Origin = %q; expected the workflow in the configured directory", found.Origin)
+	}
+}
+
+func TestConfiguredWorkflowsDirFollowsActiveProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AMO_WORKFLOWS_DIR", "")
+	t.Setenv(config.EnvConfigFile, "")
+
+	configDir := filepath.Join(home, ".amo")
+	defaultDir := filepath.Join(home, "default-workflows")
+	workDir := filepath.Join(home, "work-workflows")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", configDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte("workflows: "+defaultDir+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write default config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.work.yaml"), []byte("workflows: "+workDir+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write profile config: %v", err)
+	}
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("NewWorkflowDownloader failed: %v", err)
+	}
+	if got := downloader.GetConfiguredWorkflowsDir(); got != defaultDir {
+		t.Errorf("Workflows dir = %q; expected %q for the default profile", got, defaultDir)
+	}
+	if err := config.SetActiveProfile(configDir, "work"); err != nil {
+		t.Fatalf("SetActiveProfile failed: %v", err)
+	}
+	if got := downloader.GetConfiguredWorkflowsDir(); got != workDir {
+		t.Errorf("Workflows dir = %q; expected %q for the work profile", got, workDir)
+	}
+}
+
+func TestRunWorkflowLoadError(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	err := NewEngine(context.Background()).RunWorkflow(filepath.Join(t.TempDir(), "missing", "wf.js"))
+	var wfErr *WorkflowError
+	if !errors.As(err, &wfErr) {
+		t.Fatalf("Expected *WorkflowError, got %T: %v", err, err)
+	}
+	if wfErr.Stage != StageLoad {
+		t.Errorf("Stage = %q; expected %q", wfErr.Stage, StageLoad)
+	}
+}
+
+func TestRunWorkflowSource(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	testCases := []struct {
+		name    string
+		source  string
+		invalid bool
+		runtime bool
+	}{
+		{"Valid source", "//!amo\nvar a = 1 + 1;\nif (a !== 2) { throw new Error(\"bad\"); }\n", false, false},
+		{"Missing header", "var a = 1;\n", true, false},
+		{"Runtime throw", "//!amo\nthrow new Error(\"boom\");\n", false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewEngine(context.Background()).RunWorkflowSource(tc.source, "stdin")
+			if !tc.invalid && !tc.runtime {
+				if err != nil {
+					t.Fatalf("RunWorkflowSource failed: %v", err)
+				}
+				return
+			}
+
+			var wfErr *WorkflowError
+			if !errors.As(err, &wfErr) {
+				t.Fatalf("Expected *WorkflowError, got %T: %v", err, err)
+			}
+			if wfErr.ScriptPath != "stdin" {
+				t.Errorf("ScriptPath = %q; expected %q", wfErr.ScriptPath, "stdin")
+			}
+			if invalid := errors.Is(err, ErrInvalidWorkflow); invalid != tc.invalid {
+				t.Errorf("errors.Is(err, ErrInvalidWorkflow) = %v for %v", invalid, err)
+			}
+		})
+	}
+}
+
+func TestGetVarObject(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	script := `//!amo
+var files = getVarObject("files");
+if (files.length !== 2 || files[1] !== "b.txt") throw new Error("files: " + files);
+var opts = getVarObject("opts");
+if (opts.depth !== 3 || opts.nested.enabled !== true) throw new Error("opts");
+if (getVar("files") !== '["a.txt","b.txt"]') throw new Error("getVar files: " + getVar("files"));
+if (getVarObject("name") !== "plain") throw new Error("string fallback");
+if (getVarObject("missing") !== undefined) throw new Error("missing should be undefined");
+`
+	scriptPath := filepath.Join(t.TempDir(), "wf.js")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetVars(map[string]string{"files": `["a.txt","b.txt"]`, "name": "plain"})
+	engine.SetVarObjects(map[string]interface{}{
+		"files": []interface{}{"a.txt", "b.txt"},
+		"opts":  map[string]interface{}{"depth": float64(3), "nested": map[string]interface{}{"enabled": true}},
+	})
+	if err := engine.RunWorkflow(scriptPath); err != nil {
+		t.Errorf("RunWorkflow failed: %v", err)
+	}
+}
+
+func TestSetRegion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	engine := NewEngine(context.Background())
+	engine.SetRegion(" CN ")
+	source := "//!amo\nif (getRegion() !== \"cn\") throw new Error(\"region: \" + getRegion());\n"
+	if err := engine.RunWorkflowSource(source, "region"); err != nil {
+		t.Errorf("RunWorkflowSource failed: %v", err)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	var stdout, stderr bytes.Buffer
+	engine := NewEngine(context.Background())
+	engine.SetOutput(&stdout, &stderr)
+	source := "//!amo\nconsole.log(\"done\", 3);\nconsole.error(\"failed\");\nconsole.warn(\"slow\");\n"
+	if err := engine.RunWorkflowSource(source, "output"); err != nil {
+		t.Fatalf("RunWorkflowSource failed: %v", err)
+	}
+	if got := stdout.String(); got != "done 3\n" {
+		t.Errorf("stdout = %q; expected %q", got, "done 3\n")
+	}
+	if got := stderr.String(); got != "failed\nWARNING: slow\n" {
+		t.Errorf("stderr = %q; expected %q", got, "failed\nWARNING: slow\n")
+	}
+}
+
+func TestSetStepper(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	var actions []string
+	var stdout bytes.Buffer
+	engine := NewEngine(context.Background())
+	engine.SetOutput(&stdout, nil)
+	engine.SetStepper(func(action string) bool {
+		actions = append(actions, action)
+		return len(actions) < 2
+	})
+
+	// The aborting prompt cannot be caught by the script
+	source := `//!amo
+var first = http.get("http://127.0.0.1:1/first", {"X-Id": 1});
+console.log("after get");
+try {
+	cliCommand("echo", ["second"]);
+} catch (e) {
+	console.log("caught");
+}
+console.log("not reached");
+`
+	err := engine.RunWorkflowSource(source, "step")
+	if !errors.Is(err, ErrStepAborted) {
+		t.Errorf("RunWorkflowSource error = %v; expected ErrStepAborted", err)
+	}
+	expected := []string{`http.get("http://127.0.0.1:1/first", {"X-Id":1})`, `cliCommand("echo", ["second"])`}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("Stepped actions = %q; expected %q", actions, expected)
+	}
+	if got := stdout.String(); got != "after get\n" {
+		t.Errorf("stdout = %q; expected only the output before the aborted call", got)
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	var probed []string
+	engine := NewEngine(context.Background())
+	engine.RegisterFunction("media", "probe", func(path string) map[string]interface{} {
+		probed = append(probed, path)
+		return map[string]interface{}{"duration": 12.5}
+	})
+	engine.RegisterFunction("", "hostVersion", func() string { return "1.2.3" })
+	engine.RegisterObject("fs", map[string]interface{}{
+		"shout": func(s string) string { return strings.ToUpper(s) },
+	})
+
+	source := `//!amo
+if (media.probe("clip.mp4").duration !== 12.5) throw new Error("media.probe");
+if (hostVersion() !== "1.2.3") throw new Error("hostVersion");
+if (fs.shout("hi") !== "HI") throw new Error("fs.shout");
+if (typeof fs.exists !== "function") throw new Error("built-in fs functions were replaced");
+`
+	if err := engine.RunWorkflowSource(source, "custom"); err != nil {
+		t.Fatalf("RunWorkflowSource failed: %v", err)
+	}
+	if !reflect.DeepEqual(probed, []string{"clip.mp4"}) {
+		t.Errorf("media.probe calls = %q; expected [clip.mp4]", probed)
+	}
+}
+
+func TestGetArgs(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "args.js")
+	script := `//!amo
+var args = getArgs();
+if (args.length !== 2 || args[0] !== "a b.wav" || args[1] !== "--flag") throw new Error("args: " + JSON.stringify(args));
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetArgs([]string{"a b.wav", "--flag"})
+	if err := engine.RunWorkflow(scriptPath); err != nil {
+		t.Errorf("Workflow with arguments failed: %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.js")
+	if err := os.WriteFile(empty, []byte("//!amo\nif (getArgs().length !== 0) throw new Error(\"expected no args\");\n"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	if err := NewEngine(context.Background()).RunWorkflow(empty); err != nil {
+		t.Errorf("Workflow without arguments failed: %v", err)
+	}
+}
+
+// fakeAssets is an AssetReader serving workflows from a map
+type fakeAssets map[string]string
+
+func (f fakeAssets) ReadFileAsString(path string) (string, error) {
+	if content, ok := f[path]; ok {
+		return content, nil
+	}
+	return "", os.ErrNotExist
+}
+
+func (f fakeAssets) Exists(path string) bool {
+	_, ok := f[path]
+	return ok
+}
+
+func (f fakeAssets) GetWorkflowFileNames() ([]string, error) {
+	var names []string
+	for name := range f {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func TestResolveWorkflowSource(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	workflowsDir := filepath.Join(home, ".amo", "workflows")
+	if err := os.MkdirAll(workflowsDir, 0755); err != nil {
+		t.Fatalf("Failed to create workflows dir: %v", err)
+	}
+	downloaded := filepath.Join(workflowsDir, "shared.js")
+	if err := os.WriteFile(downloaded, []byte("//!amo\n"), 0644); err != nil {
+		t.Fatalf("Failed to write workflow: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetAssetReader(fakeAssets{"shared.js": "//!amo\n", "builtin.js": "//!amo\n"})
+
+	testCases := []struct {
+		name  string
+		kinds []string
+	}{
+		{"shared.js", []string{SourceDownloaded, SourceEmbedded}},
+		{"shared", []string{SourceDownloaded, SourceEmbedded}},
+		{"builtin.js", []string{SourceEmbedded}},
+	}
+	for _, tc := range testCases {
+		locations, err := engine.ResolveWorkflowSource(tc.name)
+		if err != nil {
+			t.Errorf("ResolveWorkflowSource(%q) failed: %v", tc.name, err)
+			continue
+		}
+		var kinds []string
+		for _, loc := range locations {
+			kinds = append(kinds, loc.Kind)
+		}
+		if strings.Join(kinds, ",") != strings.Join(tc.kinds, ",") {
+			t.Errorf("ResolveWorkflowSource(%q) kinds = %v; expected %v", tc.name, kinds, tc.kinds)
+		}
+	}
+
+	if locations, _ := engine.ResolveWorkflowSource("shared.js"); len(locations) > 0 && locations[0].Origin != downloaded {
+		t.Errorf("First location = %q; expected the downloaded copy %q", locations[0].Origin, downloaded)
+	}
+	if _, err := engine.ResolveWorkflowSource("missing.js"); err == nil {
+		t.Errorf("Expected an error for a missing workflow")
+	}
+}
+
+func TestTimeoutInterruptsComputeLoops(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	testCases := []struct {
+		name   string
+		source string
+	}{
+		{"empty while", "while (true) {}"},
+		{"arithmetic for", "var x = 0; for (;;) { x = (x + 1) % 7; }"},
+		{"do while", "var s = ''; do { s = s.length > 100 ? '' : s + 'a'; } while (true);"},
+		{"nested loops", "for (var i = 0; ; i++) { for (var j = 0; j < 1000000; j++) {} }"},
+		{"caught exceptions", "while (true) { try { throw new Error('again'); } catch (e) {} }"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := NewEngine(ctx).RunWorkflowSource("//!amo\n"+tc.source+"\n", "loop")
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("RunWorkflowSource error = %v; expected the deadline to stop the loop", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Loop kept running %v after the deadline", elapsed)
+			}
+		})
+	}
+}
+
+func TestRunawayRecursionStopsWorkflow(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	source := `//!amo
+function down(n) { return down(n + 1); }
+try { down(0); } catch (e) {}
+`
+	err := NewEngine(context.Background()).RunWorkflowSource(source, "recursion.js")
+	var wfErr *WorkflowError
+	if !errors.As(err, &wfErr) || wfErr.Stage != StageRuntime {
+		t.Fatalf("RunWorkflowSource error = %v; expected a runtime WorkflowError", err)
+	}
+	if !strings.Contains(err.Error(), "maximum call stack size") || len(err.Error()) > 500 {
+		t.Errorf("Error = %q; expected a short stack overflow message", err.Error())
+	}
+	if wfErr.Line != 2 {
+		t.Errorf("Line = %d; expected the recursive call on line 2", wfErr.Line)
+	}
+}
+
+func TestMaxScriptSize(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	source := "//!amo\n" + strings.Repeat("// padding\n", 200)
+	scriptPath := filepath.Join(t.TempDir(), "big.js")
+	if err := os.WriteFile(scriptPath, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	engine := NewEngine(context.Background())
+	engine.SetMaxScriptSize(1024)
+	for name, err := range map[string]error{
+		"RunWorkflow":       engine.RunWorkflow(scriptPath),
+		"RunWorkflowSource": engine.RunWorkflowSource(source, "stdin"),
+	} {
+		var wfErr *WorkflowError
+		if !errors.As(err, &wfErr) || wfErr.Stage != StageLoad || !errors.Is(err, ErrScriptTooLarge) {
+			t.Errorf("%s error = %v; expected a load error wrapping ErrScriptTooLarge", name, err)
+		}
+	}
+
+	engine.SetMaxScriptSize(0)
+	if err := engine.RunWorkflow(scriptPath); err != nil {
+		t.Errorf("RunWorkflow without a limit failed: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Workflow failure stages reported by WorkflowError
+const (
+	StageLoad     = "load"
+	StageValidate = "validate"
+	StageCompile  = "compile"
+	StageRuntime  = "runtime"
+)
+
+// ErrInvalidWorkflow is wrapped by errors for scripts that are not amo
+// workflows because they do not start with the //!amo line
+var ErrInvalidWorkflow = errors.New("not a valid amo workflow (must start with //!amo)")
+
+// ErrScriptTooLarge is wrapped by errors for workflow scripts over the
+// workflow_max_script_size_kb limit
+var ErrScriptTooLarge = errors.New("workflow script is too large")
+
+// sourceContextLines is how many lines around an error its source context shows
+const sourceContextLines = 2
+
+// WorkflowError describes a workflow failure and the stage it happened in.
+// Line and Column are 1-based and zero when the location is unknown. File is
+// the script or module the location is in, and Context the source lines
+// around it when the workflow's own script is at fault.
+type WorkflowError struct {
+	Stage      string
+	ScriptPath string
+	File       string
+	Line       int
+	Column     int
+	Context    string
+	Cause      error
+}
+
+func (e *WorkflowError) Error() string {
+	switch e.Stage {
+	case StageCompile, StageRuntime:
+		text := describeCause(e.Cause)
+		if e.Line > 0 {
+			// Syntax errors name the position themselves; it leads the message instead
+			text = fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, syntaxPrefixPattern.ReplaceAllString(text, ""))
+		}
+		if e.Context != "" {
+			text = strings.TrimRight(text, "\n") + "\n" + strings.TrimRight(e.Context, "\n")
+		}
+		return fmt.Sprintf("execution failed for %s: %s", e.ScriptPath, text)
+	default:
+		return e.Cause.Error()
+	}
+}
+
+func (e *WorkflowError) Unwrap() error {
+	return e.Cause
+}
+
+var (
+	// syntaxPositionPattern matches the "wf.js: Line 12:5" part of goja parser errors
+	syntaxPositionPattern = regexp.MustCompile(`(?:(\S+): )?Line (\d+):(\d+)`)
+	// syntaxPrefixPattern matches the same part with the space after it, to drop it
+	syntaxPrefixPattern = regexp.MustCompile(`(?:\S+: )?Line \d+:\d+ `)
+	// stackPositionPattern matches the "wf.js:12:5(31)" part of a goja stack
+	// frame; native frames have no position and are skipped
+	stackPositionPattern = regexp.MustCompile(`([^\s()]+):(\d+):(\d+)\(\d+\)`)
+)
+
+// newScriptError wraps a compile or runtime error from goja, extracting the
+// source location when goja reports one. script is the workflow source, used
+// for the context lines when the location is in it rather than in a module.
+func newScriptError(stage, scriptPath, script string, err error) *WorkflowError {
+	wfErr := &WorkflowError{Stage: stage, ScriptPath: scriptPath, Cause: err}
+
+	pattern := stackPositionPattern
+	text := describeCause(err)
+	var syntaxErr *goja.CompilerSyntaxError
+	if errors.As(err, &syntaxErr) {
+		pattern = syntaxPositionPattern
+	}
+
+	if match := pattern.FindStringSubmatch(text); match != nil {
+		wfErr.File = match[1]
+		wfErr.Line, _ = strconv.Atoi(match[2])
+		wfErr.Column, _ = strconv.Atoi(match[3])
+	}
+	scriptName := filepath.Base(scriptPath)
+	if wfErr.File == "" {
+		wfErr.File = scriptName
+	}
+	if wfErr.Line > 0 && wfErr.File == scriptName {
+		wfErr.Context = sourceContext(script, wfErr.Line, wfErr.Column)
+	}
+	return wfErr
+}
+
+// sourceContext returns the lines around line, numbered, with a caret under
+// column of the line itself
+func sourceContext(script string, line, column int) string {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(script, "\r\n", "\n"), "\n"), "\n")
+	if line > len(lines) {
+		return ""
+	}
+	first := max(line-sourceContextLines, 1)
+	last := min(line+sourceContextLines, len(lines))
+	width := len(strconv.Itoa(last))
+
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		text := lines[n-1]
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, n, text)
+		if n == line && column > 0 {
+			// Keep tabs so the caret lines up with the text above it
+			runes := []rune(text)
+			pad := make([]rune, 0, column-1)
+			for i := 0; i < column-1 && i < len(runes); i++ {
+				if runes[i] == '\t' {
+					pad = append(pad, '\t')
+				} else {
+					pad = append(pad, ' ')
+				}
+			}
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", string(pad))
+		}
+	}
+	return b.String()
+}
+
+// describeCause returns the error text, including the JS stack for exceptions
+func describeCause(err error) string {
+	// The overflow carries no message and a stack maxCallStackSize frames
+	// deep, so only its innermost frame is shown
+	var overflow *goja.StackOverflowError
+	if errors.As(err, &overflow) {
+		text := fmt.Sprintf("maximum call stack size of %d exceeded, e.g. by runaway recursion", maxCallStackSize)
+		if frame, _, _ := strings.Cut(strings.TrimSpace(overflow.String()), "\n"); frame != "" {
+			text += " " + frame
+		}
+		return text
+	}
+
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return exception.String()
+	}
+	return err.Error()
+}
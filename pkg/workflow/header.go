@@ -0,0 +1,131 @@
+package workflow
+
+import (
+	"strings"
+)
+
+// WorkflowHeader is the metadata declared in the comment block that follows
+// the //!amo line of a workflow:
+//
+//	//!amo
+//	// @name Video to audio
+//	// @description Extract the audio track of every video in a folder
+//	// @requires ffmpeg
+//	// @var input Folder with the videos
+//	// @var format=mp3 Output format
+//	// @var? title Title tag for the output files
+//
+// Both // lines and a /* ... */ block are accepted. Without @description the
+// first plain comment line is used as the description. A variable is
+// required unless it has a default (name=value) or is declared with @var?.
+type WorkflowHeader struct {
+	Name        string        `json:"name,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Requires    []string      `json:"requires,omitempty"`
+	Vars        []WorkflowVar `json:"vars,omitempty"`
+}
+
+// WorkflowVar is a variable a workflow declares with @var or @var?
+type WorkflowVar struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Default is used when the variable is not passed; HasDefault tells an
+	// empty default (name=) from none
+	Default    string `json:"default,omitempty"`
+	HasDefault bool   `json:"has_default,omitempty"`
+	// Optional is set by @var?; the workflow handles the variable being unset
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Required reports whether the variable must be passed to run the workflow
+func (v WorkflowVar) Required() bool {
+	return !v.Optional && !v.HasDefault
+}
+
+// ParseWorkflowHeader reads the metadata header of a workflow script. Unknown
+// @tags are ignored; a script without the //!amo line is an error.
+func ParseWorkflowHeader(source string) (*WorkflowHeader, error) {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(source), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "//!amo") {
+		return nil, ErrInvalidWorkflow
+	}
+
+	header := &WorkflowHeader{}
+	var firstComment string
+	inBlock := false
+	started := false
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+
+		var text string
+		switch {
+		case inBlock:
+			if end := strings.Index(line, "*/"); end >= 0 {
+				line = line[:end]
+				inBlock = false
+			}
+			text = strings.TrimPrefix(line, "*")
+		case strings.HasPrefix(line, "//"):
+			text = strings.TrimPrefix(line, "//")
+		case strings.HasPrefix(line, "/*"):
+			line = strings.TrimLeft(strings.TrimPrefix(line, "/*"), "*")
+			if end := strings.Index(line, "*/"); end >= 0 {
+				line = line[:end]
+			} else {
+				inBlock = true
+			}
+			text = line
+		case line == "" && !started:
+			continue
+		default:
+			return finishHeader(header, firstComment), nil
+		}
+		started = true
+
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, "@") {
+			if firstComment == "" {
+				firstComment = text
+			}
+			continue
+		}
+
+		tag, value, _ := strings.Cut(text[1:], " ")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(tag) {
+		case "name":
+			header.Name = value
+		case "description":
+			if header.Description != "" {
+				header.Description += " "
+			}
+			header.Description += value
+		case "requires":
+			for _, tool := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				header.Requires = append(header.Requires, tool)
+			}
+		case "var", "var?":
+			spec, description, _ := strings.Cut(value, " ")
+			name, defaultValue, hasDefault := strings.Cut(spec, "=")
+			if name != "" {
+				header.Vars = append(header.Vars, WorkflowVar{
+					Name:        name,
+					Description: strings.TrimSpace(description),
+					Default:     defaultValue,
+					HasDefault:  hasDefault,
+					Optional:    strings.HasSuffix(tag, "?"),
+				})
+			}
+		}
+	}
+
+	return finishHeader(header, firstComment), nil
+}
+
+// finishHeader falls back to the first plain comment line for the description
+func finishHeader(header *WorkflowHeader, firstComment string) *WorkflowHeader {
+	if header.Description == "" {
+		header.Description = firstComment
+	}
+	return header
+}
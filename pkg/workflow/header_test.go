@@ -0,0 +1,66 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWorkflowHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    *WorkflowHeader
+		wantErr bool
+	}{
+		{
+			name: "line comments",
+			source: "//!amo\n// @name Video to audio\n// @description Extract the audio track\n" +
+				"// @requires ffmpeg, jq\n// @var input Folder with the videos\n// @var format\n\nconsole.log(1);\n// @var ignored\n",
+			want: &WorkflowHeader{
+				Name:        "Video to audio",
+				Description: "Extract the audio track",
+				Requires:    []string{"ffmpeg", "jq"},
+				Vars:        []WorkflowVar{{Name: "input", Description: "Folder with the videos"}, {Name: "format"}},
+			},
+		},
+		{
+			name:   "optional and defaulted vars",
+			source: "//!amo\n// @var input\n// @var format=mp3 Output format\n// @var suffix=\n// @var? title Title tag\n",
+			want: &WorkflowHeader{
+				Vars: []WorkflowVar{
+					{Name: "input"},
+					{Name: "format", Description: "Output format", Default: "mp3", HasDefault: true},
+					{Name: "suffix", HasDefault: true},
+					{Name: "title", Description: "Title tag", Optional: true},
+				},
+			},
+		},
+		{
+			name:   "block comment after a blank line",
+			source: "//!amo\r\n\r\n/**\r\n * Installer workflow\r\n * @requires curl\r\n */\r\nvar x = 1;\r\n",
+			want:   &WorkflowHeader{Description: "Installer workflow", Requires: []string{"curl"}},
+		},
+		{
+			name:   "no header",
+			source: "//!amo\nconsole.log(1);\n",
+			want:   &WorkflowHeader{},
+		},
+		{
+			name:    "not a workflow",
+			source:  "console.log(1);\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWorkflowHeader(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWorkflowHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWorkflowHeader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
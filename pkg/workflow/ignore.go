@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName is the file at the root of a workflows directory listing
+// paths to leave out of workflow listings
+const IgnoreFileName = ".amoignore"
+
+// IgnoreMatcher matches relative paths against .amoignore patterns. The
+// syntax is a subset of gitignore: blank lines and # comments are skipped,
+// * and ? match within one path segment, ** matches across segments, a
+// leading ! re-includes a path and a trailing / only matches directories.
+// Patterns without a / match the name at any depth. The last matching
+// pattern wins.
+type IgnoreMatcher struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// LoadIgnoreFile reads the .amoignore file in dir. A missing file gives a
+// matcher that ignores nothing.
+func LoadIgnoreFile(dir string) (*IgnoreMatcher, error) {
+	file, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if os.IsNotExist(err) {
+		return &IgnoreMatcher{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFileName, err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFileName, err)
+	}
+	return ParseIgnorePatterns(lines), nil
+}
+
+// ParseIgnorePatterns builds a matcher from .amoignore lines
+func ParseIgnorePatterns(lines []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Only patterns with a / in them are relative to the directory root
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		expr := globToRegexp(line)
+		if !anchored {
+			expr = "(?:.*/)?" + expr
+		}
+		p.re = regexp.MustCompile("^" + expr + "$")
+		m.patterns = append(m.patterns, p)
+	}
+	return m
+}
+
+// globToRegexp translates the *, ** and ? wildcards of a pattern. It works
+// on runes, so ? matches one character of a non-ASCII name.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		rest := string(runes[i:])
+		switch c := runes[i]; {
+		case c == '*' && strings.HasPrefix(rest, "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(rest, "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Ignored reports whether relPath, relative to the directory holding the
+// .amoignore file, is excluded. A path inside an ignored directory is
+// ignored too.
+func (m *IgnoreMatcher) Ignored(relPath string, isDir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+	relPath = strings.Trim(filepath.ToSlash(relPath), "/")
+	for parent := path.Dir(relPath); parent != "."; parent = path.Dir(parent) {
+		if m.match(parent, true) {
+			return true
+		}
+	}
+	return m.match(relPath, isDir)
+}
+
+// match applies the patterns to a single path, the last match winning
+func (m *IgnoreMatcher) match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,94 @@
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	matcher := ParseIgnorePatterns([]string{
+		"# editor and download leftovers",
+		"*~",
+		"*.download",
+		"",
+		"drafts/",
+		"/scratch.js",
+		"**/tmp/**",
+		"lib/**/*.test.js",
+		"old-*.js",
+		"!old-keep.js",
+		"草稿-?.js",
+		"vidéo*.tmp",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"convert.js", false, false},
+		{"convert.js~", false, true},
+		{"nested/convert.js~", false, true},
+		{"video.js.download", false, true},
+		{"drafts", true, true},
+		{"drafts/wip.js", false, true},
+		{"drafts", false, false},
+		{"scratch.js", false, true},
+		{"nested/scratch.js", false, false},
+		{"a/tmp/b/c.js", false, true},
+		{"tmp/c.js", false, true},
+		{"lib/x.test.js", false, true},
+		{"lib/a/b/x.test.js", false, true},
+		{"lib/x.js", false, false},
+		{"old-convert.js", false, true},
+		{"old-keep.js", false, false},
+		{"sub/old-keep.js", false, false},
+		{"草稿-一.js", false, true},
+		{"草稿-一二.js", false, false},
+		{"vidéo-clip.tmp", false, true},
+		{"video-clip.tmp", false, false},
+	}
+	for _, tt := range tests {
+		if got := matcher.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestListUserWorkflowsHonorsIgnoreFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AMO_WORKFLOWS_DIR", "")
+
+	dir := filepath.Join(home, ".amo", "workflows")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create workflows dir: %v", err)
+	}
+	files := map[string]string{
+		"convert.js":     "//!amo\n",
+		"backup.js":      "//!amo\n",
+		"draft-a.js":     "//!amo\n",
+		IgnoreFileName:   "backup.js\ndraft-*.js\n",
+		"convert.js.bak": "//!amo\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	downloader, err := NewWorkflowDownloader()
+	if err != nil {
+		t.Fatalf("Failed to create workflow downloader: %v", err)
+	}
+	got, err := downloader.ListUserWorkflows()
+	if err != nil {
+		t.Fatalf("ListUserWorkflows failed: %v", err)
+	}
+	if want := []string{"convert.js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListUserWorkflows = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+package workflow
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// Lint finding severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Lint rule identifiers
+const (
+	RuleSyntax          = "syntax"
+	RuleMissingHeader   = "missing-header"
+	RuleUnguardedRemove = "unguarded-remove"
+	RuleCommandNotAllow = "command-not-allowed"
+	RuleUnhandledResult = "unhandled-result"
+	RuleAbsolutePath    = "absolute-path"
+)
+
+// LintFinding is a single problem reported by LintScript.
+// Line and Column are 1-based; both are zero for file-level findings.
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Message  string `json:"message"`
+}
+
+// resultFunctions return a {success, error} envelope that should be checked
+var resultFunctions = map[string]bool{
+	"fs.write": true, "fs.writeFile": true, "fs.append": true, "fs.appendFile": true, "fs.writeJSON": true,
+	"fs.copy": true, "fs.move": true, "fs.rename": true, "fs.mkdir": true,
+	"fs.remove": true, "fs.delete": true, "fs.rm": true,
+	"fs.zip": true, "fs.unzip": true, "fs.extractZip": true, "fs.targz": true, "fs.untargz": true,
+	"clipboard.write": true, "notify.webhook": true,
+}
+
+var removeFunctions = map[string]bool{"fs.remove": true, "fs.delete": true, "fs.rm": true}
+
+var existenceChecks = map[string]bool{"fs.exists": true, "fs.isFile": true, "fs.isDir": true}
+
+// absolutePathPattern matches string literals that look like machine-specific paths
+var absolutePathPattern = regexp.MustCompile(`^(/(Users|home|root|tmp|var|opt|usr|etc|mnt|Volumes)/|[A-Za-z]:[\\/])`)
+
+// LintScript checks a workflow script for common mistakes. allowedCommands is the
+// CLI whitelist used to check cliCommand calls; pass nil to skip that rule.
+func LintScript(name, src string, allowedCommands []string) []LintFinding {
+	var findings []LintFinding
+
+	if !strings.HasPrefix(strings.TrimSpace(src), "//!amo") {
+		findings = append(findings, LintFinding{
+			Rule:     RuleMissingHeader,
+			Severity: SeverityError,
+			Message:  "workflow must start with //!amo",
+		})
+	}
+
+	program, err := parser.ParseFile(nil, name, src, 0)
+	if err != nil {
+		finding := LintFinding{Rule: RuleSyntax, Severity: SeverityError, Message: err.Error()}
+		var list parser.ErrorList
+		if el, ok := err.(parser.ErrorList); ok {
+			list = el
+		}
+		if len(list) > 0 {
+			finding.Line = list[0].Position.Line
+			finding.Column = list[0].Position.Column
+			finding.Message = list[0].Message
+		}
+		return append(findings, finding)
+	}
+
+	l := &linter{program: program, src: src}
+	if allowedCommands != nil {
+		l.allowed = make(map[string]bool, len(allowedCommands))
+		for _, c := range allowedCommands {
+			l.allowed[c] = true
+		}
+	}
+	l.walk(reflect.ValueOf(program), make(map[ast.Node]bool))
+	findings = append(findings, l.findings...)
+	findings = append(findings, l.unguardedRemoves()...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings
+}
+
+type pathCall struct {
+	idx  int
+	path string
+}
+
+type linter struct {
+	program  *ast.Program
+	src      string
+	allowed  map[string]bool
+	findings []LintFinding
+	checks   []pathCall
+	removes  []pathCall
+	cliCalls []CLICommandCall
+}
+
+var astPkgPath = reflect.TypeOf(ast.Program{}).PkgPath()
+
+// walk visits every AST node reachable from v. The goja AST has no visitor,
+// so fields are traversed by reflection; seen guards against shared nodes.
+func (l *linter) walk(v reflect.Value, seen map[ast.Node]bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if node, ok := v.Interface().(ast.Node); ok {
+			if seen[node] {
+				return
+			}
+			seen[node] = true
+			l.visit(node)
+		}
+		l.walk(v.Elem(), seen)
+	case reflect.Interface:
+		if !v.IsNil() {
+			l.walk(v.Elem(), seen)
+		}
+	case reflect.Struct:
+		if v.Type().PkgPath() != astPkgPath {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				l.walk(v.Field(i), seen)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			l.walk(v.Index(i), seen)
+		}
+	}
+}
+
+func (l *linter) visit(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.ExpressionStatement:
+		if call, ok := n.Expression.(*ast.CallExpression); ok {
+			if name := calleeName(call); resultFunctions[name] {
+				l.report(call, RuleUnhandledResult, SeverityWarning,
+					name+"() result is ignored; check .success before continuing")
+			}
+		}
+
+	case *ast.CallExpression:
+		name := calleeName(n)
+		switch {
+		case existenceChecks[name] && len(n.ArgumentList) > 0:
+			l.checks = append(l.checks, pathCall{int(n.Idx0()), l.source(n.ArgumentList[0])})
+		case removeFunctions[name] && len(n.ArgumentList) > 0:
+			l.removes = append(l.removes, pathCall{int(n.Idx0()), l.source(n.ArgumentList[0])})
+		case name == "cliCommand" && len(n.ArgumentList) > 0:
+			command := ""
+			if lit, ok := n.ArgumentList[0].(*ast.StringLiteral); ok {
+				command = filepath.Base(lit.Value.String())
+			}
+			pos := l.program.File.Position(int(n.Idx0()) - l.program.File.Base())
+			l.cliCalls = append(l.cliCalls, CLICommandCall{
+				Command: command,
+				Line:    pos.Line,
+				Column:  pos.Column,
+				Allowed: command != "" && l.allowed[command],
+			})
+			if command != "" && l.allowed != nil && !l.allowed[command] {
+				l.report(n, RuleCommandNotAllow, SeverityWarning,
+					"command '"+command+"' is not in the allowed CLI commands list")
+			}
+		}
+
+	case *ast.StringLiteral:
+		if absolutePathPattern.MatchString(n.Value.String()) {
+			l.report(n, RuleAbsolutePath, SeverityInfo,
+				"hard-coded absolute path "+n.Literal+"; prefer getVar() or fs.join()")
+		}
+	}
+}
+
+// unguardedRemoves reports removals of paths that were not checked with
+// fs.exists/isFile/isDir earlier in the script
+func (l *linter) unguardedRemoves() []LintFinding {
+	var findings []LintFinding
+	for _, r := range l.removes {
+		guarded := false
+		for _, c := range l.checks {
+			if c.idx < r.idx && c.path == r.path {
+				guarded = true
+				break
+			}
+		}
+		if !guarded {
+			pos := l.program.File.Position(r.idx - l.program.File.Base())
+			findings = append(findings, LintFinding{
+				Rule:     RuleUnguardedRemove,
+				Severity: SeverityWarning,
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Message:  "removing " + r.path + " without checking fs.exists() first",
+			})
+		}
+	}
+	return findings
+}
+
+func (l *linter) report(node ast.Node, rule, severity, message string) {
+	pos := l.program.File.Position(int(node.Idx0()) - l.program.File.Base())
+	l.findings = append(l.findings, LintFinding{
+		Rule:     rule,
+		Severity: severity,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Message:  message,
+	})
+}
+
+// source returns the script text of a node
+func (l *linter) source(node ast.Node) string {
+	base := l.program.File.Base()
+	start, end := int(node.Idx0())-base, int(node.Idx1())-base
+	if start < 0 || end > len(l.src) || start > end {
+		return ""
+	}
+	return l.src[start:end]
+}
+
+// calleeName returns "cliCommand" or "fs.remove" style names for simple calls
+func calleeName(call *ast.CallExpression) string {
+	switch callee := call.Callee.(type) {
+	case *ast.Identifier:
+		return callee.Name.String()
+	case *ast.DotExpression:
+		if obj, ok := callee.Left.(*ast.Identifier); ok {
+			return obj.Name.String() + "." + callee.Identifier.Name.String()
+		}
+	}
+	return ""
+}
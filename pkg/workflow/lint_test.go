@@ -0,0 +1,116 @@
+package workflow
+
+import (
+	"testing"
+)
+
+func countRule(findings []LintFinding, rule string) int {
+	n := 0
+	for _, f := range findings {
+		if f.Rule == rule {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLintScriptRules(t *testing.T) {
+	allowed := []string{"git", "echo"}
+
+	testCases := []struct {
+		name   string
+		script string
+		rule   string
+		count  int
+	}{
+		{"Missing header fires", "var a = 1;\n", RuleMissingHeader, 1},
+		{"Missing header passes", "//!amo\nvar a = 1;\n", RuleMissingHeader, 0},
+
+		{"Syntax error fires", "//!amo\nvar a = ;\n", RuleSyntax, 1},
+		{"Syntax error passes", "//!amo\nvar a = 1;\n", RuleSyntax, 0},
+
+		{"Unguarded remove fires", "//!amo\nvar r = fs.remove(p);\n", RuleUnguardedRemove, 1},
+		{"Unguarded remove checks same path", "//!amo\nif (fs.exists(q)) { var r = fs.remove(p); }\n", RuleUnguardedRemove, 1},
+		{"Check after remove fires", "//!amo\nvar r = fs.remove(p);\nfs.exists(p);\n", RuleUnguardedRemove, 1},
+		{"Guarded remove passes", "//!amo\nif (fs.exists(p)) { var r = fs.remove(p); }\n", RuleUnguardedRemove, 0},
+		{"isDir guard passes", "//!amo\nif (fs.isDir(dir)) { var r = fs.remove(dir); }\n", RuleUnguardedRemove, 0},
+
+		{"Command not allowed fires", "//!amo\nvar r = cliCommand(\"rm\", [\"-rf\", \"x\"]);\n", RuleCommandNotAllow, 1},
+		{"Command with path not allowed fires", "//!amo\nvar r = cliCommand(\"/bin/curl\", []);\n", RuleCommandNotAllow, 1},
+		{"Allowed command passes", "//!amo\nvar r = cliCommand(\"git\", [\"status\"]);\n", RuleCommandNotAllow, 0},
+		{"Dynamic command passes", "//!amo\nvar r = cliCommand(cmd, []);\n", RuleCommandNotAllow, 0},
+
+		{"Unhandled result fires", "//!amo\nfs.write(\"out.txt\", \"x\");\n", RuleUnhandledResult, 1},
+		{"Unhandled result in block fires", "//!amo\nfunction f() { fs.copy(a, b); }\n", RuleUnhandledResult, 1},
+		{"Assigned result passes", "//!amo\nvar r = fs.write(\"out.txt\", \"x\");\nif (!r.success) { console.error(r.error); }\n", RuleUnhandledResult, 0},
+		{"Non-envelope call passes", "//!amo\nconsole.log(\"hi\");\n", RuleUnhandledResult, 0},
+
+		{"Absolute unix path fires", "//!amo\nvar p = \"/Users/alice/Desktop\";\n", RuleAbsolutePath, 1},
+		{"Absolute windows path fires", "//!amo\nvar p = \"C:\\\\Users\\\\alice\";\n", RuleAbsolutePath, 1},
+		{"Relative path passes", "//!amo\nvar p = fs.join(getVar(\"dir\"), \"out.txt\");\n", RuleAbsolutePath, 0},
+		{"URL passes", "//!amo\nvar u = \"https://example.com/home/x\";\n", RuleAbsolutePath, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := LintScript("wf.js", tc.script, allowed)
+			if got := countRule(findings, tc.rule); got != tc.count {
+				t.Errorf("Rule %s fired %d time(s); expected %d (findings: %+v)", tc.rule, got, tc.count, findings)
+			}
+		})
+	}
+}
+
+func TestLintScriptSkipsCommandRuleWithoutWhitelist(t *testing.T) {
+	findings := LintScript("wf.js", "//!amo\nvar r = cliCommand(\"rm\", []);\n", nil)
+	if got := countRule(findings, RuleCommandNotAllow); got != 0 {
+		t.Errorf("Expected command rule to be skipped without a whitelist, got %d finding(s)", got)
+	}
+}
+
+func TestLintScriptFindingPosition(t *testing.T) {
+	findings := LintScript("wf.js", "//!amo\nvar a = 1;\n  fs.write(\"x\", \"y\");\n", nil)
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %+v", findings)
+	}
+	f := findings[0]
+	if f.Line != 3 || f.Column != 3 {
+		t.Errorf("Position = %d:%d; expected 3:3", f.Line, f.Column)
+	}
+	if f.Severity != SeverityWarning {
+		t.Errorf("Severity = %q; expected %q", f.Severity, SeverityWarning)
+	}
+}
+
+func TestFindCLICommands(t *testing.T) {
+	src := `//!amo
+cliCommand("echo", ["hi"]);
+function deploy() {
+  return cliCommand("/usr/local/bin/git", ["push"]);
+}
+var tool = getVar("tool");
+cliCommand(tool, []);
+`
+	calls, err := FindCLICommands("deploy.js", src, []string{"echo"})
+	if err != nil {
+		t.Fatalf("FindCLICommands failed: %v", err)
+	}
+
+	expected := []CLICommandCall{
+		{Command: "echo", Line: 2, Column: 1, Allowed: true},
+		{Command: "git", Line: 4, Column: 10, Allowed: false},
+		{Command: "", Line: 7, Column: 1, Allowed: false},
+	}
+	if len(calls) != len(expected) {
+		t.Fatalf("Found %d calls; expected %d: %+v", len(calls), len(expected), calls)
+	}
+	for i, call := range calls {
+		if call != expected[i] {
+			t.Errorf("Call %d = %+v; expected %+v", i, call, expected[i])
+		}
+	}
+
+	if _, err := FindCLICommands("broken.js", "//!amo\ncliCommand(", nil); err == nil {
+		t.Error("Expected a parse error")
+	}
+}
@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"fmt"
+
+	"amo/pkg/config"
+	"amo/pkg/filesystem"
+)
+
+// MaxScriptSize returns the workflow_max_script_size_kb limit in bytes, or 0
+// when it is turned off
+func MaxScriptSize() int64 {
+	kb := config.DefaultWorkflowMaxScriptSizeKB
+	if manager, err := config.NewManager(); err == nil {
+		kb = manager.GetInt(config.KeyWorkflowMaxScriptSizeKB)
+	}
+	if kb <= 0 {
+		return 0
+	}
+	return int64(kb) * 1024
+}
+
+// checkScriptSize fails with ErrScriptTooLarge when size is over a non-zero limit
+func checkScriptSize(name string, size, limit int64) error {
+	if limit > 0 && size > limit {
+		return fmt.Errorf("%s is %s, over the %s limit (%s): %w",
+			name, filesystem.FormatBytes(size), filesystem.FormatBytes(limit), config.KeyWorkflowMaxScriptSizeKB, ErrScriptTooLarge)
+	}
+	return nil
+}
+
+// SetMaxScriptSize caps the size in bytes of the workflows the engine loads;
+// 0 removes the limit. It defaults to workflow_max_script_size_kb.
+func (e *Engine) SetMaxScriptSize(limit int64) {
+	e.maxScriptSize = limit
+	e.maxScriptSizeSet = true
+}
+
+// scriptSizeLimit reads the configured limit on first use
+func (e *Engine) scriptSizeLimit() int64 {
+	if !e.maxScriptSizeSet {
+		e.SetMaxScriptSize(MaxScriptSize())
+	}
+	return e.maxScriptSize
+}
+
+// SetMaxScriptSize caps the size in bytes of downloaded workflows; 0 removes
+// the limit. It defaults to workflow_max_script_size_kb.
+func (wd *WorkflowDownloader) SetMaxScriptSize(limit int64) {
+	wd.maxScriptSize = limit
+	wd.maxScriptSizeSet = true
+}
+
+// scriptSizeLimit reads the configured limit on first use
+func (wd *WorkflowDownloader) scriptSizeLimit() int64 {
+	if !wd.maxScriptSizeSet {
+		wd.SetMaxScriptSize(MaxScriptSize())
+	}
+	return wd.maxScriptSize
+}
@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// CLICommandCall is a cliCommand call found in a workflow script. Command is
+// the base name of the command, or empty when it is not a string literal and
+// so cannot be checked without running the workflow.
+type CLICommandCall struct {
+	Command string `json:"command"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Allowed bool   `json:"allowed"`
+}
+
+// FindCLICommands lists the cliCommand calls in a workflow script and whether
+// each command is in allowedCommands, the CLI whitelist cliCommand enforces
+func FindCLICommands(name, src string, allowedCommands []string) ([]CLICommandCall, error) {
+	program, err := parser.ParseFile(nil, name, src, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	l := &linter{program: program, src: src, allowed: make(map[string]bool, len(allowedCommands))}
+	for _, c := range allowedCommands {
+		l.allowed[c] = true
+	}
+	l.walk(reflect.ValueOf(program), make(map[ast.Node]bool))
+	return l.cliCalls, nil
+}
+
+// LoadWorkflowSource finds a workflow the way RunWorkflow does (file path,
+// workflows directories, embedded assets, optional .js extension) and returns
+// its source and the name it was found under
+func (e *Engine) LoadWorkflowSource(scriptPath string) (string, string, error) {
+	found, err := e.FindWorkflow(scriptPath)
+	if err != nil {
+		return "", "", err
+	}
+	return found.Source, found.Name, nil
+}
+
+// WorkflowSource is a workflow located by FindWorkflow
+type WorkflowSource struct {
+	// Name is the name the workflow was found under, with .js added if needed
+	Name string
+	// Origin is the file the workflow was read from, or "embedded:<path>"
+	Origin string
+	Source string
+}
+
+// FindWorkflow locates a workflow like LoadWorkflowSource and also reports
+// where it was found
+func (e *Engine) FindWorkflow(scriptPath string) (*WorkflowSource, error) {
+	script, origin, err := e.loadScript(scriptPath)
+	if err != nil && e.shouldTryJsExtension(scriptPath, err) {
+		altPath := scriptPath + ".js"
+		if script, origin, altErr := e.loadScript(altPath); altErr == nil {
+			return &WorkflowSource{Name: altPath, Origin: origin, Source: script}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &WorkflowSource{Name: scriptPath, Origin: origin, Source: script}, nil
+}
+
+// WorkflowLocation is a place a workflow name resolves to
+type WorkflowLocation struct {
+	// Kind is SourcePath, SourceConfigured, SourceDownloaded or SourceEmbedded
+	Kind string `json:"kind"`
+	// Origin is the file path, or "embedded:<path>"
+	Origin string `json:"origin"`
+}
+
+// ResolveWorkflowSource lists every location holding the workflow name, in
+// the priority order RunWorkflow uses. The first location is the copy that
+// runs; the rest are shadowed by it. Like FindWorkflow, a name without an
+// extension is also looked up with .js.
+func (e *Engine) ResolveWorkflowSource(name string) ([]WorkflowLocation, error) {
+	found := e.findScriptLocations(name, false)
+	notFound := fmt.Errorf("script not found: %s", name)
+	if len(found) == 0 && e.shouldTryJsExtension(name, notFound) {
+		found = e.findScriptLocations(name+".js", false)
+	}
+	if len(found) == 0 {
+		return nil, notFound
+	}
+
+	// The configured directory may be the default one; report each file once
+	locations := make([]WorkflowLocation, 0, len(found))
+	seen := make(map[string]bool, len(found))
+	for _, loc := range found {
+		if seen[loc.origin] {
+			continue
+		}
+		seen[loc.origin] = true
+		locations = append(locations, WorkflowLocation{Kind: loc.kind, Origin: loc.origin})
+	}
+	return locations, nil
+}